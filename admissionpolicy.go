@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// admissionParamsDir _global 下保存 ValidatingAdmissionPolicyBinding 通过 paramRef 引用的参数对象的目录
+const admissionParamsDir = "admissionpolicyparams"
+
+// policyParamRef 一个 binding 通过 paramRef 引用的参数对象, 参数类型来自其 policy 的 spec.paramKind
+type policyParamRef struct {
+	binding   string
+	gvk       schema.GroupVersionKind
+	namespace string // 为空且参数类型为命名空间级时, 匹配所有命名空间 (按被校验对象的命名空间取参数)
+	name      string
+	selector  labels.Selector // name 为空时使用
+}
+
+// collectParamRefs 根据 policy 的 spec.paramKind 与 binding 的 spec.paramRef 列出需要备份的参数对象
+// policy 未声明 paramKind, 或 binding 引用的 policy 不在备份中时忽略
+func collectParamRefs(policies, bindings []map[string]interface{}) ([]policyParamRef, []string) {
+	paramKinds := make(map[string]schema.GroupVersionKind)
+	for _, p := range policies {
+		name, _, _ := unstructured.NestedString(p, "metadata", "name")
+		apiVersion, _, _ := unstructured.NestedString(p, "spec", "paramKind", "apiVersion")
+		kind, _, _ := unstructured.NestedString(p, "spec", "paramKind", "kind")
+		if kind == "" {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			continue
+		}
+		paramKinds[name] = gv.WithKind(kind)
+	}
+
+	var refs []policyParamRef
+	var problems []string
+	for _, b := range bindings {
+		bindingName, _, _ := unstructured.NestedString(b, "metadata", "name")
+		paramRef, found, _ := unstructured.NestedMap(b, "spec", "paramRef")
+		if !found {
+			continue
+		}
+		policyName, _, _ := unstructured.NestedString(b, "spec", "policyName")
+		gvk, ok := paramKinds[policyName]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: policy '%s' 不在备份中或未声明 paramKind", bindingName, policyName))
+			continue
+		}
+		ref := policyParamRef{binding: bindingName, gvk: gvk}
+		ref.name, _ = paramRef["name"].(string)
+		ref.namespace, _ = paramRef["namespace"].(string)
+		if ref.name == "" {
+			selectorMap, _ := paramRef["selector"].(map[string]interface{})
+			var ls metav1.LabelSelector
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(selectorMap, &ls); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: 无效的 paramRef.selector: %v", bindingName, err))
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(&ls)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: 无效的 paramRef.selector: %v", bindingName, err))
+				continue
+			}
+			ref.selector = selector
+		}
+		refs = append(refs, ref)
+	}
+	return refs, problems
+}
+
+// matches 判断参数对象是否被 paramRef 选中
+func (r policyParamRef) matches(obj *unstructured.Unstructured) bool {
+	if r.name != "" {
+		return obj.GetName() == r.name
+	}
+	return r.selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+// backupPolicyParams 获取 binding 引用的参数对象, 写入 _global/admissionpolicyparams/<kind>/, 多个 binding 引用同一对象时只写入一次
+func (b *Backupper) backupPolicyParams(refs []policyParamRef, writer *manifestWriter, partition *backupPartition, globalDir string, graph *dependencyGraph) {
+	if len(refs) == 0 {
+		return
+	}
+	if b.mapper == nil {
+		fmt.Fprintln(logOut, "  警告: 无法解析参数类型, 跳过 ValidatingAdmissionPolicy 参数对象")
+		return
+	}
+	fmt.Fprintf(logOut, "  资源: ValidatingAdmissionPolicy 参数 (%d 个 paramRef)\n", len(refs))
+	written := make(map[string]bool)
+	backupCount := 0
+	for _, ref := range refs {
+		if b.exceeded() {
+			break
+		}
+		mapping, err := b.mapper.RESTMapping(ref.gvk.GroupKind(), ref.gvk.Version)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "    错误: binding '%s' 的参数类型 %s 无法解析: %v\n", ref.binding, ref.gvk, err)
+			partition.skip(skipEntry{Reason: skipListFailed, Kind: ref.gvk.Kind, Detail: err.Error()})
+			continue
+		}
+		namespace := ref.namespace
+		if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			namespace = ""
+		}
+		if !b.canList(mapping.Resource, namespace, newOutputSection(false)) {
+			fmt.Fprintf(logOut, "    警告: 无权限读取 %s, 跳过\n", ref.gvk.Kind)
+			partition.skip(skipEntry{Reason: skipPermissionDenied, Kind: ref.gvk.Kind, Namespace: namespace})
+			continue
+		}
+		list, err := b.resourceClient(mapping.Resource).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "    错误: 获取 %s 失败: %v\n", ref.gvk.Kind, err)
+			partition.skip(skipEntry{Reason: skipListFailed, Kind: ref.gvk.Kind, Namespace: namespace, Detail: err.Error()})
+			continue
+		}
+		found := false
+		for i := range list.Items {
+			resource := &list.Items[i]
+			if !ref.matches(resource) {
+				continue
+			}
+			found = true
+			key := objectKey(resource.GetKind(), resource.GetNamespace(), resource.GetName())
+			if written[key] {
+				continue
+			}
+			written[key] = true
+
+			entry := newIndexEntry(resource)
+			obj, yamlData, err := renderResource("", resource, b.cleanOpts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "    错误: 序列化 '%s' 失败: %v\n", resource.GetName(), err)
+				partition.skip(skipEntry{Reason: skipRenderFailed, Kind: ref.gvk.Kind, Namespace: resource.GetNamespace(), Name: resource.GetName(), Detail: err.Error()})
+				continue
+			}
+			if !b.reserveBytes(len(yamlData)) {
+				msg := b.sizeExceededError(fmt.Sprintf("%s %s", ref.gvk.Kind, resource.GetName()))
+				fmt.Fprintf(os.Stderr, "    错误: %s\n", msg)
+				b.progress.Emit(progressEvent{Event: "backup_size_limit_failed", Kind: ref.gvk.Kind, Name: resource.GetName(), Error: msg})
+				partition.skip(skipEntry{Reason: skipSizeLimit, Kind: ref.gvk.Kind, Namespace: resource.GetNamespace(), Name: resource.GetName(), Detail: "该对象及之后的全部对象未备份"})
+				break
+			}
+			subDir := filepath.Join(admissionParamsDir, strings.ToLower(ref.gvk.Kind))
+			filename := resource.GetName() + ".yaml"
+			if ns := resource.GetNamespace(); ns != "" {
+				filename = ns + "_" + filename
+			}
+			fullPath, err := writer.write(subDir, filename, yamlData)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "    错误: 写入文件 '%s' 失败: %v\n", filepath.Join(globalDir, subDir, filename), err)
+				partition.skip(skipEntry{Reason: skipWriteFailed, Kind: ref.gvk.Kind, Namespace: resource.GetNamespace(), Name: resource.GetName(), Detail: err.Error()})
+				continue
+			}
+			backupCount++
+			partition.addEntry(entry.withFile(partition.Root, fullPath, yamlData), nil, nil)
+			graph.add(obj)
+			b.progress.Emit(progressEvent{Event: "resource_backed_up", Namespace: resource.GetNamespace(), Kind: ref.gvk.Kind, Name: resource.GetName(), Path: fullPath})
+		}
+		if !found {
+			fmt.Fprintf(logOut, "    警告: binding '%s' 引用的 %s 参数不存在\n", ref.binding, ref.gvk.Kind)
+		}
+	}
+	fmt.Fprintf(logOut, "    ✓ 备份 %d 个参数对象\n", backupCount)
+	b.addResources(backupCount)
+	partition.addTotal(backupCount)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestBackupPolicyParams(t *testing.T) {
+	run, partitions := newFakeBackupper(t,
+		[]string{"validatingadmissionpolicies", "validatingadmissionpolicybindings"},
+		nil,
+		fakeObject("admissionregistration.k8s.io/v1", "ValidatingAdmissionPolicy", "", "replica-limit", map[string]interface{}{
+			"spec": map[string]interface{}{"paramKind": map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap"}},
+		}),
+		fakeObject("admissionregistration.k8s.io/v1", "ValidatingAdmissionPolicyBinding", "", "replica-limit-prod", map[string]interface{}{
+			"spec": map[string]interface{}{
+				"policyName": "replica-limit",
+				"paramRef":   map[string]interface{}{"name": "limits", "namespace": "policy"},
+			},
+		}),
+		fakeObject("admissionregistration.k8s.io/v1", "ValidatingAdmissionPolicyBinding", "", "replica-limit-labeled", map[string]interface{}{
+			"spec": map[string]interface{}{
+				"policyName": "replica-limit",
+				"paramRef": map[string]interface{}{
+					"namespace": "policy",
+					"selector":  map[string]interface{}{"matchLabels": map[string]interface{}{"tier": "prod"}},
+				},
+			},
+		}),
+		fakeObject("admissionregistration.k8s.io/v1", "ValidatingAdmissionPolicyBinding", "", "orphan", map[string]interface{}{
+			"spec": map[string]interface{}{"policyName": "missing", "paramRef": map[string]interface{}{"name": "x"}},
+		}),
+		fakeObject("v1", "ConfigMap", "policy", "limits", map[string]interface{}{"data": map[string]interface{}{"max": "5"}}),
+		fakeObject("v1", "ConfigMap", "policy", "unrelated", nil),
+		labeledConfigMap("policy", "prod-limits", map[string]string{"tier": "prod"}),
+	)
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	run.mapper = mapper
+	run.backupClusterResources()
+
+	paramDir := filepath.Join(partitions.byName[""].Root, "_global", admissionParamsDir, "configmap")
+	if _, err := os.Stat(filepath.Join(paramDir, "policy_limits.yaml")); err != nil {
+		t.Errorf("缺少 paramRef 引用的参数对象: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(paramDir, "policy_unrelated.yaml")); err == nil {
+		t.Error("不应备份未被引用的 ConfigMap")
+	}
+	if _, err := os.Stat(filepath.Join(paramDir, "policy_prod-limits.yaml")); err != nil {
+		t.Errorf("缺少 paramRef.selector 选中的参数对象: %v", err)
+	}
+	if run.totalResources != 6 {
+		t.Errorf("备份资源数 = %d, 期望 6 (1 个 policy, 3 个 binding, 2 个参数)", run.totalResources)
+	}
+}
+
+func labeledConfigMap(namespace, name string, labels map[string]string) *unstructured.Unstructured {
+	obj := fakeObject("v1", "ConfigMap", namespace, name, nil)
+	obj.SetLabels(labels)
+	return obj
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// changedSince --since / --modified-after 指定的时间下限, 只备份在此之后创建或修改过的对象
+// 零值表示不筛选
+type changedSince struct {
+	after time.Time
+}
+
+// parseChangedSince 解析 --since (相对时长, 如 7d, 36h) 与 --modified-after (RFC3339 时间或 YYYY-MM-DD 日期), 两者只能指定一个
+func parseChangedSince(since, modifiedAfter string, now time.Time) (changedSince, error) {
+	if since != "" && modifiedAfter != "" {
+		return changedSince{}, fmt.Errorf("--since 与 --modified-after 不能同时使用")
+	}
+	if since != "" {
+		d, err := parseAge(since)
+		if err != nil {
+			return changedSince{}, fmt.Errorf("无效的 --since '%s': %w", since, err)
+		}
+		return changedSince{after: now.Add(-d)}, nil
+	}
+	if modifiedAfter != "" {
+		if t, err := time.Parse(time.RFC3339, modifiedAfter); err == nil {
+			return changedSince{after: t}, nil
+		}
+		t, err := time.ParseInLocation("2006-01-02", modifiedAfter, time.Local)
+		if err != nil {
+			return changedSince{}, fmt.Errorf("无效的 --modified-after '%s': 应为 RFC3339 时间 (如 2024-05-01T08:00:00Z) 或日期 (如 2024-05-01)", modifiedAfter)
+		}
+		return changedSince{after: t}, nil
+	}
+	return changedSince{}, nil
+}
+
+// parseAge 解析时长, 在 time.ParseDuration 的基础上支持以天为单位 (如 7d)
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("天数应为正整数")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("时长应大于 0")
+	}
+	return d, nil
+}
+
+func (c changedSince) enabled() bool {
+	return !c.after.IsZero()
+}
+
+// String 返回写入 metadata.yaml 的时间下限, 未启用时为空
+func (c changedSince) String() string {
+	if !c.enabled() {
+		return ""
+	}
+	return c.after.UTC().Format(time.RFC3339)
+}
+
+// includes 判断对象是否在时间下限之后创建或修改, 未启用筛选时总是返回 true
+func (c changedSince) includes(obj *unstructured.Unstructured) bool {
+	return !c.enabled() || lastChanged(obj).After(c.after)
+}
+
+// lastChanged 返回对象最近一次变化的时间: creationTimestamp 与 managedFields 中各字段管理者最后写入时间的最大值
+// 没有 managedFields 的对象 (如服务端关闭了该功能) 只能依据 creationTimestamp
+func lastChanged(obj *unstructured.Unstructured) time.Time {
+	latest := obj.GetCreationTimestamp().Time
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Time != nil && entry.Time.After(latest) {
+			latest = entry.Time.Time
+		}
+	}
+	return latest
+}
+
+// check 供 filterSkipped 使用, 未在时间下限之后变化的对象返回 unchanged 及其最后变化时间
+func (c changedSince) check(obj *unstructured.Unstructured) (string, string) {
+	if c.includes(obj) {
+		return "", ""
+	}
+	return skipUnchanged, "最后变化于 " + lastChanged(obj).UTC().Format(time.RFC3339)
+}
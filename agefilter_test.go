@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParseChangedSince(t *testing.T) {
+	now := time.Date(2024, 5, 8, 12, 0, 0, 0, time.UTC)
+	c, err := parseChangedSince("7d", "", now)
+	if err != nil || !c.after.Equal(now.AddDate(0, 0, -7)) {
+		t.Errorf("--since 7d = %v, %v", c.after, err)
+	}
+	c, err = parseChangedSince("", "2024-05-01T08:00:00Z", now)
+	if err != nil || c.String() != "2024-05-01T08:00:00Z" {
+		t.Errorf("--modified-after = %q, %v", c, err)
+	}
+	if c, err := parseChangedSince("", "", now); err != nil || c.enabled() {
+		t.Errorf("未指定时不应启用筛选: %v, %v", c, err)
+	}
+	for _, tc := range [][2]string{{"7d", "2024-05-01"}, {"0d", ""}, {"-1h", ""}, {"", "yesterday"}} {
+		if _, err := parseChangedSince(tc[0], tc[1], now); err == nil {
+			t.Errorf("parseChangedSince(%q, %q) 应返回错误", tc[0], tc[1])
+		}
+	}
+}
+
+func TestChangedSinceFilter(t *testing.T) {
+	cutoff := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	newObj := func(name string, created time.Time, managed ...time.Time) unstructured.Unstructured {
+		obj := fakeObject("v1", "ConfigMap", "web", name, nil)
+		obj.SetCreationTimestamp(metav1.NewTime(created))
+		var entries []metav1.ManagedFieldsEntry
+		for _, m := range managed {
+			mt := metav1.NewTime(m)
+			entries = append(entries, metav1.ManagedFieldsEntry{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply, Time: &mt})
+		}
+		obj.SetManagedFields(entries)
+		return *obj
+	}
+	items := []unstructured.Unstructured{
+		newObj("old", cutoff.AddDate(0, -1, 0)),
+		newObj("created", cutoff.Add(time.Hour)),
+		newObj("updated", cutoff.AddDate(0, -1, 0), cutoff.AddDate(0, 0, -3), cutoff.Add(2*time.Hour)),
+		newObj("stale", cutoff.AddDate(0, -1, 0), cutoff.AddDate(0, 0, -3)),
+	}
+	p := &backupPartition{}
+	kept, skipped := p.filterSkipped(items, changedSince{after: cutoff}.check)
+	if skipped != 2 || len(kept) != 2 || kept[0].GetName() != "created" || kept[1].GetName() != "updated" {
+		t.Errorf("保留 %d 个, 跳过 %d 个: %v", len(kept), skipped, kept)
+	}
+	if len(p.Skipped) != 2 || p.Skipped[0].Reason != skipUnchanged || p.Skipped[0].Name != "old" || p.Skipped[1].Name != "stale" {
+		t.Errorf("跳过记录 = %+v", p.Skipped)
+	}
+	if kept, skipped := p.filterSkipped(kept, (changedSince{}).check); len(kept) != 2 || skipped != 0 {
+		t.Errorf("未启用筛选时应保留全部对象")
+	}
+}
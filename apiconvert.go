@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// apiConversion 旧 apiVersion 到新 apiVersion 的机械转换, convert 为 nil 表示只需修改 apiVersion
+type apiConversion struct {
+	to      string
+	convert func(obj map[string]interface{})
+}
+
+// apiConversions 已从新版本 Kubernetes 中移除, 但存在安全的字段级转换的 apiVersion/Kind
+// 键为 "<旧 apiVersion>/<Kind>"; 语义发生变化或需要人工判断的类型 (如 v1beta1 CRD, webhook 配置) 不在此列
+var apiConversions = map[string]apiConversion{
+	"extensions/v1beta1/Ingress":                                      {to: "networking.k8s.io/v1", convert: convertIngressV1beta1},
+	"networking.k8s.io/v1beta1/Ingress":                               {to: "networking.k8s.io/v1", convert: convertIngressV1beta1},
+	"networking.k8s.io/v1beta1/IngressClass":                          {to: "networking.k8s.io/v1"},
+	"extensions/v1beta1/NetworkPolicy":                                {to: "networking.k8s.io/v1"},
+	"policy/v1beta1/PodDisruptionBudget":                              {to: "policy/v1"},
+	"batch/v1beta1/CronJob":                                           {to: "batch/v1"},
+	"autoscaling/v2beta2/HorizontalPodAutoscaler":                     {to: "autoscaling/v2"},
+	"autoscaling/v2beta1/HorizontalPodAutoscaler":                     {to: "autoscaling/v2", convert: convertHPAV2beta1},
+	"extensions/v1beta1/Deployment":                                   {to: "apps/v1", convert: ensureWorkloadSelector},
+	"apps/v1beta1/Deployment":                                         {to: "apps/v1", convert: ensureWorkloadSelector},
+	"apps/v1beta2/Deployment":                                         {to: "apps/v1", convert: ensureWorkloadSelector},
+	"apps/v1beta1/StatefulSet":                                        {to: "apps/v1", convert: ensureWorkloadSelector},
+	"apps/v1beta2/StatefulSet":                                        {to: "apps/v1", convert: ensureWorkloadSelector},
+	"extensions/v1beta1/DaemonSet":                                    {to: "apps/v1", convert: ensureWorkloadSelector},
+	"apps/v1beta2/DaemonSet":                                          {to: "apps/v1", convert: ensureWorkloadSelector},
+	"extensions/v1beta1/ReplicaSet":                                   {to: "apps/v1", convert: ensureWorkloadSelector},
+	"apps/v1beta2/ReplicaSet":                                         {to: "apps/v1", convert: ensureWorkloadSelector},
+	"rbac.authorization.k8s.io/v1beta1/Role":                          {to: "rbac.authorization.k8s.io/v1"},
+	"rbac.authorization.k8s.io/v1beta1/RoleBinding":                   {to: "rbac.authorization.k8s.io/v1"},
+	"rbac.authorization.k8s.io/v1beta1/ClusterRole":                   {to: "rbac.authorization.k8s.io/v1"},
+	"rbac.authorization.k8s.io/v1beta1/ClusterRoleBinding":            {to: "rbac.authorization.k8s.io/v1"},
+	"storage.k8s.io/v1beta1/StorageClass":                             {to: "storage.k8s.io/v1"},
+	"scheduling.k8s.io/v1beta1/PriorityClass":                         {to: "scheduling.k8s.io/v1"},
+	"coordination.k8s.io/v1beta1/Lease":                               {to: "coordination.k8s.io/v1"},
+	"discovery.k8s.io/v1beta1/EndpointSlice":                          {to: "discovery.k8s.io/v1"},
+	"events.k8s.io/v1beta1/Event":                                     {to: "events.k8s.io/v1"},
+	"node.k8s.io/v1beta1/RuntimeClass":                                {to: "node.k8s.io/v1"},
+	"certificates.k8s.io/v1beta1/CertificateSigningRequest":           {to: "certificates.k8s.io/v1"},
+	"flowcontrol.apiserver.k8s.io/v1beta3/FlowSchema":                 {to: "flowcontrol.apiserver.k8s.io/v1"},
+	"flowcontrol.apiserver.k8s.io/v1beta3/PriorityLevelConfiguration": {to: "flowcontrol.apiserver.k8s.io/v1"},
+
+	// 反向转换: 字段结构与 v2 相同, 用于将新集群的备份恢复到尚未提供 autoscaling/v2 的旧集群 (1.23 之前)
+	"autoscaling/v2/HorizontalPodAutoscaler": {to: "autoscaling/v2beta2"},
+}
+
+// rewriteAPIVersions 将目标集群不提供, 且存在安全转换的对象改写为新的 apiVersion
+// 只在新版本被目标集群提供时改写, 返回 "旧版本 Kind -> 新版本" 与对象数量的汇总
+func rewriteAPIVersions(mapper meta.RESTMapper, items []restoreItem) map[string]int {
+	rewritten := make(map[string]int)
+	for _, item := range items {
+		obj := item.Obj
+		gvk := obj.GroupVersionKind()
+		conv, ok := apiConversions[obj.GetAPIVersion()+"/"+gvk.Kind]
+		if !ok {
+			continue
+		}
+		if _, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+			continue
+		}
+		to, err := schema.ParseGroupVersion(conv.to)
+		if err != nil {
+			continue
+		}
+		if _, err := mapper.RESTMapping(schema.GroupKind{Group: to.Group, Kind: gvk.Kind}, to.Version); err != nil {
+			continue
+		}
+		if conv.convert != nil {
+			conv.convert(obj.Object)
+		}
+		rewritten[fmt.Sprintf("%s %s -> %s", obj.GetAPIVersion(), gvk.Kind, conv.to)]++
+		obj.SetAPIVersion(conv.to)
+	}
+	return rewritten
+}
+
+// convertIngressV1beta1 将 v1beta1 Ingress 的后端写法转换为 networking.k8s.io/v1:
+// spec.backend -> spec.defaultBackend, serviceName/servicePort -> service.name/port, 并为缺少 pathType 的路径补充 ImplementationSpecific
+func convertIngressV1beta1(obj map[string]interface{}) {
+	spec := nestedMapNoCopy(obj, "spec")
+	if spec == nil {
+		return
+	}
+	if backend, ok := spec["backend"].(map[string]interface{}); ok {
+		convertIngressBackend(backend)
+		spec["defaultBackend"] = backend
+		delete(spec, "backend")
+	}
+	rules, _ := spec["rules"].([]interface{})
+	for _, rule := range mapsOf(rules) {
+		paths, _, _ := unstructured.NestedSlice(rule, "http", "paths")
+		for _, p := range mapsOf(paths) {
+			if backend, ok := p["backend"].(map[string]interface{}); ok {
+				convertIngressBackend(backend)
+			}
+			if _, ok := p["pathType"]; !ok {
+				p["pathType"] = "ImplementationSpecific"
+			}
+		}
+		if len(paths) > 0 {
+			unstructured.SetNestedSlice(rule, paths, "http", "paths")
+		}
+	}
+}
+
+// convertIngressBackend 将 serviceName/servicePort 转换为 service.name 与 service.port.number 或 service.port.name
+func convertIngressBackend(backend map[string]interface{}) {
+	name, hasName := backend["serviceName"].(string)
+	if !hasName {
+		return
+	}
+	port := map[string]interface{}{}
+	switch p := backend["servicePort"].(type) {
+	case string:
+		port["name"] = p
+	case int, int64, float64:
+		port["number"] = p
+	}
+	delete(backend, "serviceName")
+	delete(backend, "servicePort")
+	backend["service"] = map[string]interface{}{"name": name, "port": port}
+}
+
+// ensureWorkloadSelector apps/v1 要求工作负载显式声明 selector, 旧版本缺省时使用 Pod 模板的标签
+func ensureWorkloadSelector(obj map[string]interface{}) {
+	if _, found, _ := unstructured.NestedFieldNoCopy(obj, "spec", "selector"); found {
+		return
+	}
+	labels, found, _ := unstructured.NestedStringMap(obj, "spec", "template", "metadata", "labels")
+	if !found || len(labels) == 0 {
+		return
+	}
+	matchLabels := make(map[string]interface{}, len(labels))
+	for k, v := range labels {
+		matchLabels[k] = v
+	}
+	unstructured.SetNestedMap(obj, map[string]interface{}{"matchLabels": matchLabels}, "spec", "selector")
+}
+
+// convertHPAV2beta1 将 v2beta1 HPA 的指标写法转换为 autoscaling/v2 (与 v2beta2 相同):
+// targetAverageUtilization/targetAverageValue/targetValue -> target, metricName/selector -> metric, object.target -> describedObject
+func convertHPAV2beta1(obj map[string]interface{}) {
+	metrics, _, _ := unstructured.NestedSlice(obj, "spec", "metrics")
+	if len(metrics) == 0 {
+		return
+	}
+	for _, m := range mapsOf(metrics) {
+		switch metricType, _ := m["type"].(string); metricType {
+		case "Resource":
+			convertHPAMetricSource(nestedMapNoCopy(m, "resource"), false)
+		case "ContainerResource":
+			convertHPAMetricSource(nestedMapNoCopy(m, "containerResource"), false)
+		case "Pods":
+			convertHPAMetricSource(nestedMapNoCopy(m, "pods"), true)
+		case "External":
+			source := nestedMapNoCopy(m, "external")
+			if selector, ok := source["metricSelector"]; ok {
+				source["selector"] = selector
+				delete(source, "metricSelector")
+			}
+			convertHPAMetricSource(source, true)
+		case "Object":
+			source := nestedMapNoCopy(m, "object")
+			if source == nil {
+				continue
+			}
+			// v2beta1 的 object.target 是被描述的对象, v2 中改名为 describedObject, target 改为指标目标值
+			if described, ok := source["target"].(map[string]interface{}); ok {
+				source["describedObject"] = described
+				delete(source, "target")
+			}
+			if average, ok := source["averageValue"]; ok {
+				source["targetAverageValue"] = average
+				delete(source, "averageValue")
+			}
+			convertHPAMetricSource(source, true)
+		}
+	}
+	unstructured.SetNestedSlice(obj, metrics, "spec", "metrics")
+}
+
+// convertHPAMetricSource 转换单个指标来源的目标值写法; named 为 true 时将 metricName 与 selector 移入 metric
+func convertHPAMetricSource(source map[string]interface{}, named bool) {
+	if source == nil {
+		return
+	}
+	target := map[string]interface{}{}
+	if v, ok := source["targetAverageUtilization"]; ok {
+		target["type"], target["averageUtilization"] = "Utilization", v
+	}
+	if v, ok := source["targetAverageValue"]; ok {
+		target["type"], target["averageValue"] = "AverageValue", v
+	}
+	if v, ok := source["targetValue"]; ok {
+		target["type"], target["value"] = "Value", v
+	}
+	delete(source, "targetAverageUtilization")
+	delete(source, "targetAverageValue")
+	delete(source, "targetValue")
+	if len(target) > 0 {
+		source["target"] = target
+	}
+	if !named {
+		return
+	}
+	if name, ok := source["metricName"]; ok {
+		metric := map[string]interface{}{"name": name}
+		if selector, ok := source["selector"]; ok {
+			metric["selector"] = selector
+			delete(source, "selector")
+		}
+		source["metric"] = metric
+		delete(source, "metricName")
+	}
+}
+
+// printAPIRewrites 输出改写汇总
+func printAPIRewrites(rewritten map[string]int) {
+	if len(rewritten) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(rewritten))
+	for k := range rewritten {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintln(logOut, "已改写目标集群不再提供的 apiVersion:")
+	for _, k := range keys {
+		fmt.Fprintf(logOut, "  - %s (%d 个对象)\n", k, rewritten[k])
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRewriteAPIVersions(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "networking.k8s.io", Version: "v1"}, {Group: "apps", Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1beta2", Kind: "DaemonSet"}, meta.RESTScopeNamespace)
+
+	ingress := fakeObject("extensions/v1beta1", "Ingress", "web", "site", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"backend": map[string]interface{}{"serviceName": "default", "servicePort": int64(80)},
+			"rules": []interface{}{map[string]interface{}{
+				"host": "example.com",
+				"http": map[string]interface{}{"paths": []interface{}{map[string]interface{}{
+					"path":    "/api",
+					"backend": map[string]interface{}{"serviceName": "api", "servicePort": "http"},
+				}}},
+			}},
+		},
+	})
+	deployment := fakeObject("apps/v1beta1", "Deployment", "web", "api", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "api"}}},
+		},
+	})
+	// 目标集群仍提供旧版本时不改写
+	daemonSet := fakeObject("apps/v1beta2", "DaemonSet", "web", "agent", nil)
+	// 新版本也不被提供时不改写, 交给版本检查报告
+	pdb := fakeObject("policy/v1beta1", "PodDisruptionBudget", "web", "api", nil)
+
+	items := []restoreItem{{Obj: ingress}, {Obj: deployment}, {Obj: daemonSet}, {Obj: pdb}}
+	rewritten := rewriteAPIVersions(mapper, items)
+	if len(rewritten) != 2 || rewritten["extensions/v1beta1 Ingress -> networking.k8s.io/v1"] != 1 {
+		t.Errorf("改写汇总 = %v", rewritten)
+	}
+
+	if ingress.GetAPIVersion() != "networking.k8s.io/v1" {
+		t.Errorf("Ingress apiVersion = %s", ingress.GetAPIVersion())
+	}
+	if port, _, _ := unstructured.NestedInt64(ingress.Object, "spec", "defaultBackend", "service", "port", "number"); port != 80 {
+		t.Errorf("defaultBackend 端口 = %d, 期望 80: %v", port, ingress.Object["spec"])
+	}
+	rules, _, _ := unstructured.NestedSlice(ingress.Object, "spec", "rules")
+	paths, _, _ := unstructured.NestedSlice(rules[0].(map[string]interface{}), "http", "paths")
+	path := paths[0].(map[string]interface{})
+	if path["pathType"] != "ImplementationSpecific" {
+		t.Errorf("pathType = %v", path["pathType"])
+	}
+	if name, _, _ := unstructured.NestedString(path, "backend", "service", "port", "name"); name != "http" {
+		t.Errorf("路径后端端口名 = %q: %v", name, path["backend"])
+	}
+
+	if deployment.GetAPIVersion() != "apps/v1" {
+		t.Errorf("Deployment apiVersion = %s", deployment.GetAPIVersion())
+	}
+	if app, _, _ := unstructured.NestedString(deployment.Object, "spec", "selector", "matchLabels", "app"); app != "api" {
+		t.Errorf("Deployment 应补充来自模板标签的 selector: %v", deployment.Object["spec"])
+	}
+	if daemonSet.GetAPIVersion() != "apps/v1beta2" || pdb.GetAPIVersion() != "policy/v1beta1" {
+		t.Errorf("不应改写 DaemonSet (%s) 与 PodDisruptionBudget (%s)", daemonSet.GetAPIVersion(), pdb.GetAPIVersion())
+	}
+}
+
+func TestRewriteHPAVersions(t *testing.T) {
+	hpa := func(apiVersion, name string, metrics ...interface{}) *unstructured.Unstructured {
+		return fakeObject(apiVersion, "HorizontalPodAutoscaler", "web", name, map[string]interface{}{
+			"spec": map[string]interface{}{"maxReplicas": int64(5), "metrics": metrics},
+		})
+	}
+	v2beta1 := hpa("autoscaling/v2beta1", "api",
+		map[string]interface{}{"type": "Resource", "resource": map[string]interface{}{"name": "cpu", "targetAverageUtilization": int64(70)}},
+		map[string]interface{}{"type": "Pods", "pods": map[string]interface{}{"metricName": "qps", "targetAverageValue": "100"}},
+		map[string]interface{}{"type": "Object", "object": map[string]interface{}{
+			"target":     map[string]interface{}{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress", "name": "site"},
+			"metricName": "requests", "targetValue": "2k",
+		}},
+		map[string]interface{}{"type": "External", "external": map[string]interface{}{
+			"metricName": "queue_length", "metricSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"queue": "jobs"}}, "targetAverageValue": "30",
+		}},
+	)
+
+	// 目标集群只提供 autoscaling/v2 时转换 v2beta1 的指标写法
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"}, meta.RESTScopeNamespace)
+	rewriteAPIVersions(mapper, []restoreItem{{Obj: v2beta1}})
+	if v2beta1.GetAPIVersion() != "autoscaling/v2" {
+		t.Fatalf("apiVersion = %s, 期望 autoscaling/v2", v2beta1.GetAPIVersion())
+	}
+	metrics, _, _ := unstructured.NestedSlice(v2beta1.Object, "spec", "metrics")
+	want := []map[string]interface{}{
+		{"type": "Resource", "resource": map[string]interface{}{"name": "cpu", "target": map[string]interface{}{"type": "Utilization", "averageUtilization": int64(70)}}},
+		{"type": "Pods", "pods": map[string]interface{}{"metric": map[string]interface{}{"name": "qps"}, "target": map[string]interface{}{"type": "AverageValue", "averageValue": "100"}}},
+		{"type": "Object", "object": map[string]interface{}{
+			"describedObject": map[string]interface{}{"apiVersion": "networking.k8s.io/v1", "kind": "Ingress", "name": "site"},
+			"metric":          map[string]interface{}{"name": "requests"},
+			"target":          map[string]interface{}{"type": "Value", "value": "2k"},
+		}},
+		{"type": "External", "external": map[string]interface{}{
+			"metric": map[string]interface{}{"name": "queue_length", "selector": map[string]interface{}{"matchLabels": map[string]interface{}{"queue": "jobs"}}},
+			"target": map[string]interface{}{"type": "AverageValue", "averageValue": "30"},
+		}},
+	}
+	for i, m := range mapsOf(metrics) {
+		if !jsonEqual(m, want[i]) {
+			t.Errorf("指标 %d = %v, 期望 %v", i, m, want[i])
+		}
+	}
+
+	// 旧集群只提供 autoscaling/v2beta2 时将 v2 改写为 v2beta2, 字段不变
+	mapper = meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "autoscaling", Version: "v2beta2", Kind: "HorizontalPodAutoscaler"}, meta.RESTScopeNamespace)
+	v2 := hpa("autoscaling/v2", "worker",
+		map[string]interface{}{"type": "Resource", "resource": map[string]interface{}{"name": "memory", "target": map[string]interface{}{"type": "Utilization", "averageUtilization": int64(80)}}})
+	before := v2.DeepCopy()
+	rewriteAPIVersions(mapper, []restoreItem{{Obj: v2}})
+	if v2.GetAPIVersion() != "autoscaling/v2beta2" || !jsonEqual(v2.Object["spec"], before.Object["spec"]) {
+		t.Errorf("v2 -> v2beta2 改写结果不正确: %v", v2.Object)
+	}
+}
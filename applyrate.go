@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// parseApplyRate 解析 --apply-rate (如 20/s, 600/m), 返回每秒对象数, 空字符串表示不限制
+func parseApplyRate(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	count, unit, ok := strings.Cut(s, "/")
+	if !ok {
+		unit = "s"
+	}
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("无效的 --apply-rate '%s': 应为正数加时间单位, 如 20/s 或 600/m", s)
+	}
+	switch unit {
+	case "s":
+		return n, nil
+	case "m":
+		return n / 60, nil
+	default:
+		return 0, fmt.Errorf("无效的 --apply-rate '%s': 时间单位只能是 s 或 m", s)
+	}
+}
+
+// applyThrottle 限制恢复时创建对象的速率, 并在每批对象之后暂停, 避免大量对象同时触发准入 webhook 与控制器
+type applyThrottle struct {
+	limiter    flowcontrol.RateLimiter // 为空表示不限速
+	batchSize  int                     // 0 表示不分批
+	batchPause time.Duration
+	applied    int
+}
+
+// newApplyThrottle 创建限速器, rate 为每秒对象数, 允许的突发量与一秒的配额相同
+func newApplyThrottle(rate float64, batchSize int, batchPause time.Duration) *applyThrottle {
+	t := &applyThrottle{batchSize: batchSize, batchPause: batchPause}
+	if rate > 0 {
+		t.limiter = flowcontrol.NewTokenBucketRateLimiter(float32(rate), int(math.Max(1, math.Ceil(rate))))
+	}
+	return t
+}
+
+// wait 在创建下一个对象前调用, 按速率等待; 每完成 batchSize 个对象暂停 batchPause
+func (t *applyThrottle) wait() {
+	if t.batchSize > 0 && t.applied > 0 && t.applied%t.batchSize == 0 && t.batchPause > 0 {
+		fmt.Fprintf(logOut, "  ... 已处理 %d 个对象, 暂停 %s\n", t.applied, t.batchPause)
+		time.Sleep(t.batchPause)
+	}
+	if t.limiter != nil {
+		t.limiter.Accept()
+	}
+	t.applied++
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseApplyRate(t *testing.T) {
+	cases := map[string]float64{"": 0, "20/s": 20, "20": 20, "600/m": 10, "0.5/s": 0.5}
+	for in, want := range cases {
+		if got, err := parseApplyRate(in); err != nil || got != want {
+			t.Errorf("parseApplyRate(%q) = %v, %v, 期望 %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"0/s", "-1/s", "fast", "20/h"} {
+		if _, err := parseApplyRate(in); err == nil {
+			t.Errorf("parseApplyRate(%q) 应报错", in)
+		}
+	}
+}
+
+func TestApplyThrottleRate(t *testing.T) {
+	throttle := newApplyThrottle(100, 0, 0)
+	start := time.Now()
+	for i := 0; i < 150; i++ {
+		throttle.wait()
+	}
+	// 突发 100 个之后, 剩余 50 个按 100/s 约需 0.5s
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("150 个对象耗时 %s, 未按 100/s 限速", elapsed)
+	}
+}
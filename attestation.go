@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// --attestation 生成的 in-toto Statement, 位于备份根目录, 本身不计入 subject
+// Statement 未签名, 可使用 cosign attest-blob 等工具签名后与镜像走同一套供应链校验
+const (
+	attestationFileName = "attestation.intoto.json"
+
+	inTotoStatementType = "https://in-toto.io/Statement/v1"
+	slsaProvenanceType  = "https://slsa.dev/provenance/v1"
+	backupBuildType     = "https://k8s-back.io/backup/v1"
+	backupBuilderID     = "https://k8s-back.io/builder/k8s-backup"
+)
+
+// inTotoStatement in-toto Statement v1, predicate 为 SLSA Provenance v1
+type inTotoStatement struct {
+	Type          string          `json:"_type"`
+	Subject       []inTotoSubject `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     slsaProvenance  `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type slsaProvenance struct {
+	BuildDefinition slsaBuildDefinition `json:"buildDefinition"`
+	RunDetails      slsaRunDetails      `json:"runDetails"`
+}
+
+type slsaBuildDefinition struct {
+	BuildType            string                 `json:"buildType"`
+	ExternalParameters   map[string]interface{} `json:"externalParameters"`
+	InternalParameters   map[string]interface{} `json:"internalParameters,omitempty"`
+	ResolvedDependencies []slsaResourceDesc     `json:"resolvedDependencies,omitempty"`
+}
+
+type slsaResourceDesc struct {
+	URI         string            `json:"uri"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type slsaRunDetails struct {
+	Builder  slsaBuilder       `json:"builder"`
+	Metadata slsaBuildMetadata `json:"metadata"`
+}
+
+type slsaBuilder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version,omitempty"`
+}
+
+type slsaBuildMetadata struct {
+	InvocationID string `json:"invocationId"`
+	StartedOn    string `json:"startedOn"`
+	FinishedOn   string `json:"finishedOn"`
+}
+
+// newBackupAttestation 为备份目录生成 Statement: 每个文件一个 subject, 另以备份目录名为 name 的 subject
+// 记录整个目录的摘要 (按路径排序的 "sha256  路径" 行的 sha256, 与 sha256sum 的输出格式一致), 便于只校验一个值
+func newBackupAttestation(root string, meta backupMetadata, started, finished time.Time) (*inTotoStatement, error) {
+	subjects, err := digestBackupFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	tree := sha256.New()
+	for _, s := range subjects {
+		fmt.Fprintf(tree, "%s  %s\n", s.Digest["sha256"], s.Name)
+	}
+	name := filepath.Base(root)
+	subjects = append([]inTotoSubject{{Name: name, Digest: map[string]string{"sha256": hex.EncodeToString(tree.Sum(nil))}}}, subjects...)
+
+	params := map[string]interface{}{
+		"namespaces":    meta.Namespaces,
+		"resourceTypes": meta.ResourceTypes,
+	}
+	internal := make(map[string]interface{})
+	builder := slsaBuilder{ID: backupBuilderID, Version: map[string]string{"k8s-backup": version}}
+	if meta.Tool != nil {
+		params["args"] = meta.Tool.Args
+		if len(meta.Tool.Flags) > 0 {
+			params["flags"] = meta.Tool.Flags
+		}
+		if meta.Tool.ConfigSource != "" {
+			internal["configSource"] = meta.Tool.ConfigSource
+			internal["configHash"] = meta.Tool.ConfigHash
+		}
+		builder.Version["go"] = meta.Tool.GoVersion
+		if meta.Tool.Commit != "" {
+			builder.Version["commit"] = meta.Tool.Commit
+		}
+	}
+	if meta.Shard != "" {
+		params["shard"] = meta.Shard
+	}
+	if meta.ModifiedAfter != "" {
+		params["modifiedAfter"] = meta.ModifiedAfter
+	}
+
+	var deps []slsaResourceDesc
+	if fp := meta.Cluster; fp != nil && (fp.ServerHash != "" || fp.ClusterUID != "") {
+		dep := slsaResourceDesc{URI: "k8s://" + fp.ServerHash, Annotations: map[string]string{}}
+		if fp.ClusterUID != "" {
+			dep.Annotations["clusterUID"] = fp.ClusterUID
+		}
+		if fp.KubernetesVersion != "" {
+			dep.Annotations["kubernetesVersion"] = fp.KubernetesVersion
+		}
+		deps = append(deps, dep)
+	}
+	if len(internal) == 0 {
+		internal = nil
+	}
+
+	return &inTotoStatement{
+		Type:          inTotoStatementType,
+		Subject:       subjects,
+		PredicateType: slsaProvenanceType,
+		Predicate: slsaProvenance{
+			BuildDefinition: slsaBuildDefinition{
+				BuildType:            backupBuildType,
+				ExternalParameters:   params,
+				InternalParameters:   internal,
+				ResolvedDependencies: deps,
+			},
+			RunDetails: slsaRunDetails{
+				Builder: builder,
+				Metadata: slsaBuildMetadata{
+					InvocationID: name,
+					StartedOn:    started.UTC().Format(time.RFC3339),
+					FinishedOn:   finished.UTC().Format(time.RFC3339),
+				},
+			},
+		},
+	}, nil
+}
+
+// digestBackupFiles 计算备份目录中全部文件的 sha256, 路径相对备份根目录并按字母排序
+func digestBackupFiles(root string) ([]inTotoSubject, error) {
+	var subjects []inTotoSubject
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == attestationFileName {
+			return nil
+		}
+		digest, err := fileSHA256(path)
+		if err != nil {
+			return err
+		}
+		subjects = append(subjects, inTotoSubject{Name: rel, Digest: map[string]string{"sha256": digest}})
+		return nil
+	})
+	sort.Slice(subjects, func(i, j int) bool { return subjects[i].Name < subjects[j].Name })
+	return subjects, err
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeBackupAttestation 生成并写入 attestation.intoto.json, 需在备份目录的其他文件全部写完后调用
+func writeBackupAttestation(root string, meta backupMetadata, started, finished time.Time) error {
+	statement, err := newBackupAttestation(root, meta, started, finished)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(statement, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(root, attestationFileName), append(data, '\n'), 0644)
+}
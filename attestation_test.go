@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupAttestation(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "k8s-backup-20240101-020000")
+	if err := os.MkdirAll(filepath.Join(root, "app", "configmaps"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := []byte("apiVersion: v1\nkind: ConfigMap\n")
+	if err := os.WriteFile(filepath.Join(root, "app", "configmaps", "settings.yaml"), manifest, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, metadataFileName), []byte("version: v2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	meta := backupMetadata{
+		Namespaces:    []string{"app"},
+		ResourceTypes: []string{"configmaps"},
+		Cluster:       &clusterFingerprint{ServerHash: "0123456789abcdef", ClusterUID: "uid-1"},
+		Tool:          &toolInfo{Version: version, Commit: "3f2a9c1e0b7d", GoVersion: "go1.24.0", Args: []string{"-n", "app"}},
+	}
+	started := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+	if err := writeBackupAttestation(root, meta, started, started.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// 重复生成时不应把已有的证明文件计入 subject
+	if err := writeBackupAttestation(root, meta, started, started.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, attestationFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statement inTotoStatement
+	if err := json.Unmarshal(data, &statement); err != nil {
+		t.Fatal(err)
+	}
+	if statement.Type != inTotoStatementType || statement.PredicateType != slsaProvenanceType {
+		t.Errorf("类型 = %s / %s", statement.Type, statement.PredicateType)
+	}
+	if len(statement.Subject) != 3 {
+		t.Fatalf("subject = %+v, 期望目录摘要与 2 个文件", statement.Subject)
+	}
+	sum := sha256.Sum256(manifest)
+	if s := statement.Subject[1]; s.Name != "app/configmaps/settings.yaml" || s.Digest["sha256"] != hex.EncodeToString(sum[:]) {
+		t.Errorf("文件 subject = %+v", s)
+	}
+	if s := statement.Subject[0]; s.Name != filepath.Base(root) || len(s.Digest["sha256"]) != 64 {
+		t.Errorf("目录 subject = %+v", s)
+	}
+	run := statement.Predicate.RunDetails
+	if run.Builder.Version["commit"] != "3f2a9c1e0b7d" || run.Metadata.StartedOn != "2024-01-01T02:00:00Z" {
+		t.Errorf("runDetails = %+v", run)
+	}
+	deps := statement.Predicate.BuildDefinition.ResolvedDependencies
+	if len(deps) != 1 || deps[0].URI != "k8s://0123456789abcdef" || deps[0].Annotations["clusterUID"] != "uid-1" {
+		t.Errorf("resolvedDependencies = %+v", deps)
+	}
+}
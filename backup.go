@@ -0,0 +1,500 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"backup-k8s/clean"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Backupper 备份引擎, 保存一次备份的配置, 客户端与累计结果, 按命名空间与集群级资源分别执行
+// 各资源类型的动态客户端与集群范围的权限检查结果在首次使用后缓存, 在数百个命名空间之间复用
+type Backupper struct {
+	clientset     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	resourceTypes []string
+	skipSecrets   bool
+	pullSecrets   bool // skipSecrets 时仍备份被 ServiceAccount imagePullSecrets 引用的镜像仓库凭据
+	keepSystem    bool // 不应用内置排除清单 (clean.SystemManagedReason), 备份控制器自动生成的对象
+	systemConfig  bool // 随集群级资源备份 systemConfigObjects 中的系统配置
+	stripReplicas bool
+	orderedNames  bool
+	incremental   *incrementalBaseline // --incremental, 为空时完整备份
+	layout        string               // --layout, 空值等同于 flat
+	allInOne      string
+	graphFormat   string
+	configUsage   bool         // --config-usage-report, 在命名空间目录写出 ConfigMap/Secret 的引用报告
+	since         changedSince // --since / --modified-after, 只备份此后创建或修改过的对象
+	cleanOpts     clean.Options
+	imageRewrites []imageRewrite // --image-rewrite, 在清理前改写工作负载的容器镜像
+	validator     *schemaValidator
+	mapper        meta.RESTMapper // 解析 ValidatingAdmissionPolicy paramKind 等运行时才知道的类型, 为空时跳过
+	progress      *progressReporter
+	partitions    *partitionSet
+	maxBytes      int64     // --max-backup-size, 0 表示不限制
+	sink          *fileSink // 清单写入器, 为空时同步写入
+	bufferOutput  bool      // 每个命名空间的输出缓存到结束时整体写出, 并行备份多个命名空间时使用
+	nsWorkers     int       // --namespace-concurrency, 大于 1 时并行备份命名空间
+
+	mu             sync.Mutex // 保护以下累计结果, 使多个命名空间可以并行备份
+	totalResources int
+	invalidObjects []string // 未通过Schema校验的对象描述
+	writtenBytes   int64
+	sizeExceeded   bool
+	cacheMu        sync.Mutex // 保护以下缓存
+	clients        map[schema.GroupVersionResource]dynamic.NamespaceableResourceInterface
+	clusterAccess  map[schema.GroupVersionResource]bool // 集群范围 list 权限的检查结果
+}
+
+// addResources 累加已备份的对象数, n 为负数表示移除写入失败的对象
+func (b *Backupper) addResources(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.totalResources += n
+}
+
+// recordInvalid 记录未通过Schema校验的对象
+func (b *Backupper) recordInvalid(desc string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.invalidObjects = append(b.invalidObjects, desc)
+}
+
+// exceeded 判断备份大小是否已超过 --max-backup-size
+func (b *Backupper) exceeded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sizeExceeded
+}
+
+// resourceClient 返回资源类型的动态客户端, 首次使用时创建
+func (b *Backupper) resourceClient(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	b.cacheMu.Lock()
+	defer b.cacheMu.Unlock()
+	if b.clients == nil {
+		b.clients = make(map[schema.GroupVersionResource]dynamic.NamespaceableResourceInterface)
+	}
+	client, ok := b.clients[gvr]
+	if !ok {
+		client = b.dynamicClient.Resource(gvr)
+		b.clients[gvr] = client
+	}
+	return client
+}
+
+// canList 检查能否在命名空间中 list 该资源类型, namespace 为空表示集群范围, 权限检查调用失败时经 out 输出
+// 先检查一次集群范围的权限并缓存, 有权限时不再为每个命名空间单独发起 SelfSubjectAccessReview
+func (b *Backupper) canList(gvr schema.GroupVersionResource, namespace string, out *outputSection) bool {
+	b.cacheMu.Lock()
+	if b.clusterAccess == nil {
+		b.clusterAccess = make(map[schema.GroupVersionResource]bool)
+	}
+	allowed, checked := b.clusterAccess[gvr]
+	b.cacheMu.Unlock()
+	if !checked {
+		var err error
+		if allowed, err = checkResourceAccess(b.clientset, gvr, ""); err != nil {
+			out.errorf("%v\n", err)
+		}
+		b.cacheMu.Lock()
+		b.clusterAccess[gvr] = allowed
+		b.cacheMu.Unlock()
+	}
+	if allowed || namespace == "" {
+		return allowed
+	}
+	allowed, err := checkResourceAccess(b.clientset, gvr, namespace)
+	if err != nil {
+		out.errorf("%v\n", err)
+	}
+	return allowed
+}
+
+// backupNamespaces 备份全部目标命名空间, nsWorkers 大于 1 时由多个协程并行备份
+// done 在每个命名空间完成后串行调用 (如更新检查点); 备份大小超过上限后不再开始新的命名空间
+func (b *Backupper) backupNamespaces(namespaces []string, labels map[string]map[string]string, pace *backupPace, done func(nsName string)) {
+	if b.nsWorkers <= 1 {
+		for i, nsName := range namespaces {
+			if b.exceeded() {
+				break
+			}
+			if i > 0 {
+				pace.pause()
+			}
+			b.backupNamespace(nsName, labels[nsName])
+			done(nsName)
+		}
+		return
+	}
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	var doneMu sync.Mutex
+	for i := 0; i < b.nsWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for nsName := range queue {
+				b.backupNamespace(nsName, labels[nsName])
+				doneMu.Lock()
+				done(nsName)
+				doneMu.Unlock()
+			}
+		}()
+	}
+	for _, nsName := range namespaces {
+		if b.exceeded() {
+			break
+		}
+		queue <- nsName
+	}
+	close(queue)
+	wg.Wait()
+	// 完成顺序不确定, 按目标命名空间的顺序排列, 使元数据与顺序备份一致
+	order := make(map[string]int, len(namespaces))
+	for i, nsName := range namespaces {
+		order[nsName] = i
+	}
+	for _, p := range b.partitions.sorted() {
+		sort.Slice(p.Namespaces, func(i, j int) bool { return order[p.Namespaces[i]] < order[p.Namespaces[j]] })
+	}
+}
+
+// backupNamespace 备份单个命名空间内的全部所选资源类型, labels 为命名空间标签, 用于确定分区
+func (b *Backupper) backupNamespace(nsName string, labels map[string]string) {
+	out := newOutputSection(b.bufferOutput)
+	defer out.flush()
+	out.printf("\n[命名空间: %s]\n", nsName)
+	b.progress.Emit(progressEvent{Event: "namespace_started", Namespace: nsName})
+	nsTotal := 0
+	partition, err := b.partitions.get(b.partitions.forNamespace(labels))
+	if err != nil {
+		out.errorf("  警告: 创建分区目录失败: %v\n", err)
+		return
+	}
+	partition.addNamespace(nsName)
+	nsDir := filepath.Join(partition.Root, nsName)
+	if err := os.MkdirAll(nsDir, 0755); err != nil {
+		out.errorf("  警告: 创建目录 '%s' 失败: %v\n", nsDir, err)
+		return
+	}
+
+	nsResource := map[string]interface{}{
+		"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]string{"name": nsName},
+	}
+	nsYaml, _ := yaml.Marshal(nsResource)
+	batch := b.sink.batch()
+	nsWriter := newManifestWriter(nsDir, b.allInOne, batch)
+	nsWriter.write("", "00-namespace.yaml", nsYaml)
+	graph := newDependencyGraph()
+	var usage *configUsage
+	if b.configUsage {
+		usage = newConfigUsage(nsName, configUsageKinds)
+	}
+	pullLinks := make(pullSecretLinks)
+	loadBalancers := make(loadBalancerRecords)
+
+	for _, resType := range b.resourceTypes {
+		if b.exceeded() {
+			break
+		}
+		resInfo, exists := resourceMap[resType]
+		if !exists || !resInfo.Namespaced {
+			continue
+		}
+		pullSecretsOnly := b.skipSecrets && resType == "secrets"
+		if pullSecretsOnly && (!b.pullSecrets || len(pullLinks) == 0) {
+			partition.skip(skipEntry{Reason: skipSecretsDisabled, Kind: resInfo.Kind, Namespace: nsName})
+			continue
+		}
+		if !b.canList(resInfo.GVR, nsName, out) {
+			out.printf("  警告: 无权限读取 %s, 跳过\n", resInfo.Kind)
+			b.progress.Emit(progressEvent{Event: "resource_type_skipped", Namespace: nsName, Kind: resInfo.Kind, Reason: "permission_denied"})
+			partition.skip(skipEntry{Reason: skipPermissionDenied, Kind: resInfo.Kind, Namespace: nsName})
+			continue
+		}
+
+		// 增量备份时仍需全部 ServiceAccount 才能确定哪些镜像拉取凭据被引用
+		fullList := resType == "serviceaccounts" && b.skipSecrets && b.pullSecrets
+		resources, unchanged, err := b.listObjects(resInfo, nsName, fullList)
+		if err != nil {
+			out.errorf("  错误: 获取 %s 失败: %v\n", resInfo.Kind, err)
+			b.progress.Emit(progressEvent{Event: "resource_type_failed", Namespace: nsName, Kind: resInfo.Kind, Error: err.Error()})
+			partition.skip(skipEntry{Reason: skipListFailed, Kind: resInfo.Kind, Namespace: nsName, Detail: err.Error()})
+			continue
+		}
+		usage.list(resInfo.Kind, resources)
+		if len(resources)+unchanged == 0 {
+			continue
+		}
+		out.printf("  资源: %s (找到 %d 个)\n", resInfo.Kind, len(resources)+unchanged)
+		if unchanged > 0 {
+			out.printf("    跳过与上一次备份相比未变化的对象 %d 个\n", unchanged)
+		}
+		if resType == "secrets" {
+			referenced := pullLinks.secretNames()
+			resources, _ = partition.filterSkipped(resources, func(r *unstructured.Unstructured) (string, string) {
+				switch {
+				case pullSecretsOnly && !(referenced[r.GetName()] && isDockerConfigSecret(r.Object)):
+					return skipSecretsDisabled, "未被 ServiceAccount imagePullSecrets 引用"
+				case !pullSecretsOnly && !clean.ShouldBackupSecret(r.Object):
+					return skipSystemSecret, ""
+				}
+				return "", ""
+			})
+			if pullSecretsOnly {
+				out.printf("    仅备份 ServiceAccount 引用的镜像拉取凭据 (%d 个)\n", len(resources))
+			}
+		}
+		if resType == "serviceaccounts" {
+			for _, r := range resources {
+				pullLinks.add(r.Object)
+			}
+		}
+		if !b.keepSystem {
+			var skipped int
+			resources, skipped = partition.filterSkipped(resources, func(r *unstructured.Unstructured) (string, string) {
+				if reason := clean.SystemManagedReason(r.Object); reason != "" {
+					return skipSystemManaged, reason
+				}
+				return "", ""
+			})
+			if skipped > 0 {
+				out.printf("    跳过系统自动生成的对象 %d 个\n", skipped)
+			}
+		}
+		resources, unchanged = partition.filterSkipped(resources, b.since.check)
+		if unchanged > 0 {
+			out.printf("    跳过 %s 之后未变化的对象 %d 个\n", b.since, unchanged)
+		}
+		if len(resources) == 0 {
+			continue
+		}
+
+		resDir := typeDirName(resType, resInfo, b.layout, b.orderedNames)
+
+		backupCount := 0
+		for _, resource := range resources {
+			entry := newIndexEntry(&resource)
+			if resType == "services" {
+				loadBalancers.add(resource.Object)
+			}
+			rewrites := rewriteImages(resource.Object, b.imageRewrites)
+			for _, c := range rewrites {
+				out.printf("    改写镜像 %s 容器 %s: %s → %s\n", resource.GetName(), c.Container, c.From, c.To)
+			}
+			obj, yamlData, err := renderResource(resType, &resource, b.cleanOpts)
+			if err != nil {
+				out.errorf("    错误: 序列化 '%s' 失败: %v\n", resource.GetName(), err)
+				b.progress.Emit(progressEvent{Event: "resource_failed", Namespace: nsName, Kind: resInfo.Kind, Name: resource.GetName(), Error: err.Error()})
+				partition.skip(skipEntry{Reason: skipRenderFailed, Kind: resInfo.Kind, Namespace: nsName, Name: resource.GetName(), Detail: err.Error()})
+				continue
+			}
+			if !b.reserveBytes(len(yamlData)) {
+				msg := b.sizeExceededError(fmt.Sprintf("%s %s/%s", resInfo.Kind, nsName, resource.GetName()))
+				out.errorf("    错误: %s\n", msg)
+				b.progress.Emit(progressEvent{Event: "backup_size_limit_failed", Namespace: nsName, Kind: resInfo.Kind, Name: resource.GetName(), Error: msg})
+				partition.skip(skipEntry{Reason: skipSizeLimit, Kind: resInfo.Kind, Namespace: nsName, Name: resource.GetName(), Detail: "该对象及之后的全部对象未备份"})
+				break
+			}
+
+			filename := fmt.Sprintf("%s.yaml", resource.GetName())
+			fullPath, err := nsWriter.write(resDir, filename, yamlData)
+			if err != nil {
+				out.errorf("    错误: 写入文件 '%s' 失败: %v\n", filepath.Join(nsDir, resDir, filename), err)
+				b.progress.Emit(progressEvent{Event: "resource_failed", Namespace: nsName, Kind: resInfo.Kind, Name: resource.GetName(), Error: err.Error()})
+				partition.skip(skipEntry{Reason: skipWriteFailed, Kind: resInfo.Kind, Namespace: nsName, Name: resource.GetName(), Detail: err.Error()})
+				continue
+			}
+			if b.validator != nil {
+				if problems := b.validator.Validate(obj); len(problems) > 0 {
+					desc := fmt.Sprintf("%s %s/%s", resInfo.Kind, nsName, resource.GetName())
+					out.printf("    警告: %s 未通过Schema校验: %s\n", desc, strings.Join(problems, "; "))
+					b.progress.Emit(progressEvent{Event: "resource_validation_failed", Namespace: nsName, Kind: resInfo.Kind, Name: resource.GetName(), Error: strings.Join(problems, "; ")})
+					b.recordInvalid(desc)
+				}
+			}
+			backupCount++
+			partition.addEntry(entry.withFile(partition.Root, fullPath, yamlData), obj, rewrites)
+			graph.add(obj)
+			usage.add(obj)
+			b.progress.Emit(progressEvent{Event: "resource_backed_up", Namespace: nsName, Kind: resInfo.Kind, Name: resource.GetName(), Path: fullPath})
+		}
+		out.printf("    ✓ 备份 %d 个 %s\n", backupCount, resInfo.Kind)
+		b.addResources(backupCount)
+		partition.addTotal(backupCount)
+		nsTotal += backupCount
+	}
+	if err := nsWriter.flush(); err != nil {
+		out.errorf("  警告: 写入 %s 失败: %v\n", allInOneFileName, err)
+	}
+	nsTotal -= b.dropFailedWrites(partition, batch, out)
+	if err := graph.write(nsDir, b.graphFormat); err != nil {
+		out.errorf("  警告: 写入依赖图失败: %v\n", err)
+	}
+	if summary, err := usage.write(nsDir); err != nil {
+		out.errorf("  警告: 写入 %s 失败: %v\n", configUsageFileName, err)
+	} else if summary != "" {
+		out.printf("  %s\n", summary)
+	}
+	if len(pullLinks) > 0 {
+		if err := writeYAMLFile(filepath.Join(nsDir, pullSecretsFileName), pullLinks); err != nil {
+			out.errorf("  警告: 写入 %s 失败: %v\n", pullSecretsFileName, err)
+		}
+	}
+	if len(loadBalancers) > 0 {
+		if err := writeYAMLFile(filepath.Join(nsDir, loadBalancersFileName), loadBalancers); err != nil {
+			out.errorf("  警告: 写入 %s 失败: %v\n", loadBalancersFileName, err)
+		}
+	}
+	if b.stripReplicas {
+		if sizing := collectNamespaceSizing(b.dynamicClient, nsName, out); !sizing.empty() {
+			if err := writeYAMLFile(filepath.Join(nsDir, sizingFileName), sizing); err != nil {
+				out.errorf("  警告: 写入 %s 失败: %v\n", sizingFileName, err)
+			}
+		}
+	}
+	b.progress.Emit(progressEvent{Event: "namespace_completed", Namespace: nsName, Count: nsTotal})
+}
+
+// backupClusterResources 备份集群级资源到集群分区的 _global 目录
+func (b *Backupper) backupClusterResources() {
+	clusterPartition, err := b.partitions.get(b.partitions.forCluster())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 创建分区目录失败, 跳过集群级资源: %v\n", err)
+		return
+	}
+	fmt.Fprintln(logOut, "\n[集群范围资源]")
+	globalDir := filepath.Join(clusterPartition.Root, "_global")
+	os.MkdirAll(globalDir, 0755)
+	batch := b.sink.batch()
+	globalWriter := newManifestWriter(globalDir, b.allInOne, batch)
+	graph := newDependencyGraph()
+	var policies, policyBindings []map[string]interface{}
+
+	for _, resType := range b.resourceTypes {
+		if b.exceeded() {
+			break
+		}
+		resInfo, exists := resourceMap[resType]
+		if !exists || resInfo.Namespaced {
+			continue
+		}
+		if !b.canList(resInfo.GVR, "", newOutputSection(false)) {
+			fmt.Fprintf(logOut, "  警告: 无权限读取集群级 %s, 跳过\n", resInfo.Kind)
+			b.progress.Emit(progressEvent{Event: "resource_type_skipped", Kind: resInfo.Kind, Reason: "permission_denied"})
+			clusterPartition.skip(skipEntry{Reason: skipPermissionDenied, Kind: resInfo.Kind})
+			continue
+		}
+
+		resources, unchanged, err := b.listObjects(resInfo, "", false)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  错误: 获取 %s 失败: %v\n", resInfo.Kind, err)
+			b.progress.Emit(progressEvent{Event: "resource_type_failed", Kind: resInfo.Kind, Error: err.Error()})
+			clusterPartition.skip(skipEntry{Reason: skipListFailed, Kind: resInfo.Kind, Detail: err.Error()})
+			continue
+		}
+		if len(resources)+unchanged == 0 {
+			continue
+		}
+		fmt.Fprintf(logOut, "  资源: %s (找到 %d 个)\n", resInfo.Kind, len(resources)+unchanged)
+		if unchanged > 0 {
+			fmt.Fprintf(logOut, "    跳过与上一次备份相比未变化的对象 %d 个\n", unchanged)
+		}
+
+		resources, unchanged = clusterPartition.filterSkipped(resources, b.since.check)
+		if unchanged > 0 {
+			fmt.Fprintf(logOut, "    跳过 %s 之后未变化的对象 %d 个\n", b.since, unchanged)
+		}
+		if len(resources) == 0 {
+			continue
+		}
+
+		resDir := typeDirName(resType, resInfo, b.layout, b.orderedNames)
+
+		backupCount := 0
+		pvBindings := make(map[string]pvBinding)
+		for _, resource := range resources {
+			entry := newIndexEntry(&resource)
+			if resType == "persistentvolumes" {
+				pvBindings[resource.GetName()] = newPVBinding(resource.Object)
+			}
+			obj, yamlData, err := renderResource(resType, &resource, b.cleanOpts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "    错误: 序列化 '%s' 失败: %v\n", resource.GetName(), err)
+				b.progress.Emit(progressEvent{Event: "resource_failed", Kind: resInfo.Kind, Name: resource.GetName(), Error: err.Error()})
+				clusterPartition.skip(skipEntry{Reason: skipRenderFailed, Kind: resInfo.Kind, Name: resource.GetName(), Detail: err.Error()})
+				continue
+			}
+			if !b.reserveBytes(len(yamlData)) {
+				msg := b.sizeExceededError(fmt.Sprintf("%s %s", resInfo.Kind, resource.GetName()))
+				fmt.Fprintf(os.Stderr, "    错误: %s\n", msg)
+				b.progress.Emit(progressEvent{Event: "backup_size_limit_failed", Kind: resInfo.Kind, Name: resource.GetName(), Error: msg})
+				clusterPartition.skip(skipEntry{Reason: skipSizeLimit, Kind: resInfo.Kind, Name: resource.GetName(), Detail: "该对象及之后的全部对象未备份"})
+				break
+			}
+			filename := fmt.Sprintf("%s.yaml", resource.GetName())
+			fullPath, err := globalWriter.write(resDir, filename, yamlData)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "    错误: 写入文件 '%s' 失败: %v\n", filepath.Join(globalDir, resDir, filename), err)
+				b.progress.Emit(progressEvent{Event: "resource_failed", Kind: resInfo.Kind, Name: resource.GetName(), Error: err.Error()})
+				clusterPartition.skip(skipEntry{Reason: skipWriteFailed, Kind: resInfo.Kind, Name: resource.GetName(), Detail: err.Error()})
+				continue
+			}
+			if b.validator != nil {
+				if problems := b.validator.Validate(obj); len(problems) > 0 {
+					desc := fmt.Sprintf("%s %s", resInfo.Kind, resource.GetName())
+					fmt.Fprintf(logOut, "    警告: %s 未通过Schema校验: %s\n", desc, strings.Join(problems, "; "))
+					b.progress.Emit(progressEvent{Event: "resource_validation_failed", Kind: resInfo.Kind, Name: resource.GetName(), Error: strings.Join(problems, "; ")})
+					b.recordInvalid(desc)
+				}
+			}
+			backupCount++
+			clusterPartition.addEntry(entry.withFile(clusterPartition.Root, fullPath, yamlData), nil, nil)
+			graph.add(obj)
+			switch resType {
+			case "validatingadmissionpolicies":
+				policies = append(policies, obj)
+			case "validatingadmissionpolicybindings":
+				policyBindings = append(policyBindings, obj)
+			}
+			b.progress.Emit(progressEvent{Event: "resource_backed_up", Kind: resInfo.Kind, Name: resource.GetName(), Path: fullPath})
+		}
+		fmt.Fprintf(logOut, "    ✓ 备份 %d 个 %s\n", backupCount, resInfo.Kind)
+		b.addResources(backupCount)
+		clusterPartition.addTotal(backupCount)
+
+		if len(pvBindings) > 0 {
+			if err := writeYAMLFile(filepath.Join(globalDir, pvBindingsFileName), pvBindings); err != nil {
+				fmt.Fprintf(os.Stderr, "  警告: 写入 %s 失败: %v\n", pvBindingsFileName, err)
+			}
+		}
+	}
+	if !b.exceeded() {
+		refs, problems := collectParamRefs(policies, policyBindings)
+		for _, problem := range problems {
+			fmt.Fprintf(logOut, "  警告: 无法确定 ValidatingAdmissionPolicyBinding 的参数对象: %s\n", problem)
+		}
+		b.backupPolicyParams(refs, globalWriter, clusterPartition, globalDir, graph)
+	}
+	if b.systemConfig && !b.exceeded() {
+		b.backupSystemConfig(globalWriter, clusterPartition, globalDir, graph)
+	}
+	if err := globalWriter.flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "  警告: 写入 %s 失败: %v\n", allInOneFileName, err)
+	}
+	b.dropFailedWrites(clusterPartition, batch, newOutputSection(false))
+	if err := graph.write(globalDir, b.graphFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "  警告: 写入依赖图失败: %v\n", err)
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"backup-k8s/clean"
+	authv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeBackupper 构造基于 fake 客户端的 Backupper, denied 中的资源 (GVR.Resource) 在权限检查时被拒绝
+func newFakeBackupper(t testing.TB, resourceTypes []string, denied map[string]bool, objects ...runtime.Object) (*Backupper, *partitionSet) {
+	t.Helper()
+	logOut = io.Discard
+	t.Cleanup(func() { logOut = os.Stdout })
+
+	listKinds := make(map[schema.GroupVersionResource]string)
+	for _, info := range resourceMap {
+		listKinds[info.GVR] = info.Kind + "List"
+	}
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+
+	clientset := kubefake.NewSimpleClientset()
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		ssar := action.(k8stesting.CreateAction).GetObject().(*authv1.SelfSubjectAccessReview)
+		ssar.Status.Allowed = !denied[ssar.Spec.ResourceAttributes.Resource]
+		return true, ssar, nil
+	})
+
+	partitions := newPartitionSet(t.TempDir(), "backup", "")
+	run := &Backupper{
+		clientset:     clientset,
+		dynamicClient: dynamicClient,
+		resourceTypes: resourceTypes,
+		allInOne:      allInOneOff,
+		cleanOpts:     clean.Options{LastApplied: clean.LastAppliedStrip},
+		progress:      &progressReporter{},
+		partitions:    partitions,
+	}
+	return run, partitions
+}
+
+func fakeObject(apiVersion, kind, namespace, name string, fields map[string]interface{}) *unstructured.Unstructured {
+	obj := map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":            name,
+			"namespace":       namespace,
+			"uid":             "uid-" + name,
+			"resourceVersion": "1",
+		},
+	}
+	for k, v := range fields {
+		obj[k] = v
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func TestBackupNamespaceWritesCleanedManifests(t *testing.T) {
+	run, partitions := newFakeBackupper(t,
+		[]string{"configmaps", "secrets", "services", "deployments"},
+		map[string]bool{"services": true},
+		fakeObject("v1", "ConfigMap", "web", "settings", map[string]interface{}{
+			"data": map[string]interface{}{"app.conf": `a=1\nb=2`},
+		}),
+		fakeObject("v1", "Secret", "web", "db-password", map[string]interface{}{"type": "Opaque"}),
+		fakeObject("v1", "Secret", "web", "default-token-abcde", map[string]interface{}{"type": "kubernetes.io/service-account-token"}),
+		fakeObject("v1", "Service", "web", "frontend", map[string]interface{}{"spec": map[string]interface{}{"clusterIP": "10.0.0.1"}}),
+		fakeObject("apps/v1", "Deployment", "web", "frontend", map[string]interface{}{
+			"spec":   map[string]interface{}{"replicas": int64(2)},
+			"status": map[string]interface{}{"readyReplicas": int64(2)},
+		}),
+		fakeObject("v1", "ConfigMap", "other", "ignored", nil),
+	)
+	run.backupNamespace("web", nil)
+
+	if run.totalResources != 3 {
+		t.Errorf("备份资源数 = %d, 期望 3", run.totalResources)
+	}
+	p := partitions.byName[""]
+	nsDir := filepath.Join(p.Root, "web")
+	for _, rel := range []string{
+		"00-namespace.yaml",
+		"configmaps/settings.yaml",
+		"secrets/db-password.yaml",
+		"deployments/frontend.yaml",
+	} {
+		if _, err := os.Stat(filepath.Join(nsDir, rel)); err != nil {
+			t.Errorf("缺少清单 %s: %v", rel, err)
+		}
+	}
+	for _, rel := range []string{"secrets/default-token-abcde.yaml", "services", "configmaps/ignored.yaml"} {
+		if _, err := os.Stat(filepath.Join(nsDir, rel)); err == nil {
+			t.Errorf("不应生成 %s", rel)
+		}
+	}
+
+	deployment, err := os.ReadFile(filepath.Join(nsDir, "deployments", "frontend.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"status:", "uid:", "resourceVersion:"} {
+		if strings.Contains(string(deployment), field) {
+			t.Errorf("清理后的 Deployment 仍包含 %s\n%s", field, deployment)
+		}
+	}
+	configMap, err := os.ReadFile(filepath.Join(nsDir, "configmaps", "settings.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(configMap), "a=1\n") {
+		t.Errorf("ConfigMap 数据未标准化换行\n%s", configMap)
+	}
+
+	if len(p.Index) != 3 {
+		t.Errorf("索引条目数 = %d, 期望 3", len(p.Index))
+	}
+	issues := run.progress.Issues()
+	if len(issues) != 1 || issues[0].Event != "resource_type_skipped" || issues[0].Kind != "Service" {
+		t.Errorf("期望记录一条 Service 权限跳过事件, 实际 %+v", issues)
+	}
+
+	report := newSkipReport(p.Skipped)
+	if report.Counts[skipPermissionDenied] != 1 || report.Counts[skipSystemSecret] != 1 || len(report.Objects) != 2 {
+		t.Errorf("跳过报告 = %+v", report)
+	}
+	if e := report.Objects[1]; e.Reason != skipSystemSecret || e.Name != "default-token-abcde" || e.Namespace != "web" {
+		t.Errorf("Secret 跳过记录 = %+v", e)
+	}
+}
+
+func TestBackupClusterResources(t *testing.T) {
+	run, partitions := newFakeBackupper(t,
+		[]string{"persistentvolumes", "deployments"},
+		nil,
+		fakeObject("v1", "PersistentVolume", "", "pv-data", map[string]interface{}{
+			"spec": map[string]interface{}{
+				"claimRef": map[string]interface{}{"namespace": "web", "name": "data"},
+			},
+		}),
+	)
+	run.backupClusterResources()
+
+	if run.totalResources != 1 {
+		t.Errorf("备份资源数 = %d, 期望 1", run.totalResources)
+	}
+	globalDir := filepath.Join(partitions.byName[""].Root, "_global")
+	pv, err := os.ReadFile(filepath.Join(globalDir, "persistentvolumes", "pv-data.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(pv), "claimRef") {
+		t.Errorf("PersistentVolume 应移除 claimRef\n%s", pv)
+	}
+	if _, err := os.Stat(filepath.Join(globalDir, pvBindingsFileName)); err != nil {
+		t.Errorf("缺少 %s: %v", pvBindingsFileName, err)
+	}
+}
+
+func TestBackupGroupLayout(t *testing.T) {
+	run, partitions := newFakeBackupper(t,
+		[]string{"configmaps", "deployments", "persistentvolumes"},
+		nil,
+		fakeObject("v1", "ConfigMap", "web", "settings", nil),
+		fakeObject("apps/v1", "Deployment", "web", "frontend", nil),
+		fakeObject("v1", "PersistentVolume", "", "pv-data", nil),
+	)
+	run.layout = layoutGroup
+	run.backupNamespace("web", nil)
+	run.backupClusterResources()
+
+	root := partitions.byName[""].Root
+	for _, rel := range []string{
+		"web/00-namespace.yaml",
+		"web/core/ConfigMap/settings.yaml",
+		"web/apps/Deployment/frontend.yaml",
+		"_global/core/PersistentVolume/pv-data.yaml",
+	} {
+		if _, err := os.Stat(filepath.Join(root, rel)); err != nil {
+			t.Errorf("缺少清单 %s: %v", rel, err)
+		}
+	}
+	items, err := loadRestoreItems(root)
+	if err != nil || len(items) != 4 {
+		t.Errorf("恢复时读取到 %d 个对象 (%v), 期望 4", len(items), err)
+	}
+
+	if err := validateLayout(layoutGroup, true); err == nil {
+		t.Error("--layout group 与 --ordered-names 同时使用应报错")
+	}
+	if err := validateLayout("tree", false); err == nil {
+		t.Error("未知布局应报错")
+	}
+}
+
+func TestBackupNamespacePullSecrets(t *testing.T) {
+	run, partitions := newFakeBackupper(t,
+		[]string{"serviceaccounts", "secrets"},
+		nil,
+		fakeObject("v1", "ServiceAccount", "web", "builder", map[string]interface{}{
+			"imagePullSecrets": []interface{}{map[string]interface{}{"name": "registry"}},
+		}),
+		fakeObject("v1", "Secret", "web", "registry", map[string]interface{}{"type": "kubernetes.io/dockerconfigjson"}),
+		fakeObject("v1", "Secret", "web", "db-password", map[string]interface{}{"type": "Opaque"}),
+	)
+	run.skipSecrets, run.pullSecrets = true, true
+	run.backupNamespace("web", nil)
+
+	nsDir := filepath.Join(partitions.byName[""].Root, "web")
+	if _, err := os.Stat(filepath.Join(nsDir, "secrets", "registry.yaml")); err != nil {
+		t.Errorf("被引用的镜像拉取凭据应被备份: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(nsDir, "secrets", "db-password.yaml")); err == nil {
+		t.Error("--skip-secrets 时不应备份其他 Secret")
+	}
+	var links pullSecretLinks
+	if err := readYAMLFile(filepath.Join(nsDir, pullSecretsFileName), &links); err != nil {
+		t.Fatal(err)
+	}
+	if got := links["builder"]; len(got) != 1 || got[0] != "registry" {
+		t.Errorf("%s 记录 = %v", pullSecretsFileName, links)
+	}
+}
+
+func TestBackupNamespaceSizeLimit(t *testing.T) {
+	run, partitions := newFakeBackupper(t,
+		[]string{"configmaps"},
+		nil,
+		fakeObject("v1", "ConfigMap", "web", "a", map[string]interface{}{"data": map[string]interface{}{"k": strings.Repeat("x", 600)}}),
+		fakeObject("v1", "ConfigMap", "web", "b", map[string]interface{}{"data": map[string]interface{}{"k": strings.Repeat("x", 600)}}),
+	)
+	run.maxBytes = 1000
+	run.backupNamespace("web", nil)
+
+	if !run.sizeExceeded || run.totalResources != 1 {
+		t.Errorf("超限 = %v, 备份资源数 = %d, 期望超限且只备份 1 个", run.sizeExceeded, run.totalResources)
+	}
+	if _, err := os.Stat(filepath.Join(partitions.byName[""].Root, "web", "configmaps", "b.yaml")); err == nil {
+		t.Error("超限后不应继续写入对象")
+	}
+	if issues := run.progress.Issues(); len(issues) != 1 || issues[0].Event != "backup_size_limit_failed" {
+		t.Errorf("期望记录一条超限事件, 实际 %+v", issues)
+	}
+}
+
+func TestBackupperReusesClusterAccess(t *testing.T) {
+	run, _ := newFakeBackupper(t, []string{"configmaps", "services"}, map[string]bool{"services": true},
+		fakeObject("v1", "ConfigMap", "a", "settings", nil),
+		fakeObject("v1", "ConfigMap", "b", "settings", nil),
+	)
+	reviews := 0
+	run.clientset.(*kubefake.Clientset).PrependReactor("create", "selfsubjectaccessreviews", func(k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		return false, nil, nil
+	})
+	run.backupNamespace("a", nil)
+	run.backupNamespace("b", nil)
+
+	// configmaps 集群范围有权限, 只检查一次; services 无集群范围权限, 需逐个命名空间检查
+	if reviews != 4 {
+		t.Errorf("SelfSubjectAccessReview 次数 = %d, 期望 4", reviews)
+	}
+	if len(run.clients) != 1 || run.totalResources != 2 {
+		t.Errorf("客户端缓存 %d 个, 备份资源数 %d", len(run.clients), run.totalResources)
+	}
+}
+
+// BenchmarkBackupNamespaces 模拟包含大量命名空间的集群, 衡量每轮命名空间循环的分配
+func BenchmarkBackupNamespaces(b *testing.B) {
+	const namespaces = 200
+	var objects []runtime.Object
+	for i := 0; i < namespaces; i++ {
+		ns := fmt.Sprintf("ns-%03d", i)
+		objects = append(objects,
+			fakeObject("v1", "ConfigMap", ns, "settings", map[string]interface{}{"data": map[string]interface{}{"k": "v"}}),
+			fakeObject("v1", "ServiceAccount", ns, "builder", nil),
+			fakeObject("apps/v1", "Deployment", ns, "web", map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(2)}}),
+		)
+	}
+	run, _ := newFakeBackupper(b, []string{"configmaps", "serviceaccounts", "deployments"}, nil, objects...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := 0; i < namespaces; i++ {
+			run.backupNamespace(fmt.Sprintf("ns-%03d", i), nil)
+		}
+	}
+}
+
+func TestVerifyBackupCounts(t *testing.T) {
+	run, partitions := newFakeBackupper(t, []string{"configmaps", "secrets"}, nil,
+		fakeObject("v1", "ConfigMap", "web", "settings", nil),
+		fakeObject("v1", "ConfigMap", "web", "kube-root-ca.crt", nil),
+		fakeObject("v1", "Secret", "web", "db-password", map[string]interface{}{"type": "Opaque"}),
+	)
+	run.backupNamespace("web", nil)
+	p := partitions.byName[""]
+	if m := verifyBackupCounts(run.dynamicClient, p, run.resourceTypes, false); len(m) != 0 {
+		t.Fatalf("备份后立即复查不应有差异: %v", m)
+	}
+
+	// 备份之后新增对象, 以及清单文件丢失
+	cm := fakeObject("v1", "ConfigMap", "web", "late", nil)
+	if _, err := run.dynamicClient.Resource(resourceMap["configmaps"].GVR).Namespace("web").Create(context.TODO(), cm, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(p.Root, "web", "secrets", "db-password.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	m := verifyBackupCounts(run.dynamicClient, p, run.resourceTypes, false)
+	if len(m) != 2 {
+		t.Fatalf("差异 = %v, 期望 2 处", m)
+	}
+	if m[0].Kind != "ConfigMap" || m[0].Listed != 3 || m[0].Written != 1 || m[0].Skipped != 1 {
+		t.Errorf("ConfigMap 差异 = %+v", m[0])
+	}
+	if m[1].Kind != "Secret" || len(m[1].Missing) != 1 {
+		t.Errorf("Secret 差异 = %+v", m[1])
+	}
+}
+
+func TestManifestDigestIgnoresBackupTime(t *testing.T) {
+	manifest := func(backupTime, value string) []byte {
+		return []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  annotations:\n    " + clean.AnnotationBackupTime + ": \"" + backupTime + "\"\n  name: app\ndata:\n  key: " + value + "\n")
+	}
+	if manifestDigest(manifest("2026-10-14T08:00:00Z", "a")) != manifestDigest(manifest("2026-10-15T08:00:00Z", "a")) {
+		t.Error("只有备份时间不同的清单摘要应相同")
+	}
+	if manifestDigest(manifest("2026-10-15T08:00:00Z", "a")) == manifestDigest(manifest("2026-10-15T08:00:00Z", "b")) {
+		t.Error("内容不同的清单摘要应不同")
+	}
+}
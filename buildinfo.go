@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// gitCommit 构建时通过 -ldflags "-X main.gitCommit=..." 注入, 为空时从 Go 构建信息中读取 vcs.revision
+var gitCommit string
+
+// toolInfo 记录生成备份的工具版本与调用方式, 写入 metadata.yaml, 供恢复时检测不兼容并复现原始命令
+type toolInfo struct {
+	Version      string            `yaml:"version"`
+	Commit       string            `yaml:"commit,omitempty"`
+	GoVersion    string            `yaml:"goVersion"`
+	Args         []string          `yaml:"args"`                   // 命令行参数 (不含程序名)
+	Flags        map[string]string `yaml:"flags,omitempty"`        // 实际生效的非默认参数, 含集群策略下发的默认值
+	ConfigSource string            `yaml:"configSource,omitempty"` // 生效的集群策略 ConfigMap
+	ConfigHash   string            `yaml:"configHash,omitempty"`   // 集群策略 ConfigMap data 的 sha256
+}
+
+// newToolInfo 收集当前二进制的版本信息与 fs 中已设置的参数
+func newToolInfo(fs *pflag.FlagSet, args []string, policy *clusterPolicy) *toolInfo {
+	info := &toolInfo{
+		Version:   version,
+		Commit:    buildCommit(),
+		GoVersion: runtime.Version(),
+		Args:      args,
+		Flags:     make(map[string]string),
+	}
+	fs.Visit(func(f *pflag.Flag) {
+		info.Flags[f.Name] = f.Value.String()
+	})
+	if policy != nil {
+		info.ConfigSource = policy.Source
+		info.ConfigHash = policy.Hash
+	}
+	return info
+}
+
+// buildCommit 返回构建时的 git 提交, 本地修改未提交时追加 -dirty
+func buildCommit() string {
+	if gitCommit != "" {
+		return gitCommit
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision string
+	var modified bool
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// hashConfigData 计算 ConfigMap data 的摘要, 按键排序以保证结果稳定
+func hashConfigData(data map[string]string) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%q\n", k, data[k])
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// checkToolVersion 比较生成备份的工具版本与当前版本, 备份由更新的主版本或次版本生成时返回警告
+// 新版本可能写入当前版本不认识的文件或字段, 旧版本生成的备份总是可以恢复
+func checkToolVersion(backupVersion string) string {
+	if backupVersion == "" || backupVersion == version {
+		return ""
+	}
+	backup, ok1 := parseToolVersion(backupVersion)
+	current, ok2 := parseToolVersion(version)
+	if !ok1 || !ok2 {
+		return ""
+	}
+	if backup[0] > current[0] || backup[0] == current[0] && backup[1] > current[1] {
+		return fmt.Sprintf("备份由较新版本 %s 生成, 当前版本为 %s, 部分备份内容可能无法识别, 建议使用相同或更新的版本恢复", backupVersion, version)
+	}
+	return ""
+}
+
+// parseToolVersion 解析 v2.3.0 形式的版本号, 返回主版本与次版本
+func parseToolVersion(v string) ([2]int, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+	if len(parts) < 2 {
+		return [2]int{}, false
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return [2]int{}, false
+	}
+	return [2]int{major, minor}, true
+}
+
+// describeTool 返回工具版本的可读描述, 如 v2.3.0 (3f2a9c1e0b7d)
+func describeTool(info *toolInfo) string {
+	if info.Commit == "" {
+		return info.Version
+	}
+	return fmt.Sprintf("%s (%s)", info.Version, info.Commit)
+}
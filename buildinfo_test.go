@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestToolInfo(t *testing.T) {
+	fs := pflag.NewFlagSet("backup", pflag.ContinueOnError)
+	fs.String("namespace", "", "")
+	fs.Bool("skip-secrets", false, "")
+	fs.Int("write-concurrency", 1, "")
+	args := []string{"--namespace", "web", "--skip-secrets"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	policy := &clusterPolicy{Source: "k8s-back/k8s-back-config", Hash: hashConfigData(map[string]string{"exclude-secrets": "true"})}
+
+	info := newToolInfo(fs, args, policy)
+	if info.Version != version || info.GoVersion == "" {
+		t.Errorf("版本信息不完整: %+v", info)
+	}
+	if len(info.Flags) != 2 || info.Flags["namespace"] != "web" || info.Flags["skip-secrets"] != "true" {
+		t.Errorf("生效参数 = %v, 期望只包含显式设置的 namespace 与 skip-secrets", info.Flags)
+	}
+	if info.ConfigSource != policy.Source || !strings.HasPrefix(info.ConfigHash, "sha256:") {
+		t.Errorf("集群策略信息 = %s %s", info.ConfigSource, info.ConfigHash)
+	}
+
+	a := hashConfigData(map[string]string{"a": "1", "b": "2"})
+	b := hashConfigData(map[string]string{"b": "2", "a": "1"})
+	c := hashConfigData(map[string]string{"a": "1", "b": "3"})
+	if a != b || a == c {
+		t.Error("摘要应与键顺序无关且随内容变化")
+	}
+}
+
+func TestCheckToolVersion(t *testing.T) {
+	saved := version
+	version = "v2.3.0"
+	t.Cleanup(func() { version = saved })
+
+	cases := []struct {
+		backup string
+		warn   bool
+	}{
+		{"v2.3.0", false},
+		{"v2.3.5", false},
+		{"v2.1.0", false},
+		{"v1.9.0", false},
+		{"v2.4.0", true},
+		{"v3.0.0", true},
+		{"20240101020000", false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		if got := checkToolVersion(tc.backup) != ""; got != tc.warn {
+			t.Errorf("checkToolVersion(%q) 警告 = %v, 期望 %v", tc.backup, got, tc.warn)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestBackup(t *testing.T) string {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "k8s-backup-20261015-080000")
+	files := map[string]string{
+		metadataFileName:                 "version: v2.3.0\ntimestamp: \"2026-10-15T08:00:00Z\"\nnamespaces: [shop]\ntotalResources: 1\n",
+		"shop/configmaps/settings.yaml":  "apiVersion: v1\nkind: ConfigMap\n",
+		"shop/secrets/db.yaml.age":       "age-encryption.org/v1\n",
+		"shop/00-namespace.yaml":         "apiVersion: v1\nkind: Namespace\n",
+		"attestation.intoto.json.sig":    "signature",
+		"_global/clusterroles/view.yaml": "kind: ClusterRole\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	root := writeTestBackup(t)
+	// attestation 在签名文件之前生成, 之后写入的签名不影响目录摘要
+	sig := filepath.Join(root, "attestation.intoto.json.sig")
+	sigData, _ := os.ReadFile(sig)
+	os.Remove(sig)
+	if err := writeBackupAttestation(root, backupMetadata{}, time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(sig, sigData, 0644)
+
+	output := filepath.Join(t.TempDir(), "backup"+bundleExt)
+	manifest, checksum, err := writeBundle(root, output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Attestation != attestationFileName || len(manifest.Signatures) != 1 || len(manifest.Encrypted) != 1 || manifest.Encrypted[0].Scheme != "age" {
+		t.Errorf("包清单 = %+v", manifest)
+	}
+	if manifest.TotalResources != 1 || len(manifest.Files) != 7 {
+		t.Errorf("对象数 = %d, 文件数 = %d", manifest.TotalResources, len(manifest.Files))
+	}
+	if got, err := readBundleChecksum(output + bundleChecksumExt); err != nil || got != checksum {
+		t.Errorf("校验文件 = %s, %v; 期望 %s", got, err, checksum)
+	}
+	if _, _, err := writeBundle(root, output); err == nil {
+		t.Error("备份包已存在时应返回错误")
+	}
+
+	if _, dir, err := extractBundle(output, ""); err != nil || dir != "" {
+		t.Fatalf("只校验: dir = %q, err = %v", dir, err)
+	}
+	outputDir := t.TempDir()
+	_, dir, err := extractBundle(output, outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != filepath.Join(outputDir, manifest.Backup) {
+		t.Errorf("解包目录 = %s", dir)
+	}
+	for _, f := range manifest.Files {
+		if digest, err := fileSHA256(filepath.Join(dir, filepath.FromSlash(f.Path))); err != nil || digest != f.SHA256 {
+			t.Errorf("%s: 摘要 %s, %v", f.Path, digest, err)
+		}
+	}
+	if _, _, err := extractBundle(output, outputDir); err == nil {
+		t.Error("目标目录已存在时应返回错误")
+	}
+}
+
+// writeRawBundle 按给定的包清单与条目内容构造备份包, 用于模拟损坏或被修改的包
+func writeRawBundle(t *testing.T, manifest bundleManifest, entries map[string]string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "raw"+bundleExt)
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	data, _ := json.Marshal(manifest)
+	writeTarEntry(tw, bundleManifestName, int64(len(data)), time.Now(), strings.NewReader(string(data)), "")
+	for name, content := range entries {
+		writeTarEntry(tw, name, int64(len(content)), time.Now(), strings.NewReader(content), "")
+	}
+	tw.Close()
+	gz.Close()
+	return p
+}
+
+func TestExtractBundleRejectsTampering(t *testing.T) {
+	root := writeTestBackup(t)
+	manifest, err := buildBundleManifest(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make(map[string]string)
+	for _, f := range manifest.Files {
+		data, _ := os.ReadFile(filepath.Join(root, filepath.FromSlash(f.Path)))
+		entries[manifest.Backup+"/"+f.Path] = string(data)
+	}
+	if _, _, err := extractBundle(writeRawBundle(t, *manifest, entries), t.TempDir()); err != nil {
+		t.Fatalf("未修改的包: %v", err)
+	}
+
+	cases := map[string]func(m *bundleManifest, entries map[string]string){
+		"内容被修改": func(m *bundleManifest, entries map[string]string) {
+			entries[m.Backup+"/shop/configmaps/settings.yaml"] = "apiVersion: v1\nkind: Secret\n"
+		},
+		"缺少文件": func(m *bundleManifest, entries map[string]string) {
+			delete(entries, m.Backup+"/shop/00-namespace.yaml")
+		},
+		"多出文件": func(m *bundleManifest, entries map[string]string) {
+			entries[m.Backup+"/shop/extra.yaml"] = "kind: Pod\n"
+		},
+		"路径越界": func(m *bundleManifest, entries map[string]string) {
+			m.Files = append(m.Files, bundleFile{Path: "../escape.yaml", Size: 1, SHA256: "x"})
+		},
+		"目录摘要不一致": func(m *bundleManifest, entries map[string]string) {
+			m.TreeDigest = strings.Repeat("0", 64)
+		},
+	}
+	for name, tamper := range cases {
+		t.Run(name, func(t *testing.T) {
+			m := *manifest
+			m.Files = append([]bundleFile(nil), manifest.Files...)
+			copied := make(map[string]string)
+			for k, v := range entries {
+				copied[k] = v
+			}
+			tamper(&m, copied)
+			outputDir := t.TempDir()
+			if _, _, err := extractBundle(writeRawBundle(t, m, copied), outputDir); err == nil {
+				t.Fatal("应返回错误")
+			}
+			if left, _ := os.ReadDir(outputDir); len(left) != 0 {
+				t.Errorf("校验失败后留下了 %d 个目录项", len(left))
+			}
+		})
+	}
+}
+
+func TestBundleAttestationMismatch(t *testing.T) {
+	root := writeTestBackup(t)
+	if err := writeBackupAttestation(root, backupMetadata{}, time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// attestation 之后修改了备份内容
+	os.WriteFile(filepath.Join(root, "shop/configmaps/settings.yaml"), []byte("changed\n"), 0644)
+	output := filepath.Join(t.TempDir(), "backup"+bundleExt)
+	if _, _, err := writeBundle(root, output); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := extractBundle(output, ""); err == nil || !strings.Contains(err.Error(), attestationFileName) {
+		t.Errorf("err = %v, 应报告与 attestation 不一致", err)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+)
+
+// catalogEntry 目录中的一次备份
+type catalogEntry struct {
+	Path string // 相对扫描目录的路径
+	Meta *backupMetadata
+}
+
+// listOptions list 子命令的参数
+type listOptions struct {
+	kubeconfig   string
+	kubeContext  string
+	checkCluster bool
+}
+
+// runList 实现 list 子命令: 列出目录中的全部备份及其来源集群, 可标出与当前集群不同的备份
+func runList(args []string) {
+	var opts listOptions
+	fs := pflag.NewFlagSet("list", pflag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: k8s-backup list [参数] [备份输出目录]\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.kubeconfig, "kubeconfig", "", "kubeconfig文件路径 (默认使用~/.kube/config)")
+	fs.StringVar(&opts.kubeContext, "context", "", "kubeconfig中的上下文名称 (默认使用当前上下文)")
+	fs.BoolVar(&opts.checkCluster, "check-cluster", false, "与当前集群比较, 标出来自其他集群的备份")
+	fs.Parse(args)
+
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+	entries, err := scanBackupCatalog(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 扫描备份目录失败: %v\n", err)
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(os.Stderr, "'%s' 中没有找到备份\n", dir)
+		return
+	}
+
+	var current *clusterFingerprint
+	if opts.checkCluster {
+		config, err := loadClientConfig(opts.kubeconfig, opts.kubeContext)
+		if err == nil {
+			var clientset *kubernetes.Clientset
+			if clientset, err = kubernetes.NewForConfig(config); err == nil {
+				fp := collectFingerprint(config, clientset)
+				current = &fp
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 无法读取当前集群信息, 跳过集群比较: %v\n", err)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "备份\t时间\t资源数\t命名空间数\tKubernetes版本\t集群UID"
+	if current != nil {
+		header += "\t当前集群"
+	}
+	fmt.Fprintln(w, header)
+	mismatched := 0
+	for _, e := range entries {
+		var fp clusterFingerprint
+		if e.Meta.Cluster != nil {
+			fp = *e.Meta.Cluster
+		}
+		line := fmt.Sprintf("%s\t%s\t%d\t%d\t%s\t%s", e.Path, e.Meta.Timestamp, e.Meta.TotalResources, len(e.Meta.Namespaces),
+			orDash(fp.KubernetesVersion), orDash(fp.ClusterUID))
+		if current != nil {
+			switch {
+			case e.Meta.Cluster == nil || fp.empty():
+				line += "\t未知"
+			case fp.mismatch(*current) != "":
+				line += "\t否 !!"
+				mismatched++
+			default:
+				line += "\t是"
+			}
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+	if mismatched > 0 {
+		fmt.Fprintf(os.Stderr, "\n警告: %d 个备份来自其他集群, 恢复到当前集群前请确认这是有意的迁移\n", mismatched)
+	}
+}
+
+// scanBackupCatalog 查找 dir 下包含 metadata.yaml 的备份目录 (含 --partition-by-label 与 --shard 生成的子目录), 按时间排序
+func scanBackupCatalog(dir string) ([]catalogEntry, error) {
+	var entries []catalogEntry
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if !strings.HasPrefix(d.Name(), backupDirPrefix) {
+			// 分区与分片目录最多嵌套两层
+			if rel, _ := filepath.Rel(dir, path); strings.Count(filepath.ToSlash(rel), "/") >= 2 {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		meta, err := loadBackupMetadata(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 读取 '%s' 的元数据失败: %v\n", path, err)
+			return filepath.SkipDir
+		}
+		if meta != nil {
+			rel, _ := filepath.Rel(dir, path)
+			entries = append(entries, catalogEntry{Path: filepath.ToSlash(rel), Meta: meta})
+		}
+		return filepath.SkipDir
+	})
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Meta.Timestamp != entries[j].Meta.Timestamp {
+			return entries[i].Meta.Timestamp < entries[j].Meta.Timestamp
+		}
+		return entries[i].Path < entries[j].Path
+	})
+	return entries, err
+}
+
+// orDash 空字符串显示为 -
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanBackupCatalog(t *testing.T) {
+	dir := t.TempDir()
+	write := func(rel, timestamp string) {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeYAMLFile(filepath.Join(path, metadataFileName), backupMetadata{Timestamp: timestamp}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("k8s-backup-20260102-020000", "2026-01-02T02:00:00Z")
+	write("k8s-backup-20260101-020000", "2026-01-01T02:00:00Z")
+	write("team-a/k8s-backup-20260101-030000", "2026-01-01T03:00:00Z")
+	os.MkdirAll(filepath.Join(dir, "k8s-backup-20251231-020000"), 0755) // 没有元数据, 不计入
+
+	entries, err := scanBackupCatalog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Path)
+	}
+	want := []string{"k8s-backup-20260101-020000", "team-a/k8s-backup-20260101-030000", "k8s-backup-20260102-020000"}
+	if len(got) != len(want) {
+		t.Fatalf("备份列表 = %v, 期望 %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("备份列表 = %v, 期望 %v", got, want)
+			break
+		}
+	}
+}
+
+func TestClusterFingerprintMismatch(t *testing.T) {
+	a := clusterFingerprint{ServerHash: "aaa", ClusterUID: "uid-1"}
+	cases := []struct {
+		name     string
+		other    clusterFingerprint
+		mismatch bool
+	}{
+		{"same uid, different server", clusterFingerprint{ServerHash: "bbb", ClusterUID: "uid-1"}, false},
+		{"different uid", clusterFingerprint{ServerHash: "aaa", ClusterUID: "uid-2"}, true},
+		{"no uid, different server", clusterFingerprint{ServerHash: "bbb"}, true},
+		{"unknown target", clusterFingerprint{}, false},
+	}
+	for _, tc := range cases {
+		if got := a.mismatch(tc.other) != ""; got != tc.mismatch {
+			t.Errorf("%s: mismatch = %v, 期望 %v", tc.name, got, tc.mismatch)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package clean
+
+// jobGeneratedLabels Job 控制器写入 Job 及其 Pod 模板的标签, 值为源集群中 Job 的 uid
+// 恢复时新建的 Job uid 不同, 保留这些标签会使 API server 拒绝该 Job (选择器与模板标签不匹配)
+var jobGeneratedLabels = []string{"controller-uid", "batch.kubernetes.io/controller-uid"}
+
+// stripJobGeneratedFields 移除 Job 规约中由 API server 根据 Job uid 生成的字段:
+// 自动生成的 spec.selector (spec.manualSelector 为 true 时由用户指定, 保留) 与模板中的 controller-uid 标签
+// 恢复时 API server 会按新 Job 的 uid 重新生成; CronJob 的 jobTemplate.spec 同样适用
+func stripJobGeneratedFields(spec map[string]interface{}) {
+	if manual, _ := spec["manualSelector"].(bool); !manual {
+		delete(spec, "selector")
+	}
+	template, _ := spec["template"].(map[string]interface{})
+	metadata, _ := template["metadata"].(map[string]interface{})
+	stripJobGeneratedLabels(metadata)
+}
+
+// stripJobGeneratedLabels 从 metadata.labels 中移除 Job 控制器写入的 uid 标签, 清理后为空时移除 labels
+func stripJobGeneratedLabels(metadata map[string]interface{}) {
+	labels, ok := metadata["labels"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, key := range jobGeneratedLabels {
+		delete(labels, key)
+	}
+	if len(labels) == 0 {
+		delete(metadata, "labels")
+	}
+}
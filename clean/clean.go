@@ -0,0 +1,196 @@
+// Package clean 实现备份清单的清理规则: 移除集群生成的字段, 过滤系统生成的 Secret
+package clean
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Resource 清理资源中对恢复无用或有害的字段
+func Resource(resource map[string]interface{}, opts Options) map[string]interface{} {
+	if resource == nil {
+		return nil
+	}
+
+	// 移除顶层状态信息
+	delete(resource, "status")
+
+	// 按 managedFields 移除控制器持有的字段, 需在 managedFields 被清理之前执行
+	if len(opts.ControllerManagers) > 0 {
+		stripControllerFields(resource, opts.ControllerManagers)
+	}
+
+	// 在清理 annotations 之前确定 nodePort 策略, 避免注解被移除后无法读取
+	stripPorts := shouldStripNodePorts(resource, opts)
+
+	// 移除与源集群绑定的证书/身份字段 (需在清理 annotations 之前执行, 以便移除后为空的 annotations 被一并清理)
+	kind, _ := resource["kind"].(string)
+	stripClusterCertFields(resource, kind, opts)
+	lastApplied, hadLastApplied := topLevelAnnotation(resource, LastAppliedAnnotation)
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	resourceVersion, _ := metadata["resourceVersion"].(string)
+
+	// --- 递归清理函数定义 ---
+	// 定义一个可重用的函数来清理任何 metadata 块
+	var cleanMetadata func(map[string]interface{})
+	cleanMetadata = func(metadata map[string]interface{}) {
+		if metadata == nil {
+			return
+		}
+
+		// 移除所有由Kubernetes自动生成的元数据字段
+		for _, field := range []string{
+			"creationTimestamp", "resourceVersion", "selfLink", "uid",
+			"managedFields", "generation",
+		} {
+			delete(metadata, field)
+		}
+
+		// 清理annotations
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			// 将需要移除的 annotations key 加入列表
+			for _, keyToRemove := range []string{
+				LastAppliedAnnotation,
+				"deployment.kubernetes.io/revision",
+				"rollout.argoproj.io/revision",
+				"kubesphere.io/restartedAt",
+				"logging.kubesphere.io/logsidecar-config",
+			} {
+				delete(annotations, keyToRemove)
+			}
+			// 如果清理后为空，则移除整个annotations字段
+			if len(annotations) == 0 {
+				delete(metadata, "annotations")
+			}
+		}
+	}
+
+	// 清理顶层 metadata
+	if metadata, ok := resource["metadata"].(map[string]interface{}); ok {
+		cleanMetadata(metadata)
+	}
+
+	// 清理 Pod 模板中的 metadata
+	if spec, ok := resource["spec"].(map[string]interface{}); ok {
+		// 清理 Deployment, StatefulSet, Job 等资源的 template.metadata
+		if template, ok := spec["template"].(map[string]interface{}); ok {
+			if templateMetadata, ok := template["metadata"].(map[string]interface{}); ok {
+				cleanMetadata(templateMetadata) // 复用清理函数
+			}
+		}
+		// 清理 CronJob 资源的 jobTemplate.metadata 与 jobTemplate.spec.template.metadata
+		if jobTemplate, ok := spec["jobTemplate"].(map[string]interface{}); ok {
+			if jobMetadata, ok := jobTemplate["metadata"].(map[string]interface{}); ok {
+				cleanMetadata(jobMetadata)
+				if len(jobMetadata) == 0 {
+					delete(jobTemplate, "metadata")
+				}
+			}
+			if jobSpec, ok := jobTemplate["spec"].(map[string]interface{}); ok {
+				if template, ok := jobSpec["template"].(map[string]interface{}); ok {
+					if templateMetadata, ok := template["metadata"].(map[string]interface{}); ok {
+						cleanMetadata(templateMetadata) // 复用清理函数
+					}
+				}
+			}
+		}
+	}
+
+	// 根据资源类型进行特定字段的清理
+	if spec, specOK := resource["spec"].(map[string]interface{}); specOK {
+		switch kind {
+		case "Service":
+			for _, field := range []string{"clusterIP", "clusterIPs", "ipFamilies", "ipFamilyPolicy"} {
+				delete(spec, field)
+			}
+			if stripPorts {
+				stripNodePorts(spec)
+			}
+		case "Deployment", "StatefulSet", "Rollout":
+			if opts.StripReplicas {
+				delete(spec, "replicas")
+			}
+		case "Job":
+			stripJobGeneratedFields(spec)
+			if metadata, ok := resource["metadata"].(map[string]interface{}); ok {
+				stripJobGeneratedLabels(metadata)
+			}
+		case "CronJob":
+			jobTemplate, _ := spec["jobTemplate"].(map[string]interface{})
+			if jobSpec, ok := jobTemplate["spec"].(map[string]interface{}); ok {
+				stripJobGeneratedFields(jobSpec)
+			}
+		case "PersistentVolume":
+			delete(spec, "claimRef")
+		case "PersistentVolumeClaim":
+			delete(spec, "volumeName")
+		case "ServiceAccount":
+			delete(resource, "secrets")
+		case "Route":
+			stripGeneratedRouteHost(resource, spec)
+		case "HorizontalPodAutoscaler":
+			stripHPAStatusAnnotations(resource)
+		case "Ingress":
+			stripIngressAnnotations(resource, opts.IngressStripAnnotations, opts.IngressKeepAnnotations)
+		}
+	}
+
+	dropExcludedKeys(resource, kind, opts.ExcludeKeys)
+	applyPatchRules(resource, kind, opts.PatchRules)
+	if kind == "Secret" && opts.SecretStringData {
+		secretDataToStringData(resource)
+	}
+	applyLastAppliedPolicy(resource, lastApplied, hadLastApplied, opts)
+	// 来源注解在生成 last-applied 之后写入, 不进入 regenerate 生成的注解内容
+	if opts.Origin != nil {
+		stampOrigin(resource, opts.Origin, resourceVersion)
+	}
+	return resource
+}
+
+// ShouldBackupSecret 判断Secret是否需要备份，过滤掉系统生成的Secret
+func ShouldBackupSecret(secretObj map[string]interface{}) bool {
+	metadata, ok := secretObj["metadata"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	name, _ := metadata["name"].(string)
+	secretType, _ := secretObj["type"].(string)
+
+	// 跳过由各类控制器或系统默认生成的Secret
+	if strings.HasPrefix(name, "default-token-") ||
+		strings.HasPrefix(name, "sh.helm.release.v1.") ||
+		(strings.Contains(name, "-token-") && secretType == string(corev1.SecretTypeServiceAccountToken)) {
+		return false
+	}
+
+	// 跳过特定类型的Secret
+	excludedTypes := map[string]struct{}{
+		string(corev1.SecretTypeServiceAccountToken): {},
+		"helm.sh/release.v1":                         {},
+	}
+	if _, found := excludedTypes[secretType]; found {
+		return false
+	}
+
+	return true
+}
+
+// NormalizeStringMapValues 标准化ConfigMap中的字符串值，处理换行和转义
+func NormalizeStringMapValues(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	processed := make(map[string]interface{})
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			s = strings.ReplaceAll(s, "\r\n", "\n")
+			s = strings.ReplaceAll(s, "\\n", "\n")
+			processed[k] = s
+		} else {
+			processed[k] = v
+		}
+	}
+	return processed
+}
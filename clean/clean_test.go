@@ -0,0 +1,350 @@
+package clean
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "用当前输出重写 testdata 中的 golden 文件")
+
+// TestResourceGolden 对 testdata/<input>.in.yaml 执行清理, 与 testdata/<name>.golden.yaml 比较
+// 修改清理规则后运行 go test ./clean -update 重新生成, 并在代码评审中检查 golden 文件的差异
+func TestResourceGolden(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		opts  Options
+	}{
+		{name: "deployment", input: "deployment"},
+		{name: "deployment-strip-replicas", input: "deployment", opts: Options{StripReplicas: true}},
+		{name: "deployment-last-applied-preserve", input: "deployment", opts: Options{LastApplied: LastAppliedPreserve}},
+		{name: "deployment-last-applied-regenerate", input: "deployment", opts: Options{LastApplied: LastAppliedRegenerate}},
+		{name: "service", input: "service"},
+		{name: "service-strip-nodeports", input: "service", opts: Options{StripNodePorts: true}},
+		{name: "service-annotated-keep", input: "service-annotated", opts: Options{StripNodePorts: true}},
+		{name: "cronjob", input: "cronjob"},
+		{name: "job", input: "job"},
+		{name: "job-manual-selector", input: "job-manual-selector"},
+		{name: "persistentvolume", input: "persistentvolume"},
+		{name: "persistentvolumeclaim", input: "persistentvolumeclaim"},
+		{name: "serviceaccount", input: "serviceaccount"},
+		{name: "webhook", input: "webhook"},
+		{name: "webhook-keep-certs", input: "webhook", opts: Options{KeepCertKinds: ParseKindSet("ValidatingWebhookConfiguration")}},
+		{name: "secret-sa", input: "secret-sa"},
+		{name: "pod", input: "pod"},
+		{name: "route", input: "route"},
+		{name: "hpa", input: "hpa"},
+		{name: "ingress", input: "ingress", opts: Options{IngressStripAnnotations: DefaultIngressStripAnnotations}},
+		{name: "ingress-keep", input: "ingress", opts: Options{
+			IngressStripAnnotations: []string{"ingress.kubernetes.io/*", "nginx.ingress.kubernetes.io/*"},
+			IngressKeepAnnotations:  []string{"nginx.ingress.kubernetes.io/proxy-*", "ingress.kubernetes.io/static-ip"},
+		}},
+		{name: "deployment-patch-rules", input: "deployment-injected", opts: Options{PatchRules: []PatchRule{
+			{Kind: "Deployment", Name: "web*", Patch: []PatchOperation{
+				{Op: "test", Path: "/spec/template/spec/containers/0/env/1/name", Value: "OTEL_EXPORTER_OTLP_ENDPOINT"},
+				{Op: "remove", Path: "/spec/template/spec/containers/0/env/1"},
+				{Op: "replace", Path: "/spec/template/spec/containers/0/env/0/value", Value: "debug"},
+			}},
+			// test 不成立, 整条规则不生效
+			{Kind: "Deployment", Patch: []PatchOperation{
+				{Op: "test", Path: "/spec/template/spec/containers/0/env/0/name", Value: "INJECTED"},
+				{Op: "remove", Path: "/spec/template/spec/containers/0/env/0"},
+			}},
+			{Kind: "StatefulSet", Patch: []PatchOperation{{Op: "remove", Path: "/spec/replicas"}}},
+		}}},
+		{name: "deployment-managed", input: "deployment-managed"},
+		{name: "deployment-strip-controller-fields", input: "deployment-managed", opts: Options{ControllerManagers: DefaultControllerManagers}},
+		{name: "deployment-strip-controller-fields-extra", input: "deployment-managed", opts: Options{ControllerManagers: []string{"kube-controller-manager", "sidecar-injector"}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tc.input+".in.yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var obj map[string]interface{}
+			if err := yaml.Unmarshal(data, &obj); err != nil {
+				t.Fatal(err)
+			}
+			got, err := yaml.Marshal(Resource(obj, tc.opts))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			goldenPath := filepath.Join("testdata", tc.name+".golden.yaml")
+			if *update {
+				if err := os.WriteFile(goldenPath, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("读取 golden 文件失败 (首次添加用例时使用 -update 生成): %v", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("清理结果与 %s 不一致\n--- 实际 ---\n%s\n--- 期望 ---\n%s", goldenPath, got, want)
+			}
+		})
+	}
+}
+
+func TestShouldBackupSecret(t *testing.T) {
+	cases := []struct {
+		name       string
+		secretName string
+		secretType string
+		want       bool
+	}{
+		{"opaque", "db-password", "Opaque", true},
+		{"tls", "web-tls", "kubernetes.io/tls", true},
+		{"legacy default token", "default-token-x7k2p", "kubernetes.io/service-account-token", false},
+		{"service account token", "builder-token-abcde", "kubernetes.io/service-account-token", false},
+		{"token-like name but opaque", "api-token-store", "Opaque", true},
+		{"helm release by name", "sh.helm.release.v1.web.v3", "Opaque", false},
+		{"helm release by type", "release-web", "helm.sh/release.v1", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			secret := map[string]interface{}{
+				"metadata": map[string]interface{}{"name": tc.secretName},
+				"type":     tc.secretType,
+			}
+			if got := ShouldBackupSecret(secret); got != tc.want {
+				t.Errorf("ShouldBackupSecret(%s, %s) = %v, 期望 %v", tc.secretName, tc.secretType, got, tc.want)
+			}
+		})
+	}
+	if ShouldBackupSecret(map[string]interface{}{}) {
+		t.Error("缺少 metadata 的 Secret 不应被备份")
+	}
+}
+
+func TestNormalizeStringMapValues(t *testing.T) {
+	got := NormalizeStringMapValues(map[string]interface{}{
+		"crlf":    "a\r\nb",
+		"escaped": `line1\nline2`,
+		"other":   42,
+	})
+	want := map[string]interface{}{"crlf": "a\nb", "escaped": "line1\nline2", "other": 42}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, 期望 %q", k, got[k], v)
+		}
+	}
+	if NormalizeStringMapValues(nil) != nil {
+		t.Error("nil 输入应返回 nil")
+	}
+}
+
+func TestSystemManagedReason(t *testing.T) {
+	obj := func(kind, name string, fields map[string]interface{}) map[string]interface{} {
+		o := map[string]interface{}{"kind": kind, "metadata": map[string]interface{}{"name": name}}
+		for k, v := range fields {
+			o[k] = v
+		}
+		return o
+	}
+	leaderMeta := map[string]interface{}{
+		"name":        "ingress-controller-leader",
+		"annotations": map[string]interface{}{leaderAnnotation: `{"holderIdentity":"a"}`},
+	}
+	cases := []struct {
+		name   string
+		obj    map[string]interface{}
+		system bool
+	}{
+		{"root ca", obj("ConfigMap", "kube-root-ca.crt", nil), true},
+		{"service ca", obj("ConfigMap", "openshift-service-ca.crt", nil), true},
+		{"leader configmap", obj("ConfigMap", "", map[string]interface{}{"metadata": leaderMeta}), true},
+		{"user configmap", obj("ConfigMap", "settings", nil), false},
+		{"leader lease", obj("Lease", "controller", map[string]interface{}{"spec": map[string]interface{}{"holderIdentity": "pod-a"}}), true},
+		{"default sa", obj("ServiceAccount", "default", map[string]interface{}{
+			"secrets": []interface{}{map[string]interface{}{"name": "default-token-x7k2p"}},
+		}), true},
+		{"default sa with pull secret", obj("ServiceAccount", "default", map[string]interface{}{
+			"imagePullSecrets": []interface{}{map[string]interface{}{"name": "registry"}},
+		}), false},
+		{"default sa without automount", obj("ServiceAccount", "default", map[string]interface{}{"automountServiceAccountToken": false}), false},
+		{"openshift default sa", obj("ServiceAccount", "default", map[string]interface{}{
+			"metadata":         map[string]interface{}{"name": "default", "annotations": map[string]interface{}{"openshift.io/internal-registry-pull-secret-ref": "default-dockercfg-9xk2w"}},
+			"secrets":          []interface{}{map[string]interface{}{"name": "default-dockercfg-9xk2w"}},
+			"imagePullSecrets": []interface{}{map[string]interface{}{"name": "default-dockercfg-9xk2w"}},
+		}), true},
+		{"openshift default sa with pull secret", obj("ServiceAccount", "default", map[string]interface{}{
+			"imagePullSecrets": []interface{}{map[string]interface{}{"name": "default-dockercfg-9xk2w"}, map[string]interface{}{"name": "registry"}},
+		}), false},
+		{"other sa", obj("ServiceAccount", "builder", nil), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SystemManagedReason(tc.obj) != ""; got != tc.system {
+				t.Errorf("SystemManagedReason = %v, 期望 %v", got, tc.system)
+			}
+		})
+	}
+}
+
+func TestExcludeKeys(t *testing.T) {
+	rules, err := ParseKeyRules("ca.crt, secrets:*.pem, ConfigMap/istio-*:root-cert.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 || rules[1].Kind != "Secret" || rules[2].Name != "istio-*" {
+		t.Fatalf("规则 = %+v", rules)
+	}
+	for _, bad := range []string{"Deployment:ca.crt", "Secret:", "Secret:[a-"} {
+		if _, err := ParseKeyRules(bad); err == nil {
+			t.Errorf("规则 %q 应返回错误", bad)
+		}
+	}
+
+	configMap := func(name string) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": "v1", "kind": "ConfigMap",
+			"metadata": map[string]interface{}{"name": name},
+			"data":     map[string]interface{}{"ca.crt": "x", "root-cert.pem": "y", "app.conf": "z"},
+		}
+	}
+	opts := Options{ExcludeKeys: rules}
+	got := Resource(configMap("istio-ca-root-cert"), opts)
+	if data := got["data"].(map[string]interface{}); len(data) != 1 || data["app.conf"] != "z" {
+		t.Errorf("istio ConfigMap data = %v", data)
+	}
+	got = Resource(configMap("settings"), opts)
+	if data := got["data"].(map[string]interface{}); len(data) != 2 || data["root-cert.pem"] != "y" {
+		t.Errorf("名称不匹配的规则不应生效: %v", data)
+	}
+
+	secret := map[string]interface{}{
+		"apiVersion": "v1", "kind": "Secret",
+		"metadata": map[string]interface{}{"name": "tls"},
+		"data":     map[string]interface{}{"tls.pem": "a", "ca.crt": "b"},
+	}
+	got = Resource(secret, opts)
+	if _, ok := got["data"]; ok {
+		t.Errorf("全部键被移除后应删除 data 字段: %v", got["data"])
+	}
+}
+
+func TestSecretStringData(t *testing.T) {
+	secret := map[string]interface{}{
+		"apiVersion": "v1", "kind": "Secret",
+		"metadata": map[string]interface{}{"name": "app"},
+		"data": map[string]interface{}{
+			"password": "czNjcjN0",     // s3cr3t
+			"keystore": "/+7/AA==",     // 非 UTF-8 二进制
+			"invalid":  "not base64!!", // 无法解码, 原样保留
+		},
+	}
+	got := Resource(secret, Options{SecretStringData: true})
+	stringData, _ := got["stringData"].(map[string]interface{})
+	data, _ := got["data"].(map[string]interface{})
+	if len(stringData) != 1 || stringData["password"] != "s3cr3t" {
+		t.Errorf("stringData = %v", stringData)
+	}
+	if len(data) != 2 || data["keystore"] != "/+7/AA==" || data["invalid"] != "not base64!!" {
+		t.Errorf("data = %v", data)
+	}
+
+	text := map[string]interface{}{
+		"apiVersion": "v1", "kind": "Secret",
+		"metadata": map[string]interface{}{"name": "text"},
+		"data":     map[string]interface{}{"token": "YWJj"},
+	}
+	if got := Resource(text, Options{SecretStringData: true}); got["data"] != nil {
+		t.Errorf("全部值可读时应移除 data: %v", got)
+	}
+	unchanged := Resource(map[string]interface{}{
+		"kind": "Secret", "metadata": map[string]interface{}{"name": "x"},
+		"data": map[string]interface{}{"token": "YWJj"},
+	}, Options{})
+	if unchanged["stringData"] != nil {
+		t.Error("未开启 SecretStringData 时不应改写")
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	cm := map[string]interface{}{
+		"apiVersion": "v1", "kind": "ConfigMap",
+		"metadata": map[string]interface{}{"name": "app", "resourceVersion": "4711"},
+	}
+	origin := &Origin{Cluster: "prod-eu", BackupTime: "2026-10-15T08:00:00Z"}
+	got := Resource(cm, Options{Origin: origin, LastApplied: LastAppliedRegenerate})
+	metadata := got["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	if annotations[AnnotationSourceCluster] != "prod-eu" || annotations[AnnotationBackupTime] != origin.BackupTime || annotations[AnnotationSourceResourceVersion] != "4711" {
+		t.Errorf("来源注解 = %v", annotations)
+	}
+	if _, ok := metadata["resourceVersion"]; ok {
+		t.Error("resourceVersion 仍应被移除")
+	}
+	if lastApplied, _ := annotations[LastAppliedAnnotation].(string); strings.Contains(lastApplied, AnnotationBackupTime) {
+		t.Errorf("regenerate 生成的 last-applied 不应包含来源注解: %s", lastApplied)
+	}
+
+	delete(annotations, LastAppliedAnnotation)
+	if !StripOrigin(got) {
+		t.Fatal("StripOrigin 应返回 true")
+	}
+	if _, ok := metadata["annotations"]; ok {
+		t.Errorf("移除后为空的 annotations 应删除: %v", metadata)
+	}
+	if StripOrigin(got) {
+		t.Error("没有来源注解时应返回 false")
+	}
+}
+
+func TestParseAnnotationPatterns(t *testing.T) {
+	patterns, err := ParseAnnotationPatterns(" ingress.kubernetes.io/*, ,nginx.ingress.kubernetes.io/proxy-body-size")
+	if err != nil || len(patterns) != 2 || patterns[0] != "ingress.kubernetes.io/*" {
+		t.Errorf("patterns = %v, err = %v", patterns, err)
+	}
+	if _, err := ParseAnnotationPatterns("ingress.kubernetes.io/[a"); err == nil {
+		t.Error("无效的通配符应被拒绝")
+	}
+}
+
+func TestParsePatchRules(t *testing.T) {
+	rules, err := ParsePatchRules([]byte(`
+- kind: Deployment
+  name: web-*
+  patch:
+    - {op: test, path: /metadata/annotations/sidecar.istio.io~1status, value: injected}
+    - {op: remove, path: /metadata/annotations/sidecar.istio.io~1status}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || len(rules[0].Patch) != 2 || !rules[0].matches("Deployment", "web-1") || rules[0].matches("Deployment", "api") {
+		t.Errorf("规则 = %+v", rules)
+	}
+
+	obj := map[string]interface{}{
+		"kind":     "Deployment",
+		"metadata": map[string]interface{}{"name": "web-1", "annotations": map[string]interface{}{"sidecar.istio.io/status": "injected", "team": "a"}},
+	}
+	if applied := applyPatchRules(obj, "Deployment", rules); applied != 1 {
+		t.Fatalf("生效的规则数 = %d", applied)
+	}
+	if annotations := obj["metadata"].(map[string]interface{})["annotations"].(map[string]interface{}); len(annotations) != 1 {
+		t.Errorf("注解 = %v", annotations)
+	}
+
+	for _, bad := range []string{
+		"kind: Deployment",
+		"- patch: [{op: remove, path: /spec}]",
+		"- {kind: Deployment, patch: [{op: add, path: /spec}]}",
+		"- {kind: Deployment, patch: [{op: remove, path: spec}]}",
+		"- {kind: Deployment, name: '[', patch: [{op: remove, path: /spec}]}",
+	} {
+		if _, err := ParsePatchRules([]byte(bad)); err == nil {
+			t.Errorf("ParsePatchRules(%q) 应返回错误", bad)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package clean
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// DefaultIngressStripAnnotations Options.IngressStripAnnotations 的默认值: Ingress 控制器回写的状态类注解
+// 如 GCE 控制器记录的后端健康状态与负载均衡器资源名, Rancher 记录的公开地址; 每次同步都可能变化, 恢复到其他集群后也不再有效
+var DefaultIngressStripAnnotations = []string{
+	"ingress.kubernetes.io/backends",
+	"ingress.kubernetes.io/forwarding-rule",
+	"ingress.kubernetes.io/https-forwarding-rule",
+	"ingress.kubernetes.io/target-proxy",
+	"ingress.kubernetes.io/https-target-proxy",
+	"ingress.kubernetes.io/url-map",
+	"ingress.kubernetes.io/ssl-cert",
+	"ingress.kubernetes.io/static-ip",
+	"ingress.kubernetes.io/firewall-rule",
+	"field.cattle.io/publicEndpoints",
+}
+
+// ParseAnnotationPatterns 解析逗号分隔的注解名模式, 支持 path.Match 通配符 (如 nginx.ingress.kubernetes.io/*)
+func ParseAnnotationPatterns(s string) ([]string, error) {
+	var patterns []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		if _, err := path.Match(item, ""); err != nil {
+			return nil, fmt.Errorf("注解模式 '%s' 中的通配符无效: %w", item, err)
+		}
+		patterns = append(patterns, item)
+	}
+	return patterns, nil
+}
+
+// stripIngressAnnotations 移除 Ingress 中匹配 strip 且不匹配 keep 的注解, 清理后为空时移除 annotations
+func stripIngressAnnotations(resource map[string]interface{}, strip, keep []string) {
+	if len(strip) == 0 {
+		return
+	}
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key := range annotations {
+		if matchesAnyPattern(key, strip) && !matchesAnyPattern(key, keep) {
+			delete(annotations, key)
+		}
+	}
+	if len(annotations) == 0 {
+		delete(metadata, "annotations")
+	}
+}
+
+// matchesAnyPattern 判断 name 是否匹配任一 path.Match 模式
+func matchesAnyPattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
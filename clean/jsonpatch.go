@@ -0,0 +1,227 @@
+package clean
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PatchRule 对指定类型的对象应用的 RFC 6902 JSON Patch, 用于按字段路径精确清理, 如移除 webhook 注入的某个环境变量
+// 补丁作为整体应用: 任一操作失败 (路径不存在, test 不成立) 时该对象保持不变, 因此可以先以 test 确认列表元素再 remove
+type PatchRule struct {
+	Kind  string           `yaml:"kind"`           // 对象的 Kind, "*" 表示全部类型
+	Name  string           `yaml:"name,omitempty"` // 对象名称, 支持 path.Match 通配符, 为空时匹配全部
+	Patch []PatchOperation `yaml:"patch"`
+}
+
+// PatchOperation 一个 JSON Patch 操作, 支持 remove, replace 与 test
+type PatchOperation struct {
+	Op    string      `yaml:"op"`
+	Path  string      `yaml:"path"`
+	Value interface{} `yaml:"value,omitempty"`
+}
+
+// ParsePatchRules 解析 YAML 格式的补丁规则列表, 例如:
+//
+//	# 确认第一个环境变量由 webhook 注入后将其移除
+//	- kind: Deployment
+//	  name: web-*
+//	  patch:
+//	    - {op: test, path: /spec/template/spec/containers/0/env/0/name, value: INJECTED_BY_WEBHOOK}
+//	    - {op: remove, path: /spec/template/spec/containers/0/env/0}
+func ParsePatchRules(data []byte) ([]PatchRule, error) {
+	var rules []PatchRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("补丁规则不是合法的 YAML 列表: %w", err)
+	}
+	for i, rule := range rules {
+		if rule.Kind == "" || len(rule.Patch) == 0 {
+			return nil, fmt.Errorf("第 %d 条补丁规则缺少 kind 或 patch", i+1)
+		}
+		if _, err := path.Match(rule.Name, ""); err != nil {
+			return nil, fmt.Errorf("第 %d 条补丁规则的名称通配符无效: %w", i+1, err)
+		}
+		for _, op := range rule.Patch {
+			switch op.Op {
+			case "remove", "replace", "test":
+			default:
+				return nil, fmt.Errorf("第 %d 条补丁规则: 不支持的操作 '%s' (可选: remove, replace, test)", i+1, op.Op)
+			}
+			if _, err := parseJSONPointer(op.Path); err != nil || op.Path == "" {
+				return nil, fmt.Errorf("第 %d 条补丁规则: 无效的路径 '%s'", i+1, op.Path)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// matches 判断规则是否作用于指定对象
+func (r PatchRule) matches(kind, name string) bool {
+	if r.Kind != "*" && r.Kind != kind {
+		return false
+	}
+	if r.Name == "" {
+		return true
+	}
+	ok, _ := path.Match(r.Name, name)
+	return ok
+}
+
+// applyPatchRules 依次应用匹配的补丁规则, 返回实际生效的规则数
+func applyPatchRules(resource map[string]interface{}, kind string, rules []PatchRule) int {
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	applied := 0
+	for _, rule := range rules {
+		if !rule.matches(kind, name) {
+			continue
+		}
+		patched := copyJSONValue(resource).(map[string]interface{})
+		if applyJSONPatch(patched, rule.Patch) != nil {
+			continue
+		}
+		for k := range resource {
+			delete(resource, k)
+		}
+		for k, v := range patched {
+			resource[k] = v
+		}
+		applied++
+	}
+	return applied
+}
+
+// applyJSONPatch 在 doc 上原地执行补丁, 出错时 doc 可能已被部分修改, 调用方应传入副本
+func applyJSONPatch(doc map[string]interface{}, ops []PatchOperation) error {
+	for _, op := range ops {
+		tokens, err := parseJSONPointer(op.Path)
+		if err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			return fmt.Errorf("不能对文档根执行 %s", op.Op)
+		}
+		parent, err := resolveJSONPointer(doc, tokens[:len(tokens)-1])
+		if err != nil {
+			return err
+		}
+		last := tokens[len(tokens)-1]
+		switch container := parent.(type) {
+		case map[string]interface{}:
+			current, ok := container[last]
+			if !ok {
+				return fmt.Errorf("路径 %s 不存在", op.Path)
+			}
+			switch op.Op {
+			case "remove":
+				delete(container, last)
+			case "replace":
+				container[last] = op.Value
+			case "test":
+				if !jsonEqual(current, op.Value) {
+					return fmt.Errorf("test %s 不成立", op.Path)
+				}
+			}
+		case []interface{}:
+			index, err := strconv.Atoi(last)
+			if err != nil || index < 0 || index >= len(container) {
+				return fmt.Errorf("路径 %s 的列表下标无效", op.Path)
+			}
+			switch op.Op {
+			case "remove":
+				// 列表长度变化, 需要写回父对象
+				list := append(container[:index:index], container[index+1:]...)
+				if err := setJSONPointer(doc, tokens[:len(tokens)-1], list); err != nil {
+					return err
+				}
+			case "replace":
+				container[index] = op.Value
+			case "test":
+				if !jsonEqual(container[index], op.Value) {
+					return fmt.Errorf("test %s 不成立", op.Path)
+				}
+			}
+		default:
+			return fmt.Errorf("路径 %s 的上级不是对象或列表", op.Path)
+		}
+	}
+	return nil
+}
+
+// parseJSONPointer 解析 RFC 6901 JSON Pointer, "" 表示文档根
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON Pointer '%s' 应以 / 开头", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// resolveJSONPointer 返回路径指向的值
+func resolveJSONPointer(doc interface{}, tokens []string) (interface{}, error) {
+	current := doc
+	for _, t := range tokens {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			next, ok := c[t]
+			if !ok {
+				return nil, fmt.Errorf("路径 /%s 不存在", strings.Join(tokens, "/"))
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(t)
+			if err != nil || index < 0 || index >= len(c) {
+				return nil, fmt.Errorf("路径 /%s 的列表下标无效", strings.Join(tokens, "/"))
+			}
+			current = c[index]
+		default:
+			return nil, fmt.Errorf("路径 /%s 不存在", strings.Join(tokens, "/"))
+		}
+	}
+	return current, nil
+}
+
+// setJSONPointer 将路径指向的值替换为 value, 路径必须已存在且不为文档根
+func setJSONPointer(doc map[string]interface{}, tokens []string, value interface{}) error {
+	parent, err := resolveJSONPointer(doc, tokens[:len(tokens)-1])
+	if err != nil {
+		return err
+	}
+	last := tokens[len(tokens)-1]
+	switch c := parent.(type) {
+	case map[string]interface{}:
+		c[last] = value
+	case []interface{}:
+		index, _ := strconv.Atoi(last)
+		c[index] = value
+	}
+	return nil
+}
+
+// copyJSONValue 深拷贝由 map 与列表组成的值, 标量原样保留
+func copyJSONValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			m[k] = copyJSONValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(x))
+		for i, e := range x {
+			l[i] = copyJSONValue(e)
+		}
+		return l
+	}
+	return v
+}
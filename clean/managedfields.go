@@ -0,0 +1,188 @@
+package clean
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// DefaultControllerManagers Options.ControllerManagers 的默认值 (managedFields 中 manager 名称的前缀)
+// kube-controller-manager 包括 HPA 经 scale 子资源写入的 spec.replicas; cert-manager 包括 cainjector 注入的 caBundle
+// 与 cert-manager 写入 Secret 的 cert-manager.io/* 注解
+var DefaultControllerManagers = []string{"kube-controller-manager", "cert-manager"}
+
+// stripControllerFields 根据 metadata.managedFields 移除只由控制器写入的字段:
+// 属于 manager 名称以 managers 中任一前缀开头的条目, 且不被其他 manager (kubectl, helm, GitOps 工具等) 同时持有的叶子字段
+// 恢复后的清单以 kubectl apply --server-side 应用时不再声明这些字段的所有权, 避免与目标集群中的控制器冲突或来回改写
+// 必须在清理 managedFields 之前调用; 返回移除的字段数
+func stripControllerFields(resource map[string]interface{}, managers []string) int {
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	entries, _ := metadata["managedFields"].([]interface{})
+	var controller, others []map[string]interface{}
+	for _, e := range entries {
+		entry, _ := e.(map[string]interface{})
+		fields, ok := entry["fieldsV1"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		manager, _ := entry["manager"].(string)
+		if isControllerManager(manager, managers) {
+			controller = append(controller, fields)
+		} else {
+			others = append(others, fields)
+		}
+	}
+	removed := 0
+	for _, fields := range controller {
+		removed += stripOwnedFields(resource, fields, nil, others)
+	}
+	return removed
+}
+
+// stripOwnedFields 遍历控制器的 FieldsV1 集合, 删除其他 manager 未引用的叶子字段;
+// 控制器持有 (含 ".") 且其他 manager 未引用的列表元素整体删除, 如注入的 env 条目
+func stripOwnedFields(resource, fields map[string]interface{}, prefix []string, others []map[string]interface{}) int {
+	removed := 0
+	for key, child := range fields {
+		if key == "." {
+			continue
+		}
+		path := append(append([]string(nil), prefix...), key)
+		sub, _ := child.(map[string]interface{})
+		_, ownsItem := sub["."]
+		leaf := len(sub) == 0 || (len(sub) == 1 && ownsItem)
+		listItem := strings.HasPrefix(key, "k:") || strings.HasPrefix(key, "v:")
+		if !fieldsContain(others, path) && (leaf || (ownsItem && listItem)) {
+			if removeManagedPath(resource, path) {
+				removed++
+			}
+			continue
+		}
+		if !leaf {
+			removed += stripOwnedFields(resource, sub, path, others)
+		}
+	}
+	return removed
+}
+
+func isControllerManager(manager string, managers []string) bool {
+	for _, prefix := range managers {
+		if prefix != "" && strings.HasPrefix(manager, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldsContain 判断路径是否出现在任一 FieldsV1 集合中 (作为叶子或其下仍有字段)
+func fieldsContain(sets []map[string]interface{}, path []string) bool {
+	for _, fields := range sets {
+		node := fields
+		found := true
+		for _, key := range path {
+			child, ok := node[key]
+			if !ok {
+				found = false
+				break
+			}
+			node, _ = child.(map[string]interface{})
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// removeManagedPath 按 FieldsV1 路径删除对象中的字段, 删除后变为空的 map 一并删除; 字段不存在时返回 false
+func removeManagedPath(obj map[string]interface{}, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	key := path[0]
+	if !strings.HasPrefix(key, "f:") {
+		return false // 对象本身只能以字段名寻址
+	}
+	name := key[2:]
+	value, ok := obj[name]
+	if !ok {
+		return false
+	}
+	if len(path) == 1 {
+		delete(obj, name)
+		return true
+	}
+	var removed bool
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if removed = removeManagedPath(v, path[1:]); removed && len(v) == 0 {
+			delete(obj, name)
+		}
+	case []interface{}:
+		var list []interface{}
+		if list, removed = removeManagedListPath(v, path[1:]); removed {
+			if len(list) == 0 {
+				delete(obj, name)
+			} else {
+				obj[name] = list
+			}
+		}
+	}
+	return removed
+}
+
+// removeManagedListPath 按 "k:{...}" (按键字段匹配元素) 或 "v:..." (集合中的值) 删除列表元素或其中的字段
+func removeManagedListPath(list []interface{}, path []string) ([]interface{}, bool) {
+	key := path[0]
+	var match func(interface{}) bool
+	switch {
+	case strings.HasPrefix(key, "k:"):
+		var want map[string]interface{}
+		if json.Unmarshal([]byte(key[2:]), &want) != nil {
+			return list, false
+		}
+		match = func(item interface{}) bool {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			for field, v := range want {
+				if !jsonEqual(m[field], v) {
+					return false
+				}
+			}
+			return true
+		}
+	case strings.HasPrefix(key, "v:"):
+		var want interface{}
+		if json.Unmarshal([]byte(key[2:]), &want) != nil {
+			return list, false
+		}
+		match = func(item interface{}) bool { return jsonEqual(item, want) }
+	default:
+		return list, false
+	}
+	removed := false
+	kept := list[:0:0]
+	for _, item := range list {
+		if !match(item) {
+			kept = append(kept, item)
+			continue
+		}
+		if len(path) == 1 {
+			removed = true
+			continue
+		}
+		if m, ok := item.(map[string]interface{}); ok && removeManagedPath(m, path[1:]) {
+			removed = true
+		}
+		kept = append(kept, item)
+	}
+	return kept, removed
+}
+
+// jsonEqual 以 JSON 编码比较两个值, 使 int64 与 float64 表示的同一数字相等
+func jsonEqual(a, b interface{}) bool {
+	x, err1 := json.Marshal(a)
+	y, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && string(x) == string(y)
+}
@@ -0,0 +1,396 @@
+package clean
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// kubectl 用于三方合并的注解
+const LastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// --last-applied 的可选策略
+const (
+	LastAppliedStrip      = "strip"      // 移除 (默认行为)
+	LastAppliedPreserve   = "preserve"   // 原样保留集群中的值
+	LastAppliedRegenerate = "regenerate" // 根据清理后的对象重新生成
+)
+
+// Options 控制 Resource 中可按需开关的清理规则
+type Options struct {
+	// KeepCertKinds 中列出的资源类型保留集群专属的证书/身份字段 (键为 Kind, "all" 表示全部保留)
+	KeepCertKinds map[string]bool
+	// LastApplied 控制 last-applied-configuration 注解的处理方式, 为空时等同于 LastAppliedStrip
+	LastApplied string
+	// StripReplicas 移除 Deployment/StatefulSet/Rollout 的 spec.replicas, 交由恢复后的 HPA 或运维决定副本数
+	StripReplicas bool
+	// StripNodePorts 移除 Service 的 nodePort/healthCheckNodePort, 可被 Service 上的 k8s-back.io/nodeports 注解覆盖
+	StripNodePorts bool
+	// SecretStringData 将 Secret 中解码后为合法 UTF-8 的值改写到 stringData, 使加密或 sealed 后的备份在 git diff 中可读
+	// 二进制值仍保留在 data 中; 恢复时 API server 会将 stringData 合并回 data
+	SecretStringData bool
+	// ExcludeKeys 从 ConfigMap/Secret 中移除的键, 用于对象中个别由程序维护的键 (如注入的 ca.crt)
+	ExcludeKeys []KeyRule
+	// Origin 非空时在清单的顶层注解中记录来源集群, 备份时间与源对象的 resourceVersion,
+	// 使单独找到的清单文件可以自我描述; 恢复时可用 StripOrigin 移除
+	Origin *Origin
+	// ControllerManagers 非空时根据 managedFields 移除只由这些控制器 (manager 名称前缀) 持有的字段,
+	// 如 HPA 调整的 spec.replicas, 使清单可以直接 kubectl apply --server-side 而不与控制器争夺所有权
+	ControllerManagers []string
+	// IngressStripAnnotations 从 Ingress 中移除的注解名模式 (支持通配符), 用于控制器回写的状态类注解, 避免其污染备份的差异
+	// IngressKeepAnnotations 中的模式优先, 用于在较宽的移除模式中保留需要的注解 (如 nginx 的调优注解)
+	IngressStripAnnotations []string
+	IngressKeepAnnotations  []string
+	// PatchRules 在其他清理规则之后按类型与名称应用的 JSON Patch, 用于内置规则无法覆盖的个别字段
+	PatchRules []PatchRule
+}
+
+// Origin 写入清单的来源信息
+type Origin struct {
+	Cluster    string
+	BackupTime string // RFC3339, 同一次备份的全部对象相同
+}
+
+// 来源注解, 由 Options.Origin 写入
+const (
+	AnnotationSourceCluster         = "k8s-back.io/source-cluster"
+	AnnotationBackupTime            = "k8s-back.io/backup-time"
+	AnnotationSourceResourceVersion = "k8s-back.io/source-resource-version"
+)
+
+// OriginAnnotations 全部来源注解
+var OriginAnnotations = []string{AnnotationSourceCluster, AnnotationBackupTime, AnnotationSourceResourceVersion}
+
+// AnnotationNodePorts Service 级别的 nodePort 处理策略注解, 取值 keep 或 strip
+const AnnotationNodePorts = "k8s-back.io/nodeports"
+
+// shouldStripNodePorts 判断 Service 是否需要移除 nodePort, 注解优先于命令行默认值
+func shouldStripNodePorts(resource map[string]interface{}, opts Options) bool {
+	switch value, _ := topLevelAnnotation(resource, AnnotationNodePorts); value {
+	case "keep":
+		return false
+	case "strip":
+		return true
+	}
+	return opts.StripNodePorts
+}
+
+// stripNodePorts 移除 Service 中由集群分配的节点端口
+func stripNodePorts(spec map[string]interface{}) {
+	delete(spec, "healthCheckNodePort")
+	removeFieldPath(spec, []string{"ports", "[]", "nodePort"})
+}
+
+// routeHostGeneratedAnnotation OpenShift 路由器为未指定 host 的 Route 生成域名时添加的注解
+const routeHostGeneratedAnnotation = "openshift.io/host.generated"
+
+// stripGeneratedRouteHost 移除由源集群路由器生成的 Route 域名, 恢复后由目标集群按其默认域名重新生成
+// 用户显式指定的 host 没有该注解, 原样保留
+func stripGeneratedRouteHost(resource, spec map[string]interface{}) {
+	if value, _ := topLevelAnnotation(resource, routeHostGeneratedAnnotation); value != "true" {
+		return
+	}
+	delete(spec, "host")
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	delete(annotations, routeHostGeneratedAnnotation)
+	if len(annotations) == 0 {
+		delete(metadata, "annotations")
+	}
+}
+
+// hpaStatusAnnotations 以 autoscaling/v1 读取 HPA 时 API server 将 v2 状态写入的注解, 属于状态而非配置
+// 恢复时会被目标集群的 HPA 控制器重新计算; 保留则在目标集群以不同版本应用时可能校验失败
+var hpaStatusAnnotations = []string{
+	"autoscaling.alpha.kubernetes.io/conditions",
+	"autoscaling.alpha.kubernetes.io/current-metrics",
+}
+
+// stripHPAStatusAnnotations 移除 HPA 中记录状态的注解
+func stripHPAStatusAnnotations(resource map[string]interface{}) {
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, key := range hpaStatusAnnotations {
+		delete(annotations, key)
+	}
+	if len(annotations) == 0 {
+		delete(metadata, "annotations")
+	}
+}
+
+// ValidateLastAppliedPolicy 校验 --last-applied 参数
+func ValidateLastAppliedPolicy(policy string) error {
+	switch policy {
+	case LastAppliedStrip, LastAppliedPreserve, LastAppliedRegenerate:
+		return nil
+	default:
+		return fmt.Errorf("不支持的 last-applied 策略 '%s' (可选: %s, %s, %s)",
+			policy, LastAppliedStrip, LastAppliedPreserve, LastAppliedRegenerate)
+	}
+}
+
+// topLevelAnnotation 读取顶层 metadata.annotations 中的值
+func topLevelAnnotation(resource map[string]interface{}, key string) (string, bool) {
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	value, ok := annotations[key].(string)
+	return value, ok
+}
+
+// setTopLevelAnnotation 设置顶层 metadata.annotations 中的值
+func setTopLevelAnnotation(resource map[string]interface{}, key, value string) {
+	metadata, ok := resource["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		resource["metadata"] = metadata
+	}
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		annotations = map[string]interface{}{}
+		metadata["annotations"] = annotations
+	}
+	annotations[key] = value
+}
+
+// stampOrigin 写入来源注解, resourceVersion 为清理前对象的值
+func stampOrigin(resource map[string]interface{}, origin *Origin, resourceVersion string) {
+	if origin.Cluster != "" {
+		setTopLevelAnnotation(resource, AnnotationSourceCluster, origin.Cluster)
+	}
+	setTopLevelAnnotation(resource, AnnotationBackupTime, origin.BackupTime)
+	if resourceVersion != "" {
+		setTopLevelAnnotation(resource, AnnotationSourceResourceVersion, resourceVersion)
+	}
+}
+
+// StripOrigin 移除对象上的来源注解, 返回是否有注解被移除
+func StripOrigin(resource map[string]interface{}) bool {
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	removed := false
+	for _, key := range OriginAnnotations {
+		if _, ok := annotations[key]; ok {
+			delete(annotations, key)
+			removed = true
+		}
+	}
+	if removed && len(annotations) == 0 {
+		delete(metadata, "annotations")
+	}
+	return removed
+}
+
+// applyLastAppliedPolicy 在常规清理之后按策略恢复或重新生成 last-applied-configuration
+// original 为清理前集群中的注解值
+func applyLastAppliedPolicy(resource map[string]interface{}, original string, hadOriginal bool, opts Options) {
+	switch opts.LastApplied {
+	case LastAppliedPreserve:
+		if hadOriginal {
+			setTopLevelAnnotation(resource, LastAppliedAnnotation, original)
+		}
+	case LastAppliedRegenerate:
+		// 与 kubectl 一致: 内容为不含该注解本身的对象 JSON, 末尾带换行
+		data, err := json.Marshal(resource)
+		if err != nil {
+			return
+		}
+		setTopLevelAnnotation(resource, LastAppliedAnnotation, string(data)+"\n")
+	}
+}
+
+// clusterCertFields 各资源类型中嵌入集群专属证书或身份信息的字段路径, "[]" 表示遍历列表的每个元素
+// 这些值由目标集群的控制器或 cert 注入器重新生成, 原样恢复会导致 TLS 校验失败或引用错误的对象
+var clusterCertFields = map[string][][]string{
+	"MutatingWebhookConfiguration": {
+		{"webhooks", "[]", "clientConfig", "caBundle"},
+	},
+	"ValidatingWebhookConfiguration": {
+		{"webhooks", "[]", "clientConfig", "caBundle"},
+	},
+	"CustomResourceDefinition": {
+		{"spec", "conversion", "webhook", "clientConfig", "caBundle"},
+		{"spec", "conversion", "webhookClientConfig", "caBundle"}, // apiextensions.k8s.io/v1beta1
+	},
+	"APIService": {
+		{"spec", "caBundle"},
+	},
+	"Secret": {
+		{"metadata", "annotations", "kubernetes.io/service-account.uid"},
+	},
+}
+
+// KeyRule 一条键排除规则, Name 与 Key 支持 path.Match 通配符
+type KeyRule struct {
+	Kind string // ConfigMap 或 Secret, 为空时两者都匹配
+	Name string // 对象名称, 为空时匹配全部
+	Key  string
+}
+
+// keyRuleFields 各类型中存放键值的字段
+var keyRuleFields = map[string][]string{
+	"ConfigMap": {"data", "binaryData"},
+	"Secret":    {"data", "stringData"},
+}
+
+// ParseKeyRules 解析逗号分隔的键排除规则, 格式为 [Kind[/名称]:]键, 如 ca.crt, Secret:*.pem, ConfigMap/istio-*:root-cert.pem
+func ParseKeyRules(s string) ([]KeyRule, error) {
+	var rules []KeyRule
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		var rule KeyRule
+		target, key, scoped := strings.Cut(item, ":")
+		if !scoped {
+			target, key = "", item
+		}
+		rule.Kind, rule.Name, _ = strings.Cut(target, "/")
+		rule.Key = key
+		if scoped {
+			kind, ok := canonicalKeyRuleKind(rule.Kind)
+			if !ok {
+				return nil, fmt.Errorf("键排除规则 '%s' 的类型应为 ConfigMap 或 Secret", item)
+			}
+			rule.Kind = kind
+		}
+		for _, pattern := range []string{rule.Name, rule.Key} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("键排除规则 '%s' 中的通配符无效: %w", item, err)
+			}
+		}
+		if rule.Key == "" {
+			return nil, fmt.Errorf("键排除规则 '%s' 缺少键名", item)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// canonicalKeyRuleKind 接受 configmap/configmaps 等写法, 返回规范的 Kind
+func canonicalKeyRuleKind(kind string) (string, bool) {
+	for canonical := range keyRuleFields {
+		if strings.EqualFold(kind, canonical) || strings.EqualFold(kind, canonical+"s") {
+			return canonical, true
+		}
+	}
+	return "", false
+}
+
+// matches 判断规则是否作用于指定对象
+func (r KeyRule) matches(kind, name string) bool {
+	if r.Kind != "" && r.Kind != kind {
+		return false
+	}
+	if r.Name == "" {
+		return true
+	}
+	ok, _ := path.Match(r.Name, name)
+	return ok
+}
+
+// dropExcludedKeys 按 rules 移除 ConfigMap/Secret 中的键, 移除后为空的字段一并删除
+func dropExcludedKeys(resource map[string]interface{}, kind string, rules []KeyRule) {
+	fields, ok := keyRuleFields[kind]
+	if !ok || len(rules) == 0 {
+		return
+	}
+	metadata, _ := resource["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	for _, rule := range rules {
+		if !rule.matches(kind, name) {
+			continue
+		}
+		for _, field := range fields {
+			data, _ := resource[field].(map[string]interface{})
+			removed := false
+			for key := range data {
+				if ok, _ := path.Match(rule.Key, key); ok {
+					delete(data, key)
+					removed = true
+				}
+			}
+			if removed && len(data) == 0 {
+				delete(resource, field)
+			}
+		}
+	}
+}
+
+// secretDataToStringData 按 SecretStringData 将可读的值从 data 移到 stringData
+func secretDataToStringData(resource map[string]interface{}) {
+	data, _ := resource["data"].(map[string]interface{})
+	if len(data) == 0 {
+		return
+	}
+	stringData, _ := resource["stringData"].(map[string]interface{})
+	for key, v := range data {
+		encoded, _ := v.(string)
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || !utf8.Valid(decoded) {
+			continue
+		}
+		if stringData == nil {
+			stringData = make(map[string]interface{})
+		}
+		stringData[key] = string(decoded)
+		delete(data, key)
+	}
+	if stringData != nil {
+		resource["stringData"] = stringData
+	}
+	if len(data) == 0 {
+		delete(resource, "data")
+	}
+}
+
+// ParseKindSet 解析逗号分隔的 Kind 列表
+func ParseKindSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, kind := range strings.Split(s, ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			set[kind] = true
+		}
+	}
+	return set
+}
+
+// stripClusterCertFields 按 clusterCertFields 移除资源中与源集群绑定的证书字段
+func stripClusterCertFields(resource map[string]interface{}, kind string, opts Options) {
+	if opts.KeepCertKinds["all"] || opts.KeepCertKinds[kind] {
+		return
+	}
+	for _, path := range clusterCertFields[kind] {
+		removeFieldPath(resource, path)
+	}
+}
+
+// removeFieldPath 删除嵌套字段, 路径中的 "[]" 会对列表中每个元素继续匹配
+func removeFieldPath(obj map[string]interface{}, path []string) {
+	if len(path) == 0 || obj == nil {
+		return
+	}
+	if len(path) == 1 {
+		delete(obj, path[0])
+		return
+	}
+	next := obj[path[0]]
+	if len(path) > 2 && path[1] == "[]" {
+		if list, ok := next.([]interface{}); ok {
+			for _, item := range list {
+				if m, ok := item.(map[string]interface{}); ok {
+					removeFieldPath(m, path[2:])
+				}
+			}
+		}
+		return
+	}
+	if m, ok := next.(map[string]interface{}); ok {
+		removeFieldPath(m, path[1:])
+	}
+}
@@ -0,0 +1,80 @@
+package clean
+
+import "strings"
+
+// leaderAnnotation 旧版 client-go 基于 ConfigMap/Endpoints 的选主锁记录
+const leaderAnnotation = "control-plane.alpha.kubernetes.io/leader"
+
+// generatedServiceAccountAnnotations 集群组件写入 ServiceAccount 的注解, 不视为用户配置
+var generatedServiceAccountAnnotations = map[string]bool{
+	"openshift.io/internal-registry-pull-secret-ref": true,
+}
+
+// systemConfigMaps 由集群组件在每个命名空间自动创建的 ConfigMap
+var systemConfigMaps = map[string]string{
+	"kube-root-ca.crt":         "集群 CA 证书, 由 kube-controller-manager 自动发布",
+	"openshift-service-ca.crt": "OpenShift service-ca 证书, 由 service-ca operator 自动发布",
+}
+
+// SystemManagedReason 判断对象是否属于内置排除清单 (控制器自动生成, 恢复后会重新生成的对象), 是则返回原因, 否则返回空字符串
+// 清单包括: 集群 CA ConfigMap, OpenShift service-ca ConfigMap, 选主用的 ConfigMap 与 Lease, 没有额外配置的 default ServiceAccount
+func SystemManagedReason(obj map[string]interface{}) string {
+	kind, _ := obj["kind"].(string)
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+
+	switch kind {
+	case "ConfigMap":
+		if reason, ok := systemConfigMaps[name]; ok {
+			return reason
+		}
+		if _, ok := annotations[leaderAnnotation]; ok {
+			return "选主锁"
+		}
+	case "Lease":
+		spec, _ := obj["spec"].(map[string]interface{})
+		if _, ok := spec["holderIdentity"]; ok {
+			return "选主锁"
+		}
+	case "ServiceAccount":
+		if name == "default" && isPristineServiceAccount(obj, metadata) {
+			return "未经修改的 default ServiceAccount, 由命名空间控制器自动创建"
+		}
+	}
+	return ""
+}
+
+// isPristineServiceAccount 判断 ServiceAccount 是否没有任何用户配置: 无标签, 无 (集群组件写入之外的) 注解,
+// 未设置 automountServiceAccountToken, secrets 与 imagePullSecrets 中只有自动生成的令牌与 OpenShift 镜像仓库凭据
+func isPristineServiceAccount(obj, metadata map[string]interface{}) bool {
+	if labels, _ := metadata["labels"].(map[string]interface{}); len(labels) > 0 {
+		return false
+	}
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	for key := range annotations {
+		if !generatedServiceAccountAnnotations[key] {
+			return false
+		}
+	}
+	if _, ok := obj["automountServiceAccountToken"]; ok {
+		return false
+	}
+	name, _ := metadata["name"].(string)
+	for _, field := range []string{"secrets", "imagePullSecrets"} {
+		refs, _ := obj[field].([]interface{})
+		for _, r := range refs {
+			ref, _ := r.(map[string]interface{})
+			if secret, _ := ref["name"].(string); !IsGeneratedServiceAccountSecret(name, secret) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// IsGeneratedServiceAccountSecret 判断 Secret 是否为集群为 ServiceAccount 自动生成的令牌 (<名称>-token-xxxxx, 1.24 之前)
+// 或 OpenShift 内置镜像仓库凭据 (<名称>-dockercfg-xxxxx); 这些 Secret 在目标集群中会以不同的名称重新生成
+func IsGeneratedServiceAccountSecret(serviceAccount, secret string) bool {
+	return strings.HasPrefix(secret, serviceAccount+"-token-") || strings.HasPrefix(secret, serviceAccount+"-dockercfg-")
+}
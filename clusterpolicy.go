@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"backup-k8s/clean"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// defaultClusterConfig 集群管理员统一下发备份策略的 ConfigMap (<命名空间>/<名称>)
+const defaultClusterConfig = "k8s-back/k8s-back-config"
+
+// 集群策略 ConfigMap 中的键
+const (
+	policyKeyExcludeNamespaces = "exclude-namespaces" // 逗号分隔, 始终排除的命名空间, 支持通配符
+	policyKeyExcludeTypes      = "exclude-types"      // 逗号分隔, 始终排除的资源类型
+	policyKeyExcludeSecrets    = "exclude-secrets"    // true 时不备份任何 Secret
+	policyKeyExcludeKeys       = "exclude-keys"       // 逗号分隔, 从 ConfigMap/Secret 中移除的键, 格式同 --exclude-keys
+	policyKeyPatchRules        = "patch-rules"        // YAML 列表: 按类型应用的 JSON Patch 清理规则, 格式同 --patch-rules 文件
+	policyKeyDefaults          = "defaults"           // YAML 映射: 命令行参数名 -> 未显式指定该参数时使用的值
+)
+
+// policyFixedFlags 不能通过集群策略设置默认值的参数
+var policyFixedFlags = map[string]bool{"kubeconfig": true, "cluster-config": true, "version": true}
+
+// clusterPolicy 从集群内 ConfigMap 读取的备份策略, 排除规则优先于命令行参数
+type clusterPolicy struct {
+	Source            string
+	Hash              string // ConfigMap data 的摘要, 记录到备份元数据
+	ExcludeNamespaces []string
+	ExcludeTypes      map[string]bool
+	ExcludeSecrets    bool
+	ExcludeKeys       []clean.KeyRule
+	PatchRules        []clean.PatchRule
+	Defaults          map[string]string
+}
+
+// loadClusterPolicy 读取 ref (<命名空间>/<名称>) 指定的 ConfigMap, ref 为空或 ConfigMap 不存在时返回 nil
+func loadClusterPolicy(clientset kubernetes.Interface, ref string) (*clusterPolicy, error) {
+	if ref == "" {
+		return nil, nil
+	}
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" {
+		return nil, fmt.Errorf("--cluster-config 格式应为 <命名空间>/<名称>, 实际为 '%s'", ref)
+	}
+	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取集群策略 '%s' 失败: %w", ref, err)
+	}
+	policy, err := parseClusterPolicy(cm.Data)
+	if err != nil {
+		return nil, fmt.Errorf("解析集群策略 '%s' 失败: %w", ref, err)
+	}
+	policy.Source = ref
+	policy.Hash = hashConfigData(cm.Data)
+	return policy, nil
+}
+
+// parseClusterPolicy 解析 ConfigMap 的 data
+func parseClusterPolicy(data map[string]string) (*clusterPolicy, error) {
+	p := &clusterPolicy{ExcludeTypes: make(map[string]bool), Defaults: make(map[string]string)}
+	p.ExcludeNamespaces = splitList(data[policyKeyExcludeNamespaces])
+	for _, resType := range splitList(data[policyKeyExcludeTypes]) {
+		p.ExcludeTypes[resType] = true
+	}
+	if v := strings.TrimSpace(data[policyKeyExcludeSecrets]); v != "" {
+		if v != "true" && v != "false" {
+			return nil, fmt.Errorf("%s 应为 true 或 false, 实际为 '%s'", policyKeyExcludeSecrets, v)
+		}
+		p.ExcludeSecrets = v == "true"
+	}
+	keys, err := clean.ParseKeyRules(data[policyKeyExcludeKeys])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", policyKeyExcludeKeys, err)
+	}
+	p.ExcludeKeys = keys
+	if raw := data[policyKeyPatchRules]; raw != "" {
+		rules, err := clean.ParsePatchRules([]byte(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", policyKeyPatchRules, err)
+		}
+		p.PatchRules = rules
+	}
+	if raw := data[policyKeyDefaults]; raw != "" {
+		var defaults map[string]interface{}
+		if err := yaml.Unmarshal([]byte(raw), &defaults); err != nil {
+			return nil, fmt.Errorf("%s 不是合法的YAML映射: %w", policyKeyDefaults, err)
+		}
+		for flagName, value := range defaults {
+			if policyFixedFlags[flagName] {
+				return nil, fmt.Errorf("%s 不能设置参数 --%s", policyKeyDefaults, flagName)
+			}
+			p.Defaults[flagName] = fmt.Sprint(value)
+		}
+	}
+	return p, nil
+}
+
+// applyDefaults 将策略中的默认值应用到未在命令行显式指定的参数, 返回实际生效的参数名
+func (p *clusterPolicy) applyDefaults(fs *pflag.FlagSet) ([]string, error) {
+	names := make([]string, 0, len(p.Defaults))
+	for name := range p.Defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var applied []string
+	for _, name := range names {
+		flag := fs.Lookup(name)
+		if flag == nil {
+			return applied, fmt.Errorf("%s 中的参数 --%s 不存在", policyKeyDefaults, name)
+		}
+		if flag.Changed {
+			continue
+		}
+		if err := fs.Set(name, p.Defaults[name]); err != nil {
+			return applied, fmt.Errorf("%s 中的参数 --%s 值无效: %w", policyKeyDefaults, name, err)
+		}
+		applied = append(applied, name)
+	}
+	return applied, nil
+}
+
+// excludesNamespace 判断命名空间是否被策略排除, 排除列表支持通配符 (如 kube-*)
+func (p *clusterPolicy) excludesNamespace(namespace string) bool {
+	return matchNamespacePattern(p.ExcludeNamespaces, namespace)
+}
+
+// splitList 拆分逗号分隔的列表, 忽略空白项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
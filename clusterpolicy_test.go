@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoadClusterPolicy(t *testing.T) {
+	clientset := kubefake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "k8s-back", Name: "k8s-back-config"},
+		Data: map[string]string{
+			policyKeyExcludeNamespaces: "payments, vault",
+			policyKeyExcludeTypes:      "secrets",
+			policyKeyExcludeSecrets:    "true",
+			policyKeyExcludeKeys:       "ConfigMap:ca.crt",
+			policyKeyPatchRules:        "- kind: Deployment\n  patch:\n    - {op: remove, path: /metadata/annotations/injected}\n",
+			policyKeyDefaults:          "last-applied: preserve\nstrip-replicas: true\n",
+		},
+	})
+
+	policy, err := loadClusterPolicy(clientset, defaultClusterConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !policy.excludesNamespace("vault") || policy.excludesNamespace("web") {
+		t.Errorf("排除的命名空间 = %v", policy.ExcludeNamespaces)
+	}
+	if !policy.ExcludeTypes["secrets"] || !policy.ExcludeSecrets || len(policy.ExcludeKeys) != 1 || len(policy.PatchRules) != 1 {
+		t.Errorf("策略 = %+v", policy)
+	}
+
+	var lastApplied string
+	var stripReplicas bool
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.StringVar(&lastApplied, "last-applied", "strip", "")
+	fs.BoolVar(&stripReplicas, "strip-replicas", false, "")
+	if err := fs.Parse([]string{"--last-applied", "regenerate"}); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := policy.applyDefaults(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 命令行显式指定的参数优先于策略默认值
+	if lastApplied != "regenerate" || !stripReplicas || len(applied) != 1 || applied[0] != "strip-replicas" {
+		t.Errorf("last-applied = %s, strip-replicas = %v, 生效参数 %v", lastApplied, stripReplicas, applied)
+	}
+
+	if policy, err := loadClusterPolicy(clientset, "other/missing"); policy != nil || err != nil {
+		t.Errorf("ConfigMap 不存在时应忽略, 实际 %v, %v", policy, err)
+	}
+	if _, err := loadClusterPolicy(clientset, "invalid"); err == nil {
+		t.Error("格式错误的引用应被拒绝")
+	}
+}
+
+func TestParseClusterPolicyRejectsFixedFlags(t *testing.T) {
+	if _, err := parseClusterPolicy(map[string]string{policyKeyDefaults: "kubeconfig: /tmp/x"}); err == nil {
+		t.Error("策略不应能设置 --kubeconfig")
+	}
+	if _, err := parseClusterPolicy(map[string]string{policyKeyExcludeSecrets: "yes"}); err == nil {
+		t.Error("exclude-secrets 只接受 true 或 false")
+	}
+}
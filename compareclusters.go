@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"backup-k8s/clean"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// compare-clusters 中对象的比较结果
+const (
+	driftOnlyA   = "only-a"  // 只存在于集群 A
+	driftOnlyB   = "only-b"  // 只存在于集群 B
+	driftChanged = "changed" // 两个集群中都存在, 清理后的清单不同
+)
+
+// compareOptions compare-clusters 子命令的参数
+type compareOptions struct {
+	kubeconfig     string
+	contextA       string
+	contextB       string
+	namespaces     []string
+	excludeNs      []string
+	types          string
+	includeSecrets bool
+	presets        []string
+	excludeKeys    []clean.KeyRule
+	clusterConfig  string
+	summary        bool
+	output         string
+}
+
+// clusterSnapshot 由 Backupper 读取并清理后的一个集群的对象, 对象键 -> 清单 YAML, 只保存在内存中
+type clusterSnapshot struct {
+	name      string
+	manifests map[string]string
+	objects   map[string]driftObject
+	unserved  []string // 集群不提供的可选资源类型
+}
+
+// driftObject 清单对应的对象标识
+type driftObject struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// driftEntry 两个集群之间存在差异的对象
+type driftEntry struct {
+	driftObject
+	Status string `json:"status"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+// compareCleanOptions 比较前对两侧对象执行的清理, 与默认备份相同, 另外去掉控制器回写的 Ingress 状态注解
+var compareCleanOptions = clean.Options{LastApplied: clean.LastAppliedStrip, IngressStripAnnotations: clean.DefaultIngressStripAnnotations}
+
+// runCompareClusters 实现 compare-clusters 子命令: 按备份流程在内存中读取两个集群的对象并清理, 报告两者之间的配置漂移,
+// 用于确认灾备集群与生产集群是否一致; 清单不写入文件, 不修改任何集群
+func runCompareClusters(args []string) {
+	var opts compareOptions
+	var namespace, excludeNs, presetStr, excludeKeysStr string
+	fs := pflag.NewFlagSet("compare-clusters", pflag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: k8s-backup compare-clusters --context-a <上下文> --context-b <上下文> [参数]\n")
+		fmt.Fprintf(os.Stderr, "只读: 比较两个集群中按备份规则清理后的对象, 存在差异时退出码为 1\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.kubeconfig, "kubeconfig", "", "kubeconfig文件路径 (默认使用~/.kube/config)")
+	fs.StringVar(&opts.contextA, "context-a", "", "集群 A 在 kubeconfig 中的上下文名称 (如 prod)")
+	fs.StringVar(&opts.contextB, "context-b", "", "集群 B 在 kubeconfig 中的上下文名称 (如 dr)")
+	fs.StringVarP(&namespace, "namespace", "n", "", "只比较这些命名空间 (逗号分隔), 未指定时比较所有命名空间与集群级资源")
+	fs.StringVarP(&excludeNs, "exclude-namespaces", "e", "kube-system", "需要排除的命名空间 (逗号分隔, 支持通配符, 同备份命令)")
+	fs.StringVarP(&opts.types, "type", "t", "all", "比较的资源类型 (同备份命令)")
+	fs.BoolVar(&opts.includeSecrets, "include-secrets", false, "同时比较 Secret (默认跳过, 避免在差异中输出凭据)")
+	fs.StringVar(&presetStr, "preset", "", "额外比较的资源预设 (同备份命令)")
+	fs.StringVar(&excludeKeysStr, "exclude-keys", "", "比较前从 ConfigMap/Secret 中移除的键 (同备份命令)")
+	fs.StringVar(&opts.clusterConfig, "cluster-config", defaultClusterConfig, "各集群中的备份策略 ConfigMap (同备份命令), 其排除规则同样用于比较; 为空时不读取")
+	fs.BoolVar(&opts.summary, "summary", false, "只列出存在差异的对象, 不输出 diff")
+	fs.StringVarP(&opts.output, "output", "o", "text", "输出格式 (text|json)")
+	fs.Parse(args)
+
+	// stdout 只输出比较结果, 备份过程的输出改写到 stderr
+	logOut = os.Stderr
+	if opts.contextA == "" || opts.contextB == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if opts.contextA == opts.contextB {
+		fmt.Fprintf(os.Stderr, "错误: --context-a 与 --context-b 相同 ('%s')\n", opts.contextA)
+		os.Exit(2)
+	}
+	if opts.output != "text" && opts.output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s' (可选: text, json)\n", opts.output)
+		os.Exit(2)
+	}
+	opts.namespaces, opts.excludeNs = splitList(namespace), splitList(excludeNs)
+	if _, err := newNamespaceExclusion(opts.excludeNs, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(2)
+	}
+	presets, err := parsePresets(presetStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(2)
+	}
+	opts.presets = presets
+	if opts.excludeKeys, err = clean.ParseKeyRules(excludeKeysStr); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: --exclude-keys: %v\n", err)
+		os.Exit(2)
+	}
+	enabled, err := selectedTypes(typeSelection{types: opts.types, presets: opts.presets, skipSecrets: !opts.includeSecrets, noClusterResources: len(opts.namespaces) > 0})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(2)
+	}
+	var resourceTypes []string
+	for resType, reason := range enabled {
+		if reason == "" {
+			resourceTypes = append(resourceTypes, resType)
+		}
+	}
+	sortResourceTypes(resourceTypes)
+
+	var snapshots [2]*clusterSnapshot
+	for i, kubeContext := range []string{opts.contextA, opts.contextB} {
+		fmt.Fprintf(logOut, "读取集群 %s...\n", kubeContext)
+		snapshot, err := snapshotContext(opts, kubeContext, resourceTypes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 集群 %s: %v\n", kubeContext, err)
+			os.Exit(1)
+		}
+		snapshots[i] = snapshot
+	}
+
+	entries := compareSnapshots(snapshots[0], snapshots[1], !opts.summary || opts.output == "json")
+	if opts.output == "json" {
+		if entries == nil {
+			entries = []driftEntry{}
+		}
+		data, _ := json.MarshalIndent(entries, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printClusterDrift(os.Stdout, snapshots[0], snapshots[1], entries, opts.summary)
+	}
+	if len(entries) > 0 {
+		os.Exit(1)
+	}
+}
+
+// snapshotContext 连接 kubeContext 指定的集群, 按该集群的备份策略与命令行参数配置 Backupper, 跳过集群不提供的可选类型后读取对象
+func snapshotContext(opts compareOptions, kubeContext string, resourceTypes []string) (*clusterSnapshot, error) {
+	config, err := loadClientConfig(opts.kubeconfig, kubeContext)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建标准客户端失败: %w", err)
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建发现客户端失败: %w", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("创建动态客户端失败: %w", err)
+	}
+	policy, err := loadClusterPolicy(clientset, opts.clusterConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v, 忽略集群策略\n", err)
+	}
+	cleanOpts := compareCleanOptions
+	cleanOpts.ExcludeKeys = opts.excludeKeys
+	skipSecrets, excludeNs := !opts.includeSecrets, opts.excludeNs
+	if policy != nil {
+		var allowed []string
+		for _, resType := range resourceTypes {
+			if !policy.ExcludeTypes[resType] {
+				allowed = append(allowed, resType)
+			}
+		}
+		resourceTypes = allowed
+		skipSecrets = skipSecrets || policy.ExcludeSecrets
+		excludeNs = append(append([]string{}, excludeNs...), policy.ExcludeNamespaces...)
+		cleanOpts.ExcludeKeys = append(append([]clean.KeyRule{}, cleanOpts.ExcludeKeys...), policy.ExcludeKeys...)
+		cleanOpts.PatchRules = policy.PatchRules
+	}
+	exclusion, err := newNamespaceExclusion(excludeNs, "")
+	if err != nil {
+		return nil, err
+	}
+	served, unserved := filterServedTypes(discoveryClient, resourceTypes)
+	run := &Backupper{
+		clientset:     clientset,
+		dynamicClient: dynamicClient,
+		resourceTypes: served,
+		skipSecrets:   skipSecrets,
+		cleanOpts:     cleanOpts,
+	}
+	snapshot, err := snapshotCluster(run, kubeContext, opts.namespaces, exclusion)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.unserved = unserved
+	return snapshot, nil
+}
+
+// snapshotCluster 以 run 执行一次备份, 清单写入内存而非文件, 再按索引收集为快照
+// namespaces 为空时备份 exclusion 排除之外的所有命名空间, 同时备份集群级资源
+func snapshotCluster(run *Backupper, name string, namespaces []string, exclusion *namespaceExclusion) (*clusterSnapshot, error) {
+	targets := namespaces
+	if len(namespaces) == 0 {
+		nsList, err := run.clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("获取命名空间列表失败: %w", err)
+		}
+		for _, ns := range nsList.Items {
+			if !exclusion.excludes(ns.Name, ns.Labels) {
+				targets = append(targets, ns.Name)
+			}
+		}
+	}
+	// 清单只保存在内存中; 命名空间目录与附带的报告 (如 loadbalancers.yaml) 写入临时目录, 结束后删除
+	dir, err := os.MkdirTemp("", "k8s-back-compare-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	run.allInOne = allInOneOff
+	run.sink = newMemorySink()
+	run.partitions = newPartitionSet(dir, name, "")
+	run.backupNamespaces(targets, nil, nil, func(string) {})
+	if len(namespaces) == 0 {
+		run.backupClusterResources()
+	}
+
+	snapshot := &clusterSnapshot{name: name, manifests: make(map[string]string), objects: make(map[string]driftObject)}
+	for _, p := range run.partitions.sorted() {
+		for _, s := range p.Skipped {
+			if s.Reason == skipListFailed || s.Reason == skipPermissionDenied {
+				return nil, fmt.Errorf("读取 %s 失败 (命名空间 %s): %s %s", s.Kind, s.Namespace, s.Reason, s.Detail)
+			}
+		}
+		for _, e := range p.Index {
+			data, ok := run.sink.manifest(filepath.Join(p.Root, filepath.FromSlash(e.Path)))
+			if !ok {
+				continue
+			}
+			key := objectKey(e.Kind, e.Namespace, e.Name)
+			snapshot.manifests[key] = string(data)
+			snapshot.objects[key] = driftObject{Kind: e.Kind, Namespace: e.Namespace, Name: e.Name}
+		}
+	}
+	return snapshot, nil
+}
+
+// compareSnapshots 比较两个集群的对象, 返回存在差异的对象, 按类型, 命名空间, 名称排序; withDiff 为 false 时不生成 diff
+func compareSnapshots(a, b *clusterSnapshot, withDiff bool) []driftEntry {
+	keys := make(map[string]driftObject)
+	for key, obj := range a.objects {
+		keys[key] = obj
+	}
+	for key, obj := range b.objects {
+		keys[key] = obj
+	}
+	var entries []driftEntry
+	for key, obj := range keys {
+		from, inA := a.manifests[key]
+		to, inB := b.manifests[key]
+		entry := driftEntry{driftObject: obj}
+		switch {
+		case !inB:
+			entry.Status, to = driftOnlyA, ""
+		case !inA:
+			entry.Status, from = driftOnlyB, ""
+		case from != to:
+			entry.Status = driftChanged
+		default:
+			continue
+		}
+		if withDiff {
+			entry.Diff = unifiedDiff(a.name, b.name, from, to)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		x, y := entries[i], entries[j]
+		if x.Kind != y.Kind {
+			return x.Kind < y.Kind
+		}
+		if x.Namespace != y.Namespace {
+			return x.Namespace < y.Namespace
+		}
+		return x.Name < y.Name
+	})
+	return entries
+}
+
+// printClusterDrift 输出漂移报告, summary 为 true 时不输出 diff
+func printClusterDrift(w io.Writer, a, b *clusterSnapshot, entries []driftEntry, summary bool) {
+	for _, s := range []*clusterSnapshot{a, b} {
+		if len(s.unserved) > 0 {
+			fmt.Fprintf(w, "集群 %s 不提供的可选资源类型 (未比较): %v\n", s.name, s.unserved)
+		}
+	}
+	counts := make(map[string]int)
+	for _, e := range entries {
+		counts[e.Status]++
+	}
+	fmt.Fprintf(w, "\n比较 %s (%d 个对象) 与 %s (%d 个对象): 仅 %s %d 个, 仅 %s %d 个, 不同 %d 个\n",
+		a.name, len(a.objects), b.name, len(b.objects), a.name, counts[driftOnlyA], b.name, counts[driftOnlyB], counts[driftChanged])
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "✓ 两个集群的配置一致")
+		return
+	}
+	for _, e := range entries {
+		var mark string
+		switch e.Status {
+		case driftOnlyA:
+			mark = "仅 " + a.name
+		case driftOnlyB:
+			mark = "仅 " + b.name
+		default:
+			mark = "不同"
+		}
+		fmt.Fprintf(w, "  [%s] %s\n", mark, e.describe())
+		if !summary && e.Diff != "" {
+			fmt.Fprint(w, indentLines(e.Diff, "    "))
+		}
+	}
+}
+
+func (o driftObject) describe() string {
+	if o.Namespace == "" {
+		return fmt.Sprintf("%s %s", o.Kind, o.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+}
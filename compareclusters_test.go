@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"backup-k8s/clean"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func fakeCluster(t *testing.T, name string, objects ...runtime.Object) *clusterSnapshot {
+	t.Helper()
+	run, _ := newFakeBackupper(t, []string{"configmaps", "deployments"}, nil, objects...)
+	run.cleanOpts = compareCleanOptions
+	for _, ns := range []string{"shop", "kube-public"} {
+		if _, err := run.clientset.CoreV1().Namespaces().Create(context.TODO(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}}, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exclusion, err := newNamespaceExclusion([]string{"kube-*"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := snapshotCluster(run, name, nil, exclusion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+func TestCompareClusters(t *testing.T) {
+	deployment := func(image string) map[string]interface{} {
+		return map[string]interface{}{"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "web", "image": image}},
+		}}}}
+	}
+	prod := fakeCluster(t, "prod",
+		fakeObject("apps/v1", "Deployment", "shop", "web", deployment("nginx:1.25")),
+		fakeObject("v1", "ConfigMap", "shop", "settings", map[string]interface{}{"data": map[string]interface{}{"mode": "live"}}),
+		fakeObject("v1", "ConfigMap", "shop", "prod-only", nil),
+		fakeObject("v1", "ConfigMap", "kube-public", "cluster-info", nil),
+	)
+	dr := fakeCluster(t, "dr",
+		fakeObject("apps/v1", "Deployment", "shop", "web", deployment("nginx:1.24")),
+		// uid 与 resourceVersion 不同不算漂移
+		fakeObject("v1", "ConfigMap", "shop", "settings", map[string]interface{}{"data": map[string]interface{}{"mode": "live"}, "metadata": map[string]interface{}{"name": "settings", "namespace": "shop", "uid": "other", "resourceVersion": "99"}}),
+		fakeObject("v1", "ConfigMap", "shop", "dr-only", nil),
+	)
+
+	entries := compareSnapshots(prod, dr, true)
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Status+" "+e.describe())
+	}
+	want := []string{"only-b ConfigMap shop/dr-only", "only-a ConfigMap shop/prod-only", "changed Deployment shop/web"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("差异 = %v, 期望 %v", got, want)
+	}
+	if diff := entries[2].Diff; !strings.Contains(diff, "-                - image: nginx:1.25") || !strings.Contains(diff, "+                - image: nginx:1.24") {
+		t.Errorf("diff = %s", diff)
+	}
+
+	var out bytes.Buffer
+	printClusterDrift(&out, prod, dr, entries, true)
+	if s := out.String(); !strings.Contains(s, "仅 prod 1 个, 仅 dr 1 个, 不同 1 个") || strings.Contains(s, "image:") {
+		t.Errorf("输出 = %s", s)
+	}
+	if entries := compareSnapshots(prod, prod, true); len(entries) != 0 {
+		t.Errorf("同一集群比较的差异 = %v", entries)
+	}
+}
+
+func TestSnapshotClusterUsesBackupRules(t *testing.T) {
+	run, _ := newFakeBackupper(t, []string{"configmaps"}, nil,
+		fakeObject("v1", "ConfigMap", "shop", "kube-root-ca.crt", map[string]interface{}{"data": map[string]interface{}{"ca.crt": "x"}}),
+		fakeObject("v1", "ConfigMap", "shop", "settings", map[string]interface{}{"data": map[string]interface{}{"mode": "live", "ca.crt": "x"}}),
+	)
+	rules, err := clean.ParseKeyRules("ca.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run.cleanOpts.ExcludeKeys = rules
+	snapshot, err := snapshotCluster(run, "prod", []string{"shop"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := snapshot.objects[objectKey("ConfigMap", "shop", "kube-root-ca.crt")]; ok {
+		t.Error("系统自动生成的 ConfigMap 不应出现在快照中")
+	}
+	manifest, ok := snapshot.manifests[objectKey("ConfigMap", "shop", "settings")]
+	if !ok || strings.Contains(manifest, "ca.crt") || !strings.Contains(manifest, "mode: live") {
+		t.Errorf("清单应按 --exclude-keys 移除 ca.crt: %s", manifest)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"backup-k8s/clean"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// configUsageFileName --config-usage-report 在命名空间目录写入的报告
+const configUsageFileName = "config-usage.yaml"
+
+// configUsageKinds --config-usage-report 跟踪的被引用类型
+var configUsageKinds = []string{"ConfigMap", "Secret"}
+
+// 不计为孤立对象的 Secret 类型: 由 API server 或 Helm 维护, 本就不被工作负载引用
+var configUsageIgnoredSecretTypes = map[string]bool{
+	"kubernetes.io/service-account-token": true,
+	"helm.sh/release.v1":                  true,
+}
+
+// configUsageRef 引用 ConfigMap/Secret 的对象
+type configUsageRef struct {
+	Kind string `yaml:"kind"`
+	Name string `yaml:"name"`
+	Via  string `yaml:"via"` // 引用所在的字段, 如 envFrom, volumes, imagePullSecrets
+}
+
+// configUsageObject 命名空间中的一个 ConfigMap/Secret 及引用它的对象
+type configUsageObject struct {
+	Kind     string           `yaml:"kind"`
+	Name     string           `yaml:"name"`
+	Orphaned bool             `yaml:"orphaned,omitempty"` // 未被任何已备份的对象引用
+	UsedBy   []configUsageRef `yaml:"usedBy,omitempty"`
+}
+
+// configUsageMissing 被引用但命名空间中不存在的 ConfigMap/Secret
+type configUsageMissing struct {
+	Kind         string           `yaml:"kind"`
+	Name         string           `yaml:"name"`
+	ReferencedBy []configUsageRef `yaml:"referencedBy"`
+}
+
+// configUsageReport config-usage.yaml 的内容
+type configUsageReport struct {
+	Namespace string               `yaml:"namespace"`
+	Objects   []configUsageObject  `yaml:"objects,omitempty"`
+	Missing   []configUsageMissing `yaml:"missing,omitempty"`
+	// Unchecked 本次未列出的类型 (如 --skip-secrets 时的 Secret), 对其的引用无法判断是否缺失
+	Unchecked []string `yaml:"unchecked,omitempty"`
+}
+
+// configUsageKey 命名空间内的 ConfigMap/Secret
+type configUsageKey struct {
+	Kind string
+	Name string
+}
+
+// configUsage 收集一个命名空间中 ConfigMap/Secret (及 kinds 中的其他类型) 与引用它们的工作负载等对象, 只使用备份过程中已获取的对象
+// 为空时各方法不做任何事, 对应未指定 --config-usage-report
+type configUsage struct {
+	namespace string
+	kinds     []string
+	listed    map[string]map[string]bool // Kind -> 名称 -> 是否不参与孤立判断, 只包含本次成功列出的类型
+	refs      map[configUsageKey][]configUsageRef
+	claims    map[string]configUsageRef // StatefulSet volumeClaimTemplates 生成的 PVC 名称前缀 (<模板>-<StatefulSet>-) -> StatefulSet
+}
+
+func newConfigUsage(namespace string, kinds []string) *configUsage {
+	return &configUsage{
+		namespace: namespace,
+		kinds:     kinds,
+		listed:    make(map[string]map[string]bool),
+		refs:      make(map[configUsageKey][]configUsageRef),
+		claims:    make(map[string]configUsageRef),
+	}
+}
+
+// list 记录从集群列出的全部 ConfigMap/Secret (含之后因系统生成等原因未备份的对象), 其他类型忽略
+func (u *configUsage) list(kind string, objects []unstructured.Unstructured) {
+	if u == nil || !slices.Contains(u.kinds, kind) {
+		return
+	}
+	u.listed[kind] = make(map[string]bool, len(objects))
+	for i := range objects {
+		u.exists(&objects[i])
+	}
+}
+
+// exists 记录命名空间中存在的一个被跟踪类型的对象, 所属类型视为已完整列出
+// 由其他对象拥有 (ownerReferences), 系统自动生成或为 configUsageIgnoredSecretTypes 的对象未被引用时不计为孤立
+func (u *configUsage) exists(obj *unstructured.Unstructured) {
+	kind := obj.GetKind()
+	if !slices.Contains(u.kinds, kind) {
+		return
+	}
+	if u.listed[kind] == nil {
+		u.listed[kind] = make(map[string]bool)
+	}
+	secretType, _ := obj.Object["type"].(string)
+	u.listed[kind][obj.GetName()] = len(obj.GetOwnerReferences()) > 0 || configUsageIgnoredSecretTypes[secretType] || clean.SystemManagedReason(obj.Object) != ""
+}
+
+// add 记录已备份对象对同命名空间中被跟踪类型的引用
+func (u *configUsage) add(obj map[string]interface{}) {
+	if u == nil {
+		return
+	}
+	o := &unstructured.Unstructured{Object: obj}
+	from := func(kind, name, via string) {
+		if name == "" {
+			return
+		}
+		key := configUsageKey{Kind: kind, Name: name}
+		u.refs[key] = append(u.refs[key], configUsageRef{Kind: o.GetKind(), Name: o.GetName(), Via: via})
+	}
+	for _, ref := range objectReferences(obj) {
+		if slices.Contains(u.kinds, ref.Kind) && ref.Namespace == u.namespace {
+			from(ref.Kind, ref.Name, ref.Reason)
+		}
+	}
+	if o.GetKind() == "ServiceAccount" {
+		refs, _, _ := unstructured.NestedSlice(obj, "imagePullSecrets")
+		for _, ref := range mapsOf(refs) {
+			name, _ := ref["name"].(string)
+			from("Secret", name, "imagePullSecrets")
+		}
+	}
+	if o.GetKind() == "StatefulSet" {
+		templates, _, _ := unstructured.NestedSlice(obj, "spec", "volumeClaimTemplates")
+		for _, tpl := range mapsOf(templates) {
+			name, _, _ := unstructured.NestedString(tpl, "metadata", "name")
+			if name != "" {
+				u.claims[name+"-"+o.GetName()+"-"] = configUsageRef{Kind: o.GetKind(), Name: o.GetName(), Via: "volumeClaimTemplates"}
+			}
+		}
+	}
+}
+
+// claimRefs 返回由 StatefulSet volumeClaimTemplates 创建的 PVC (<模板>-<StatefulSet>-<序号>) 的引用方
+func (u *configUsage) claimRefs(name string) []configUsageRef {
+	for prefix, ref := range u.claims {
+		if ordinal, ok := strings.CutPrefix(name, prefix); ok {
+			if _, err := strconv.Atoi(ordinal); err == nil {
+				return []configUsageRef{ref}
+			}
+		}
+	}
+	return nil
+}
+
+// report 汇总引用关系, 标出孤立对象与缺失的引用
+func (u *configUsage) report() configUsageReport {
+	report := configUsageReport{Namespace: u.namespace}
+	keys := make([]configUsageKey, 0, len(u.refs))
+	for key := range u.refs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Kind != keys[j].Kind {
+			return keys[i].Kind < keys[j].Kind
+		}
+		return keys[i].Name < keys[j].Name
+	})
+	for _, kind := range u.kinds {
+		entries, ok := u.listed[kind]
+		if !ok {
+			for _, key := range keys {
+				if key.Kind == kind {
+					report.Unchecked = append(report.Unchecked, kind)
+					break
+				}
+			}
+			continue
+		}
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			refs := u.refs[configUsageKey{Kind: kind, Name: name}]
+			if len(refs) == 0 && kind == "PersistentVolumeClaim" {
+				refs = u.claimRefs(name)
+			}
+			if len(refs) == 0 && entries[name] {
+				continue
+			}
+			report.Objects = append(report.Objects, configUsageObject{Kind: kind, Name: name, Orphaned: len(refs) == 0, UsedBy: refs})
+		}
+	}
+	for _, key := range keys {
+		if entries, ok := u.listed[key.Kind]; ok {
+			if _, exists := entries[key.Name]; !exists {
+				report.Missing = append(report.Missing, configUsageMissing{Kind: key.Kind, Name: key.Name, ReferencedBy: u.refs[key]})
+			}
+		}
+	}
+	return report
+}
+
+// orphaned 返回报告中孤立对象的个数
+func (r configUsageReport) orphaned() int {
+	n := 0
+	for _, obj := range r.Objects {
+		if obj.Orphaned {
+			n++
+		}
+	}
+	return n
+}
+
+// write 在命名空间目录写出报告并返回概要, 命名空间中没有 ConfigMap/Secret 及对其的引用时不生成文件
+func (u *configUsage) write(nsDir string) (string, error) {
+	if u == nil {
+		return "", nil
+	}
+	report := u.report()
+	if len(report.Objects) == 0 && len(report.Missing) == 0 && len(report.Unchecked) == 0 {
+		return "", nil
+	}
+	summary := fmt.Sprintf("配置引用: ConfigMap/Secret %d 个, 孤立 %d 个, 缺失的引用 %d 个", len(report.Objects), report.orphaned(), len(report.Missing))
+	return summary, writeYAMLFile(filepath.Join(nsDir, configUsageFileName), report)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigUsageReport(t *testing.T) {
+	podSpec := map[string]interface{}{
+		"imagePullSecrets": []interface{}{map[string]interface{}{"name": "registry"}},
+		"containers": []interface{}{map[string]interface{}{
+			"name":    "api",
+			"envFrom": []interface{}{map[string]interface{}{"configMapRef": map[string]interface{}{"name": "settings"}}},
+			"env": []interface{}{map[string]interface{}{"name": "TOKEN", "valueFrom": map[string]interface{}{
+				"secretKeyRef": map[string]interface{}{"name": "api-token", "key": "token"},
+			}}},
+		}},
+		"volumes": []interface{}{map[string]interface{}{"name": "certs", "secret": map[string]interface{}{"secretName": "api-certs"}}},
+	}
+	run, partitions := newFakeBackupper(t, []string{"configmaps", "secrets", "deployments"}, nil,
+		fakeObject("v1", "ConfigMap", "web", "settings", nil),
+		fakeObject("v1", "ConfigMap", "web", "legacy", nil),
+		fakeObject("v1", "ConfigMap", "web", "kube-root-ca.crt", nil),
+		fakeObject("v1", "Secret", "web", "api-token", map[string]interface{}{"type": "Opaque"}),
+		fakeObject("v1", "Secret", "web", "registry", map[string]interface{}{"type": "kubernetes.io/dockerconfigjson"}),
+		fakeObject("v1", "Secret", "web", "sh.helm.release.v1.web.v1", map[string]interface{}{"type": "helm.sh/release.v1"}),
+		fakeObject("apps/v1", "Deployment", "web", "api", map[string]interface{}{
+			"spec": map[string]interface{}{"template": map[string]interface{}{"spec": podSpec}},
+		}),
+	)
+	run.configUsage = true
+	run.backupNamespace("web", nil)
+
+	var report configUsageReport
+	if err := readYAMLFile(filepath.Join(partitions.byName[""].Root, "web", configUsageFileName), &report); err != nil {
+		t.Fatal(err)
+	}
+	usedBy := func(via string) []configUsageRef {
+		return []configUsageRef{{Kind: "Deployment", Name: "api", Via: via}}
+	}
+	wantObjects := []configUsageObject{
+		{Kind: "ConfigMap", Name: "legacy", Orphaned: true},
+		{Kind: "ConfigMap", Name: "settings", UsedBy: usedBy("envFrom")},
+		{Kind: "Secret", Name: "api-token", UsedBy: usedBy("env")},
+		{Kind: "Secret", Name: "registry", UsedBy: usedBy("imagePullSecrets")},
+	}
+	if !reflect.DeepEqual(report.Objects, wantObjects) {
+		t.Errorf("objects = %+v\n期望 %+v", report.Objects, wantObjects)
+	}
+	wantMissing := []configUsageMissing{{Kind: "Secret", Name: "api-certs", ReferencedBy: usedBy("volumes")}}
+	if !reflect.DeepEqual(report.Missing, wantMissing) {
+		t.Errorf("missing = %+v\n期望 %+v", report.Missing, wantMissing)
+	}
+	if !isReservedFile("web/" + configUsageFileName) {
+		t.Error("config-usage.yaml 不应被恢复当作资源清单")
+	}
+}
+
+func TestConfigUsageUncheckedKinds(t *testing.T) {
+	usage := newConfigUsage("web", configUsageKinds)
+	usage.list("ConfigMap", nil)
+	usage.add(fakeObject("v1", "ServiceAccount", "web", "builder", map[string]interface{}{
+		"imagePullSecrets": []interface{}{map[string]interface{}{"name": "registry"}},
+	}).Object)
+
+	report := usage.report()
+	if len(report.Missing) != 0 || !reflect.DeepEqual(report.Unchecked, []string{"Secret"}) {
+		t.Errorf("未列出 Secret 时不应报告缺失, 得到 missing=%v unchecked=%v", report.Missing, report.Unchecked)
+	}
+	var nilUsage *configUsage
+	if summary, err := nilUsage.write(t.TempDir()); summary != "" || err != nil {
+		t.Errorf("未启用时不应写出报告: %q, %v", summary, err)
+	}
+}
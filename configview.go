@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+)
+
+// 参数值的来源
+const (
+	sourceDefault = "默认值"
+	sourceFlag    = "命令行"
+	sourcePolicy  = "集群策略"
+	sourceEnv     = "环境变量"
+)
+
+// flagSetting 一个备份参数的最终取值及其来源
+type flagSetting struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+// collectFlagSettings 汇总备份参数的最终取值, policyApplied 为集群策略设置了默认值的参数; all 为 false 时只返回非默认值
+// --kubeconfig 未指定时按 client-go 的规则读取 KUBECONFIG 环境变量, 一并列出
+func collectFlagSettings(fs *pflag.FlagSet, policyApplied []string, policySource string, all bool) []flagSetting {
+	fromPolicy := make(map[string]bool, len(policyApplied))
+	for _, name := range policyApplied {
+		fromPolicy[name] = true
+	}
+	var settings []flagSetting
+	fs.VisitAll(func(f *pflag.Flag) {
+		s := flagSetting{Name: f.Name, Value: f.Value.String(), Source: sourceDefault}
+		switch {
+		case fromPolicy[f.Name]:
+			s.Source = sourcePolicy + " " + policySource
+		case f.Changed:
+			s.Source = sourceFlag
+		case f.Name == "kubeconfig" && os.Getenv("KUBECONFIG") != "":
+			s.Value, s.Source = os.Getenv("KUBECONFIG"), sourceEnv+" KUBECONFIG"
+		}
+		if all || s.Source != sourceDefault {
+			settings = append(settings, s)
+		}
+	})
+	return settings
+}
+
+// effectiveConfig config view 输出的内容: 参数取值, 以及按参数与集群状态解析出的资源类型与命名空间
+type effectiveConfig struct {
+	Settings      []flagSetting
+	Resolved      bool              // 是否解析了以下字段 (config view --effective)
+	OutputDir     string            // 展开模板变量之后
+	ResourceTypes []string          // 最终备份的资源类型
+	ExcludedTypes map[string]string // 资源类型 -> 未备份的原因
+	Namespaces    []string          // 最终备份的命名空间
+	Skips         []skipEntry       // 备份前已确定的跳过记录, 其中包含被排除的命名空间
+}
+
+// print 以表格形式输出
+func (c *effectiveConfig) print(w io.Writer) {
+	fmt.Fprintln(w, "[参数]")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  参数\t值\t来源")
+	for _, s := range c.Settings {
+		fmt.Fprintf(tw, "  --%s\t%s\t%s\n", s.Name, s.Value, s.Source)
+	}
+	tw.Flush()
+	if !c.Resolved {
+		return
+	}
+	fmt.Fprintf(w, "\n[输出目录]\n  %s\n", c.OutputDir)
+
+	fmt.Fprintf(w, "\n[资源类型] 备份 %d 种\n  %s\n", len(c.ResourceTypes), strings.Join(c.ResourceTypes, ", "))
+	if len(c.ExcludedTypes) > 0 {
+		excluded := make([]string, 0, len(c.ExcludedTypes))
+		for resType := range c.ExcludedTypes {
+			excluded = append(excluded, resType)
+		}
+		sort.Strings(excluded)
+		fmt.Fprintln(w, "  未备份:")
+		for _, resType := range excluded {
+			fmt.Fprintf(w, "    - %s (%s)\n", resType, c.ExcludedTypes[resType])
+		}
+	}
+
+	fmt.Fprintf(w, "\n[命名空间] 备份 %d 个\n  %s\n", len(c.Namespaces), strings.Join(c.Namespaces, ", "))
+	var excluded []string
+	for _, s := range c.Skips {
+		if s.Reason == skipExcludedNamespace {
+			excluded = append(excluded, s.Name)
+		}
+	}
+	if len(excluded) > 0 {
+		fmt.Fprintln(w, "  已排除 (--exclude-namespaces, --exclude-namespace-selector 或集群策略):")
+		fmt.Fprintf(w, "    %s\n", strings.Join(excluded, ", "))
+	}
+}
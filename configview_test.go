@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestConfigView(t *testing.T) {
+	t.Setenv("KUBECONFIG", "/etc/k8s/admin.conf")
+	fs := pflag.NewFlagSet("backup", pflag.ContinueOnError)
+	fs.String("kubeconfig", "", "")
+	fs.String("namespace", "all", "")
+	fs.String("exclude-namespaces", "kube-system", "")
+	fs.Bool("skip-secrets", false, "")
+	if err := fs.Parse([]string{"--exclude-namespaces", "kube-*"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Set("skip-secrets", "true"); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := collectFlagSettings(fs, []string{"skip-secrets"}, "k8s-back/k8s-back-config", false)
+	got := make(map[string]flagSetting)
+	for _, s := range settings {
+		got[s.Name] = s
+	}
+	if len(settings) != 3 {
+		t.Errorf("只应列出非默认值, 实际 %v", settings)
+	}
+	if got["exclude-namespaces"].Source != sourceFlag || got["exclude-namespaces"].Value != "kube-*" {
+		t.Errorf("命令行参数 = %+v", got["exclude-namespaces"])
+	}
+	if got["skip-secrets"].Source != sourcePolicy+" k8s-back/k8s-back-config" {
+		t.Errorf("集群策略参数 = %+v", got["skip-secrets"])
+	}
+	if got["kubeconfig"].Value != "/etc/k8s/admin.conf" || got["kubeconfig"].Source != sourceEnv+" KUBECONFIG" {
+		t.Errorf("环境变量参数 = %+v", got["kubeconfig"])
+	}
+	if all := collectFlagSettings(fs, nil, "", true); len(all) != 4 {
+		t.Errorf("--effective 应列出全部参数, 实际 %d 个", len(all))
+	}
+
+	view := effectiveConfig{
+		Settings:      settings,
+		Resolved:      true,
+		OutputDir:     "/backups/k8s-backup-20240101-020000",
+		ResourceTypes: []string{"configmaps", "deployments"},
+		ExcludedTypes: map[string]string{"routes": "集群不提供该 API"},
+		Namespaces:    []string{"web"},
+		Skips:         []skipEntry{{Reason: skipExcludedNamespace, Kind: "Namespace", Name: "kube-system"}},
+	}
+	var buf bytes.Buffer
+	view.print(&buf)
+	for _, want := range []string{"--exclude-namespaces", "configmaps, deployments", "routes (集群不提供该 API)", "kube-system"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("输出缺少 %q:\n%s", want, buf.String())
+		}
+	}
+}
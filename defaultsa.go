@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"backup-k8s/clean"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// isDefaultServiceAccount 判断对象是否为命名空间的 default ServiceAccount
+// 备份只包含带有用户配置的 default ServiceAccount (见 clean.SystemManagedReason); 目标命名空间创建时控制器已自动创建同名对象,
+// 因此恢复时不能简单地因已存在而跳过
+func isDefaultServiceAccount(obj *unstructured.Unstructured) bool {
+	return obj.GetKind() == "ServiceAccount" && obj.GetName() == "default"
+}
+
+// defaultServiceAccountPatch 计算将备份中的配置合并到目标集群现有 default ServiceAccount 的 merge patch, 无需修改时返回 nil
+// 合并标签, 注解与 automountServiceAccountToken; imagePullSecrets 取两者并集, 保留目标集群自动生成的凭据,
+// 不加入源集群自动生成的凭据 (在目标集群中不存在)
+func defaultServiceAccountPatch(existing, desired *unstructured.Unstructured) ([]byte, error) {
+	patch := make(map[string]interface{})
+	metadata := make(map[string]interface{})
+	for field, values := range map[string]map[string]string{"labels": desired.GetLabels(), "annotations": desired.GetAnnotations()} {
+		current := existing.GetLabels()
+		if field == "annotations" {
+			current = existing.GetAnnotations()
+		}
+		changed := make(map[string]interface{})
+		for k, v := range values {
+			if cur, ok := current[k]; !ok || cur != v {
+				changed[k] = v
+			}
+		}
+		if len(changed) > 0 {
+			metadata[field] = changed
+		}
+	}
+	if len(metadata) > 0 {
+		patch["metadata"] = metadata
+	}
+	if automount, ok, _ := unstructured.NestedBool(desired.Object, "automountServiceAccountToken"); ok {
+		if cur, set, _ := unstructured.NestedBool(existing.Object, "automountServiceAccountToken"); !set || cur != automount {
+			patch["automountServiceAccountToken"] = automount
+		}
+	}
+
+	pulls := mapsOf(existing.Object["imagePullSecrets"])
+	present := make(map[string]bool)
+	for _, ref := range pulls {
+		name, _ := ref["name"].(string)
+		present[name] = true
+	}
+	added := false
+	for _, ref := range mapsOf(desired.Object["imagePullSecrets"]) {
+		name, _ := ref["name"].(string)
+		if name == "" || present[name] || clean.IsGeneratedServiceAccountSecret(desired.GetName(), name) {
+			continue
+		}
+		pulls = append(pulls, map[string]interface{}{"name": name})
+		present[name] = true
+		added = true
+	}
+	if added {
+		list := make([]interface{}, len(pulls))
+		for i, ref := range pulls {
+			list[i] = ref
+		}
+		patch["imagePullSecrets"] = list
+	}
+	if len(patch) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(patch)
+}
+
+// mergeDefaultServiceAccount 将备份中 default ServiceAccount 的配置合并到目标集群的现有对象, 返回是否有修改
+func mergeDefaultServiceAccount(resClient dynamic.ResourceInterface, obj *unstructured.Unstructured) (bool, error) {
+	existing, err := resClient.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("读取已存在的对象失败: %w", err)
+	}
+	patch, err := defaultServiceAccountPatch(existing, obj)
+	if err != nil || patch == nil {
+		return false, err
+	}
+	if _, err := resClient.Patch(context.TODO(), obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return false, fmt.Errorf("合并配置失败: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestMergeDefaultServiceAccount(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"}
+	live := fakeObject("v1", "ServiceAccount", "web", "default", map[string]interface{}{
+		"imagePullSecrets": []interface{}{map[string]interface{}{"name": "default-dockercfg-target"}},
+	})
+	backup := fakeObject("v1", "ServiceAccount", "web", "default", map[string]interface{}{
+		"automountServiceAccountToken": false,
+		"imagePullSecrets": []interface{}{
+			map[string]interface{}{"name": "default-dockercfg-source"},
+			map[string]interface{}{"name": "registry"},
+		},
+	})
+	backup.SetLabels(map[string]string{"team": "web"})
+
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), live)
+	resClient := client.Resource(gvr).Namespace("web")
+	merged, err := mergeDefaultServiceAccount(resClient, backup)
+	if err != nil || !merged {
+		t.Fatalf("merged = %v, err = %v", merged, err)
+	}
+	got, _ := resClient.Get(context.TODO(), "default", metav1.GetOptions{})
+	wantPulls := []interface{}{
+		map[string]interface{}{"name": "default-dockercfg-target"},
+		map[string]interface{}{"name": "registry"},
+	}
+	if !reflect.DeepEqual(got.Object["imagePullSecrets"], wantPulls) {
+		t.Errorf("imagePullSecrets = %v, 期望 %v", got.Object["imagePullSecrets"], wantPulls)
+	}
+	if got.Object["automountServiceAccountToken"] != false || got.GetLabels()["team"] != "web" {
+		t.Errorf("automount/labels 未合并: %v", got.Object)
+	}
+
+	if merged, err := mergeDefaultServiceAccount(resClient, backup); err != nil || merged {
+		t.Errorf("配置已相同时不应再修改, merged = %v, err = %v", merged, err)
+	}
+	if !isDefaultServiceAccount(backup) || isDefaultServiceAccount(fakeObject("v1", "ServiceAccount", "web", "builder", nil)) {
+		t.Error("isDefaultServiceAccount 结果不正确")
+	}
+}
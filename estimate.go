@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"backup-k8s/clean"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+)
+
+// estimateSampleSize --estimate 为估算每个资源类型的平均清单大小而完整获取的对象数
+const estimateSampleSize = 20
+
+// typeEstimate 单个资源类型的估算结果
+type typeEstimate struct {
+	Kind     string
+	Count    int
+	AvgBytes int // 抽样对象清理后的平均 YAML 大小, 未抽样到对象时为 0
+}
+
+// namespaceEstimate 单个命名空间的估算结果, 集群级资源的 Namespace 为空
+type namespaceEstimate struct {
+	Namespace string
+	Count     int
+	Bytes     int
+}
+
+// backupEstimate --estimate 的汇总, 数量为对象的实际数量, 大小按各类型的抽样平均值推算
+// 未计入系统对象, Secret 等在备份时才按内容过滤掉的对象, 实际备份会略小
+type backupEstimate struct {
+	Types      []typeEstimate
+	Namespaces []namespaceEstimate
+	Count      int
+	Bytes      int
+	Failed     []string // 无法列出的资源类型及原因
+}
+
+// estimateBackup 通过 metadata 客户端 (PartialObjectMetadata) 分页列出各资源类型的对象元数据并按命名空间计数,
+// 每个类型只完整获取 estimateSampleSize 个对象用于估算平均大小, 不下载全部对象
+func estimateBackup(metaClient metadata.Interface, client dynamic.Interface, resourceTypes []string, namespaces []string, clusterScoped bool, opts clean.Options) backupEstimate {
+	var est backupEstimate
+	targets := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		targets[ns] = true
+	}
+	byNamespace := make(map[string]*namespaceEstimate)
+	for _, resType := range resourceTypes {
+		resInfo, exists := resourceMap[resType]
+		if !exists || (!resInfo.Namespaced && !clusterScoped) {
+			continue
+		}
+		counts, err := countObjectMetadata(metaClient, resInfo)
+		if err != nil {
+			est.Failed = append(est.Failed, fmt.Sprintf("%s: %v", resInfo.Kind, err))
+			continue
+		}
+		t := typeEstimate{Kind: resInfo.Kind, AvgBytes: sampleAverageSize(client, resType, resInfo, opts)}
+		for ns, n := range counts {
+			if resInfo.Namespaced && !targets[ns] {
+				continue
+			}
+			t.Count += n
+			e, ok := byNamespace[ns]
+			if !ok {
+				e = &namespaceEstimate{Namespace: ns}
+				byNamespace[ns] = e
+			}
+			e.Count += n
+			e.Bytes += n * t.AvgBytes
+		}
+		if t.Count == 0 {
+			continue
+		}
+		est.Types = append(est.Types, t)
+		est.Count += t.Count
+		est.Bytes += t.Count * t.AvgBytes
+	}
+	for _, e := range byNamespace {
+		est.Namespaces = append(est.Namespaces, *e)
+	}
+	sort.Slice(est.Namespaces, func(i, j int) bool { return est.Namespaces[i].Namespace < est.Namespaces[j].Namespace })
+	return est
+}
+
+// countObjectMetadata 分页列出资源类型在全部命名空间中的对象元数据, 返回命名空间 -> 对象数
+func countObjectMetadata(metaClient metadata.Interface, resInfo ResourceInfo) (map[string]int, error) {
+	counts := make(map[string]int)
+	opts := metav1.ListOptions{Limit: verifyPageSize}
+	for {
+		list, err := metaClient.Resource(resInfo.GVR).List(context.TODO(), opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			counts[item.Namespace]++
+		}
+		if opts.Continue = list.Continue; opts.Continue == "" {
+			return counts, nil
+		}
+	}
+}
+
+// sampleAverageSize 获取资源类型的前 estimateSampleSize 个对象, 返回清理后 YAML 的平均大小, 获取失败时返回 0
+func sampleAverageSize(client dynamic.Interface, resType string, resInfo ResourceInfo, opts clean.Options) int {
+	list, err := client.Resource(resInfo.GVR).List(context.TODO(), metav1.ListOptions{Limit: estimateSampleSize})
+	if err != nil || len(list.Items) == 0 {
+		return 0
+	}
+	total := 0
+	for i := range list.Items {
+		if _, data, err := renderResource(resType, &list.Items[i], opts); err == nil {
+			total += len(data)
+		}
+	}
+	return total / len(list.Items)
+}
+
+// print 输出按命名空间与资源类型的估算表格
+func (est backupEstimate) print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "命名空间\t对象数\t估算大小")
+	for _, e := range est.Namespaces {
+		ns := e.Namespace
+		if ns == "" {
+			ns = "(集群级)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", ns, e.Count, formatBytes(e.Bytes))
+	}
+	fmt.Fprintln(tw, "\t\t")
+	fmt.Fprintln(tw, "资源类型\t对象数\t平均大小")
+	for _, t := range est.Types {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", t.Kind, t.Count, formatBytes(t.AvgBytes))
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n合计: %d 个对象, 约 %s\n", est.Count, formatBytes(est.Bytes))
+	for _, f := range est.Failed {
+		fmt.Fprintf(w, "警告: 无法列出 %s\n", f)
+	}
+}
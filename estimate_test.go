@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metadatafake "k8s.io/client-go/metadata/fake"
+)
+
+func partialObject(apiVersion, kind, namespace, name string) *metav1.PartialObjectMetadata {
+	return &metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+	}
+}
+
+func TestEstimateBackup(t *testing.T) {
+	scheme := metadatafake.NewTestScheme()
+	metav1.AddMetaToScheme(scheme)
+	metaClient := metadatafake.NewSimpleMetadataClient(scheme,
+		partialObject("v1", "ConfigMap", "web", "a"),
+		partialObject("v1", "ConfigMap", "web", "b"),
+		partialObject("v1", "ConfigMap", "api", "c"),
+		partialObject("v1", "ConfigMap", "kube-system", "coredns"),
+		partialObject("v1", "PersistentVolume", "", "pv-data"),
+	)
+	run, _ := newFakeBackupper(t, nil, nil,
+		fakeObject("v1", "ConfigMap", "web", "a", map[string]interface{}{"data": map[string]interface{}{"k": "v"}}),
+		fakeObject("v1", "PersistentVolume", "", "pv-data", nil),
+	)
+
+	est := estimateBackup(metaClient, run.dynamicClient, []string{"configmaps", "persistentvolumes"}, []string{"web", "api"}, true, run.cleanOpts)
+	if est.Count != 4 {
+		t.Errorf("对象数 = %d, 期望 4 (排除的 kube-system 不计入)", est.Count)
+	}
+	want := map[string]int{"": 1, "api": 1, "web": 2}
+	if len(est.Namespaces) != len(want) {
+		t.Fatalf("命名空间估算 = %+v", est.Namespaces)
+	}
+	for _, e := range est.Namespaces {
+		if e.Count != want[e.Namespace] {
+			t.Errorf("命名空间 %q 对象数 = %d, 期望 %d", e.Namespace, e.Count, want[e.Namespace])
+		}
+		if e.Bytes == 0 {
+			t.Errorf("命名空间 %q 估算大小为 0", e.Namespace)
+		}
+	}
+
+	est = estimateBackup(metaClient, run.dynamicClient, []string{"configmaps", "persistentvolumes"}, []string{"web"}, false, run.cleanOpts)
+	if est.Count != 2 {
+		t.Errorf("不含集群级资源时对象数 = %d, 期望 2", est.Count)
+	}
+}
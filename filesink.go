@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// --fsync 的可选值
+const (
+	fsyncNone   = "none"   // 不调用 fsync, 由操作系统决定落盘时机 (默认, 最快)
+	fsyncBatch  = "batch"  // 每个命名空间或 _global 目录写完后统一 fsync 其中的文件
+	fsyncAlways = "always" // 每个文件写入后立即 fsync (最慢)
+)
+
+// validateFsyncPolicy 校验 --fsync 参数
+func validateFsyncPolicy(policy string) error {
+	switch policy {
+	case fsyncNone, fsyncBatch, fsyncAlways:
+		return nil
+	default:
+		return fmt.Errorf("不支持的 fsync 策略 '%s' (可选: %s, %s, %s)", policy, fsyncNone, fsyncBatch, fsyncAlways)
+	}
+}
+
+// writeFailure 异步写入失败的文件
+type writeFailure struct {
+	Path string
+	Err  error
+}
+
+// writeJob 交给写入协程的任务, data 为 nil 时只 fsync 已写入的文件
+type writeJob struct {
+	batch *writeBatch
+	path  string
+	data  []byte
+}
+
+// fileSink 清单文件的写入器, 缓存已创建的目录以减少 NFS 等网络文件系统上的元数据请求
+// workers 大于 1 时由多个协程并发写入; nil 表示同步写入且不 fsync
+type fileSink struct {
+	fsync   string
+	workers int
+	jobs    chan writeJob
+	mu      sync.Mutex // 保护 dirs 与 memory
+	dirs    map[string]bool
+	memory  map[string][]byte // 不为 nil 时清单按路径保存在内存中, 不写入文件
+}
+
+// newFileSink 创建写入器, workers 小于等于 1 时同步写入
+func newFileSink(workers int, fsync string) *fileSink {
+	s := &fileSink{fsync: fsync, workers: workers, dirs: make(map[string]bool)}
+	if workers > 1 {
+		s.jobs = make(chan writeJob, workers*4)
+		for i := 0; i < workers; i++ {
+			go s.run()
+		}
+	}
+	return s
+}
+
+// newMemorySink 创建只在内存中保存清单的写入器, 供 compare-clusters 按备份流程读取集群而不落盘
+func newMemorySink() *fileSink {
+	return &fileSink{memory: make(map[string][]byte)}
+}
+
+// manifest 返回内存写入器中 path 对应的清单
+func (s *fileSink) manifest(path string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.memory[path]
+	return data, ok
+}
+
+func (s *fileSink) run() {
+	for job := range s.jobs {
+		var err error
+		if job.data == nil {
+			err = syncFile(job.path)
+		} else {
+			err = s.writeFile(job.batch, job.path, job.data)
+		}
+		if err != nil {
+			job.batch.fail(job.path, err)
+		}
+		job.batch.wg.Done()
+	}
+}
+
+// writeBatch 一个命名空间或 _global 目录的清单写入, 可单独等待完成
+// 并行备份多个命名空间时, 每个命名空间只等待并收集自己的文件, 写完后才能记入检查点
+// 并发模式下 write 立即返回, 失败在 wait 时统一返回
+type writeBatch struct {
+	sink     *fileSink
+	wg       sync.WaitGroup
+	mu       sync.Mutex // 保护 written 与 failures
+	written  []string   // batch 模式下等待 fsync 的文件
+	failures []writeFailure
+}
+
+// batch 创建一组写入, s 为 nil 时同步写入
+func (s *fileSink) batch() *writeBatch {
+	return &writeBatch{sink: s}
+}
+
+// write 写入文件, 父目录不存在时创建; 并发模式下只返回创建目录的错误
+func (b *writeBatch) write(path string, data []byte) error {
+	s := b.sink
+	if s == nil {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0644)
+	}
+	if s.memory != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.memory[path] = data
+		return nil
+	}
+	dir := filepath.Dir(path)
+	s.mu.Lock()
+	created := s.dirs[dir]
+	s.mu.Unlock()
+	if !created {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.dirs[dir] = true
+		s.mu.Unlock()
+	}
+	if s.jobs == nil {
+		return s.writeFile(b, path, data)
+	}
+	b.wg.Add(1)
+	s.jobs <- writeJob{batch: b, path: path, data: data}
+	return nil
+}
+
+func (b *writeBatch) fail(path string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = append(b.failures, writeFailure{Path: path, Err: err})
+}
+
+func (s *fileSink) writeFile(b *writeBatch, path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if s.fsync == fsyncAlways {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if s.fsync == fsyncBatch {
+		b.mu.Lock()
+		b.written = append(b.written, path)
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// wait 等待这组已提交的文件全部写入, batch 模式下再 fsync 这些文件, 返回此前尚未返回过的失败
+func (b *writeBatch) wait() []writeFailure {
+	if b.sink == nil {
+		return nil
+	}
+	b.wg.Wait()
+	b.mu.Lock()
+	written := b.written
+	b.written = nil
+	b.mu.Unlock()
+	for _, path := range written {
+		if b.sink.jobs == nil {
+			if err := syncFile(path); err != nil {
+				b.fail(path, err)
+			}
+			continue
+		}
+		b.wg.Add(1)
+		b.sink.jobs <- writeJob{batch: b, path: path}
+	}
+	b.wg.Wait()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	failures := b.failures
+	b.failures = nil
+	return failures
+}
+
+// close 结束写入协程, 调用前需先等待全部写入
+func (s *fileSink) close() {
+	if s != nil && s.jobs != nil {
+		close(s.jobs)
+	}
+}
+
+func syncFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dropFailedWrites 等待一组清单全部写入, 将写入失败的对象移出分区索引并记入 skipped.yaml, 返回移除的对象数
+func (b *Backupper) dropFailedWrites(partition *backupPartition, batch *writeBatch, out *outputSection) int {
+	failures := batch.wait()
+	if len(failures) == 0 {
+		return 0
+	}
+	failed := make(map[string]error, len(failures))
+	for _, f := range failures {
+		out.errorf("    错误: 写入文件 '%s' 失败: %v\n", f.Path, f.Err)
+		failed[f.Path] = f.Err
+	}
+	dropped := partition.dropFailed(failed)
+	for _, e := range dropped {
+		err := failed[filepath.Join(partition.Root, filepath.FromSlash(e.Path))]
+		b.progress.Emit(progressEvent{Event: "resource_failed", Namespace: e.Namespace, Kind: e.Kind, Name: e.Name, Error: err.Error()})
+	}
+	b.addResources(-len(dropped))
+	return len(dropped)
+}
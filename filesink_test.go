@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSinkConcurrentWrites(t *testing.T) {
+	for _, policy := range []string{fsyncNone, fsyncBatch, fsyncAlways} {
+		dir := t.TempDir()
+		sink := newFileSink(4, policy)
+		batch := sink.batch()
+		for _, name := range []string{"a", "b", "c"} {
+			if err := batch.write(filepath.Join(dir, "sub", name+".yaml"), []byte(name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// 目标路径是已存在的目录, 只能在 wait 时得到失败
+		blocked := filepath.Join(dir, "sub", "blocked.yaml")
+		os.MkdirAll(blocked, 0755)
+		if err := batch.write(blocked, []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+		failures := batch.wait()
+		sink.close()
+		if len(failures) != 1 || failures[0].Path != blocked {
+			t.Errorf("%s: 失败 = %v, 期望只有 %s", policy, failures, blocked)
+		}
+		if data, err := os.ReadFile(filepath.Join(dir, "sub", "b.yaml")); err != nil || string(data) != "b" {
+			t.Errorf("%s: b.yaml = %q, %v", policy, data, err)
+		}
+	}
+}
+
+func TestBackupNamespaceDropsFailedWrites(t *testing.T) {
+	run, partitions := newFakeBackupper(t, []string{"configmaps"}, nil,
+		fakeObject("v1", "ConfigMap", "web", "ok", nil),
+		fakeObject("v1", "ConfigMap", "web", "blocked", nil),
+	)
+	run.sink = newFileSink(4, fsyncNone)
+	defer run.sink.close()
+	p, _ := partitions.get("")
+	os.MkdirAll(filepath.Join(p.Root, "web", "configmaps", "blocked.yaml"), 0755)
+
+	run.backupNamespace("web", nil)
+	if run.totalResources != 1 || len(p.Index) != 1 || p.Index[0].Name != "ok" {
+		t.Errorf("备份资源数 = %d, 索引 = %+v, 期望只保留写入成功的 ok", run.totalResources, p.Index)
+	}
+	if len(p.Skipped) != 1 || p.Skipped[0].Reason != skipWriteFailed || p.Skipped[0].Name != "blocked" {
+		t.Errorf("跳过记录 = %+v", p.Skipped)
+	}
+}
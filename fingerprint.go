@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// clusterFingerprint 备份来源集群的标识, 写入 metadata.yaml, 用于恢复前识别目标集群是否为同一集群
+// 不记录 API server 地址本身, 只记录其哈希, 避免备份泄露内部地址
+type clusterFingerprint struct {
+	ServerHash        string `yaml:"serverHash,omitempty"`
+	ClusterUID        string `yaml:"clusterUID,omitempty"` // kube-system 命名空间的 UID, 集群重建前保持不变
+	KubernetesVersion string `yaml:"kubernetesVersion,omitempty"`
+}
+
+// collectFingerprint 读取当前集群的标识, 无法读取的字段留空
+func collectFingerprint(config *rest.Config, clientset kubernetes.Interface) clusterFingerprint {
+	var fp clusterFingerprint
+	if config != nil && config.Host != "" {
+		sum := sha256.Sum256([]byte(strings.TrimSuffix(config.Host, "/")))
+		fp.ServerHash = hex.EncodeToString(sum[:])[:16]
+	}
+	if ns, err := clientset.CoreV1().Namespaces().Get(context.TODO(), metav1.NamespaceSystem, metav1.GetOptions{}); err == nil {
+		fp.ClusterUID = string(ns.UID)
+	}
+	if info, err := clientset.Discovery().ServerVersion(); err == nil {
+		fp.KubernetesVersion = info.GitVersion
+	}
+	return fp
+}
+
+func (fp clusterFingerprint) empty() bool {
+	return fp.ServerHash == "" && fp.ClusterUID == ""
+}
+
+// mismatch 比较两个集群标识, 不同时返回原因; 优先比较集群 UID, 双方都没有 UID 时比较 API server 地址
+func (fp clusterFingerprint) mismatch(other clusterFingerprint) string {
+	if fp.empty() || other.empty() {
+		return ""
+	}
+	if fp.ClusterUID != "" && other.ClusterUID != "" {
+		if fp.ClusterUID != other.ClusterUID {
+			return fmt.Sprintf("集群UID不同 (备份 %s, 目标 %s)", fp.ClusterUID, other.ClusterUID)
+		}
+		return ""
+	}
+	if fp.ServerHash != other.ServerHash {
+		return "API server 地址不同"
+	}
+	return ""
+}
+
+// warnClusterMismatch 在备份与目标集群不是同一集群时输出醒目的警告
+func warnClusterMismatch(backupName string, source, target clusterFingerprint) {
+	reason := source.mismatch(target)
+	if reason == "" {
+		return
+	}
+	banner := strings.Repeat("!", 72)
+	fmt.Fprintln(os.Stderr, banner)
+	fmt.Fprintf(os.Stderr, "警告: 备份 %s 来自另一个集群: %s\n", backupName, reason)
+	if source.KubernetesVersion != "" || target.KubernetesVersion != "" {
+		fmt.Fprintf(os.Stderr, "      Kubernetes 版本: 备份 %s, 目标 %s\n", source.KubernetesVersion, target.KubernetesVersion)
+	}
+	fmt.Fprintln(os.Stderr, "      请确认这是有意的跨集群迁移, 而不是误用了 kubeconfig 上下文")
+	fmt.Fprintln(os.Stderr, banner)
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// --graph 的可选格式
+const (
+	graphFormatDOT  = "dot"
+	graphFormatJSON = "json"
+)
+
+// graphFileBaseName 依赖图文件名 (不含扩展名), 位于命名空间目录与 _global 目录
+const graphFileBaseName = "graph"
+
+// validateGraphFormat 校验 --graph 参数, 空值表示不导出
+func validateGraphFormat(format string) error {
+	switch format {
+	case "", graphFormatDOT, graphFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("不支持的依赖图格式 '%s' (可选: %s, %s)", format, graphFormatDOT, graphFormatJSON)
+	}
+}
+
+// graphNode 依赖图中的对象, External 表示被引用但不在本目录备份内容中 (如集群级 StorageClass 或缺失的 ConfigMap)
+type graphNode struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	External  bool   `json:"external,omitempty"`
+}
+
+// graphEdge 表示 From 引用了 To, 恢复时 To 应先于 From 创建
+type graphEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+}
+
+// dependencyGraph 收集一个目录 (命名空间或 _global) 中对象之间的引用关系
+type dependencyGraph struct {
+	nodes map[string]*graphNode
+	edges []graphEdge
+}
+
+func newDependencyGraph() *dependencyGraph {
+	return &dependencyGraph{nodes: make(map[string]*graphNode)}
+}
+
+// add 记录对象及其引用的其他对象
+func (g *dependencyGraph) add(obj map[string]interface{}) {
+	u := &unstructured.Unstructured{Object: obj}
+	id := objectKey(u.GetKind(), u.GetNamespace(), u.GetName())
+	g.nodes[id] = &graphNode{ID: id, Kind: u.GetKind(), Namespace: u.GetNamespace(), Name: u.GetName()}
+	for _, ref := range objectReferences(obj) {
+		g.edges = append(g.edges, graphEdge{From: id, To: ref.ID(), Reason: ref.Reason})
+		if _, ok := g.nodes[ref.ID()]; !ok {
+			g.nodes[ref.ID()] = &graphNode{ID: ref.ID(), Kind: ref.Kind, Namespace: ref.Namespace, Name: ref.Name, External: true}
+		}
+	}
+	// 之前作为外部引用加入的节点此时已在备份内容中
+	g.nodes[id].External = false
+}
+
+// objectRef 对象引用的目标
+type objectRef struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Reason    string // 引用所在的字段, 如 envFrom, volumes
+}
+
+func (r objectRef) ID() string {
+	return objectKey(r.Kind, r.Namespace, r.Name)
+}
+
+// objectReferences 提取对象对同命名空间或集群级对象的引用, 重复引用只保留一次
+// 覆盖工作负载 -> ConfigMap/Secret/PVC/ServiceAccount, Ingress -> Service/Secret, PVC -> StorageClass,
+// (Cluster)RoleBinding -> ServiceAccount/(Cluster)Role
+func objectReferences(obj map[string]interface{}) []objectRef {
+	u := &unstructured.Unstructured{Object: obj}
+	ns := u.GetNamespace()
+	var refs []objectRef
+	seen := make(map[string]struct{})
+	add := func(kind, namespace, name, reason string) {
+		if name == "" {
+			return
+		}
+		ref := objectRef{Kind: kind, Namespace: namespace, Name: name, Reason: reason}
+		if _, dup := seen[ref.ID()]; dup {
+			return
+		}
+		seen[ref.ID()] = struct{}{}
+		refs = append(refs, ref)
+	}
+
+	// 属主需先于对象恢复, 才能将 ownerReferences 中的 UID 改写为目标集群中的值
+	for _, owner := range u.GetOwnerReferences() {
+		add(owner.Kind, ns, owner.Name, "ownerReferences")
+	}
+	if podSpec := podSpecOf(obj); podSpec != nil && isWorkloadKind(u.GetKind()) {
+		name, _ := podSpec["serviceAccountName"].(string)
+		add("ServiceAccount", ns, name, "serviceAccountName")
+		for _, s := range mapsOf(podSpec["imagePullSecrets"]) {
+			name, _ := s["name"].(string)
+			add("Secret", ns, name, "imagePullSecrets")
+		}
+		for _, v := range mapsOf(podSpec["volumes"]) {
+			name, _, _ := unstructured.NestedString(v, "configMap", "name")
+			add("ConfigMap", ns, name, "volumes")
+			name, _, _ = unstructured.NestedString(v, "secret", "secretName")
+			add("Secret", ns, name, "volumes")
+			name, _, _ = unstructured.NestedString(v, "persistentVolumeClaim", "claimName")
+			add("PersistentVolumeClaim", ns, name, "volumes")
+			for _, source := range mapsOf(nestedMapNoCopy(v, "projected")["sources"]) {
+				name, _, _ := unstructured.NestedString(source, "configMap", "name")
+				add("ConfigMap", ns, name, "volumes")
+				name, _, _ = unstructured.NestedString(source, "secret", "name")
+				add("Secret", ns, name, "volumes")
+			}
+		}
+		for _, c := range containersOf(podSpec) {
+			for _, from := range mapsOf(c["envFrom"]) {
+				name, _, _ := unstructured.NestedString(from, "configMapRef", "name")
+				add("ConfigMap", ns, name, "envFrom")
+				name, _, _ = unstructured.NestedString(from, "secretRef", "name")
+				add("Secret", ns, name, "envFrom")
+			}
+			for _, env := range mapsOf(c["env"]) {
+				name, _, _ := unstructured.NestedString(env, "valueFrom", "configMapKeyRef", "name")
+				add("ConfigMap", ns, name, "env")
+				name, _, _ = unstructured.NestedString(env, "valueFrom", "secretKeyRef", "name")
+				add("Secret", ns, name, "env")
+			}
+		}
+	}
+
+	switch u.GetKind() {
+	case "Ingress":
+		name, _, _ := unstructured.NestedString(obj, "spec", "defaultBackend", "service", "name")
+		add("Service", ns, name, "defaultBackend")
+		rules, _, _ := unstructured.NestedSlice(obj, "spec", "rules")
+		for _, rule := range mapsOf(rules) {
+			paths, _, _ := unstructured.NestedSlice(rule, "http", "paths")
+			for _, p := range mapsOf(paths) {
+				name, _, _ := unstructured.NestedString(p, "backend", "service", "name")
+				add("Service", ns, name, "rules")
+			}
+		}
+		tls, _, _ := unstructured.NestedSlice(obj, "spec", "tls")
+		for _, t := range mapsOf(tls) {
+			name, _ := t["secretName"].(string)
+			add("Secret", ns, name, "tls")
+		}
+	case "Route":
+		backends := []interface{}{nestedMapNoCopy(obj, "spec", "to")}
+		alternates, _, _ := unstructured.NestedSlice(obj, "spec", "alternateBackends")
+		for _, b := range mapsOf(append(backends, alternates...)) {
+			if kind, _ := b["kind"].(string); kind == "" || kind == "Service" {
+				name, _ := b["name"].(string)
+				add("Service", ns, name, "to")
+			}
+		}
+	case "Rollout":
+		for _, strategy := range []struct {
+			name     string
+			services []string
+		}{
+			{"canary", []string{"stableService", "canaryService"}},
+			{"blueGreen", []string{"activeService", "previewService"}},
+		} {
+			for _, field := range strategy.services {
+				name, _, _ := unstructured.NestedString(obj, "spec", "strategy", strategy.name, field)
+				add("Service", ns, name, field)
+			}
+			for _, field := range []string{"analysis", "prePromotionAnalysis", "postPromotionAnalysis"} {
+				templates, _, _ := unstructured.NestedSlice(obj, "spec", "strategy", strategy.name, field, "templates")
+				for _, ref := range mapsOf(templates) {
+					name, _ := ref["templateName"].(string)
+					if clusterScope, _ := ref["clusterScope"].(bool); clusterScope {
+						add("ClusterAnalysisTemplate", "", name, field)
+					} else {
+						add("AnalysisTemplate", ns, name, field)
+					}
+				}
+			}
+		}
+	case "CronWorkflow":
+		name, _, _ := unstructured.NestedString(obj, "spec", "workflowSpec", "workflowTemplateRef", "name")
+		if clusterScope, _, _ := unstructured.NestedBool(obj, "spec", "workflowSpec", "workflowTemplateRef", "clusterScope"); clusterScope {
+			add("ClusterWorkflowTemplate", "", name, "workflowTemplateRef")
+		} else {
+			add("WorkflowTemplate", ns, name, "workflowTemplateRef")
+		}
+	case "ValidatingAdmissionPolicyBinding":
+		name, _, _ := unstructured.NestedString(obj, "spec", "policyName")
+		add("ValidatingAdmissionPolicy", "", name, "policyName")
+	case "PersistentVolumeClaim":
+		name, _, _ := unstructured.NestedString(obj, "spec", "storageClassName")
+		add("StorageClass", "", name, "storageClassName")
+	case "PersistentVolume":
+		name, _, _ := unstructured.NestedString(obj, "spec", "storageClassName")
+		add("StorageClass", "", name, "storageClassName")
+	case "RoleBinding", "ClusterRoleBinding":
+		subjects, _, _ := unstructured.NestedSlice(obj, "subjects")
+		for _, s := range mapsOf(subjects) {
+			if kind, _ := s["kind"].(string); kind != "ServiceAccount" {
+				continue
+			}
+			name, _ := s["name"].(string)
+			subjectNS, _ := s["namespace"].(string)
+			if subjectNS == "" {
+				subjectNS = ns
+			}
+			add("ServiceAccount", subjectNS, name, "subjects")
+		}
+		kind, _, _ := unstructured.NestedString(obj, "roleRef", "kind")
+		name, _, _ := unstructured.NestedString(obj, "roleRef", "name")
+		if kind == "Role" {
+			add(kind, ns, name, "roleRef")
+		} else if kind == "ClusterRole" {
+			add(kind, "", name, "roleRef")
+		}
+	}
+	return refs
+}
+
+// isWorkloadKind 判断对象是否为带 Pod 模板的工作负载, 避免将其他资源的 spec.template 误认作 Pod 规格
+func isWorkloadKind(kind string) bool {
+	switch kind {
+	case "Pod", "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job", "CronJob", "DeploymentConfig", "Rollout":
+		return true
+	}
+	return false
+}
+
+// mapsOf 将 []interface{} 中的 map 元素提取出来, 忽略其他类型
+func mapsOf(v interface{}) []map[string]interface{} {
+	list, _ := v.([]interface{})
+	var result []map[string]interface{}
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// sortedNodes 返回按 ID 排序的节点
+func (g *dependencyGraph) sortedNodes() []*graphNode {
+	nodes := make([]*graphNode, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// write 按格式在 dir 中写出 graph.dot 或 graph.json, 图为空时不生成文件
+func (g *dependencyGraph) write(dir, format string) error {
+	if len(g.nodes) == 0 {
+		return nil
+	}
+	var data []byte
+	switch format {
+	case graphFormatJSON:
+		out := struct {
+			Nodes []*graphNode `json:"nodes"`
+			Edges []graphEdge  `json:"edges"`
+		}{Nodes: g.sortedNodes(), Edges: g.edges}
+		if out.Edges == nil {
+			out.Edges = []graphEdge{}
+		}
+		var err error
+		if data, err = json.MarshalIndent(out, "", "  "); err != nil {
+			return err
+		}
+	case graphFormatDOT:
+		data = []byte(g.dot())
+	default:
+		return nil
+	}
+	return os.WriteFile(filepath.Join(dir, graphFileBaseName+"."+format), data, 0644)
+}
+
+// dot 生成 Graphviz 描述, 外部引用的节点以虚线框显示
+func (g *dependencyGraph) dot() string {
+	var b strings.Builder
+	b.WriteString("digraph resources {\n  rankdir=LR;\n  node [shape=box];\n")
+	for _, n := range g.sortedNodes() {
+		attrs := fmt.Sprintf("label=%q", n.Kind+"\n"+n.Name)
+		if n.External {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %q [%s];\n", n.ID, attrs)
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", e.From, e.To, e.Reason)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// orderByDependencies 在已按类型排序的基础上调整恢复顺序, 使被引用的对象先于引用方创建
+// 没有依赖约束的对象保持原有顺序; 存在循环引用时, 循环中的对象按原顺序追加在最后
+func orderByDependencies(items []restoreItem) []restoreItem {
+	position := make(map[string]int, len(items))
+	for i, item := range items {
+		position[objectKey(item.Obj.GetKind(), item.Obj.GetNamespace(), item.Obj.GetName())] = i
+	}
+	pending := make([]int, len(items))      // 尚未恢复的依赖数量
+	dependents := make([][]int, len(items)) // 引用该对象的其他对象
+	for i, item := range items {
+		for _, ref := range objectReferences(item.Obj.Object) {
+			if j, ok := position[ref.ID()]; ok && j != i {
+				pending[i]++
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+	}
+
+	ready := &intHeap{}
+	for i := range items {
+		if pending[i] == 0 {
+			heap.Push(ready, i)
+		}
+	}
+	ordered := make([]restoreItem, 0, len(items))
+	done := make([]bool, len(items))
+	for ready.Len() > 0 {
+		i := heap.Pop(ready).(int)
+		ordered = append(ordered, items[i])
+		done[i] = true
+		for _, d := range dependents[i] {
+			if pending[d]--; pending[d] == 0 {
+				heap.Push(ready, d)
+			}
+		}
+	}
+	for i, item := range items {
+		if !done[i] {
+			ordered = append(ordered, item)
+		}
+	}
+	return ordered
+}
+
+// intHeap 最小堆, 用于优先恢复原顺序中靠前的对象
+type intHeap []int
+
+func (h intHeap) Len() int            { return len(h) }
+func (h intHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h intHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *intHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+func (h *intHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestObjectReferences(t *testing.T) {
+	deployment := fakeObject("apps/v1", "Deployment", "web", "frontend", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"serviceAccountName": "frontend",
+					"volumes": []interface{}{
+						map[string]interface{}{"name": "data", "persistentVolumeClaim": map[string]interface{}{"claimName": "data"}},
+						map[string]interface{}{"name": "conf", "configMap": map[string]interface{}{"name": "settings"}},
+					},
+					"containers": []interface{}{
+						map[string]interface{}{
+							"name":    "app",
+							"envFrom": []interface{}{map[string]interface{}{"secretRef": map[string]interface{}{"name": "db"}}},
+							"env": []interface{}{
+								map[string]interface{}{"name": "A", "valueFrom": map[string]interface{}{"configMapKeyRef": map[string]interface{}{"name": "settings", "key": "a"}}},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	ingress := fakeObject("networking.k8s.io/v1", "Ingress", "web", "frontend", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"tls": []interface{}{map[string]interface{}{"secretName": "web-tls"}},
+			"rules": []interface{}{map[string]interface{}{"http": map[string]interface{}{"paths": []interface{}{
+				map[string]interface{}{"backend": map[string]interface{}{"service": map[string]interface{}{"name": "frontend"}}},
+			}}}},
+		},
+	})
+	pvc := fakeObject("v1", "PersistentVolumeClaim", "web", "data", map[string]interface{}{
+		"spec": map[string]interface{}{"storageClassName": "fast"},
+	})
+	binding := fakeObject("rbac.authorization.k8s.io/v1", "RoleBinding", "web", "frontend", map[string]interface{}{
+		"subjects": []interface{}{map[string]interface{}{"kind": "ServiceAccount", "name": "frontend"}},
+		"roleRef":  map[string]interface{}{"kind": "ClusterRole", "name": "view"},
+	})
+	route := fakeObject("route.openshift.io/v1", "Route", "web", "frontend", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"to":                map[string]interface{}{"kind": "Service", "name": "frontend"},
+			"alternateBackends": []interface{}{map[string]interface{}{"kind": "Service", "name": "frontend-canary"}},
+		},
+	})
+	rollout := fakeObject("argoproj.io/v1alpha1", "Rollout", "web", "frontend", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"strategy": map[string]interface{}{"canary": map[string]interface{}{
+				"stableService": "frontend",
+				"analysis": map[string]interface{}{"templates": []interface{}{
+					map[string]interface{}{"templateName": "success-rate"},
+				}},
+			}},
+		},
+	})
+	policyBinding := fakeObject("admissionregistration.k8s.io/v1", "ValidatingAdmissionPolicyBinding", "", "require-labels", map[string]interface{}{
+		"spec": map[string]interface{}{"policyName": "require-labels", "validationActions": []interface{}{"Deny"}},
+	})
+
+	cases := []struct {
+		obj  *unstructured.Unstructured
+		want []string
+	}{
+		{deployment, []string{"ServiceAccount/web/frontend", "PersistentVolumeClaim/web/data", "ConfigMap/web/settings", "Secret/web/db"}},
+		{ingress, []string{"Service/web/frontend", "Secret/web/web-tls"}},
+		{pvc, []string{"StorageClass//fast"}},
+		{binding, []string{"ServiceAccount/web/frontend", "ClusterRole//view"}},
+		{route, []string{"Service/web/frontend", "Service/web/frontend-canary"}},
+		{rollout, []string{"Service/web/frontend", "AnalysisTemplate/web/success-rate"}},
+		{policyBinding, []string{"ValidatingAdmissionPolicy//require-labels"}},
+	}
+	for _, tc := range cases {
+		var got []string
+		for _, ref := range objectReferences(tc.obj.Object) {
+			got = append(got, ref.ID())
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s 的引用 = %v, 期望 %v", tc.obj.GetKind(), got, tc.want)
+		}
+	}
+}
+
+func TestOrderByDependencies(t *testing.T) {
+	// 同一恢复顺序下的自定义资源, 引用方排在被引用对象之前
+	binding := fakeObject("rbac.authorization.k8s.io/v1", "RoleBinding", "web", "reader", map[string]interface{}{
+		"roleRef": map[string]interface{}{"kind": "Role", "name": "reader"},
+	})
+	role := fakeObject("rbac.authorization.k8s.io/v1", "Role", "web", "reader", nil)
+	other := fakeObject("example.com/v1", "Widget", "web", "w", nil)
+
+	items := orderByDependencies([]restoreItem{{Obj: other}, {Obj: binding}, {Obj: role}})
+	var got []string
+	for _, item := range items {
+		got = append(got, item.Obj.GetKind())
+	}
+	if want := "Widget,Role,RoleBinding"; strings.Join(got, ",") != want {
+		t.Errorf("恢复顺序 = %v, 期望 %s", got, want)
+	}
+}
+
+func TestDependencyGraphDOT(t *testing.T) {
+	g := newDependencyGraph()
+	g.add(fakeObject("v1", "PersistentVolumeClaim", "web", "data", map[string]interface{}{
+		"spec": map[string]interface{}{"storageClassName": "fast"},
+	}).Object)
+	dot := g.dot()
+	for _, want := range []string{
+		`"PersistentVolumeClaim/web/data" -> "StorageClass//fast" [label="storageClassName"]`,
+		`"StorageClass//fast" [label="StorageClass\nfast", style=dashed]`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT 输出缺少 %s\n%s", want, dot)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// backupGuard 备份结束时检查资源总数, 过少通常意味着凭据失效或过滤条件错误
+type backupGuard struct {
+	failOnEmpty bool
+	minCount    int     // 资源总数下限, 0 表示不检查
+	minPercent  float64 // 相对上一次备份资源总数的百分比下限, 0 表示不检查
+}
+
+// newBackupGuard 解析 --fail-on-empty 与 --fail-below, failBelow 为绝对数量 (如 100) 或相对上一次备份的百分比 (如 80%)
+func newBackupGuard(failOnEmpty bool, failBelow string) (backupGuard, error) {
+	g := backupGuard{failOnEmpty: failOnEmpty}
+	if failBelow == "" {
+		return g, nil
+	}
+	if p, ok := strings.CutSuffix(failBelow, "%"); ok {
+		percent, err := strconv.ParseFloat(p, 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return g, fmt.Errorf("无效的 --fail-below '%s': 百分比须在 (0, 100] 之间", failBelow)
+		}
+		g.minPercent = percent
+		return g, nil
+	}
+	count, err := strconv.Atoi(failBelow)
+	if err != nil || count <= 0 {
+		return g, fmt.Errorf("无效的 --fail-below '%s': 须为正整数或百分比 (如 80%%)", failBelow)
+	}
+	g.minCount = count
+	return g, nil
+}
+
+// check 返回未通过检查的原因, hasPrevious 为 false 时跳过百分比检查
+func (g backupGuard) check(total, previousTotal int, hasPrevious bool) error {
+	if g.failOnEmpty && total == 0 {
+		return fmt.Errorf("本次备份未包含任何资源")
+	}
+	if g.minCount > 0 && total < g.minCount {
+		return fmt.Errorf("本次备份资源总数 %d 低于下限 %d", total, g.minCount)
+	}
+	if g.minPercent > 0 && hasPrevious && previousTotal > 0 {
+		if percent := float64(total) * 100 / float64(previousTotal); percent < g.minPercent {
+			return fmt.Errorf("本次备份资源总数 %d 仅为上一次 (%d) 的 %.1f%%, 低于下限 %g%%", total, previousTotal, percent, g.minPercent)
+		}
+	}
+	return nil
+}
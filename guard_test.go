@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestBackupGuard(t *testing.T) {
+	cases := []struct {
+		name          string
+		failOnEmpty   bool
+		failBelow     string
+		total         int
+		previousTotal int
+		hasPrevious   bool
+		wantErr       bool
+	}{
+		{name: "disabled", total: 0},
+		{name: "empty", failOnEmpty: true, total: 0, wantErr: true},
+		{name: "not empty", failOnEmpty: true, total: 1},
+		{name: "below count", failBelow: "100", total: 99, wantErr: true},
+		{name: "at count", failBelow: "100", total: 100},
+		{name: "below percent", failBelow: "80%", total: 70, previousTotal: 100, hasPrevious: true, wantErr: true},
+		{name: "above percent", failBelow: "80%", total: 85, previousTotal: 100, hasPrevious: true},
+		{name: "percent without previous", failBelow: "80%", total: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := newBackupGuard(tc.failOnEmpty, tc.failBelow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := g.check(tc.total, tc.previousTotal, tc.hasPrevious); (err != nil) != tc.wantErr {
+				t.Errorf("check() = %v, 期望出错 %v", err, tc.wantErr)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"abc", "0", "-5", "0%", "150%"} {
+		if _, err := newBackupGuard(false, invalid); err == nil {
+			t.Errorf("--fail-below %q 应被拒绝", invalid)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// imageRewriteKinds --image-rewrite 改写镜像的工作负载类型
+var imageRewriteKinds = map[string]bool{"Deployment": true, "StatefulSet": true, "DaemonSet": true, "Job": true, "CronJob": true}
+
+// imageRewrite 将以 from 开头的镜像 (仓库地址, 或仓库地址加路径前缀) 改写为以 to 开头
+type imageRewrite struct {
+	from string
+	to   string
+}
+
+// parseImageRewrites 解析 --image-rewrite, 每项格式为 源=目标 (如 registry.cn-hangzhou.aliyuncs.com=harbor.internal), 可用逗号分隔多项或重复指定
+// 多条规则同时匹配时最长的源前缀生效
+func parseImageRewrites(specs []string) ([]imageRewrite, error) {
+	var rules []imageRewrite
+	seen := make(map[string]string)
+	for _, spec := range specs {
+		for _, pair := range splitList(spec) {
+			from, to, ok := strings.Cut(pair, "=")
+			from, to = strings.TrimSuffix(strings.TrimSpace(from), "/"), strings.TrimSuffix(strings.TrimSpace(to), "/")
+			if !ok || from == "" || to == "" || strings.Contains(from, "://") || strings.Contains(to, "://") {
+				return nil, fmt.Errorf("无效的镜像改写规则 '%s', 格式应为 源仓库=目标仓库 (如 registry.cn-hangzhou.aliyuncs.com=harbor.internal, 不含 https://)", pair)
+			}
+			if existing, dup := seen[from]; dup {
+				if existing != to {
+					return nil, fmt.Errorf("镜像仓库 '%s' 指定了多个目标 ('%s', '%s')", from, existing, to)
+				}
+				continue
+			}
+			seen[from] = to
+			rules = append(rules, imageRewrite{from: from, to: to})
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].from) > len(rules[j].from) })
+	return rules, nil
+}
+
+// rewriteImage 按规则改写单个镜像, 标签与摘要保持不变; 未匹配任何规则时返回 false
+// 镜像按完整名称匹配, 省略仓库地址的镜像 (如 nginx:1.25) 视为 docker.io/library/nginx:1.25
+func rewriteImage(image string, rules []imageRewrite) (string, bool) {
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name = name[:i]
+	}
+	suffix := image[len(name):]
+	ref := parseImageRef(name)
+	repository := ref.Repository
+	if ref.Registry == "docker.io" && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	full := ref.Registry + "/" + repository
+	for _, r := range rules {
+		if full == r.from || strings.HasPrefix(full, r.from+"/") {
+			return r.to + full[len(r.from):] + suffix, true
+		}
+	}
+	return image, false
+}
+
+// imageChange 一个被改写的容器镜像
+type imageChange struct {
+	Container string
+	From      string
+	To        string
+}
+
+// rewriteImages 改写工作负载 Pod 模板中全部容器 (含 init 与 ephemeral 容器) 的镜像, 返回改写记录; 其他类型的对象不做修改
+func rewriteImages(obj map[string]interface{}, rules []imageRewrite) []imageChange {
+	kind, _ := obj["kind"].(string)
+	if len(rules) == 0 || !imageRewriteKinds[kind] {
+		return nil
+	}
+	podSpec := podSpecOf(obj)
+	if podSpec == nil {
+		return nil
+	}
+	var changes []imageChange
+	for _, c := range containersOf(podSpec) {
+		image, _ := c["image"].(string)
+		rewritten, ok := rewriteImage(image, rules)
+		if !ok || rewritten == image {
+			continue
+		}
+		c["image"] = rewritten
+		name, _ := c["name"].(string)
+		changes = append(changes, imageChange{Container: name, From: image, To: rewritten})
+	}
+	return changes
+}
+
+// rewriteRestoreImages 改写待恢复对象中的镜像, 输出改写报告
+func rewriteRestoreImages(items []restoreItem, rules []imageRewrite) {
+	total := 0
+	var lines []string
+	for _, item := range items {
+		for _, c := range rewriteImages(item.Obj.Object, rules) {
+			total++
+			lines = append(lines, fmt.Sprintf("  - %s 容器 %s: %s → %s", describeObject(item.Obj), c.Container, c.From, c.To))
+		}
+	}
+	if total == 0 {
+		fmt.Fprintln(os.Stderr, "警告: 备份中没有与 --image-rewrite 匹配的镜像")
+		return
+	}
+	fmt.Fprintf(logOut, "已改写 %d 个容器镜像:\n%s\n", total, strings.Join(lines, "\n"))
+}
+
+// describeImageRewrites 返回规则的可读描述, 如 "registry.cn-hangzhou.aliyuncs.com → harbor.internal"
+func describeImageRewrites(rules []imageRewrite) string {
+	parts := make([]string, len(rules))
+	for i, r := range rules {
+		parts[i] = r.from + " → " + r.to
+	}
+	return strings.Join(parts, ", ")
+}
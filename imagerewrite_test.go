@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRewriteImage(t *testing.T) {
+	rules, err := parseImageRewrites([]string{"registry.cn-hangzhou.aliyuncs.com=harbor.internal", "registry.cn-hangzhou.aliyuncs.com/mirrors=harbor.internal/hub/, docker.io=harbor.internal/dockerhub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"registry.cn-hangzhou.aliyuncs.com/shop/web:1.2":         "harbor.internal/shop/web:1.2",
+		"registry.cn-hangzhou.aliyuncs.com/mirrors/redis:7":      "harbor.internal/hub/redis:7",
+		"registry.cn-hangzhou.aliyuncs.com/shop/web@sha256:abc":  "harbor.internal/shop/web@sha256:abc",
+		"registry.cn-hangzhou.aliyuncs.com:5000/shop/web:1.2":    "registry.cn-hangzhou.aliyuncs.com:5000/shop/web:1.2",
+		"registry.cn-hangzhou.aliyuncs.com.evil.io/shop/web:1.2": "registry.cn-hangzhou.aliyuncs.com.evil.io/shop/web:1.2",
+		"nginx:1.25":    "harbor.internal/dockerhub/library/nginx:1.25",
+		"bitnami/redis": "harbor.internal/dockerhub/bitnami/redis",
+		"quay.io/prometheus/node-exporter:v1.8.0": "quay.io/prometheus/node-exporter:v1.8.0",
+	}
+	for image, want := range cases {
+		if got, _ := rewriteImage(image, rules); got != want {
+			t.Errorf("rewriteImage(%s) = %s, 期望 %s", image, got, want)
+		}
+	}
+
+	for _, bad := range [][]string{{"registry.example.com"}, {"=harbor.internal"}, {"a.io="}, {"https://a.io=b.io"}, {"a.io=b.io", "a.io=c.io"}} {
+		if _, err := parseImageRewrites(bad); err == nil {
+			t.Errorf("parseImageRewrites(%q) 应返回错误", bad)
+		}
+	}
+}
+
+func TestRewriteImages(t *testing.T) {
+	rules, _ := parseImageRewrites([]string{"registry.cn-hangzhou.aliyuncs.com=harbor.internal"})
+	cronJob := fakeObject("batch/v1", "CronJob", "shop", "report", map[string]interface{}{
+		"spec": map[string]interface{}{"jobTemplate": map[string]interface{}{"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+			"initContainers": []interface{}{map[string]interface{}{"name": "migrate", "image": "registry.cn-hangzhou.aliyuncs.com/shop/migrate:3"}},
+			"containers":     []interface{}{map[string]interface{}{"name": "report", "image": "registry.cn-hangzhou.aliyuncs.com/shop/report:3"}},
+		}}}}},
+	})
+	changes := rewriteImages(cronJob.Object, rules)
+	if len(changes) != 2 || changes[0].Container != "migrate" || changes[1].To != "harbor.internal/shop/report:3" {
+		t.Fatalf("改写记录 = %+v", changes)
+	}
+	containers, _, _ := unstructured.NestedSlice(cronJob.Object, "spec", "jobTemplate", "spec", "template", "spec", "containers")
+	if image := mapsOf(containers)[0]["image"]; image != "harbor.internal/shop/report:3" {
+		t.Errorf("镜像 = %v", image)
+	}
+
+	pod := fakeObject("v1", "Pod", "shop", "debug", map[string]interface{}{
+		"spec": map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "debug", "image": "registry.cn-hangzhou.aliyuncs.com/shop/debug:1"}}},
+	})
+	if changes := rewriteImages(pod.Object, rules); len(changes) != 0 {
+		t.Errorf("不应改写 Pod: %+v", changes)
+	}
+}
+
+func TestBackupImageRewrite(t *testing.T) {
+	deployment := fakeObject("apps/v1", "Deployment", "shop", "web", map[string]interface{}{
+		"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "web", "image": "registry.cn-hangzhou.aliyuncs.com/shop/web:1.2"}},
+		}}},
+	})
+	run, partitions := newFakeBackupper(t, []string{"deployments"}, nil, deployment)
+	run.imageRewrites, _ = parseImageRewrites([]string{"registry.cn-hangzhou.aliyuncs.com=harbor.internal"})
+	run.backupNamespace("shop", nil)
+
+	partition, err := partitions.get("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 镜像清单记录改写前的源镜像, 供同步到内部仓库
+	ref := partition.Images.byNamespace["shop"]["registry.cn-hangzhou.aliyuncs.com/shop/web:1.2"]
+	if ref == nil || ref.Rewritten != "harbor.internal/shop/web:1.2" {
+		t.Errorf("镜像清单 = %+v, 期望源镜像及改写后的镜像", partition.Images.byNamespace["shop"])
+	}
+	items, err := loadRestoreItems(partition.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		if item.Obj.GetKind() != "Deployment" {
+			continue
+		}
+		containers, _, _ := unstructured.NestedSlice(item.Obj.Object, "spec", "template", "spec", "containers")
+		if image := mapsOf(containers)[0]["image"]; image != "harbor.internal/shop/web:1.2" {
+			t.Errorf("备份中的镜像 = %v", image)
+		}
+		return
+	}
+	t.Fatal("备份中没有 Deployment")
+}
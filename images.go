@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 镜像清单文件, 位于备份根目录
+const (
+	imagesTextFileName = "images.txt"
+	imagesJSONFileName = "images.json"
+)
+
+// imageRef 解析后的镜像引用
+type imageRef struct {
+	Image      string   `json:"image"`
+	Registry   string   `json:"registry"`
+	Repository string   `json:"repository"`
+	Tag        string   `json:"tag,omitempty"`
+	Digest     string   `json:"digest,omitempty"`
+	UsedBy     []string `json:"usedBy"`                // Kind/名称/容器名
+	Rewritten  string   `json:"rewrittenTo,omitempty"` // --image-rewrite 改写后写入清单的镜像
+}
+
+// imageInventory 按命名空间收集备份中工作负载引用的镜像
+type imageInventory struct {
+	byNamespace map[string]map[string]*imageRef
+}
+
+func newImageInventory() *imageInventory {
+	return &imageInventory{byNamespace: make(map[string]map[string]*imageRef)}
+}
+
+// add 记录工作负载对象中引用的全部镜像, 非工作负载对象会被忽略
+// changes 为 --image-rewrite 对该对象的改写, 清单按改写前的源镜像记录, 供同步到内部镜像仓库
+func (inv *imageInventory) add(obj map[string]interface{}, changes []imageChange) {
+	podSpec := podSpecOf(obj)
+	if podSpec == nil {
+		return
+	}
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	namespace, _ := metadata["namespace"].(string)
+	name, _ := metadata["name"].(string)
+	kind, _ := obj["kind"].(string)
+
+	images := inv.byNamespace[namespace]
+	if images == nil {
+		images = make(map[string]*imageRef)
+		inv.byNamespace[namespace] = images
+	}
+	for _, c := range containersOf(podSpec) {
+		image, _ := c["image"].(string)
+		if image == "" {
+			continue
+		}
+		containerName, _ := c["name"].(string)
+		rewritten := ""
+		for _, change := range changes {
+			if change.Container == containerName && change.To == image {
+				image, rewritten = change.From, change.To
+			}
+		}
+		ref, ok := images[image]
+		if !ok {
+			ref = parseImageRef(image)
+			images[image] = ref
+		}
+		ref.Rewritten = rewritten
+		ref.UsedBy = append(ref.UsedBy, kind+"/"+name+"/"+containerName)
+	}
+}
+
+// parseImageRef 将镜像字符串拆分为仓库地址, 仓库, 标签与摘要
+func parseImageRef(image string) *imageRef {
+	ref := &imageRef{Image: image}
+	rest := image
+	if i := strings.Index(rest, "@"); i >= 0 {
+		ref.Digest = rest[i+1:]
+		rest = rest[:i]
+	}
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		ref.Tag = rest[i+1:]
+		rest = rest[:i]
+	}
+	// 第一段包含 '.' 或 ':' 或为 localhost 时视为仓库地址, 否则为 Docker Hub
+	if i := strings.Index(rest, "/"); i >= 0 && (strings.ContainsAny(rest[:i], ".:") || rest[:i] == "localhost") {
+		ref.Registry = rest[:i]
+		ref.Repository = rest[i+1:]
+	} else {
+		ref.Registry = "docker.io"
+		ref.Repository = rest
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref
+}
+
+// write 在备份根目录写出 images.txt (去重后的源镜像列表) 与 images.json (按命名空间分组的明细, 含改写后的镜像)
+func (inv *imageInventory) write(backupRoot string) error {
+	if len(inv.byNamespace) == 0 {
+		return nil
+	}
+	unique := make(map[string]struct{})
+	detail := make(map[string][]*imageRef, len(inv.byNamespace))
+	for namespace, images := range inv.byNamespace {
+		refs := make([]*imageRef, 0, len(images))
+		for image, ref := range images {
+			unique[image] = struct{}{}
+			sort.Strings(ref.UsedBy)
+			refs = append(refs, ref)
+		}
+		sort.Slice(refs, func(i, j int) bool { return refs[i].Image < refs[j].Image })
+		detail[namespace] = refs
+	}
+
+	lines := make([]string, 0, len(unique))
+	for image := range unique {
+		lines = append(lines, image)
+	}
+	sort.Strings(lines)
+	if err := os.WriteFile(filepath.Join(backupRoot, imagesTextFileName), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(detail, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(backupRoot, imagesJSONFileName), data, 0644)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// restore --immutable-conflict 的可选策略: 目标集群中已存在内容不同的不可变 ConfigMap/Secret 时如何处理
+// 不可变对象无法更新 data, 只能删除后重建; 已挂载它的 Pod 需重启才能读取新内容
+const (
+	immutableConflictSkip     = "skip"     // 保留现有对象并输出警告 (默认)
+	immutableConflictRecreate = "recreate" // 删除现有对象后按备份重建
+)
+
+// immutableRecreateTimeout 删除不可变对象后等待其消失 (如有 finalizer) 的最长时间
+const immutableRecreateTimeout = 30 * time.Second
+
+// immutableConflict handleImmutableConflict 的处理结果
+type immutableConflict int
+
+const (
+	immutableNoConflict immutableConflict = iota // 现有对象不是不可变对象, 或内容与备份一致
+	immutableDiffers                             // 内容不同, 按 skip 策略保留现有对象
+	immutableRecreated                           // 内容不同, 已删除并重建
+)
+
+// validateImmutableConflict 校验 --immutable-conflict 参数
+func validateImmutableConflict(policy string) error {
+	switch policy {
+	case immutableConflictSkip, immutableConflictRecreate:
+		return nil
+	default:
+		return fmt.Errorf("不支持的 --immutable-conflict 策略 '%s' (可选: %s, %s)", policy, immutableConflictSkip, immutableConflictRecreate)
+	}
+}
+
+// isImmutableObject 判断对象是否为 immutable: true 的 ConfigMap 或 Secret
+func isImmutableObject(obj map[string]interface{}) bool {
+	kind, _ := obj["kind"].(string)
+	immutable, _ := obj["immutable"].(bool)
+	return immutable && (kind == "ConfigMap" || kind == "Secret")
+}
+
+// immutableDataEqual 比较两个对象的内容, Secret 的 stringData 按写入后的 base64 data 计算
+func immutableDataEqual(existing, desired map[string]interface{}) bool {
+	for _, field := range []string{"data", "binaryData"} {
+		if !jsonEqual(emptyAsNil(existing[field]), emptyAsNil(effectiveData(desired, field))) {
+			return false
+		}
+	}
+	return true
+}
+
+// effectiveData 返回对象创建后的字段内容: Secret 的 stringData 由 API server 合并到 data 中
+func effectiveData(obj map[string]interface{}, field string) interface{} {
+	stringData, _ := obj["stringData"].(map[string]interface{})
+	if field != "data" || obj["kind"] != "Secret" || len(stringData) == 0 {
+		return obj[field]
+	}
+	merged := make(map[string]interface{})
+	data, _ := obj["data"].(map[string]interface{})
+	for k, v := range data {
+		merged[k] = v
+	}
+	for k, v := range stringData {
+		s, _ := v.(string)
+		merged[k] = base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	return merged
+}
+
+func emptyAsNil(v interface{}) interface{} {
+	if m, ok := v.(map[string]interface{}); ok && len(m) == 0 {
+		return nil
+	}
+	return v
+}
+
+// handleImmutableConflict 在创建返回 AlreadyExists 后调用: 现有对象为不可变 ConfigMap/Secret 且内容与备份不同时,
+// 按 policy 保留或删除重建; 删除时以 UID 为前置条件, 避免误删期间被他人重建的对象
+func handleImmutableConflict(resClient dynamic.ResourceInterface, obj *unstructured.Unstructured, policy string) (immutableConflict, error) {
+	existing, err := resClient.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return immutableNoConflict, fmt.Errorf("读取已存在的对象失败: %w", err)
+	}
+	if !isImmutableObject(existing.Object) || immutableDataEqual(existing.Object, obj.Object) {
+		return immutableNoConflict, nil
+	}
+	if policy != immutableConflictRecreate {
+		return immutableDiffers, nil
+	}
+
+	if _, err := recreateObject(resClient, obj, existing.GetUID()); err != nil {
+		return immutableDiffers, err
+	}
+	return immutableRecreated, nil
+}
+
+// recreateObject 以 UID 为前置条件删除现有对象后按 obj 重建, 对象因 finalizer 尚未消失时在 immutableRecreateTimeout 内重试创建
+func recreateObject(resClient dynamic.ResourceInterface, obj *unstructured.Unstructured, uid types.UID) (*unstructured.Unstructured, error) {
+	err := resClient.Delete(context.TODO(), obj.GetName(), metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &uid}})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("删除现有对象失败: %w", err)
+	}
+	deadline := time.Now().Add(immutableRecreateTimeout)
+	for {
+		result, err := resClient.Create(context.TODO(), obj, metav1.CreateOptions{})
+		if err == nil {
+			return result, nil
+		}
+		if !apierrors.IsAlreadyExists(err) || time.Now().After(deadline) {
+			return nil, fmt.Errorf("已删除现有对象, 但重建失败: %w", err)
+		}
+		time.Sleep(restoreHookPollInterval)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"backup-k8s/clean"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestHandleImmutableConflict(t *testing.T) {
+	configMapsGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	existing := fakeObject("v1", "ConfigMap", "app", "settings", map[string]interface{}{
+		"immutable": true,
+		"data":      map[string]interface{}{"mode": "old"},
+	})
+	existing.SetUID("uid-1")
+	desired := fakeObject("v1", "ConfigMap", "app", "settings", map[string]interface{}{
+		"immutable": true,
+		"data":      map[string]interface{}{"mode": "new"},
+	})
+	if clean.Resource(desired.DeepCopy().Object, clean.Options{})["immutable"] != true {
+		t.Fatal("备份清理不应移除 immutable 字段")
+	}
+
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), existing.DeepCopy())
+	resClient := client.Resource(configMapsGVR).Namespace("app")
+
+	conflict, err := handleImmutableConflict(resClient, existing.DeepCopy(), immutableConflictRecreate)
+	if err != nil || conflict != immutableNoConflict {
+		t.Errorf("内容一致时 = %v, %v", conflict, err)
+	}
+	conflict, err = handleImmutableConflict(resClient, desired, immutableConflictSkip)
+	if err != nil || conflict != immutableDiffers {
+		t.Errorf("skip 策略 = %v, %v", conflict, err)
+	}
+	conflict, err = handleImmutableConflict(resClient, desired, immutableConflictRecreate)
+	if err != nil || conflict != immutableRecreated {
+		t.Fatalf("recreate 策略 = %v, %v", conflict, err)
+	}
+	got, err := resClient.Get(context.TODO(), "settings", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := got.Object["data"].(map[string]interface{}); data["mode"] != "new" {
+		t.Errorf("重建后的 data = %v", data)
+	}
+
+	// 可变对象沿用已存在即跳过的行为
+	mutable := fakeObject("v1", "ConfigMap", "app", "plain", map[string]interface{}{"data": map[string]interface{}{"a": "1"}})
+	client = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), mutable.DeepCopy())
+	mutable.Object["data"] = map[string]interface{}{"a": "2"}
+	if conflict, _ := handleImmutableConflict(client.Resource(configMapsGVR).Namespace("app"), mutable, immutableConflictRecreate); conflict != immutableNoConflict {
+		t.Errorf("可变对象 = %v", conflict)
+	}
+}
+
+func TestImmutableDataEqualStringData(t *testing.T) {
+	existing := map[string]interface{}{"kind": "Secret", "immutable": true, "data": map[string]interface{}{"token": "YWJj"}}
+	desired := map[string]interface{}{"kind": "Secret", "immutable": true, "stringData": map[string]interface{}{"token": "abc"}}
+	if !immutableDataEqual(existing, desired) {
+		t.Error("stringData 应按 base64 编码后与 data 比较")
+	}
+	if err := validateImmutableConflict("replace"); err == nil {
+		t.Error("未知策略应返回错误")
+	}
+}
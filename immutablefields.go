@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// restore --immutable-fields 的可选策略: 按 --on-conflict 或 --server-side 更新已存在的对象时,
+// API server 因不可变字段 (如 Service 的 clusterIP, PVC 的 spec, Job 的 selector 与 template) 与备份不同而拒绝更新时如何处理
+// immutable: true 的 ConfigMap/Secret 按 --immutable-conflict 处理
+const (
+	immutableFieldsFail     = "fail"          // 报告不可变字段, 该对象计为失败 (默认)
+	immutableFieldsKeep     = "keep-existing" // 不可变字段保留现有对象的值, 其余字段按备份更新
+	immutableFieldsRecreate = "recreate"      // 删除现有对象后按备份重建
+)
+
+// validateImmutableFields 校验 --immutable-fields 参数
+func validateImmutableFields(policy string) error {
+	switch policy {
+	case immutableFieldsFail, immutableFieldsKeep, immutableFieldsRecreate:
+		return nil
+	default:
+		return fmt.Errorf("不支持的 --immutable-fields 策略 '%s' (可选: %s, %s, %s)", policy, immutableFieldsFail, immutableFieldsKeep, immutableFieldsRecreate)
+	}
+}
+
+// immutableFieldsError 更新因不可变字段被拒绝, 错误信息列出字段与可选的处理方式
+type immutableFieldsError struct {
+	fields []string
+	err    error
+}
+
+func (e *immutableFieldsError) Error() string {
+	return fmt.Sprintf("不可变字段 %s 与现有对象不同, 无法原地更新 (使用 --immutable-fields=%s 保留现有值, 或 --immutable-fields=%s 删除后重建): %v",
+		strings.Join(e.fields, ", "), immutableFieldsKeep, immutableFieldsRecreate, e.err)
+}
+
+func (e *immutableFieldsError) Unwrap() error { return e.err }
+
+// immutableFieldPaths 返回 API server 以字段不可变为由拒绝请求时涉及的字段路径 (如 spec.selector), 其他错误返回 nil
+func immutableFieldPaths(err error) []string {
+	var status apierrors.APIStatus
+	if !apierrors.IsInvalid(err) || !errors.As(err, &status) || status.Status().Details == nil {
+		return nil
+	}
+	var fields []string
+	seen := make(map[string]bool)
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Field == "" || seen[cause.Field] || !strings.Contains(cause.Message, "immutable") {
+			continue
+		}
+		seen[cause.Field] = true
+		fields = append(fields, cause.Field)
+	}
+	return fields
+}
+
+// updateWithImmutableFields 以 update 更新已存在的对象, 因不可变字段被拒绝时按 policy 处理:
+// keep-existing 将这些字段改为现有对象的值后重试一次, 返回保留的字段; recreate 删除后重建, 返回重建的对象
+func updateWithImmutableFields(resClient dynamic.ResourceInterface, obj *unstructured.Unstructured, policy string, update func(*unstructured.Unstructured) error) (kept []string, recreated *unstructured.Unstructured, err error) {
+	err = update(obj)
+	fields := immutableFieldPaths(err)
+	if len(fields) == 0 || isImmutableObject(obj.Object) {
+		return nil, nil, err
+	}
+	if policy == immutableFieldsFail {
+		return nil, nil, &immutableFieldsError{fields: fields, err: err}
+	}
+	existing, err := resClient.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取已存在的对象失败: %w", err)
+	}
+	if policy == immutableFieldsRecreate {
+		recreated, err := recreateObject(resClient, obj, existing.GetUID())
+		return nil, recreated, err
+	}
+
+	desired := obj.DeepCopy()
+	for _, field := range fields {
+		path := immutableFieldSegments(field)
+		if value, found, _ := unstructured.NestedFieldCopy(existing.Object, path...); found {
+			unstructured.SetNestedField(desired.Object, value, path...)
+		} else {
+			unstructured.RemoveNestedField(desired.Object, path...)
+		}
+	}
+	if err := update(desired); err != nil {
+		if remaining := immutableFieldPaths(err); len(remaining) > 0 {
+			return nil, nil, &immutableFieldsError{fields: remaining, err: err}
+		}
+		return nil, nil, err
+	}
+	return fields, nil, nil
+}
+
+// immutableFieldSegments 将错误中的字段路径转换为 unstructured 的路径; 列表元素 (如 spec.ports[0].nodePort) 以整个列表为单位
+func immutableFieldSegments(field string) []string {
+	var path []string
+	for _, segment := range strings.Split(field, ".") {
+		name, _, indexed := strings.Cut(segment, "[")
+		path = append(path, name)
+		if indexed {
+			break
+		}
+	}
+	return path
+}
+
+// describeKeptFields 输出中说明保留了现有值的不可变字段, 没有时返回空字符串
+func describeKeptFields(kept []string) string {
+	if len(kept) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (不可变字段 %s 保留现有值)", strings.Join(kept, ", "))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestUpdateWithImmutableFields(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+	selector := func(uid string) map[string]interface{} {
+		return map[string]interface{}{"matchLabels": map[string]interface{}{"batch.kubernetes.io/controller-uid": uid}}
+	}
+	existing := fakeObject("batch/v1", "Job", "jobs", "migrate", map[string]interface{}{
+		"spec": map[string]interface{}{"selector": selector("live"), "backoffLimit": int64(3)},
+	})
+	desired := fakeObject("batch/v1", "Job", "jobs", "migrate", map[string]interface{}{
+		"spec": map[string]interface{}{"selector": selector("backup"), "backoffLimit": int64(6)},
+	})
+
+	// 模拟 API server 的校验: selector 与现有对象不同时拒绝更新
+	newClient := func() (*dynamicfake.FakeDynamicClient, func(*unstructured.Unstructured) error) {
+		client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), existing.DeepCopy())
+		resClient := client.Resource(gvr).Namespace("jobs")
+		update := func(obj *unstructured.Unstructured) error {
+			current, err := resClient.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(current.Object["spec"].(map[string]interface{})["selector"], obj.Object["spec"].(map[string]interface{})["selector"]) {
+				return apierrors.NewInvalid(schema.GroupKind{Group: "batch", Kind: "Job"}, obj.GetName(), field.ErrorList{
+					field.Invalid(field.NewPath("spec", "selector"), obj.Object["spec"], "field is immutable"),
+				})
+			}
+			_, err = resClient.Update(context.TODO(), obj, metav1.UpdateOptions{})
+			return err
+		}
+		return client, update
+	}
+
+	client, update := newClient()
+	_, _, err := updateWithImmutableFields(client.Resource(gvr).Namespace("jobs"), desired, immutableFieldsFail, update)
+	var immErr *immutableFieldsError
+	if !errors.As(err, &immErr) || !strings.Contains(err.Error(), "spec.selector") || !strings.Contains(err.Error(), "--immutable-fields=keep-existing") {
+		t.Errorf("fail 策略应返回列出字段与处理方式的错误, 实际: %v", err)
+	}
+
+	client, update = newClient()
+	resClient := client.Resource(gvr).Namespace("jobs")
+	kept, recreated, err := updateWithImmutableFields(resClient, desired, immutableFieldsKeep, update)
+	if err != nil || recreated != nil || !reflect.DeepEqual(kept, []string{"spec.selector"}) {
+		t.Fatalf("keep-existing = %v, %v, %v", kept, recreated, err)
+	}
+	got, _ := resClient.Get(context.TODO(), "migrate", metav1.GetOptions{})
+	if limit, _, _ := unstructured.NestedInt64(got.Object, "spec", "backoffLimit"); limit != 6 {
+		t.Errorf("其余字段应按备份更新, backoffLimit = %d", limit)
+	}
+	if uid, _, _ := unstructured.NestedString(got.Object, "spec", "selector", "matchLabels", "batch.kubernetes.io/controller-uid"); uid != "live" {
+		t.Errorf("不可变字段应保留现有值, selector uid = %q", uid)
+	}
+	if desired.Object["spec"].(map[string]interface{})["selector"] == nil {
+		t.Error("不应修改传入的对象")
+	}
+
+	client, update = newClient()
+	resClient = client.Resource(gvr).Namespace("jobs")
+	_, recreated, err = updateWithImmutableFields(resClient, desired, immutableFieldsRecreate, update)
+	if err != nil || recreated == nil {
+		t.Fatalf("recreate = %v, %v", recreated, err)
+	}
+	got, _ = resClient.Get(context.TODO(), "migrate", metav1.GetOptions{})
+	if uid, _, _ := unstructured.NestedString(got.Object, "spec", "selector", "matchLabels", "batch.kubernetes.io/controller-uid"); uid != "backup" {
+		t.Errorf("重建后应为备份中的对象, selector uid = %q", uid)
+	}
+
+	if path := immutableFieldSegments("spec.ports[0].nodePort"); !reflect.DeepEqual(path, []string{"spec", "ports"}) {
+		t.Errorf("immutableFieldSegments = %v", path)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/metadata"
+)
+
+// incrementalBaseline --incremental 的比较基线, 即状态目录中上一次备份的对象版本索引
+// 每类资源先以 PartialObjectMetadata 列出 (只含 metadata, 不含 spec/data), 与基线的 UID 和 resourceVersion
+// 比较后只获取有变化的完整对象, 使请求的数据量与内存占用取决于变更量而非集群规模
+type incrementalBaseline struct {
+	Backup     string // 基线所属的备份目录
+	metaClient metadata.Interface
+	objects    map[string]stateObject
+
+	mu        sync.Mutex // 保护 unchanged, 使多个命名空间可以并行备份
+	unchanged map[string]stateObject
+}
+
+// newIncrementalBaseline 由状态目录中的版本索引创建基线
+func newIncrementalBaseline(metaClient metadata.Interface, versions stateVersions) *incrementalBaseline {
+	return &incrementalBaseline{
+		Backup:     versions.Backup,
+		metaClient: metaClient,
+		objects:    versions.Objects,
+		unchanged:  make(map[string]stateObject),
+	}
+}
+
+// listChanged 列出命名空间 (为空表示集群范围) 中相对基线新增或修改过的完整对象, 返回对象与未变化的对象数
+// 有变化的对象超过一半时改为一次完整 list, 否则逐个 get
+func (inc *incrementalBaseline) listChanged(b *Backupper, resInfo ResourceInfo, namespace string) ([]unstructured.Unstructured, int, error) {
+	list, err := inc.metaClient.Resource(resInfo.GVR).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+	var changed []string
+	unchanged := make(map[string]stateObject)
+	for _, item := range list.Items {
+		key := objectKey(resInfo.Kind, namespace, item.Name)
+		if prev, ok := inc.objects[key]; ok && prev.ResourceVersion == item.ResourceVersion && prev.UID == string(item.UID) {
+			unchanged[key] = prev
+			continue
+		}
+		changed = append(changed, item.Name)
+	}
+	inc.mu.Lock()
+	for key, obj := range unchanged {
+		inc.unchanged[key] = obj
+	}
+	inc.mu.Unlock()
+	if len(changed) == 0 {
+		return nil, len(unchanged), nil
+	}
+
+	client := b.resourceClient(resInfo.GVR).Namespace(namespace)
+	if len(changed)*2 > len(list.Items) {
+		full, err := client.List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, 0, err
+		}
+		objects := make([]unstructured.Unstructured, 0, len(changed))
+		for _, obj := range full.Items {
+			if _, same := unchanged[objectKey(resInfo.Kind, namespace, obj.GetName())]; !same {
+				objects = append(objects, obj)
+			}
+		}
+		return objects, len(unchanged), nil
+	}
+	objects := make([]unstructured.Unstructured, 0, len(changed))
+	for _, name := range changed {
+		obj, err := client.Get(context.TODO(), name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue // 列出之后被删除
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("获取 %s 失败: %w", name, err)
+		}
+		objects = append(objects, *obj)
+	}
+	return objects, len(unchanged), nil
+}
+
+// carried 返回本次未变化的对象在基线中的条目, 保存版本索引时与本次写入的对象合并
+func (inc *incrementalBaseline) carried() map[string]stateObject {
+	if inc == nil {
+		return nil
+	}
+	inc.mu.Lock()
+	defer inc.mu.Unlock()
+	return inc.unchanged
+}
+
+// listObjects 列出命名空间 (为空表示集群范围) 中的对象, 增量备份时只返回有变化的对象及未变化的对象数
+// full 为 true 时即使增量备份也返回全部对象 (如用于确定镜像拉取凭据引用的 ServiceAccount)
+func (b *Backupper) listObjects(resInfo ResourceInfo, namespace string, full bool) ([]unstructured.Unstructured, int, error) {
+	if b.incremental != nil && !full {
+		return b.incremental.listChanged(b, resInfo, namespace)
+	}
+	list, err := b.resourceClient(resInfo.GVR).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+	return list.Items, 0, nil
+}
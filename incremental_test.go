@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	metadatafake "k8s.io/client-go/metadata/fake"
+)
+
+func TestIncrementalBackupFetchesChangedObjects(t *testing.T) {
+	partial := func(kind, namespace, name, rv string) *metav1.PartialObjectMetadata {
+		obj := partialObject("v1", kind, namespace, name)
+		obj.UID, obj.ResourceVersion = types.UID("uid-"+name), rv
+		return obj
+	}
+	scheme := metadatafake.NewTestScheme()
+	metav1.AddMetaToScheme(scheme)
+	metaClient := metadatafake.NewSimpleMetadataClient(scheme,
+		partial("ConfigMap", "web", "settings", "1"),
+		partial("ConfigMap", "web", "features", "1"),
+		partial("ConfigMap", "web", "fresh", "1"),
+		partial("PersistentVolume", "", "pv-data", "1"),
+	)
+	run, partitions := newFakeBackupper(t, []string{"configmaps", "persistentvolumes"}, nil,
+		fakeObject("v1", "ConfigMap", "web", "settings", nil),
+		fakeObject("v1", "ConfigMap", "web", "features", nil),
+		fakeObject("v1", "ConfigMap", "web", "fresh", nil),
+		fakeObject("v1", "PersistentVolume", "", "pv-data", nil),
+	)
+	run.incremental = newIncrementalBaseline(metaClient, stateVersions{
+		Backup: "/backups/k8s-backup-1",
+		Objects: map[string]stateObject{
+			"ConfigMap/web/settings":    {UID: "uid-settings", ResourceVersion: "1", Digest: "sha256:a"},
+			"ConfigMap/web/features":    {UID: "uid-features", ResourceVersion: "1", Digest: "sha256:b"},
+			"PersistentVolume//pv-data": {UID: "uid-pv-data", ResourceVersion: "0"},
+		},
+	})
+	run.backupNamespace("web", nil)
+	run.backupClusterResources()
+
+	root := partitions.byName[""].Root
+	for rel, want := range map[string]bool{
+		"web/configmaps/fresh.yaml":              true,
+		"web/configmaps/settings.yaml":           false,
+		"web/configmaps/features.yaml":           false,
+		"_global/persistentvolumes/pv-data.yaml": true,
+	} {
+		if _, err := os.Stat(filepath.Join(root, rel)); (err == nil) != want {
+			t.Errorf("%s 存在 = %v, 期望 %v", rel, err == nil, want)
+		}
+	}
+	if run.totalResources != 2 {
+		t.Errorf("备份资源数 = %d, 期望 2 (只写入有变化的对象)", run.totalResources)
+	}
+	carried := run.incremental.carried()
+	if len(carried) != 2 || carried["ConfigMap/web/settings"].Digest != "sha256:a" {
+		t.Errorf("沿用的基线条目 = %v", carried)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// installOptions install 子命令的参数
+type installOptions struct {
+	namespace   string
+	name        string
+	schedule    string
+	image       string
+	pvc         string
+	storageSize string
+	backupArgs  []string
+	systemd     bool
+	systemdDir  string
+	kubeconfig  string
+}
+
+// runInstall 实现 install 子命令: 输出在集群内定时运行备份所需的全部清单
+// 清单输出到 stdout, 可直接通过管道交给 kubectl apply -f -
+func runInstall(args []string) {
+	var opts installOptions
+	fs := pflag.NewFlagSet("install", pflag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: k8s-backup install [参数] [-- 备份参数...]\n")
+		fmt.Fprintf(os.Stderr, "示例: k8s-backup install --schedule \"0 2 * * *\" --image registry.example.com/k8s-backup:v2 -- --skip-secrets | kubectl apply -f -\n")
+		fmt.Fprintf(os.Stderr, "      k8s-backup install --systemd --schedule \"0 2 * * *\" -- --output-dir /var/backups/k8s\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.namespace, "namespace", "k8s-backup", "部署备份任务的命名空间")
+	fs.StringVar(&opts.name, "name", "k8s-backup", "CronJob, ServiceAccount 及 RBAC 对象的名称")
+	fs.StringVar(&opts.schedule, "schedule", "0 2 * * *", "CronJob 的调度表达式")
+	fs.StringVar(&opts.image, "image", "", "包含本工具的容器镜像 (必填)")
+	fs.StringVar(&opts.pvc, "pvc", "", "保存备份的已有PVC名称, 为空时生成一个新的PVC")
+	fs.StringVar(&opts.storageSize, "storage-size", "10Gi", "新生成PVC的容量")
+	fs.BoolVar(&opts.systemd, "systemd", false, "改为生成在管理主机上运行的 systemd service/timer 单元")
+	fs.StringVar(&opts.systemdDir, "systemd-dir", "", "将 systemd 单元写入该目录 (如 /etc/systemd/system), 为空时输出到 stdout")
+	fs.StringVar(&opts.kubeconfig, "kubeconfig", "", "systemd 单元中使用的kubeconfig文件路径 (默认取 KUBECONFIG 环境变量)")
+	fs.Parse(args)
+	opts.backupArgs = fs.Args()
+
+	if opts.systemd {
+		if err := installSystemd(opts); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if opts.image == "" {
+		fmt.Fprintln(os.Stderr, "错误: 必须通过 --image 指定包含本工具的容器镜像")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	data, err := marshalYAMLDocuments(installManifests(opts))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 生成清单失败: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(data)
+}
+
+// allResourceTypes 返回 resourceMap 中除运行时对象与可选预设外的全部资源类型, 按依赖顺序排序
+func allResourceTypes() []string {
+	var resourceTypes []string
+	for resType, resInfo := range resourceMap {
+		if !resInfo.Runtime && resInfo.Preset == "" {
+			resourceTypes = append(resourceTypes, resType)
+		}
+	}
+	sortResourceTypes(resourceTypes)
+	return resourceTypes
+}
+
+// runtimeResourceTypes 返回 --include-runtime-objects 额外备份的资源类型, 按依赖顺序排序
+func runtimeResourceTypes() []string {
+	var resourceTypes []string
+	for resType, resInfo := range resourceMap {
+		if resInfo.Runtime {
+			resourceTypes = append(resourceTypes, resType)
+		}
+	}
+	sortResourceTypes(resourceTypes)
+	return resourceTypes
+}
+
+// installManifests 生成 Namespace, ServiceAccount, ClusterRole/Binding, PVC 与 CronJob
+func installManifests(opts installOptions) []interface{} {
+	labels := map[string]string{"app.kubernetes.io/name": "k8s-backup"}
+	meta := func(namespaced bool) map[string]interface{} {
+		m := map[string]interface{}{"name": opts.name, "labels": labels}
+		if namespaced {
+			m["namespace"] = opts.namespace
+		}
+		return m
+	}
+
+	// 备份参数启用了运行时对象或预设时, ClusterRole 需要额外授予 ReplicaSet/Pod 或预设资源的读取权限
+	resourceTypes := allResourceTypes()
+	for _, arg := range opts.backupArgs {
+		if arg == "--include-runtime-objects" {
+			resourceTypes = append(resourceTypes, runtimeResourceTypes()...)
+			break
+		}
+	}
+	resourceTypes = append(resourceTypes, presetResourceTypes(presetsFromArgs(opts.backupArgs))...)
+
+	claimName := opts.pvc
+	docs := []interface{}{
+		map[string]interface{}{
+			"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": opts.namespace},
+		},
+		map[string]interface{}{
+			"apiVersion": "v1", "kind": "ServiceAccount", "metadata": meta(true),
+		},
+		map[string]interface{}{
+			"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRole", "metadata": meta(false),
+			"rules": append([]interface{}{ssarRule(), clusterPolicyRule(), namespaceListRule()}, readRules(resourceTypes, func(ResourceInfo) bool { return true })...),
+		},
+		map[string]interface{}{
+			"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRoleBinding", "metadata": meta(false),
+			"roleRef": map[string]interface{}{
+				"apiGroup": "rbac.authorization.k8s.io", "kind": "ClusterRole", "name": opts.name,
+			},
+			"subjects": []interface{}{
+				map[string]interface{}{"kind": "ServiceAccount", "name": opts.name, "namespace": opts.namespace},
+			},
+		},
+	}
+	if claimName == "" {
+		claimName = opts.name + "-data"
+		pvcMeta := meta(true)
+		pvcMeta["name"] = claimName
+		docs = append(docs, map[string]interface{}{
+			"apiVersion": "v1", "kind": "PersistentVolumeClaim", "metadata": pvcMeta,
+			"spec": map[string]interface{}{
+				"accessModes": []string{"ReadWriteOnce"},
+				"resources":   map[string]interface{}{"requests": map[string]interface{}{"storage": opts.storageSize}},
+			},
+		})
+	}
+
+	containerArgs := append([]string{"--output-dir", "/backups"}, opts.backupArgs...)
+	docs = append(docs, map[string]interface{}{
+		"apiVersion": "batch/v1", "kind": "CronJob", "metadata": meta(true),
+		"spec": map[string]interface{}{
+			"schedule":                   opts.schedule,
+			"concurrencyPolicy":          "Forbid",
+			"successfulJobsHistoryLimit": 3,
+			"failedJobsHistoryLimit":     3,
+			"jobTemplate": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"backoffLimit": 1,
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{"labels": labels},
+						"spec": map[string]interface{}{
+							"serviceAccountName": opts.name,
+							"restartPolicy":      "OnFailure",
+							"containers": []interface{}{
+								map[string]interface{}{
+									"name":  "backup",
+									"image": opts.image,
+									"args":  containerArgs,
+									"volumeMounts": []interface{}{
+										map[string]interface{}{"name": "backups", "mountPath": "/backups"},
+									},
+								},
+							},
+							"volumes": []interface{}{
+								map[string]interface{}{
+									"name":                  "backups",
+									"persistentVolumeClaim": map[string]interface{}{"claimName": claimName},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+	return docs
+}
+
+// marshalYAMLDocuments 将多个对象序列化为以 --- 分隔的多文档YAML
+func marshalYAMLDocuments(docs []interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, doc := range docs {
+		data, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"backup-k8s/clean"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/metadata"
+)
+
+// --metadata-only 的输出: <输出目录>/k8s-inventory-<时间>/ 下的对象清单与相对上一次清单的变更
+const (
+	inventoryDirPrefix       = "k8s-inventory-"
+	inventoryFileName        = "inventory.yaml"
+	inventoryChangesFileName = "changes.yaml"
+)
+
+// inventoryEntry 清单中的一个对象, 只含元数据, 不含 spec/data
+type inventoryEntry struct {
+	APIVersion      string            `yaml:"apiVersion"`
+	Kind            string            `yaml:"kind"`
+	Namespace       string            `yaml:"namespace,omitempty"`
+	Name            string            `yaml:"name"`
+	UID             string            `yaml:"uid"`
+	ResourceVersion string            `yaml:"resourceVersion"`
+	Generation      int64             `yaml:"generation,omitempty"`
+	Labels          map[string]string `yaml:"labels,omitempty"`
+	Annotations     map[string]string `yaml:"annotations,omitempty"` // 不含 last-applied-configuration (其内容即完整 spec)
+	Owners          []string          `yaml:"owners,omitempty"`      // Kind/名称
+}
+
+func (e inventoryEntry) key() string {
+	return objectKey(e.Kind, e.Namespace, e.Name)
+}
+
+// inventoryChanges 与上一次清单比较的结果, 修改依据 resourceVersion, 同名对象 UID 变化 (被删除后重建) 同样计为修改
+type inventoryChanges struct {
+	Previous string   `yaml:"previous"`
+	Added    []string `yaml:"added,omitempty"`
+	Removed  []string `yaml:"removed,omitempty"`
+	Modified []string `yaml:"modified,omitempty"`
+}
+
+func (c inventoryChanges) empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Modified) == 0
+}
+
+// collectInventory 通过 metadata 客户端分页列出各资源类型的对象元数据, 只保留目标命名空间 (与 clusterScoped 时的集群级资源)
+// 无法列出的资源类型记入返回的失败列表, 不影响其余类型
+func collectInventory(metaClient metadata.Interface, resourceTypes, namespaces []string, clusterScoped bool) ([]inventoryEntry, []string) {
+	targets := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		targets[ns] = true
+	}
+	var entries []inventoryEntry
+	var failed []string
+	for _, resType := range resourceTypes {
+		resInfo, exists := resourceMap[resType]
+		if !exists || (!resInfo.Namespaced && !clusterScoped) {
+			continue
+		}
+		opts := metav1.ListOptions{Limit: verifyPageSize}
+		for {
+			list, err := metaClient.Resource(resInfo.GVR).List(context.TODO(), opts)
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%s: %v", resInfo.Kind, err))
+				break
+			}
+			for _, item := range list.Items {
+				if resInfo.Namespaced && !targets[item.Namespace] {
+					continue
+				}
+				entries = append(entries, newInventoryEntry(resInfo, &item.ObjectMeta))
+			}
+			if opts.Continue = list.Continue; opts.Continue == "" {
+				break
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key() < entries[j].key() })
+	return entries, failed
+}
+
+func newInventoryEntry(resInfo ResourceInfo, m *metav1.ObjectMeta) inventoryEntry {
+	e := inventoryEntry{
+		APIVersion:      resInfo.GVR.GroupVersion().String(),
+		Kind:            resInfo.Kind,
+		Namespace:       m.Namespace,
+		Name:            m.Name,
+		UID:             string(m.UID),
+		ResourceVersion: m.ResourceVersion,
+		Generation:      m.Generation,
+		Labels:          m.Labels,
+	}
+	for k, v := range m.Annotations {
+		if k == clean.LastAppliedAnnotation {
+			continue
+		}
+		if e.Annotations == nil {
+			e.Annotations = make(map[string]string)
+		}
+		e.Annotations[k] = v
+	}
+	for _, owner := range m.OwnerReferences {
+		e.Owners = append(e.Owners, owner.Kind+"/"+owner.Name)
+	}
+	return e
+}
+
+// diffInventory 比较两次清单, 结果中的对象以 "Kind 命名空间/名称" 描述并按字母排序
+func diffInventory(previousName string, previous, current []inventoryEntry) inventoryChanges {
+	changes := inventoryChanges{Previous: previousName}
+	prev := make(map[string]inventoryEntry, len(previous))
+	for _, e := range previous {
+		prev[e.key()] = e
+	}
+	for _, e := range current {
+		old, ok := prev[e.key()]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, e.describe())
+		case old.UID != e.UID || old.ResourceVersion != e.ResourceVersion:
+			changes.Modified = append(changes.Modified, e.describe())
+		}
+		delete(prev, e.key())
+	}
+	for _, e := range prev {
+		changes.Removed = append(changes.Removed, e.describe())
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Modified)
+	return changes
+}
+
+func (e inventoryEntry) describe() string {
+	if e.Namespace != "" {
+		return fmt.Sprintf("%s %s/%s", e.Kind, e.Namespace, e.Name)
+	}
+	return fmt.Sprintf("%s %s", e.Kind, e.Name)
+}
+
+// findPreviousInventory 查找 outputDir 中早于 current 的最近一次清单目录, 不存在时返回空字符串
+func findPreviousInventory(outputDir, current string) string {
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		return ""
+	}
+	currentName := filepath.Base(current)
+	previous := ""
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || !strings.HasPrefix(name, inventoryDirPrefix) || name >= currentName || name <= previous {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(outputDir, name, inventoryFileName)); err == nil {
+			previous = name
+		}
+	}
+	if previous == "" {
+		return ""
+	}
+	return filepath.Join(outputDir, previous)
+}
+
+// writeInventory 写入 inventory.yaml, 存在上一次清单时同时写入 changes.yaml, 返回变更 (无上一次清单时为 nil)
+func writeInventory(outputDir, dirName string, entries []inventoryEntry) (*inventoryChanges, error) {
+	dir := filepath.Join(outputDir, dirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := writeYAMLFile(filepath.Join(dir, inventoryFileName), entries); err != nil {
+		return nil, err
+	}
+	previousDir := findPreviousInventory(outputDir, dir)
+	if previousDir == "" {
+		return nil, nil
+	}
+	var previous []inventoryEntry
+	if err := readYAMLFile(filepath.Join(previousDir, inventoryFileName), &previous); err != nil {
+		return nil, fmt.Errorf("读取上一次清单 '%s' 失败: %w", previousDir, err)
+	}
+	changes := diffInventory(filepath.Base(previousDir), previous, entries)
+	return &changes, writeYAMLFile(filepath.Join(dir, inventoryChangesFileName), changes)
+}
+
+// runInventory 执行 --metadata-only: 列出对象元数据, 写入清单与变更并输出汇总
+func runInventory(metaClient metadata.Interface, resourceTypes, namespaces []string, clusterScoped bool, outputDir, dirName string) {
+	entries, failed := collectInventory(metaClient, resourceTypes, namespaces, clusterScoped)
+	for _, f := range failed {
+		fmt.Fprintf(os.Stderr, "警告: 无法列出 %s\n", f)
+	}
+	changes, err := writeInventory(outputDir, dirName, entries)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 写入对象清单失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(logOut, "\n对象清单: %s (%d 个对象)\n", filepath.Join(outputDir, dirName, inventoryFileName), len(entries))
+	switch {
+	case changes == nil:
+		fmt.Fprintln(logOut, "没有上一次清单, 未生成变更")
+	case changes.empty():
+		fmt.Fprintf(logOut, "与 %s 相比没有变化\n", changes.Previous)
+	default:
+		fmt.Fprintf(logOut, "与 %s 相比: 新增 %d 个, 删除 %d 个, 修改 %d 个, 详见 %s\n",
+			changes.Previous, len(changes.Added), len(changes.Removed), len(changes.Modified), inventoryChangesFileName)
+	}
+}
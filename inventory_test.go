@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"backup-k8s/clean"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metadatafake "k8s.io/client-go/metadata/fake"
+)
+
+func TestCollectInventory(t *testing.T) {
+	rs := partialObject("apps/v1", "ReplicaSet", "web", "frontend-abc")
+	rs.OwnerReferences = []metav1.OwnerReference{{Kind: "Deployment", Name: "frontend"}}
+	cm := partialObject("v1", "ConfigMap", "web", "settings")
+	cm.Labels = map[string]string{"app": "frontend"}
+	cm.Annotations = map[string]string{clean.LastAppliedAnnotation: `{"data":{}}`, "team": "web"}
+	scheme := metadatafake.NewTestScheme()
+	metav1.AddMetaToScheme(scheme)
+	metaClient := metadatafake.NewSimpleMetadataClient(scheme, rs, cm, partialObject("v1", "ConfigMap", "kube-system", "coredns"))
+
+	entries, failed := collectInventory(metaClient, []string{"configmaps", "replicasets"}, []string{"web"}, true)
+	if len(failed) != 0 || len(entries) != 2 {
+		t.Fatalf("清单 = %+v, 失败 = %v, 期望 web 中的 2 个对象", entries, failed)
+	}
+	if e := entries[0]; e.Kind != "ConfigMap" || e.Labels["app"] != "frontend" || len(e.Annotations) != 1 || e.Annotations["team"] != "web" {
+		t.Errorf("ConfigMap 条目 = %+v, 应保留标签与注解, 去掉 last-applied-configuration", e)
+	}
+	if e := entries[1]; len(e.Owners) != 1 || e.Owners[0] != "Deployment/frontend" {
+		t.Errorf("ReplicaSet owners = %v", e.Owners)
+	}
+}
+
+func TestWriteInventoryChanges(t *testing.T) {
+	dir := t.TempDir()
+	first := []inventoryEntry{
+		{Kind: "ConfigMap", Namespace: "web", Name: "a", UID: "1", ResourceVersion: "10"},
+		{Kind: "ConfigMap", Namespace: "web", Name: "b", UID: "2", ResourceVersion: "10"},
+		{Kind: "ConfigMap", Namespace: "web", Name: "c", UID: "3", ResourceVersion: "10"},
+	}
+	changes, err := writeInventory(dir, inventoryDirPrefix+"20240501-000000", first)
+	if err != nil || changes != nil {
+		t.Fatalf("首次清单: changes = %v, err = %v", changes, err)
+	}
+	second := []inventoryEntry{
+		{Kind: "ConfigMap", Namespace: "web", Name: "a", UID: "1", ResourceVersion: "10"},
+		{Kind: "ConfigMap", Namespace: "web", Name: "b", UID: "2", ResourceVersion: "11"},
+		{Kind: "ConfigMap", Namespace: "web", Name: "d", UID: "4", ResourceVersion: "12"},
+	}
+	changes, err = writeInventory(dir, inventoryDirPrefix+"20240501-000500", second)
+	if err != nil || changes == nil {
+		t.Fatalf("第二次清单: changes = %v, err = %v", changes, err)
+	}
+	if changes.Previous != inventoryDirPrefix+"20240501-000000" {
+		t.Errorf("previous = %s", changes.Previous)
+	}
+	if len(changes.Added) != 1 || changes.Added[0] != "ConfigMap web/d" ||
+		len(changes.Removed) != 1 || changes.Removed[0] != "ConfigMap web/c" ||
+		len(changes.Modified) != 1 || changes.Modified[0] != "ConfigMap web/b" {
+		t.Errorf("变更 = %+v", changes)
+	}
+	if _, err := os.Stat(filepath.Join(dir, inventoryDirPrefix+"20240501-000500", inventoryChangesFileName)); err != nil {
+		t.Errorf("缺少 %s: %v", inventoryChangesFileName, err)
+	}
+}
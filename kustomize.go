@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// kustomizeEnv 参与对比的一个环境及其备份中的对象
+type kustomizeEnv struct {
+	Name      string
+	Dir       string
+	Namespace string                                // 环境中所有命名空间级对象共同的命名空间, 不唯一时为空
+	Objects   map[string]*unstructured.Unstructured // 以 kustomizeKey 为键
+}
+
+// jsonPatchOp 一条 JSON6902 补丁操作
+type jsonPatchOp struct {
+	Op    string      `yaml:"op"`
+	Path  string      `yaml:"path"`
+	Value interface{} `yaml:"value"`
+}
+
+// runKustomize 实现 kustomize 子命令: 对比多个环境的备份, 生成共享 base 与各环境的 overlay
+func runKustomize(args []string) {
+	var outputDir string
+	fs := pflag.NewFlagSet("kustomize", pflag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: k8s-backup kustomize [参数] <环境名>=<备份目录> <环境名>=<备份目录> ...\n")
+		fmt.Fprintf(os.Stderr, "示例: k8s-backup kustomize -o gitops staging=./k8s-backup-20240101-020000/app-staging prod=./prod-backup/app\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVarP(&outputDir, "output-dir", "o", "kustomize", "生成 base/ 与 overlays/<环境名>/ 的目录")
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "错误: 至少需要两个环境的备份")
+		fs.Usage()
+		os.Exit(2)
+	}
+	var envs []*kustomizeEnv
+	for _, arg := range fs.Args() {
+		name, dir, ok := strings.Cut(arg, "=")
+		if !ok || name == "" || dir == "" {
+			fmt.Fprintf(os.Stderr, "错误: 参数格式应为 <环境名>=<备份目录>, 实际为 '%s'\n", arg)
+			os.Exit(2)
+		}
+		env, err := loadKustomizeEnv(name, dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 读取环境 %s 的备份失败: %v\n", name, err)
+			os.Exit(1)
+		}
+		envs = append(envs, env)
+	}
+
+	if err := writeKustomizeTree(outputDir, envs); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 生成 Kustomize 目录失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(logOut, "已生成 %s/base 与 %d 个 overlay\n", outputDir, len(envs))
+	fmt.Fprintf(logOut, "预览: kubectl kustomize %s\n", filepath.Join(outputDir, "overlays", envs[0].Name))
+}
+
+// kustomizeKey 跨环境匹配对象的键: 不含命名空间, 以便对比不同命名空间中的同一应用
+func kustomizeKey(obj *unstructured.Unstructured) string {
+	return obj.GetKind() + "/" + obj.GetName()
+}
+
+// loadKustomizeEnv 读取一个环境的备份, 命名空间唯一时从对象中移除, 改由 overlay 的 namespace 字段设置
+func loadKustomizeEnv(name, dir string) (*kustomizeEnv, error) {
+	items, err := loadRestoreItems(dir)
+	if err != nil {
+		return nil, err
+	}
+	env := &kustomizeEnv{Name: name, Dir: dir, Objects: make(map[string]*unstructured.Unstructured)}
+	namespaces := make(map[string]struct{})
+	for _, item := range items {
+		if item.Obj.GetKind() == "Namespace" {
+			continue
+		}
+		if ns := item.Obj.GetNamespace(); ns != "" {
+			namespaces[ns] = struct{}{}
+		}
+		key := kustomizeKey(item.Obj)
+		if _, dup := env.Objects[key]; dup {
+			fmt.Fprintf(os.Stderr, "警告: 环境 %s 中存在多个 %s, 只使用 %s\n", name, key, describeObject(env.Objects[key]))
+			continue
+		}
+		env.Objects[key] = item.Obj
+	}
+	if len(namespaces) == 1 {
+		for ns := range namespaces {
+			env.Namespace = ns
+		}
+		for _, obj := range env.Objects {
+			unstructured.RemoveNestedField(obj.Object, "metadata", "namespace")
+		}
+	}
+	return env, nil
+}
+
+// writeKustomizeTree 生成 base 与 overlays 目录
+// 所有环境都存在的对象进入 base, 内容为各环境一致的字段; 各环境不同的字段写成 JSON6902 补丁
+// 只在部分环境存在的对象直接作为对应 overlay 的资源
+func writeKustomizeTree(outputDir string, envs []*kustomizeEnv) error {
+	var common []string
+	for key := range envs[0].Objects {
+		inAll := true
+		for _, env := range envs[1:] {
+			if _, ok := env.Objects[key]; !ok {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			common = append(common, key)
+		}
+	}
+	sort.Strings(common)
+
+	baseDir := filepath.Join(outputDir, "base")
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return err
+	}
+	var baseResources []string
+	patches := make([][]interface{}, len(envs))
+	patchFiles := make([]map[string][]jsonPatchOp, len(envs))
+	for i := range envs {
+		patchFiles[i] = make(map[string][]jsonPatchOp)
+	}
+	for _, key := range common {
+		variants := make([]interface{}, len(envs))
+		for i, env := range envs {
+			variants[i] = env.Objects[key].Object
+		}
+		base, ops := commonFields(variants, "")
+		file := kustomizeFileName(envs[0].Objects[key])
+		if err := writeYAMLFile(filepath.Join(baseDir, file), base); err != nil {
+			return err
+		}
+		baseResources = append(baseResources, file)
+		for i := range envs {
+			if len(ops[i]) == 0 {
+				continue
+			}
+			patchFiles[i][file] = ops[i]
+			obj := envs[i].Objects[key]
+			gvk := obj.GroupVersionKind()
+			target := map[string]interface{}{"version": gvk.Version, "kind": gvk.Kind, "name": obj.GetName()}
+			if gvk.Group != "" {
+				target["group"] = gvk.Group
+			}
+			patches[i] = append(patches[i], map[string]interface{}{"path": "patches/" + file, "target": target})
+		}
+	}
+	if err := writeYAMLFile(filepath.Join(baseDir, "kustomization.yaml"), map[string]interface{}{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  baseResources,
+	}); err != nil {
+		return err
+	}
+
+	for i, env := range envs {
+		envDir := filepath.Join(outputDir, "overlays", env.Name)
+		if err := os.MkdirAll(filepath.Join(envDir, "patches"), 0755); err != nil {
+			return err
+		}
+		resources := []string{"../../base"}
+		if env.Namespace != "" {
+			if err := writeYAMLFile(filepath.Join(envDir, "namespace.yaml"), map[string]interface{}{
+				"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]interface{}{"name": env.Namespace},
+			}); err != nil {
+				return err
+			}
+			resources = append(resources, "namespace.yaml")
+		}
+		var extra []string
+		for key, obj := range env.Objects {
+			if containsString(common, key) {
+				continue
+			}
+			file := kustomizeFileName(obj)
+			if err := writeYAMLFile(filepath.Join(envDir, file), obj.Object); err != nil {
+				return err
+			}
+			extra = append(extra, file)
+		}
+		sort.Strings(extra)
+		resources = append(resources, extra...)
+		for file, ops := range patchFiles[i] {
+			if err := writeYAMLFile(filepath.Join(envDir, "patches", file), ops); err != nil {
+				return err
+			}
+		}
+
+		kustomization := map[string]interface{}{
+			"apiVersion": "kustomize.config.k8s.io/v1beta1",
+			"kind":       "Kustomization",
+			"resources":  resources,
+		}
+		if env.Namespace != "" {
+			kustomization["namespace"] = env.Namespace
+		}
+		if len(patches[i]) > 0 {
+			kustomization["patches"] = patches[i]
+		}
+		if err := writeYAMLFile(filepath.Join(envDir, "kustomization.yaml"), kustomization); err != nil {
+			return err
+		}
+		fmt.Fprintf(logOut, "  %s: %d 个补丁, %d 个独有对象\n", env.Name, len(patches[i]), len(extra))
+	}
+	return nil
+}
+
+// commonFields 计算多个变体共同的部分, 返回共同部分与每个变体相对共同部分需要追加的补丁操作
+// 映射逐键递归比较; 列表与标量只有完全相同才进入共同部分, 否则整体写入各变体的补丁
+func commonFields(variants []interface{}, path string) (interface{}, [][]jsonPatchOp) {
+	ops := make([][]jsonPatchOp, len(variants))
+	maps := make([]map[string]interface{}, len(variants))
+	for i, v := range variants {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			maps = nil
+			break
+		}
+		maps[i] = m
+	}
+	if maps == nil {
+		return nil, ops
+	}
+
+	keys := make(map[string]struct{})
+	for _, m := range maps {
+		for k := range m {
+			keys[k] = struct{}{}
+		}
+	}
+	base := make(map[string]interface{})
+	for _, k := range sortedKeys(keys) {
+		childPath := path + "/" + escapeJSONPointer(k)
+		children := make([]interface{}, len(maps))
+		inAll := true
+		for i, m := range maps {
+			child, ok := m[k]
+			if !ok {
+				inAll = false
+			}
+			children[i] = child
+		}
+		if inAll && allEqual(children) {
+			base[k] = children[0]
+			continue
+		}
+		if inAll {
+			if childBase, childOps := commonFields(children, childPath); childBase != nil {
+				base[k] = childBase
+				for i := range ops {
+					ops[i] = append(ops[i], childOps[i]...)
+				}
+				continue
+			}
+		}
+		for i, m := range maps {
+			if child, ok := m[k]; ok {
+				ops[i] = append(ops[i], jsonPatchOp{Op: "add", Path: childPath, Value: child})
+			}
+		}
+	}
+	return base, ops
+}
+
+// allEqual 判断所有值是否深度相等
+func allEqual(values []interface{}) bool {
+	for _, v := range values[1:] {
+		if !reflect.DeepEqual(values[0], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeJSONPointer 按 RFC 6901 转义 JSON Pointer 中的路径段
+func escapeJSONPointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// kustomizeFileName 返回对象在 base/overlay 中的文件名, 如 deployment-web.yaml
+func kustomizeFileName(obj *unstructured.Unstructured) string {
+	return strings.ToLower(obj.GetKind()) + "-" + obj.GetName() + ".yaml"
+}
+
+// containsString 判断有序字符串列表中是否包含 s
+func containsString(sorted []string, s string) bool {
+	i := sort.SearchStrings(sorted, s)
+	return i < len(sorted) && sorted[i] == s
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+)
+
+// --all-in-one 的可选值
+const (
+	allInOneOff  = "off"  // 仅输出单资源文件 (默认)
+	allInOneAlso = "also" // 额外输出 all.yaml
+	allInOneOnly = "only" // 仅输出 all.yaml
+)
+
+// --layout 的可选值, 决定单资源文件在命名空间 (或 _global) 目录下的位置
+const (
+	layoutFlat  = "flat"  // <资源类型>/<名称>.yaml, 如 deployments/web.yaml (默认)
+	layoutGroup = "group" // <API组>/<Kind>/<名称>.yaml, 如 apps/Deployment/web.yaml, 核心组为 core
+)
+
+// validateLayout 校验 --layout 参数; group 布局按 API 组分目录, 无法用目录前缀表达全局的依赖顺序
+func validateLayout(layout string, orderedNames bool) error {
+	switch layout {
+	case layoutFlat:
+		return nil
+	case layoutGroup:
+		if orderedNames {
+			return fmt.Errorf("--ordered-names 不能与 --layout %s 同时使用", layoutGroup)
+		}
+		return nil
+	default:
+		return fmt.Errorf("不支持的目录布局 '%s' (可选: %s, %s)", layout, layoutFlat, layoutGroup)
+	}
+}
+
+// allInOneFileName 汇总目录内全部清单的文件名, 文档按依赖顺序排列
+const allInOneFileName = "all.yaml"
+
+// validateAllInOne 校验 --all-in-one 参数
+func validateAllInOne(mode string) error {
+	switch mode {
+	case allInOneOff, allInOneAlso, allInOneOnly:
+		return nil
+	default:
+		return fmt.Errorf("不支持的 all-in-one 模式 '%s' (可选: %s, %s, %s)", mode, allInOneOff, allInOneAlso, allInOneOnly)
+	}
+}
+
+// manifestWriter 按输出布局写入一个目录 (命名空间或 _global) 中的清单
+// 调用方需保证按依赖顺序写入, all.yaml 中的文档顺序与写入顺序一致
+// 文件经由 batch 写入, 并发写入时 write 返回的错误不包含写入失败, 需在 flush 后调用 batch.wait 收集
+type manifestWriter struct {
+	dir      string
+	allInOne string
+	batch    *writeBatch
+	docs     [][]byte
+}
+
+// newManifestWriter 创建目录 dir 的清单写入器
+func newManifestWriter(dir, allInOne string, batch *writeBatch) *manifestWriter {
+	return &manifestWriter{dir: dir, allInOne: allInOne, batch: batch}
+}
+
+// write 写入单个清单, 返回该清单所在的文件路径 (only 模式下为 all.yaml)
+func (w *manifestWriter) write(subDir, filename string, data []byte) (string, error) {
+	if w.allInOne != allInOneOff {
+		w.docs = append(w.docs, data)
+	}
+	if w.allInOne == allInOneOnly {
+		return filepath.Join(w.dir, allInOneFileName), nil
+	}
+	fullPath := filepath.Join(w.dir, subDir, filename)
+	return fullPath, w.batch.write(fullPath, data)
+}
+
+// flush 写出 all.yaml, 关闭汇总或没有任何清单时不做任何事
+func (w *manifestWriter) flush() error {
+	if w.allInOne == allInOneOff || len(w.docs) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for i, doc := range w.docs {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(doc)
+	}
+	return w.batch.write(filepath.Join(w.dir, allInOneFileName), buf.Bytes())
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// loadBalancersFileName 记录命名空间内 LoadBalancer Service 在备份时刻分配到的外部地址的文件, 位于命名空间目录
+// 清理会移除 status, 恢复后云厂商通常会分配新的地址, 可据此更新 DNS 或通过 restore --pin-loadbalancer-ips 申请原地址
+const loadBalancersFileName = "loadbalancers.yaml"
+
+// loadBalancerRecord 单个 LoadBalancer Service 的外部地址与云厂商注解
+type loadBalancerRecord struct {
+	IPs         []string          `yaml:"ips,omitempty"`
+	Hostnames   []string          `yaml:"hostnames,omitempty"`
+	Class       string            `yaml:"loadBalancerClass,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"` // 云厂商与负载均衡控制器的注解, 清单中同样保留
+}
+
+// loadBalancerRecords Service 名称 -> 备份时刻的负载均衡信息
+type loadBalancerRecords map[string]loadBalancerRecord
+
+// loadBalancerAnnotationPrefixes 云厂商与常见负载均衡控制器使用的 Service 注解前缀
+var loadBalancerAnnotationPrefixes = []string{
+	"service.beta.kubernetes.io/",
+	"service.kubernetes.io/",
+	"cloud.google.com/",
+	"networking.gke.io/",
+	"metallb.universe.tf/",
+	"metallb.io/",
+	"lbipam.cilium.io/",
+	"io.cilium/",
+}
+
+// add 记录 type=LoadBalancer 的 Service, 需传入清理前带 status 的对象
+func (r loadBalancerRecords) add(svc map[string]interface{}) {
+	if svcType, _, _ := unstructured.NestedString(svc, "spec", "type"); svcType != "LoadBalancer" {
+		return
+	}
+	obj := &unstructured.Unstructured{Object: svc}
+	var rec loadBalancerRecord
+	ingress, _, _ := unstructured.NestedSlice(svc, "status", "loadBalancer", "ingress")
+	for _, entry := range mapsOf(ingress) {
+		if ip, _ := entry["ip"].(string); ip != "" {
+			rec.IPs = append(rec.IPs, ip)
+		}
+		if hostname, _ := entry["hostname"].(string); hostname != "" {
+			rec.Hostnames = append(rec.Hostnames, hostname)
+		}
+	}
+	rec.Class, _, _ = unstructured.NestedString(svc, "spec", "loadBalancerClass")
+	for key, value := range obj.GetAnnotations() {
+		if hasAnyPrefix(key, loadBalancerAnnotationPrefixes) {
+			if rec.Annotations == nil {
+				rec.Annotations = make(map[string]string)
+			}
+			rec.Annotations[key] = value
+		}
+	}
+	r[obj.GetName()] = rec
+}
+
+// loadBalancerIPAnnotations 各负载均衡实现指定静态地址的注解, 按 Service 上已有注解的前缀判断实现
+var loadBalancerIPAnnotations = []struct {
+	prefixes []string
+	key      string
+}{
+	{prefixes: []string{"service.beta.kubernetes.io/azure-"}, key: "service.beta.kubernetes.io/azure-load-balancer-ipv4"},
+	{prefixes: []string{"metallb.universe.tf/", "metallb.io/"}, key: "metallb.universe.tf/loadBalancerIPs"},
+	{prefixes: []string{"lbipam.cilium.io/", "io.cilium/"}, key: "lbipam.cilium.io/ips"},
+}
+
+// pinLoadBalancerIPs 为恢复的 LoadBalancer Service 指定备份时刻的外部 IP
+// Azure, MetalLB 与 Cilium 使用各自的注解, 其余实现使用 spec.loadBalancerIP (GCP, OpenStack 等支持)
+// 已显式指定地址, 或备份时只有主机名 (如 AWS ELB) 的 Service 无法固定, 返回其描述
+func pinLoadBalancerIPs(backupDir string, items []restoreItem) (int, []string) {
+	records := make(map[string]loadBalancerRecords)
+	pinned := 0
+	var unpinnable []string
+	for _, item := range items {
+		obj := item.Obj
+		if obj.GetKind() != "Service" {
+			continue
+		}
+		if svcType, _, _ := unstructured.NestedString(obj.Object, "spec", "type"); svcType != "LoadBalancer" {
+			continue
+		}
+		nsDir, _, _ := strings.Cut(item.Path, "/")
+		nsRecords, loaded := records[nsDir]
+		if !loaded {
+			var err error
+			if nsRecords, err = loadLoadBalancerRecords(filepath.Join(backupDir, nsDir)); err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 读取 %s 失败: %v\n", path.Join(nsDir, loadBalancersFileName), err)
+			}
+			records[nsDir] = nsRecords
+		}
+		rec, ok := nsRecords[obj.GetName()]
+		if !ok || len(rec.IPs) == 0 {
+			if ok && len(rec.Hostnames) > 0 {
+				unpinnable = append(unpinnable, fmt.Sprintf("%s (只有主机名 %s)", describeObject(obj), strings.Join(rec.Hostnames, ", ")))
+			}
+			continue
+		}
+		if pinLoadBalancerIP(obj, rec.IPs) {
+			pinned++
+		} else {
+			unpinnable = append(unpinnable, describeObject(obj)+" (清单中已指定地址)")
+		}
+	}
+	sort.Strings(unpinnable)
+	return pinned, unpinnable
+}
+
+// pinLoadBalancerIP 按 Service 所用的负载均衡实现写入静态地址, 清单中已指定地址时不覆盖并返回 false
+func pinLoadBalancerIP(obj *unstructured.Unstructured, ips []string) bool {
+	annotations := obj.GetAnnotations()
+	if existing, _, _ := unstructured.NestedString(obj.Object, "spec", "loadBalancerIP"); existing != "" {
+		return false
+	}
+	key := ""
+	for _, impl := range loadBalancerIPAnnotations {
+		if annotations[impl.key] != "" {
+			return false
+		}
+		for k := range annotations {
+			if key == "" && hasAnyPrefix(k, impl.prefixes) {
+				key = impl.key
+			}
+		}
+	}
+	if key == "" {
+		unstructured.SetNestedField(obj.Object, ips[0], "spec", "loadBalancerIP")
+		return true
+	}
+	annotations[key] = strings.Join(ips, ",")
+	obj.SetAnnotations(annotations)
+	return true
+}
+
+// hasAnyPrefix 判断 s 是否以任一前缀开头
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadLoadBalancerRecords 读取命名空间目录中的 loadbalancers.yaml, 文件不存在时返回 nil
+func loadLoadBalancerRecords(nsDir string) (loadBalancerRecords, error) {
+	data, err := os.ReadFile(filepath.Join(nsDir, loadBalancersFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records loadBalancerRecords
+	if err := yaml.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func loadBalancerService(name string, annotations map[string]string, ingress ...map[string]interface{}) *unstructured.Unstructured {
+	var entries []interface{}
+	for _, e := range ingress {
+		entries = append(entries, e)
+	}
+	obj := fakeObject("v1", "Service", "web", name, map[string]interface{}{
+		"spec":   map[string]interface{}{"type": "LoadBalancer"},
+		"status": map[string]interface{}{"loadBalancer": map[string]interface{}{"ingress": entries}},
+	})
+	obj.SetAnnotations(annotations)
+	return obj
+}
+
+func TestPinLoadBalancerIPs(t *testing.T) {
+	records := make(loadBalancerRecords)
+	gce := loadBalancerService("gce", map[string]string{"cloud.google.com/load-balancer-type": "Internal", "team": "web"},
+		map[string]interface{}{"ip": "10.1.2.3"})
+	azure := loadBalancerService("azure", map[string]string{"service.beta.kubernetes.io/azure-load-balancer-internal": "true"},
+		map[string]interface{}{"ip": "10.4.5.6"})
+	aws := loadBalancerService("aws", nil, map[string]interface{}{"hostname": "abc.elb.amazonaws.com"})
+	for _, svc := range []*unstructured.Unstructured{gce, azure, aws} {
+		records.add(svc.Object)
+	}
+	records.add(fakeObject("v1", "Service", "web", "internal", map[string]interface{}{"spec": map[string]interface{}{"type": "ClusterIP"}}).Object)
+	if len(records) != 3 {
+		t.Fatalf("记录数 = %d, 期望 3 (ClusterIP Service 不应记录)", len(records))
+	}
+	if ann := records["gce"].Annotations; len(ann) != 1 || ann["cloud.google.com/load-balancer-type"] != "Internal" {
+		t.Errorf("gce 注解 = %v, 期望只记录云厂商注解", ann)
+	}
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "web"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeYAMLFile(filepath.Join(dir, "web", loadBalancersFileName), records); err != nil {
+		t.Fatal(err)
+	}
+	var items []restoreItem
+	for _, svc := range []*unstructured.Unstructured{gce, azure, aws} {
+		items = append(items, restoreItem{Path: "web/services/" + svc.GetName() + ".yaml", Obj: svc})
+	}
+	pinned, unpinnable := pinLoadBalancerIPs(dir, items)
+	if pinned != 2 || len(unpinnable) != 1 {
+		t.Errorf("固定 %d 个, 无法固定 %v, 期望 2 个与 aws", pinned, unpinnable)
+	}
+	if ip, _, _ := unstructured.NestedString(gce.Object, "spec", "loadBalancerIP"); ip != "10.1.2.3" {
+		t.Errorf("gce spec.loadBalancerIP = %q", ip)
+	}
+	if ip := azure.GetAnnotations()["service.beta.kubernetes.io/azure-load-balancer-ipv4"]; ip != "10.4.5.6" {
+		t.Errorf("azure 地址注解 = %q", ip)
+	}
+	if _, found, _ := unstructured.NestedString(azure.Object, "spec", "loadBalancerIP"); found {
+		t.Error("Azure Service 不应设置 spec.loadBalancerIP")
+	}
+}
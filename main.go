@@ -3,20 +3,28 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
+	"backup-k8s/clean"
 	"github.com/spf13/pflag"
 	"gopkg.in/yaml.v3"
 	authv1 "k8s.io/api/authorization/v1"
-	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -27,6 +35,10 @@ type ResourceInfo struct {
 	Kind       string
 	GVR        schema.GroupVersionResource
 	Namespaced bool
+	Order      int    // 依赖顺序, 用于恢复排序与 --ordered-names 目录前缀 (命名空间固定为 00)
+	Runtime    bool   // 由控制器生成的运行时对象, 仅在 --include-runtime-objects 时备份
+	Preset     string // 所属的可选预设 (如 openshift), 仅在 --preset 指定时备份, 集群不提供该 API 时静默跳过
+	Optional   bool   // 仅部分集群版本或发行版提供的 API, 默认备份, 集群不提供时静默跳过
 }
 
 // 资源类型映射表
@@ -37,6 +49,7 @@ var resourceMap = map[string]ResourceInfo{
 			Group: "", Version: "v1", Resource: "configmaps",
 		},
 		Namespaced: true,
+		Order:      20,
 	},
 	"deployments": {
 		Kind: "Deployment",
@@ -44,6 +57,7 @@ var resourceMap = map[string]ResourceInfo{
 			Group: "apps", Version: "v1", Resource: "deployments",
 		},
 		Namespaced: true,
+		Order:      50,
 	},
 	"secrets": {
 		Kind: "Secret",
@@ -51,6 +65,7 @@ var resourceMap = map[string]ResourceInfo{
 			Group: "", Version: "v1", Resource: "secrets",
 		},
 		Namespaced: true,
+		Order:      30,
 	},
 	"services": {
 		Kind: "Service",
@@ -58,6 +73,7 @@ var resourceMap = map[string]ResourceInfo{
 			Group: "", Version: "v1", Resource: "services",
 		},
 		Namespaced: true,
+		Order:      45,
 	},
 	"persistentvolumeclaims": {
 		Kind: "PersistentVolumeClaim",
@@ -65,6 +81,7 @@ var resourceMap = map[string]ResourceInfo{
 			Group: "", Version: "v1", Resource: "persistentvolumeclaims",
 		},
 		Namespaced: true,
+		Order:      40,
 	},
 	"statefulsets": {
 		Kind: "StatefulSet",
@@ -72,6 +89,7 @@ var resourceMap = map[string]ResourceInfo{
 			Group: "apps", Version: "v1", Resource: "statefulsets",
 		},
 		Namespaced: true,
+		Order:      55,
 	},
 	"horizontalpodautoscalers": {
 		Kind: "HorizontalPodAutoscaler",
@@ -79,6 +97,7 @@ var resourceMap = map[string]ResourceInfo{
 			Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers",
 		},
 		Namespaced: true,
+		Order:      70,
 	},
 	"cronjobs": {
 		Kind: "CronJob",
@@ -86,6 +105,7 @@ var resourceMap = map[string]ResourceInfo{
 			Group: "batch", Version: "v1", Resource: "cronjobs",
 		},
 		Namespaced: true,
+		Order:      65,
 	},
 	"jobs": {
 		Kind: "Job",
@@ -93,6 +113,25 @@ var resourceMap = map[string]ResourceInfo{
 			Group: "batch", Version: "v1", Resource: "jobs",
 		},
 		Namespaced: true,
+		Order:      60,
+	},
+	"replicasets": {
+		Kind: "ReplicaSet",
+		GVR: schema.GroupVersionResource{
+			Group: "apps", Version: "v1", Resource: "replicasets",
+		},
+		Namespaced: true,
+		Order:      57,
+		Runtime:    true,
+	},
+	"pods": {
+		Kind: "Pod",
+		GVR: schema.GroupVersionResource{
+			Group: "", Version: "v1", Resource: "pods",
+		},
+		Namespaced: true,
+		Order:      58,
+		Runtime:    true,
 	},
 	"persistentvolumes": {
 		Kind: "PersistentVolume",
@@ -100,6 +139,23 @@ var resourceMap = map[string]ResourceInfo{
 			Group: "", Version: "v1", Resource: "persistentvolumes",
 		},
 		Namespaced: false,
+		Order:      5,
+	},
+	"limitranges": {
+		Kind: "LimitRange",
+		GVR: schema.GroupVersionResource{
+			Group: "", Version: "v1", Resource: "limitranges",
+		},
+		Namespaced: true,
+		Order:      7, // 命名空间级默认值与配额须在工作负载之前生效
+	},
+	"resourcequotas": {
+		Kind: "ResourceQuota",
+		GVR: schema.GroupVersionResource{
+			Group: "", Version: "v1", Resource: "resourcequotas",
+		},
+		Namespaced: true,
+		Order:      8,
 	},
 	"serviceaccounts": {
 		Kind: "ServiceAccount",
@@ -107,6 +163,7 @@ var resourceMap = map[string]ResourceInfo{
 			Group: "", Version: "v1", Resource: "serviceaccounts",
 		},
 		Namespaced: true,
+		Order:      10,
 	},
 	"ingresses": {
 		Kind: "Ingress",
@@ -114,145 +171,170 @@ var resourceMap = map[string]ResourceInfo{
 			Group: "networking.k8s.io", Version: "v1", Resource: "ingresses",
 		},
 		Namespaced: true,
+		Order:      80,
+	},
+	"customresourcedefinitions": {
+		Kind: "CustomResourceDefinition",
+		GVR: schema.GroupVersionResource{
+			Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions",
+		},
+		Namespaced: false,
+		Order:      5,
+	},
+	"imagestreams": {
+		Kind: "ImageStream",
+		GVR: schema.GroupVersionResource{
+			Group: "image.openshift.io", Version: "v1", Resource: "imagestreams",
+		},
+		Namespaced: true,
+		Order:      35,
+		Preset:     presetOpenShift,
+	},
+	"buildconfigs": {
+		Kind: "BuildConfig",
+		GVR: schema.GroupVersionResource{
+			Group: "build.openshift.io", Version: "v1", Resource: "buildconfigs",
+		},
+		Namespaced: true,
+		Order:      36,
+		Preset:     presetOpenShift,
+	},
+	"deploymentconfigs": {
+		Kind: "DeploymentConfig",
+		GVR: schema.GroupVersionResource{
+			Group: "apps.openshift.io", Version: "v1", Resource: "deploymentconfigs",
+		},
+		Namespaced: true,
+		Order:      52,
+		Preset:     presetOpenShift,
+	},
+	"routes": {
+		Kind: "Route",
+		GVR: schema.GroupVersionResource{
+			Group: "route.openshift.io", Version: "v1", Resource: "routes",
+		},
+		Namespaced: true,
+		Order:      80,
+		Preset:     presetOpenShift,
+	},
+	"analysistemplates": {
+		Kind: "AnalysisTemplate",
+		GVR: schema.GroupVersionResource{
+			Group: "argoproj.io", Version: "v1alpha1", Resource: "analysistemplates",
+		},
+		Namespaced: true,
+		Order:      48,
+		Preset:     presetArgo,
+	},
+	"rollouts": {
+		Kind: "Rollout",
+		GVR: schema.GroupVersionResource{
+			Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts",
+		},
+		Namespaced: true,
+		Order:      52,
+		Preset:     presetArgo,
+	},
+	"workflowtemplates": {
+		Kind: "WorkflowTemplate",
+		GVR: schema.GroupVersionResource{
+			Group: "argoproj.io", Version: "v1alpha1", Resource: "workflowtemplates",
+		},
+		Namespaced: true,
+		Order:      62,
+		Preset:     presetArgo,
+	},
+	"cronworkflows": {
+		Kind: "CronWorkflow",
+		GVR: schema.GroupVersionResource{
+			Group: "argoproj.io", Version: "v1alpha1", Resource: "cronworkflows",
+		},
+		Namespaced: true,
+		Order:      66,
+		Preset:     presetArgo,
+	},
+	"clusterresourcequotas": {
+		Kind: "ClusterResourceQuota",
+		GVR: schema.GroupVersionResource{
+			Group: "quota.openshift.io", Version: "v1", Resource: "clusterresourcequotas",
+		},
+		Namespaced: false,
+		Order:      6,
+		Optional:   true,
+	},
+	"validatingadmissionpolicies": {
+		Kind: "ValidatingAdmissionPolicy",
+		GVR: schema.GroupVersionResource{
+			Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingadmissionpolicies",
+		},
+		Namespaced: false,
+		Order:      88,
+		Optional:   true,
+	},
+	"validatingadmissionpolicybindings": {
+		Kind: "ValidatingAdmissionPolicyBinding",
+		GVR: schema.GroupVersionResource{
+			Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingadmissionpolicybindings",
+		},
+		Namespaced: false,
+		Order:      89,
+		Optional:   true,
+	},
+	"mutatingwebhookconfigurations": {
+		Kind: "MutatingWebhookConfiguration",
+		GVR: schema.GroupVersionResource{
+			Group: "admissionregistration.k8s.io", Version: "v1", Resource: "mutatingwebhookconfigurations",
+		},
+		Namespaced: false,
+		Order:      90,
+	},
+	"validatingwebhookconfigurations": {
+		Kind: "ValidatingWebhookConfiguration",
+		GVR: schema.GroupVersionResource{
+			Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingwebhookconfigurations",
+		},
+		Namespaced: false,
+		Order:      90,
 	},
 }
 
-// CleanResource 清理资源中对恢复无用或有害的字段
-func CleanResource(resource map[string]interface{}) map[string]interface{} {
-	if resource == nil {
-		return nil
-	}
-
-	// 移除顶层状态信息
-	delete(resource, "status")
-
-	// --- 递归清理函数定义 ---
-	// 定义一个可重用的函数来清理任何 metadata 块
-	var cleanMetadata func(map[string]interface{})
-	cleanMetadata = func(metadata map[string]interface{}) {
-		if metadata == nil {
-			return
-		}
-
-		// 移除所有由Kubernetes自动生成的元数据字段
-		for _, field := range []string{
-			"creationTimestamp", "resourceVersion", "selfLink", "uid",
-			"managedFields", "generation",
-		} {
-			delete(metadata, field)
-		}
-
-		// 清理annotations
-		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
-			// 将需要移除的 annotations key 加入列表
-			for _, keyToRemove := range []string{
-				"kubectl.kubernetes.io/last-applied-configuration",
-				"deployment.kubernetes.io/revision",
-				"kubesphere.io/restartedAt",
-				"logging.kubesphere.io/logsidecar-config",
-			} {
-				delete(annotations, keyToRemove)
-			}
-			// 如果清理后为空，则移除整个annotations字段
-			if len(annotations) == 0 {
-				delete(metadata, "annotations")
-			}
-		}
-	}
-
-	// 清理顶层 metadata
-	if metadata, ok := resource["metadata"].(map[string]interface{}); ok {
-		cleanMetadata(metadata)
-	}
-
-	// 清理 Pod 模板中的 metadata
-	if spec, ok := resource["spec"].(map[string]interface{}); ok {
-		// 清理 Deployment, StatefulSet, Job 等资源的 template.metadata
-		if template, ok := spec["template"].(map[string]interface{}); ok {
-			if templateMetadata, ok := template["metadata"].(map[string]interface{}); ok {
-				cleanMetadata(templateMetadata) // 复用清理函数
-			}
-		}
-		// 清理 CronJob 资源的 jobTemplate.spec.template.metadata
-		if jobTemplate, ok := spec["jobTemplate"].(map[string]interface{}); ok {
-			if jobSpec, ok := jobTemplate["spec"].(map[string]interface{}); ok {
-				if template, ok := jobSpec["template"].(map[string]interface{}); ok {
-					if templateMetadata, ok := template["metadata"].(map[string]interface{}); ok {
-						cleanMetadata(templateMetadata) // 复用清理函数
-					}
-				}
-			}
-		}
+// typeDirName 返回资源类型的输出目录名 (group 布局下为 <API组>/<Kind>), 开启 ordered 时添加两位数的顺序前缀
+func typeDirName(resType string, resInfo ResourceInfo, layout string, ordered bool) string {
+	if layout == layoutGroup {
+		return filepath.Join(displayGroup(resInfo.GVR.Group), resInfo.Kind)
 	}
-
-	// 根据资源类型进行特定字段的清理
-	kind, _ := resource["kind"].(string)
-	if spec, specOK := resource["spec"].(map[string]interface{}); specOK {
-		switch kind {
-		case "Service":
-			for _, field := range []string{"clusterIP", "clusterIPs", "ipFamilies", "ipFamilyPolicy"} {
-				delete(spec, field)
-			}
-		case "PersistentVolume":
-			delete(spec, "claimRef")
-		case "PersistentVolumeClaim":
-			delete(spec, "volumeName")
-		case "ServiceAccount":
-			delete(resource, "secrets")
-		}
+	if !ordered {
+		return resType
 	}
-
-	return resource
+	return fmt.Sprintf("%02d-%s", resInfo.Order, resType)
 }
 
-// ShouldBackupSecret 判断Secret是否需要备份，过滤掉系统生成的Secret
-func ShouldBackupSecret(secretObj map[string]interface{}) bool {
-	metadata, ok := secretObj["metadata"].(map[string]interface{})
-	if !ok {
-		return false
-	}
-	name, _ := metadata["name"].(string)
-	secretType, _ := secretObj["type"].(string)
-
-	// 跳过由各类控制器或系统默认生成的Secret
-	if strings.HasPrefix(name, "default-token-") ||
-		strings.HasPrefix(name, "sh.helm.release.v1.") ||
-		(strings.Contains(name, "-token-") && secretType == string(corev1.SecretTypeServiceAccountToken)) {
-		return false
-	}
-
-	// 跳过特定类型的Secret
-	excludedTypes := map[string]struct{}{
-		string(corev1.SecretTypeServiceAccountToken): {},
-		"helm.sh/release.v1":                         {},
-	}
-	if _, found := excludedTypes[secretType]; found {
-		return false
-	}
-
-	return true
+// sortResourceTypes 按依赖顺序排序资源类型, 使输出与日志顺序稳定
+func sortResourceTypes(resourceTypes []string) {
+	sort.SliceStable(resourceTypes, func(i, j int) bool {
+		oi, oj := resourceMap[resourceTypes[i]].Order, resourceMap[resourceTypes[j]].Order
+		if oi != oj {
+			return oi < oj
+		}
+		return resourceTypes[i] < resourceTypes[j]
+	})
 }
 
-// processStringMapValues 标准化ConfigMap中的字符串值，处理换行和转义
-func processStringMapValues(m map[string]interface{}) map[string]interface{} {
-	if m == nil {
-		return nil
-	}
-	processed := make(map[string]interface{})
-	for k, v := range m {
-		if s, ok := v.(string); ok {
-			s = strings.ReplaceAll(s, "\r\n", "\n")
-			s = strings.ReplaceAll(s, "\\n", "\n")
-			processed[k] = s
-		} else {
-			processed[k] = v
+// renderResource 将集群中的对象清理并序列化为备份清单
+func renderResource(resType string, resource *unstructured.Unstructured, opts clean.Options) (map[string]interface{}, []byte, error) {
+	// 先标准化ConfigMap数据, 使 regenerate 模式生成的 last-applied 与最终输出一致
+	if resType == "configmaps" {
+		if data, ok := resource.Object["data"].(map[string]interface{}); ok {
+			resource.Object["data"] = clean.NormalizeStringMapValues(data)
 		}
 	}
-	return processed
+	obj := clean.Resource(resource.Object, opts)
+	data, err := yaml.Marshal(obj)
+	return obj, data, err
 }
 
 // checkResourceAccess 检查当前用户是否有指定资源的读取权限
-func checkResourceAccess(clientset *kubernetes.Clientset, gvr schema.GroupVersionResource, namespace string) bool {
+func checkResourceAccess(clientset kubernetes.Interface, gvr schema.GroupVersionResource, namespace string) (bool, error) {
 	ssar := &authv1.SelfSubjectAccessReview{
 		Spec: authv1.SelfSubjectAccessReviewSpec{
 			ResourceAttributes: &authv1.ResourceAttributes{
@@ -265,28 +347,125 @@ func checkResourceAccess(clientset *kubernetes.Clientset, gvr schema.GroupVersio
 	result, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(
 		context.TODO(), ssar, metav1.CreateOptions{})
 	if err != nil {
-		fmt.Printf("权限检查API调用失败 [%s in %s]: %v\n", gvr.Resource, namespace, err)
-		return false
+		return false, fmt.Errorf("权限检查API调用失败 [%s in %s]: %w", gvr.Resource, namespace, err)
 	}
-	return result.Status.Allowed
+	return result.Status.Allowed, nil
+}
+
+// subcommands 子命令表, 未匹配任何子命令时执行默认的备份流程
+var subcommands = map[string]func(args []string){
+	"restore":          runRestore,
+	"install":          runInstall,
+	"rbac-gen":         runRBACGen,
+	"kustomize":        runKustomize,
+	"validate-restore": runValidateRestore,
+	"list":             runList,
+	"self-test":        runSelfTest,
+	"state":            runState,
+	"catalog-export":   runCatalogExport,
+	"list-types":       runListTypes,
+	"prune-orphans":    runPruneOrphans,
+	"compare-clusters": runCompareClusters,
+	"bundle":           runBundle,
+	"unbundle":         runUnbundle,
 }
 
 func main() {
-	var kubeconfig, namespace, resourceTypesStr, outputDir, skipNamespacesStr string
-	var showVersion, skipSecrets, skipClusterResources bool
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			run(os.Args[2:])
+			return
+		}
+	}
+	// config view 与备份共用参数定义与解析流程, 在开始备份前输出最终生效的配置后退出
+	args := os.Args[1:]
+	configView := len(args) > 0 && args[0] == "config"
+	if configView {
+		if len(args) < 2 || args[1] != "view" {
+			fmt.Fprintln(os.Stderr, "用法: k8s-backup config view [--effective] [备份参数]")
+			os.Exit(2)
+		}
+		args = args[2:]
+	}
+
+	var kubeconfig, namespace, resourceTypesStr, outputDir, skipNamespacesStr, progressFormat, keepCertKindsStr, lastAppliedPolicy, partitionLabel, allInOne, reportFormat, failBelow, graphFormat, shardStr, clusterConfig, maxBackupSize, since, modifiedAfter, excludeNsSelector, presetStr, fsyncPolicy, namespaceFile, stateDirPath, excludeKeysStr, patchRulesFile, layout, controllerManagers, ingressStripStr, ingressKeepStr string
+	var writeConcurrency, namespaceConcurrency int
+	var imageRewriteSpecs []string
+	var showVersion, effective, allNamespacesFlag, estimate, metadataOnly, includeSystem, includeSystemConfig, includePullSecrets, includeRuntime, failOnEmpty, validateSchema, checkSkew, attestation, secretStringData, verifyCounts, refetchSkewed, skipSecrets, skipClusterResources, stripReplicas, keepNodePorts, stripNodePortsFlag, orderedNames, nice, turbo, stampOrigin, incremental, stripControllerFields, configUsageReport bool
 
 	pflag.StringVar(&kubeconfig, "kubeconfig", "", "kubeconfig文件路径 (默认使用~/.kube/config)")
-	pflag.StringVarP(&namespace, "namespace", "n", "all", "指定备份的命名空间 (使用'all'备份所有)")
+	pflag.StringVarP(&namespace, "namespace", "n", "", "指定备份的命名空间 (逗号分隔, 如 payments,billing,auth), 未指定时备份所有命名空间")
+	pflag.BoolVarP(&allNamespacesFlag, "all-namespaces", "A", false, "备份所有命名空间 (未指定 --namespace 时的默认行为)")
 	pflag.StringVarP(&resourceTypesStr, "type", "t", "all", "备份的资源类型 (逗号分隔, 'all'代表所有支持的类型)")
-	pflag.StringVarP(&outputDir, "output-dir", "o", ".", "备份文件的输出目录")
-	pflag.StringVarP(&skipNamespacesStr, "exclude-namespaces", "e", "kube-system", "需要排除的命名空间 (逗号分隔)")
+	pflag.StringVarP(&outputDir, "output-dir", "o", ".", "备份文件的输出目录, 支持模板变量 {{.Cluster}} {{.Context}} {{.ClusterUID}} {{.Date}} {{.Time}} {{.Year}} {{.Month}} {{.Day}} (如 /backups/{{.Cluster}}/{{.Date}})")
+	pflag.StringVar(&namespaceFile, "namespace-file", "", "只备份该文件中列出的命名空间 (每行一个, 支持 # 注释), 用于由其他系统生成的较长清单; 排除规则仍然生效")
+	pflag.StringVarP(&skipNamespacesStr, "exclude-namespaces", "e", "kube-system", "需要排除的命名空间 (逗号分隔, 支持通配符, 如 kube-*,openshift-*)")
+	pflag.StringVar(&excludeNsSelector, "exclude-namespace-selector", "", "排除标签匹配该选择器的命名空间 (如 backup=disabled)")
+	pflag.StringVar(&excludeKeysStr, "exclude-keys", "", "从 ConfigMap/Secret 中移除的键 (逗号分隔, 格式 [Kind[/名称]:]键, 名称与键支持通配符, 如 ca.crt,Secret:*.pem,ConfigMap/istio-*:root-cert.pem), 用于对象中个别由程序维护的键")
+	pflag.BoolVar(&secretStringData, "secret-string-data", false, "将 Secret 中可读 (合法 UTF-8) 的值以明文写入 stringData 而非 base64 的 data, 便于在 git 中审阅加密或 sealed 后的备份差异; 二进制值保留在 data 中")
+	pflag.BoolVar(&stampOrigin, "stamp-origin", false, "在每个清单中写入来源注解 (k8s-back.io/source-cluster, backup-time, source-resource-version), 使单独找到的清单文件可以自我描述; 恢复时可用 restore --strip-origin 移除")
 	pflag.BoolVar(&skipSecrets, "skip-secrets", false, "跳过所有Secret的备份")
+	pflag.BoolVar(&includePullSecrets, "include-pull-secrets", false, "配合 --skip-secrets 使用: 仍备份被 ServiceAccount imagePullSecrets 引用的镜像仓库凭据 (dockerconfigjson)")
+	pflag.BoolVar(&includeSystem, "include-system-objects", false, "关闭内置排除清单, 同时备份 kube-root-ca.crt 等控制器自动生成的 ConfigMap, 选主锁与未经修改的 default ServiceAccount")
+	pflag.BoolVar(&includeSystemConfig, "include-system-config", false, "即使 kube-system 被排除, 仍随集群级资源备份控制面重建所需的系统配置 (coredns, kube-proxy, kubeadm-config, cluster-info, EKS 的 aws-auth 等 ConfigMap) 到 _global/systemconfig/")
+	pflag.BoolVar(&includeRuntime, "include-runtime-objects", false, "同时备份 ReplicaSet 与 Pod (已移除 status), 用于故障取证快照, 恢复时通常不需要")
+	pflag.StringVar(&presetStr, "preset", "", "额外备份的资源预设 (逗号分隔, 可选: openshift 即 Route/DeploymentConfig/ImageStream/BuildConfig; argo 即 Rollout/AnalysisTemplate/WorkflowTemplate/CronWorkflow), 集群不提供的类型静默跳过")
 	pflag.BoolVar(&skipClusterResources, "no-cluster-resources", false, "不备份所有集群级资源 (如PV)")
+	pflag.BoolVar(&stripReplicas, "strip-replicas", false, "移除Deployment/StatefulSet的spec.replicas, 并在各命名空间写入sizing.yaml记录备份时的副本数/HPA/PDB状态")
+	pflag.BoolVar(&stripControllerFields, "strip-controller-fields", false, "根据 managedFields 移除只由控制器写入的字段 (如 HPA 调整的 spec.replicas, cert-manager 注入的注解与 caBundle), 使清单可直接 kubectl apply --server-side 而不与目标集群中的控制器争夺字段所有权")
+	pflag.StringVar(&controllerManagers, "controller-managers", strings.Join(clean.DefaultControllerManagers, ","), "配合 --strip-controller-fields: 视为控制器的 managedFields manager 名称前缀 (逗号分隔)")
+	pflag.StringArrayVar(&imageRewriteSpecs, "image-rewrite", nil, "改写 Deployment/StatefulSet/DaemonSet/Job/CronJob 中容器镜像的仓库 源=目标 (如 registry.cn-hangzhou.aliyuncs.com=harbor.internal, 可用逗号分隔或重复指定), 用于在隔离网络中使用内部镜像仓库恢复; "+imagesTextFileName+" 仍列出改写前的源镜像; 恢复时也可通过 restore --image-rewrite 指定")
+	pflag.StringVar(&patchRulesFile, "patch-rules", "", "JSON Patch 清理规则文件 (YAML 列表, 每项包含 kind, 可选的 name 通配符与 RFC 6902 的 remove/replace/test 操作), 用于移除如 webhook 注入的个别环境变量等内置规则无法覆盖的字段")
+	pflag.StringVar(&ingressStripStr, "ingress-strip-annotations", strings.Join(clean.DefaultIngressStripAnnotations, ","), "从 Ingress 中移除的注解 (逗号分隔, 支持通配符, 如 ingress.kubernetes.io/*), 默认为控制器回写的状态类注解; 设为空字符串则全部保留")
+	pflag.StringVar(&ingressKeepStr, "ingress-keep-annotations", "", "始终保留的 Ingress 注解 (逗号分隔, 支持通配符, 如 nginx.ingress.kubernetes.io/*), 优先于 --ingress-strip-annotations")
+	pflag.BoolVar(&keepNodePorts, "keep-nodeports", false, "保留Service的nodePort (默认行为, 单个Service可用注解 k8s-back.io/nodeports=strip 覆盖)")
+	pflag.BoolVar(&stripNodePortsFlag, "strip-nodeports", false, "移除Service的nodePort, 恢复时由目标集群重新分配 (单个Service可用注解 k8s-back.io/nodeports=keep 覆盖)")
+	pflag.BoolVar(&orderedNames, "ordered-names", false, "资源目录名添加依赖顺序前缀 (如 10-serviceaccounts, 50-deployments), 便于直接 kubectl apply --recursive")
+	pflag.StringVar(&layout, "layout", layoutFlat, "命名空间目录内的文件布局 (flat|group): flat 为 <资源类型>/<名称>.yaml, group 为 <API组>/<Kind>/<名称>.yaml (如 apps/Deployment/web.yaml), 避免不同 API 组的同名资源类型混在一个目录")
+	pflag.StringVar(&allInOne, "all-in-one", allInOneOff, "在每个命名空间目录生成按依赖顺序汇总的 all.yaml (off|also|only, only 时不再输出单资源文件)")
+	pflag.StringVar(&lastAppliedPolicy, "last-applied", clean.LastAppliedStrip, "kubectl last-applied-configuration 注解的处理方式 (strip|preserve|regenerate)")
+	pflag.StringVar(&keepCertKindsStr, "keep-cluster-certs", "", "保留集群专属证书字段 (如webhook的caBundle) 的资源Kind (逗号分隔, 'all'代表全部保留)")
+	pflag.StringVar(&progressFormat, "progress-format", progressFormatText, "进度输出格式 (text|jsonl), jsonl 时 stdout 输出结构化事件, 文字日志改写到 stderr")
+	pflag.StringVar(&reportFormat, "report", "", "备份完成后生成汇总报告 (html), 含命名空间统计, 错误列表及与上一次备份的差异")
+	pflag.StringVar(&partitionLabel, "partition-by-label", "", "按命名空间标签值分区输出到 <输出目录>/<标签值>/<备份名>/ (无该标签的命名空间归入 _unlabeled, 集群级资源归入 _cluster)")
+	pflag.StringVar(&graphFormat, "graph", "", "在每个命名空间目录与 _global 目录导出对象引用关系图 (dot|json), 如 Deployment->ConfigMap, Ingress->Service")
+	pflag.BoolVar(&configUsageReport, "config-usage-report", false, "在每个命名空间目录生成 "+configUsageFileName+": 列出 ConfigMap/Secret 被哪些工作负载通过 envFrom, env, volumes, imagePullSecrets 引用, 标出未被引用的孤立对象与引用了不存在对象的工作负载 (只使用备份时已获取的对象)")
+	pflag.BoolVar(&validateSchema, "validate-schema", false, "使用集群的 OpenAPI 定义校验清理后的清单, 将重新应用时会被拒绝的对象 (如缺少必填字段) 列入汇总")
+	pflag.StringVar(&stateDirPath, "state-dir", "", "跨运行保存状态的目录 (对象版本索引, 检查点, 资源发现缓存与锁), 供增量与断点续传使用; 可用 state show/reset 查看或重置")
+	pflag.BoolVar(&incremental, "incremental", false, "增量备份 (需要 --state-dir): 以元数据 (PartialObjectMetadata) 列出对象, 与上一次备份记录的 resourceVersion 比较, 只获取并写入新增或修改过的对象, 大集群上的请求量与内存占用取决于变更量")
+	pflag.BoolVar(&attestation, "attestation", false, "备份完成后在备份根目录生成 in-toto 证明 (SLSA Provenance, "+attestationFileName+"), 记录集群标识, 全部文件的 sha256 与工具版本, 可签名后接入镜像相同的供应链校验")
+	pflag.BoolVar(&checkSkew, "check-skew", false, "备份结束时复查对象的resourceVersion, 将备份期间被修改或删除的对象记录到 skew.yaml")
+	pflag.BoolVar(&verifyCounts, "verify-counts", false, "备份写入后分页重新列出各命名空间的各类资源, 与已写入及已跳过的对象数比较, 标出竞争或静默写入失败造成的差异")
+	pflag.BoolVar(&refetchSkewed, "refetch-skewed", false, "复查时重新获取备份期间被修改的对象并覆盖其清单 (隐含 --check-skew, 不支持 --all-in-one)")
+	pflag.StringVar(&shardStr, "shard", "", "只备份按名称哈希分配到该分片的命名空间 (i/n, 如 1/4), 输出到 <输出目录>/shard-i-of-n/, 集群级资源仅由分片 1 备份")
+	pflag.IntVar(&writeConcurrency, "write-concurrency", 1, "并发写入清单文件的协程数, 输出目录位于 NFS 等高延迟文件系统时可调大 (如 16)")
+	pflag.IntVar(&namespaceConcurrency, "namespace-concurrency", 1, "并行备份的命名空间数, 大于 1 时各命名空间的输出在其完成后整体输出; 不能与 --nice 同时使用")
+	pflag.BoolVar(&nice, "nice", false, "低负载模式, 用于白天在敏感集群上临时备份: 客户端限速降至 2 QPS, 命名空间之间暂停 2s, 串行写入")
+	pflag.BoolVar(&turbo, "turbo", false, "高速模式, 用于专用维护窗口: 客户端限速放宽至 100 QPS, 写入并发 16 (--write-concurrency 显式指定时以其为准)")
+	pflag.StringVar(&fsyncPolicy, "fsync", fsyncNone, "清单文件的落盘策略 (none|batch|always): none 由操作系统决定, batch 在每个命名空间写完后统一 fsync, always 每个文件写入后立即 fsync")
+	pflag.StringVar(&maxBackupSize, "max-backup-size", "", "备份清单总大小上限 (如 5Gi), 超过时停止写入后续对象并以非零状态退出")
+	pflag.StringVar(&since, "since", "", "只备份最近一段时间内创建或修改过的对象 (如 7d, 36h), 依据 creationTimestamp 与 managedFields 的写入时间, 用于导出近期变更")
+	pflag.StringVar(&modifiedAfter, "modified-after", "", "只备份该时间之后创建或修改过的对象 (RFC3339 时间或 YYYY-MM-DD 日期), 不能与 --since 同时使用")
+	pflag.BoolVar(&estimate, "estimate", false, "只列出对象元数据, 按命名空间估算对象数与备份大小 (每类资源抽样少量完整对象估算平均大小), 不写入任何文件")
+	pflag.BoolVar(&metadataOnly, "metadata-only", false, "只备份对象清单 (类型, 名称, 标签, 注解, ownerReferences, 不含 spec/data) 到 <输出目录>/k8s-inventory-<时间>/, 并与上一次清单比较生成 changes.yaml, 适合高频运行以发现变更")
+	pflag.BoolVar(&failOnEmpty, "fail-on-empty", false, "备份资源总数为 0 时以非零状态退出, 并输出 backup_guard_failed 事件")
+	pflag.StringVar(&failBelow, "fail-below", "", "备份资源总数低于该值 (如 100) 或低于上一次备份的百分比 (如 80%) 时以非零状态退出")
+	pflag.StringVar(&clusterConfig, "cluster-config", defaultClusterConfig, "集群管理员下发的备份策略 ConfigMap (<命名空间>/<名称>), 其中的排除规则始终生效, 不存在时忽略; 为空时不读取")
 	pflag.BoolVarP(&showVersion, "version", "v", false, "显示工具版本号")
-	pflag.Parse()
+	if configView {
+		pflag.BoolVar(&effective, "effective", false, "列出全部参数 (含默认值), 并解析最终备份的输出目录, 资源类型与命名空间及未包含的原因")
+	}
+	pflag.CommandLine.Parse(args)
+	if configView {
+		logOut = io.Discard
+	}
 
 	if showVersion {
-		fmt.Printf("k8s-backup-tool %s\n", version)
+		fmt.Printf("k8s-backup-tool %s", version)
+		if commit := buildCommit(); commit != "" {
+			fmt.Printf(" (%s)", commit)
+		}
+		fmt.Println()
 		os.Exit(0)
 	}
 
@@ -295,6 +474,22 @@ func main() {
 		fmt.Fprintf(os.Stderr, "错误: 无法加载Kubernetes配置: %v\n", err)
 		os.Exit(1)
 	}
+	pace, err := resolveBackupPace(nice, turbo)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if pace != nil {
+		pace.apply(config, &writeConcurrency, pflag.CommandLine.Changed("write-concurrency"))
+	}
+	if namespaceConcurrency < 1 {
+		fmt.Fprintf(os.Stderr, "错误: --namespace-concurrency 必须大于 0\n")
+		os.Exit(1)
+	}
+	if nice && namespaceConcurrency > 1 {
+		fmt.Fprintf(os.Stderr, "错误: --nice 不能与 --namespace-concurrency 同时使用\n")
+		os.Exit(1)
+	}
 
 	dynamicClient, err := dynamic.NewForConfig(config)
 	if err != nil {
@@ -308,195 +503,660 @@ func main() {
 		os.Exit(1)
 	}
 
-	skipNamespaces := strings.Split(skipNamespacesStr, ",")
-	timestamp := time.Now().Format("20060102-150405")
-	backupRoot := filepath.Join(outputDir, fmt.Sprintf("k8s-backup-%s", timestamp))
-	if err := os.MkdirAll(backupRoot, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "错误: 创建备份目录 '%s' 失败: %v\n", backupRoot, err)
+	// 集群策略需在参数校验之前读取, 其默认值与命令行参数一同校验
+	policy, err := loadClusterPolicy(clientset, clusterConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v, 忽略集群策略\n", err)
+	}
+	var policyApplied []string
+	if policy != nil {
+		applied, err := policy.applyDefaults(pflag.CommandLine)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 集群策略 '%s': %v\n", policy.Source, err)
+			os.Exit(1)
+		}
+		policyApplied = applied
+		if len(applied) > 0 {
+			fmt.Fprintf(os.Stderr, "集群策略 '%s' 设置了参数默认值: --%s\n", policy.Source, strings.Join(applied, ", --"))
+		}
+		if policy.ExcludeSecrets {
+			skipSecrets, includePullSecrets = true, false
+		}
+	}
+	namespaces, allNamespaces, err := parseNamespaceList(namespace, allNamespacesFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if namespace == "all" {
+		fmt.Fprintln(os.Stderr, "警告: --namespace all 已弃用, 请使用 --all-namespaces (或不指定 --namespace)")
+	}
+	for _, ns := range namespaces {
+		if policy != nil && policy.excludesNamespace(ns) {
+			fmt.Fprintf(os.Stderr, "错误: 命名空间 '%s' 被集群策略 '%s' 排除\n", ns, policy.Source)
+			os.Exit(1)
+		}
+	}
+
+	if configView && !effective {
+		(&effectiveConfig{Settings: collectFlagSettings(pflag.CommandLine, policyApplied, clusterConfig, false)}).print(os.Stdout)
+		return
+	}
+
+	progress, err := newProgressReporter(progressFormat, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if err := clean.ValidateLastAppliedPolicy(lastAppliedPolicy); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	excludeKeys, err := clean.ParseKeyRules(excludeKeysStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: --exclude-keys: %v\n", err)
+		os.Exit(1)
+	}
+	if policy != nil {
+		excludeKeys = append(excludeKeys, policy.ExcludeKeys...)
+	}
+	imageRewrites, err := parseImageRewrites(imageRewriteSpecs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: --image-rewrite: %v\n", err)
+		os.Exit(1)
+	}
+	var patchRules []clean.PatchRule
+	if patchRulesFile != "" {
+		data, err := os.ReadFile(patchRulesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 读取 --patch-rules 文件失败: %v\n", err)
+			os.Exit(1)
+		}
+		if patchRules, err = clean.ParsePatchRules(data); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: --patch-rules: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if policy != nil {
+		patchRules = append(patchRules, policy.PatchRules...)
+	}
+	ingressStrip, err := clean.ParseAnnotationPatterns(ingressStripStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: --ingress-strip-annotations: %v\n", err)
+		os.Exit(1)
+	}
+	ingressKeep, err := clean.ParseAnnotationPatterns(ingressKeepStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: --ingress-keep-annotations: %v\n", err)
+		os.Exit(1)
+	}
+	if err := validateLayout(layout, orderedNames); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if err := validateAllInOne(allInOne); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if err := validateFsyncPolicy(fsyncPolicy); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if err := validateGraphFormat(graphFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if reportFormat != "" && reportFormat != reportFormatHTML {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的报告格式 '%s' (可选: %s)\n", reportFormat, reportFormatHTML)
+		os.Exit(1)
+	}
+	if refetchSkewed {
+		if allInOne != allInOneOff {
+			fmt.Fprintln(os.Stderr, "错误: --refetch-skewed 不能与 --all-in-one 同时使用")
+			os.Exit(1)
+		}
+		checkSkew = true
+	}
+	if incremental && stateDirPath == "" {
+		fmt.Fprintln(os.Stderr, "错误: --incremental 需要同时指定 --state-dir")
+		os.Exit(1)
+	}
+	if incremental && configUsageReport {
+		fmt.Fprintln(os.Stderr, "错误: --config-usage-report 不能与 --incremental 同时使用 (增量备份不获取未变化的对象)")
+		os.Exit(1)
+	}
+	if incremental && verifyCounts {
+		fmt.Fprintln(os.Stderr, "错误: --verify-counts 不能与 --incremental 同时使用 (增量备份只写入有变化的对象)")
+		os.Exit(1)
+	}
+	shard, err := parseShard(shardStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	var namespaceAllowlist map[string]bool
+	if namespaceFile != "" {
+		if !allNamespaces {
+			fmt.Fprintln(os.Stderr, "错误: --namespace-file 不能与 --namespace 同时使用")
+			os.Exit(1)
+		}
+		names, err := loadNamespaceFile(namespaceFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		namespaceAllowlist = make(map[string]bool, len(names))
+		for _, name := range names {
+			namespaceAllowlist[name] = true
+		}
+	}
+	if shard.enabled() && !allNamespaces {
+		fmt.Fprintln(os.Stderr, "错误: --shard 只能在备份全部命名空间 (--all-namespaces) 时使用")
+		os.Exit(1)
+	}
+	presets, err := parsePresets(presetStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	maxBytes, err := parseMaxBackupSize(maxBackupSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	changed, err := parseChangedSince(since, modifiedAfter, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	guard, err := newBackupGuard(failOnEmpty, failBelow)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	// 只包含变更的备份其资源总数不反映集群规模, 与上一次备份比较也会把未变化的对象算作删除
+	partialBackup := incremental || changed.enabled()
+	if partialBackup && (failOnEmpty || failBelow != "") {
+		fmt.Fprintln(os.Stderr, "错误: --fail-on-empty 与 --fail-below 不能与 --incremental, --since 或 --modified-after 同时使用 (备份只包含有变化的对象)")
+		os.Exit(1)
+	}
+	if estimate && metadataOnly {
+		fmt.Fprintln(os.Stderr, "错误: --estimate 与 --metadata-only 不能同时使用")
+		os.Exit(1)
+	}
+	if metadataOnly && partitionLabel != "" {
+		fmt.Fprintln(os.Stderr, "错误: --metadata-only 不支持 --partition-by-label")
+		os.Exit(1)
+	}
+	if includeSystemConfig && skipClusterResources {
+		fmt.Fprintln(os.Stderr, "错误: --include-system-config 随集群级资源备份, 不能与 --no-cluster-resources 同时使用")
+		os.Exit(1)
+	}
+	if keepNodePorts && stripNodePortsFlag {
+		fmt.Fprintln(os.Stderr, "错误: --keep-nodeports 与 --strip-nodeports 不能同时使用")
+		os.Exit(1)
+	}
+
+	var validator *schemaValidator
+	if validateSchema {
+		if discoveryClient, err := discovery.NewDiscoveryClientForConfig(config); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 创建发现客户端失败, 跳过Schema校验: %v\n", err)
+		} else if validator, err = newSchemaValidator(discoveryClient.OpenAPIV3()); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 读取集群 OpenAPI 定义失败, 跳过Schema校验: %v\n", err)
+		}
+	}
+
+	skipNamespaces := splitList(skipNamespacesStr)
+	if policy != nil {
+		skipNamespaces = append(skipNamespaces, policy.ExcludeNamespaces...)
+	}
+	nsExclusion, err := newNamespaceExclusion(skipNamespaces, excludeNsSelector)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
 		os.Exit(1)
 	}
+	cleanOpts := clean.Options{
+		KeepCertKinds:           clean.ParseKindSet(keepCertKindsStr),
+		LastApplied:             lastAppliedPolicy,
+		StripReplicas:           stripReplicas,
+		StripNodePorts:          stripNodePortsFlag,
+		ExcludeKeys:             excludeKeys,
+		SecretStringData:        secretStringData,
+		IngressStripAnnotations: ingressStrip,
+		IngressKeepAnnotations:  ingressKeep,
+		PatchRules:              patchRules,
+	}
+	if stripControllerFields {
+		cleanOpts.ControllerManagers = splitList(controllerManagers)
+	}
+	fingerprint := collectFingerprint(config, clientset)
+	var state *stateDir
+	if stateDirPath != "" {
+		if state, err = openStateDir(stateDirPath); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	backupTime := time.Now()
+	dirVars := newOutputDirVars(kubeconfig, fingerprint, backupTime)
+	if outputDir, err = expandOutputDir(outputDir, dirVars); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if stampOrigin {
+		cleanOpts.Origin = &clean.Origin{Cluster: dirVars.Cluster, BackupTime: backupTime.UTC().Format(time.RFC3339)}
+	}
+	timestamp := backupTime.Format("20060102-150405")
+	if shard.enabled() {
+		outputDir = filepath.Join(outputDir, shard.dirName())
+	}
+	partitions := newPartitionSet(outputDir, backupDirPrefix+timestamp, partitionLabel)
+	backupRoot := filepath.Join(outputDir, backupDirPrefix+timestamp)
+	if partitionLabel != "" {
+		backupRoot = filepath.Join(outputDir, "<"+partitionLabel+">", backupDirPrefix+timestamp)
+	} else if !estimate && !metadataOnly && !configView {
+		if _, err := partitions.get(""); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 创建备份目录 '%s' 失败: %v\n", backupRoot, err)
+			os.Exit(1)
+		}
+	}
 
-	fmt.Printf("备份开始于: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	fmt.Printf("备份目录: %s\n", backupRoot)
+	if !estimate && !metadataOnly && !configView {
+		fmt.Fprintf(logOut, "备份开始于: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(logOut, "备份目录: %s\n", backupRoot)
+		if pace != nil {
+			fmt.Fprintf(logOut, "备份节奏: %s\n", pace.describe(writeConcurrency))
+		}
+		if len(imageRewrites) > 0 {
+			fmt.Fprintf(logOut, "镜像仓库改写: %s\n", describeImageRewrites(imageRewrites))
+		}
+	}
 
 	var resourceTypes []string
 	if resourceTypesStr == "all" || resourceTypesStr == "" {
-		for resType := range resourceMap {
-			resourceTypes = append(resourceTypes, resType)
+		resourceTypes = append(allResourceTypes(), presetResourceTypes(presets)...)
+		if includeRuntime {
+			resourceTypes = append(resourceTypes, runtimeResourceTypes()...)
 		}
 	} else {
 		resourceTypes = strings.Split(resourceTypesStr, ",")
+		for _, resType := range resourceTypes {
+			if resourceMap[resType].Runtime && !includeRuntime {
+				fmt.Fprintf(os.Stderr, "错误: 备份运行时对象 '%s' 需要同时指定 --include-runtime-objects\n", resType)
+				os.Exit(1)
+			}
+		}
+	}
+	sortResourceTypes(resourceTypes)
+	var mapper meta.RESTMapper
+	var unservedTypes []string
+	if discoveryClient, err := discovery.NewDiscoveryClientForConfig(config); err == nil {
+		var lister serverResourcesLister = discoveryClient
+		var cache *cachedDiscovery
+		if state != nil {
+			cache = state.discovery(discoveryClient, config.Host)
+			lister = cache
+		}
+		if resourceTypes, unservedTypes = filterServedTypes(lister, resourceTypes); len(unservedTypes) > 0 {
+			fmt.Fprintf(logOut, "集群不提供的可选资源类型 (已跳过): %v\n", unservedTypes)
+		}
+		if cache != nil {
+			if err := cache.save(); err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 写入资源发现缓存失败: %v\n", err)
+			}
+		}
+		mapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
 	}
-	fmt.Printf("备份资源类型: %v\n", resourceTypes)
+	// 整个资源类型或命名空间级别的跳过记录, 备份结束后归入集群级资源所在的分区
+	var globalSkips []skipEntry
+	if (resourceTypesStr == "all" || resourceTypesStr == "") && !includeRuntime {
+		for _, resType := range runtimeResourceTypes() {
+			globalSkips = append(globalSkips, skipEntry{Reason: skipRuntimeType, Kind: resourceMap[resType].Kind, Detail: "使用 --include-runtime-objects 备份"})
+		}
+	}
+	if policy != nil && len(policy.ExcludeTypes) > 0 {
+		var allowed []string
+		for _, resType := range resourceTypes {
+			if !policy.ExcludeTypes[resType] {
+				allowed = append(allowed, resType)
+			} else {
+				globalSkips = append(globalSkips, skipEntry{Reason: skipPolicyType, Kind: resourceMap[resType].Kind, Detail: policy.Source})
+			}
+		}
+		resourceTypes = allowed
+	}
+	fmt.Fprintf(logOut, "备份资源类型: %v\n", resourceTypes)
 
 	var targetNamespaces []string
-	if namespace == "all" {
+	nsLabels := make(map[string]map[string]string)
+	if allNamespaces {
 		nsList, err := clientset.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "警告: 获取命名空间列表失败: %v\n", err)
 		} else {
-			nsLookup := make(map[string]struct{})
-			for _, ns := range skipNamespaces {
-				nsLookup[ns] = struct{}{}
-			}
+			listed := make(map[string]bool, len(namespaceAllowlist))
 			for _, ns := range nsList.Items {
-				if _, found := nsLookup[ns.Name]; !found {
-					targetNamespaces = append(targetNamespaces, ns.Name)
+				if namespaceAllowlist != nil {
+					if !namespaceAllowlist[ns.Name] {
+						continue
+					}
+					listed[ns.Name] = true
+				}
+				if !shard.contains(ns.Name) {
+					continue
+				}
+				if nsExclusion.excludes(ns.Name, ns.Labels) {
+					globalSkips = append(globalSkips, skipEntry{Reason: skipExcludedNamespace, Kind: "Namespace", Name: ns.Name})
+					continue
+				}
+				targetNamespaces = append(targetNamespaces, ns.Name)
+				nsLabels[ns.Name] = ns.Labels
+			}
+			var absent []string
+			for name := range namespaceAllowlist {
+				if !listed[name] {
+					absent = append(absent, name)
 				}
 			}
+			if len(absent) > 0 {
+				sort.Strings(absent)
+				fmt.Fprintf(os.Stderr, "警告: --namespace-file 中的 %d 个命名空间在集群中不存在: %s\n", len(absent), strings.Join(absent, ", "))
+			}
 		}
 	} else {
-		targetNamespaces = []string{namespace}
+		targetNamespaces = namespaces
+		if partitionLabel != "" {
+			for _, name := range namespaces {
+				if ns, err := clientset.CoreV1().Namespaces().Get(context.TODO(), name, metav1.GetOptions{}); err != nil {
+					fmt.Fprintf(os.Stderr, "警告: 读取命名空间 '%s' 的标签失败, 归入 %s 分区: %v\n", name, partitionUnlabeled, err)
+				} else {
+					nsLabels[name] = ns.Labels
+				}
+			}
+		}
 	}
-	fmt.Printf("目标命名空间: %v\n", targetNamespaces)
-
-	totalResources := 0
-	startTime := time.Now()
-
-	for _, nsName := range targetNamespaces {
-		fmt.Printf("\n[命名空间: %s]\n", nsName)
-		nsDir := filepath.Join(backupRoot, nsName)
-		if err := os.MkdirAll(nsDir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "  警告: 创建目录 '%s' 失败: %v\n", nsDir, err)
-			continue
+	if shard.enabled() {
+		fmt.Fprintf(logOut, "分片: %s\n", shard)
+	}
+	if changed.enabled() {
+		fmt.Fprintf(logOut, "只备份 %s 之后创建或修改过的对象\n", changed)
+	}
+	fmt.Fprintf(logOut, "目标命名空间: %v\n", targetNamespaces)
+	if configView {
+		view := effectiveConfig{
+			Settings:      collectFlagSettings(pflag.CommandLine, policyApplied, clusterConfig, true),
+			Resolved:      true,
+			OutputDir:     backupRoot,
+			ResourceTypes: resourceTypes,
+			ExcludedTypes: make(map[string]string),
+			Namespaces:    targetNamespaces,
+			Skips:         globalSkips,
 		}
-
-		nsResource := map[string]interface{}{
-			"apiVersion": "v1", "kind": "Namespace", "metadata": map[string]string{"name": nsName},
+		for _, resType := range unservedTypes {
+			view.ExcludedTypes[resType] = "集群不提供该 API"
 		}
-		nsYaml, _ := yaml.Marshal(nsResource)
-		os.WriteFile(filepath.Join(nsDir, "00-namespace.yaml"), nsYaml, 0644)
-
-		for _, resType := range resourceTypes {
-			resInfo, exists := resourceMap[resType]
-			if !exists || !resInfo.Namespaced {
-				continue
+		if policy != nil {
+			for resType := range policy.ExcludeTypes {
+				view.ExcludedTypes[resType] = "集群策略 " + policy.Source + " 排除"
 			}
-			if skipSecrets && resType == "secrets" {
-				continue
+		}
+		view.print(os.Stdout)
+		return
+	}
+	if estimate || metadataOnly {
+		metaClient, err := metadata.NewForConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 创建元数据客户端失败: %v\n", err)
+			os.Exit(1)
+		}
+		clusterScoped := !skipClusterResources && shard.ownsClusterResources()
+		if metadataOnly {
+			runInventory(metaClient, resourceTypes, targetNamespaces, clusterScoped, outputDir, inventoryDirPrefix+timestamp)
+			return
+		}
+		estimateTypes := resourceTypes
+		if skipSecrets {
+			estimateTypes = slices.DeleteFunc(slices.Clone(resourceTypes), func(t string) bool { return t == "secrets" })
+		}
+		fmt.Fprintln(logOut, "\n[备份估算]")
+		start := time.Now()
+		est := estimateBackup(metaClient, dynamicClient, estimateTypes, targetNamespaces, clusterScoped, cleanOpts)
+		est.print(logOut)
+		fmt.Fprintf(logOut, "估算耗时: %s (完整备份还需下载并写入全部对象)\n", time.Since(start).Round(time.Millisecond))
+		return
+	}
+	var checkpoint *stateCheckpoint
+	if state != nil {
+		if err := state.lock("backup " + backupRoot); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		var previous stateCheckpoint
+		if ok, _ := state.readState(stateCheckpointFile, &previous); ok {
+			fmt.Fprintf(os.Stderr, "警告: 上一次备份 %s 未正常结束 (已完成 %d/%d 个命名空间)\n", previous.Backup, len(previous.Completed), len(previous.Namespaces))
+		}
+		if checkpoint, err = state.beginCheckpoint(backupRoot, targetNamespaces); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 写入检查点失败: %v\n", err)
+			checkpoint = nil
+		}
+	}
+	var baseline *incrementalBaseline
+	if incremental {
+		var versions stateVersions
+		ok, err := state.readState(stateVersionsFile, &versions)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "警告: %v, 本次执行完整备份\n", err)
+		case !ok:
+			fmt.Fprintln(os.Stderr, "警告: 状态目录中没有对象版本索引, 本次执行完整备份")
+		default:
+			metaClient, err := metadata.NewForConfig(config)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "错误: 创建元数据客户端失败: %v\n", err)
+				os.Exit(1)
 			}
-			if !checkResourceAccess(clientset, resInfo.GVR, nsName) {
-				fmt.Printf("  警告: 无权限读取 %s, 跳过\n", resInfo.Kind)
-				continue
+			baseline = newIncrementalBaseline(metaClient, versions)
+			fmt.Fprintf(logOut, "增量备份: 基线为 %s (%d 个对象)\n", versions.Backup, len(versions.Objects))
+		}
+	}
+	progress.Emit(progressEvent{Event: "backup_started", Path: backupRoot, Count: len(targetNamespaces)})
+
+	startTime := time.Now()
+
+	run := &Backupper{
+		clientset:     clientset,
+		dynamicClient: dynamicClient,
+		resourceTypes: resourceTypes,
+		skipSecrets:   skipSecrets,
+		pullSecrets:   includePullSecrets,
+		keepSystem:    includeSystem,
+		systemConfig:  includeSystemConfig,
+		stripReplicas: stripReplicas,
+		orderedNames:  orderedNames,
+		layout:        layout,
+		incremental:   baseline,
+		allInOne:      allInOne,
+		graphFormat:   graphFormat,
+		configUsage:   configUsageReport,
+		since:         changed,
+		cleanOpts:     cleanOpts,
+		imageRewrites: imageRewrites,
+		validator:     validator,
+		mapper:        mapper,
+		progress:      progress,
+		partitions:    partitions,
+		maxBytes:      maxBytes,
+		sink:          newFileSink(writeConcurrency, fsyncPolicy),
+		nsWorkers:     namespaceConcurrency,
+		bufferOutput:  namespaceConcurrency > 1,
+	}
+	run.backupNamespaces(targetNamespaces, nsLabels, pace, func(nsName string) {
+		if checkpoint != nil {
+			if err := state.completeNamespace(checkpoint, nsName); err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 更新检查点失败: %v\n", err)
 			}
+		}
+	})
+	if !skipClusterResources && shard.ownsClusterResources() && !run.exceeded() {
+		run.backupClusterResources()
+	}
+	run.sink.close()
+	totalResources, invalidObjects := run.totalResources, run.invalidObjects
+	if len(globalSkips) > 0 {
+		if p, err := partitions.get(partitions.forCluster()); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 创建分区目录失败, 未记录 %d 条跳过记录: %v\n", len(globalSkips), err)
+		} else {
+			p.Skipped = append(globalSkips, p.Skipped...)
+		}
+	}
 
-			resClient := dynamicClient.Resource(resInfo.GVR).Namespace(nsName)
-			resList, err := resClient.List(context.TODO(), metav1.ListOptions{})
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "  错误: 获取 %s 失败: %v\n", resInfo.Kind, err)
-				continue
+	duration := time.Since(startTime).Round(time.Second)
+	if verifyCounts && run.exceeded() {
+		fmt.Fprintln(logOut, "\n备份因超过大小上限而中止, 跳过数量复查")
+	} else if verifyCounts {
+		fmt.Fprintln(logOut, "\n[数量复查]")
+		mismatched := 0
+		for _, p := range partitions.sorted() {
+			clusterScoped := p.Name == partitions.forCluster() && !skipClusterResources && shard.ownsClusterResources()
+			for _, m := range verifyBackupCounts(dynamicClient, p, resourceTypes, clusterScoped) {
+				fmt.Fprintf(logOut, "  警告: %s\n", m)
+				progress.Emit(progressEvent{Event: "resource_count_verify_failed", Namespace: m.Namespace, Kind: m.Kind, Error: m.String()})
+				mismatched++
 			}
-			if len(resList.Items) == 0 {
-				continue
+		}
+		if mismatched == 0 {
+			fmt.Fprintln(logOut, "  ✓ 各命名空间与资源类型的对象数与备份一致")
+		} else {
+			fmt.Fprintf(logOut, "  %d 处数量不一致, 可能是备份期间对象发生增删或清单写入失败\n", mismatched)
+		}
+	}
+	allIssues := progress.Issues()
+	var partitionStats []backupStats
+	previousTotal, hasPrevious := 0, false
+	for _, p := range partitions.sorted() {
+		if partitionLabel != "" {
+			fmt.Fprintf(logOut, "\n[分区: %s]\n", p.Name)
+		}
+		if checkSkew {
+			fmt.Fprintln(logOut, "\n[一致性复查]")
+			skew := checkBackupSkew(dynamicClient, p.Root, p.Index, refetchSkewed, cleanOpts)
+			if err := writeYAMLFile(filepath.Join(p.Root, skewFileName), skew); err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 写入 %s 失败: %v\n", skewFileName, err)
 			}
-			fmt.Printf("  资源: %s (找到 %d 个)\n", resInfo.Kind, len(resList.Items))
-
-			resources := resList.Items
-			if resType == "secrets" {
-				var filtered []unstructured.Unstructured
-				for _, r := range resources {
-					if ShouldBackupSecret(r.Object) {
-						filtered = append(filtered, r)
-					}
+			refetched := 0
+			for _, s := range skew.Objects {
+				if s.Refetched {
+					refetched++
 				}
-				resources = filtered
-			}
-			if len(resources) == 0 {
-				continue
 			}
+			fmt.Fprintf(logOut, "  备份期间发生变化的对象: %d 个 (已重新获取 %d 个), 详见 %s\n", len(skew.Objects), refetched, skewFileName)
+		}
 
-			resDir := filepath.Join(nsDir, resType)
-			os.MkdirAll(resDir, 0755)
-
-			backupCount := 0
-			for _, resource := range resources {
-				obj := CleanResource(resource.Object)
-				if resType == "configmaps" {
-					if data, ok := obj["data"].(map[string]interface{}); ok {
-						obj["data"] = processStringMapValues(data)
-					}
-				}
-
-				yamlData, err := yaml.Marshal(obj)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "    错误: 序列化 '%s' 失败: %v\n", resource.GetName(), err)
-					continue
-				}
+		backupMeta := backupMetadata{
+			Version:        version,
+			Timestamp:      backupTime.Format(time.RFC3339),
+			Namespaces:     p.Namespaces,
+			ResourceTypes:  resourceTypes,
+			TotalResources: p.Total,
+			Shard:          shard.String(),
+			ModifiedAfter:  changed.String(),
+			Cluster:        &fingerprint,
+			Tool:           newToolInfo(pflag.CommandLine, os.Args[1:], policy),
+		}
+		if baseline != nil {
+			backupMeta.IncrementalBase = baseline.Backup
+		}
+		p.writeIndex(backupMeta)
 
-				filename := fmt.Sprintf("%s.yaml", resource.GetName())
-				fullPath := filepath.Join(resDir, filename)
-				if err := os.WriteFile(fullPath, yamlData, 0644); err != nil {
-					fmt.Fprintf(os.Stderr, "    错误: 写入文件 '%s' 失败: %v\n", fullPath, err)
-					continue
-				}
-				backupCount++
+		var previousDir string
+		if !partialBackup {
+			previousDir = findPreviousBackup(filepath.Dir(p.Root), p.Root)
+		}
+		var previousIndex map[string]indexEntry
+		if previousDir != "" {
+			if previousIndex, err = loadBackupIndex(previousDir); err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 读取上一次备份索引 '%s' 失败: %v\n", previousDir, err)
+				previousIndex, previousDir = nil, ""
 			}
-			fmt.Printf("    ✓ 备份 %d 个 %s\n", backupCount, resInfo.Kind)
-			totalResources += backupCount
 		}
-	}
-
-	if !skipClusterResources {
-		fmt.Println("\n[集群范围资源]")
-		globalDir := filepath.Join(backupRoot, "_global")
-		os.MkdirAll(globalDir, 0755)
+		if previousIndex != nil {
+			previousTotal += len(previousIndex)
+			hasPrevious = true
+		}
+		stats := computeBackupStats(p.Index, previousDir, previousIndex)
+		if err := writeYAMLFile(filepath.Join(p.Root, statsFileName), stats); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 写入 %s 失败: %v\n", statsFileName, err)
+		}
+		partitionStats = append(partitionStats, stats)
+		if err := writeYAMLFile(filepath.Join(p.Root, skippedFileName), newSkipReport(p.Skipped)); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 写入 %s 失败: %v\n", skippedFileName, err)
+		} else if len(p.Skipped) > 0 {
+			fmt.Fprintf(logOut, "未备份的对象或资源类型: %d 项, 原因详见 %s\n", len(p.Skipped), skippedFileName)
+		}
 
-		for _, resType := range resourceTypes {
-			resInfo, exists := resourceMap[resType]
-			if !exists || resInfo.Namespaced {
-				continue
-			}
-			if !checkResourceAccess(clientset, resInfo.GVR, "") {
-				fmt.Printf("  警告: 无权限读取集群级 %s, 跳过\n", resInfo.Kind)
-				continue
+		if reportFormat == reportFormatHTML {
+			if err := writeHTMLReport(p.Root, backupMeta, duration.String(), p.Index, p.issuesFor(allIssues, partitionLabel != ""), previousDir, previousIndex); err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 生成HTML报告失败: %v\n", err)
 			}
-
-			resClient := dynamicClient.Resource(resInfo.GVR)
-			resList, err := resClient.List(context.TODO(), metav1.ListOptions{})
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "  错误: 获取 %s 失败: %v\n", resInfo.Kind, err)
-				continue
+		}
+		if attestation {
+			if err := writeBackupAttestation(p.Root, backupMeta, startTime, time.Now()); err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 生成 %s 失败: %v\n", attestationFileName, err)
+			} else {
+				fmt.Fprintf(logOut, "备份证明: %s\n", filepath.Join(p.Root, attestationFileName))
 			}
-			if len(resList.Items) == 0 {
-				continue
+		}
+	}
+	if state != nil {
+		// 超过大小上限的备份不完整, 保留检查点与上一次的版本索引
+		if !run.exceeded() {
+			if err := state.saveVersions(backupRoot, partitions.sorted(), run.incremental.carried()); err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 写入对象版本索引失败: %v\n", err)
 			}
-			fmt.Printf("  资源: %s (找到 %d 个)\n", resInfo.Kind, len(resList.Items))
-
-			resDir := filepath.Join(globalDir, resType)
-			os.MkdirAll(resDir, 0755)
-
-			backupCount := 0
-			for _, resource := range resList.Items {
-				obj := CleanResource(resource.Object)
-				yamlData, err := yaml.Marshal(obj)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "    错误: 序列化 '%s' 失败: %v\n", resource.GetName(), err)
-					continue
-				}
-				filename := fmt.Sprintf("%s.yaml", resource.GetName())
-				fullPath := filepath.Join(resDir, filename)
-				if err := os.WriteFile(fullPath, yamlData, 0644); err != nil {
-					fmt.Fprintf(os.Stderr, "    错误: 写入文件 '%s' 失败: %v\n", fullPath, err)
-					continue
-				}
-				backupCount++
+			if err := state.finishCheckpoint(); err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 删除检查点失败: %v\n", err)
 			}
-			fmt.Printf("    ✓ 备份 %d 个 %s\n", backupCount, resInfo.Kind)
-			totalResources += backupCount
 		}
+		if err := state.unlock(); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 释放状态目录锁失败: %v\n", err)
+		}
+	}
+	progress.Emit(progressEvent{Event: "backup_completed", Path: backupRoot, Count: totalResources, Duration: duration.String()})
+	fmt.Fprintf(logOut, "\n备份完成 🎉\n")
+	fmt.Fprintf(logOut, "总耗时: %s\n", duration)
+	fmt.Fprintf(logOut, "备份资源总数: %d\n", totalResources)
+	fmt.Fprintf(logOut, "备份位置: %s\n", backupRoot)
+	if len(invalidObjects) > 0 {
+		fmt.Fprintf(logOut, "未通过Schema校验的对象: %d 个 (重新应用时可能被拒绝)\n", len(invalidObjects))
+		for _, desc := range invalidObjects {
+			fmt.Fprintf(logOut, "  - %s\n", desc)
+		}
+	}
+	for i, p := range partitions.sorted() {
+		if partitionLabel != "" {
+			fmt.Fprintf(logOut, "\n分区 %s: %s (%d 个资源)\n", p.Name, p.Root, p.Total)
+		}
+		printBackupStats(partitionStats[i])
 	}
+	fmt.Fprintln(logOut)
+	fmt.Fprintln(logOut, "恢复说明:")
+	fmt.Fprintln(logOut, "1. 恢复命名空间 (如果需要):")
+	fmt.Fprintf(logOut, "   kubectl apply -f %s/<namespace>/00-namespace.yaml\n", backupRoot)
+	fmt.Fprintln(logOut, "2. 恢复命名空间内资源:")
+	fmt.Fprintf(logOut, "   kubectl apply -n <namespace> -f %s/<namespace>/\n", backupRoot)
+	fmt.Fprintln(logOut, "3. 恢复集群级资源 (如有):")
+	fmt.Fprintf(logOut, "   kubectl apply -f %s/_global/\n", backupRoot)
+	fmt.Fprintln(logOut, "或使用内置恢复命令按依赖顺序一次性恢复:")
+	fmt.Fprintf(logOut, "   %s restore %s\n", filepath.Base(os.Args[0]), backupRoot)
+	fmt.Fprintln(logOut, "\n注意: 恢复前请务必检查备份文件的内容，特别是存储和网络相关的配置。")
 
-	duration := time.Since(startTime).Round(time.Second)
-	fmt.Printf("\n备份完成 🎉\n")
-	fmt.Printf("总耗时: %s\n", duration)
-	fmt.Printf("备份资源总数: %d\n", totalResources)
-	fmt.Printf("备份位置: %s\n\n", backupRoot)
-	fmt.Println("恢复说明:")
-	fmt.Println("1. 恢复命名空间 (如果需要):")
-	fmt.Printf("   kubectl apply -f %s/<namespace>/00-namespace.yaml\n", backupRoot)
-	fmt.Println("2. 恢复命名空间内资源:")
-	fmt.Printf("   kubectl apply -n <namespace> -f %s/<namespace>/\n", backupRoot)
-	fmt.Println("3. 恢复集群级资源 (如有):")
-	fmt.Printf("   kubectl apply -f %s/_global/\n", backupRoot)
-	fmt.Println("\n注意: 恢复前请务必检查备份文件的内容，特别是存储和网络相关的配置。")
+	if run.exceeded() {
+		fmt.Fprintf(os.Stderr, "\n错误: 备份大小超过 --max-backup-size %s, 备份不完整, 请检查是否有命名空间的对象异常增长\n", maxBackupSize)
+		os.Exit(1)
+	}
+	if err := guard.check(totalResources, previousTotal, hasPrevious); err != nil {
+		progress.Emit(progressEvent{Event: "backup_guard_failed", Path: backupRoot, Count: totalResources, Error: err.Error()})
+		fmt.Fprintf(os.Stderr, "\n错误: %v, 请检查凭据权限与命名空间/类型过滤条件\n", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"backup-k8s/clean"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// 备份根目录下的元数据文件
+const (
+	metadataFileName = "metadata.yaml"
+	indexFileName    = "index.yaml"
+)
+
+// reservedFileNames 备份根目录或命名空间目录中由工具生成的非清单文件, 恢复时不会被当作资源应用
+var reservedFileNames = map[string]struct{}{
+	metadataFileName:      {},
+	indexFileName:         {},
+	sizingFileName:        {},
+	pvBindingsFileName:    {},
+	statsFileName:         {},
+	skewFileName:          {},
+	skippedFileName:       {},
+	pullSecretsFileName:   {},
+	loadBalancersFileName: {},
+	catalogInfoFileName:   {},
+	configUsageFileName:   {},
+}
+
+// isReservedFile 判断相对备份根目录的路径是否为工具生成的报告文件
+// 资源清单位于 <ns>/<type>/ 下, 只有根目录与命名空间目录中的文件可能是报告文件
+func isReservedFile(rel string) bool {
+	if strings.Count(rel, "/") > 1 {
+		return false
+	}
+	_, reserved := reservedFileNames[path.Base(rel)]
+	return reserved
+}
+
+// backupMetadata 记录一次备份的整体信息, 写入 metadata.yaml
+type backupMetadata struct {
+	Version         string              `yaml:"version"`
+	Timestamp       string              `yaml:"timestamp"`
+	Namespaces      []string            `yaml:"namespaces"`
+	ResourceTypes   []string            `yaml:"resourceTypes"`
+	TotalResources  int                 `yaml:"totalResources"`
+	Shard           string              `yaml:"shard,omitempty"`           // --shard 分片, 如 1/4
+	ModifiedAfter   string              `yaml:"modifiedAfter,omitempty"`   // --since / --modified-after 的时间下限, 非空表示备份只包含近期变更
+	IncrementalBase string              `yaml:"incrementalBase,omitempty"` // --incremental 的基线备份, 非空表示备份只包含相对该备份有变化的对象
+	Cluster         *clusterFingerprint `yaml:"cluster,omitempty"`
+	Tool            *toolInfo           `yaml:"tool,omitempty"` // 生成备份的工具版本与参数, 旧版本备份没有该字段
+}
+
+// indexEntry 记录单个备份对象在清理前的身份信息, 写入 index.yaml
+type indexEntry struct {
+	Path            string `yaml:"path"`
+	APIVersion      string `yaml:"apiVersion"`
+	Kind            string `yaml:"kind"`
+	Namespace       string `yaml:"namespace,omitempty"`
+	Name            string `yaml:"name"`
+	UID             string `yaml:"uid,omitempty"`
+	ResourceVersion string `yaml:"resourceVersion,omitempty"`
+	Size            int    `yaml:"size"`   // 清理后清单的字节数
+	Digest          string `yaml:"digest"` // 清理后清单 (不含备份时间注解) 的 sha256, 用于与其他备份比较变更
+}
+
+// newIndexEntry 在清理前记录对象的身份信息 (清理会移除 uid/resourceVersion)
+func newIndexEntry(obj *unstructured.Unstructured) indexEntry {
+	return indexEntry{
+		APIVersion:      obj.GetAPIVersion(),
+		Kind:            obj.GetKind(),
+		Namespace:       obj.GetNamespace(),
+		Name:            obj.GetName(),
+		UID:             string(obj.GetUID()),
+		ResourceVersion: obj.GetResourceVersion(),
+	}
+}
+
+// withFile 设置条目对应文件相对备份根目录的路径以及清单内容的大小与摘要
+func (e indexEntry) withFile(backupRoot, fullPath string, data []byte) indexEntry {
+	if rel, err := filepath.Rel(backupRoot, fullPath); err == nil {
+		e.Path = filepath.ToSlash(rel)
+	}
+	e.Size = len(data)
+	e.Digest = manifestDigest(data)
+	return e
+}
+
+// manifestDigest 计算清单的摘要; --stamp-origin 写入的备份时间注解每次备份都不同, 计算时跳过该行,
+// 使未变化的对象在不同备份间摘要一致
+func manifestDigest(data []byte) string {
+	h := sha256.New()
+	if !bytes.Contains(data, []byte(clean.AnnotationBackupTime)) {
+		h.Write(data)
+	} else {
+		prefix := []byte(clean.AnnotationBackupTime + ":")
+		for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+			if !bytes.HasPrefix(bytes.TrimSpace(line), prefix) {
+				h.Write(line)
+			}
+		}
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// writeYAMLFile 将任意结构序列化为YAML并写入文件
+func writeYAMLFile(path string, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// readYAMLFile 读取YAML文件并反序列化到 v
+func readYAMLFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// loadBackupMetadata 读取备份目录中的 metadata.yaml, 旧版本备份没有该文件时返回 nil
+func loadBackupMetadata(backupDir string) (*backupMetadata, error) {
+	var meta backupMetadata
+	if err := readYAMLFile(filepath.Join(backupDir, metadataFileName), &meta); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// objectKey 返回对象在一次备份中的唯一标识 (不含 apiVersion, 以兼容恢复时的版本改写)
+func objectKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// backupDirPrefix 备份目录名前缀, 目录名后缀为可按字典序排序的时间戳
+const backupDirPrefix = "k8s-backup-"
+
+// findPreviousBackup 在输出目录中查找早于 current 的最近一次备份 (须包含 index.yaml), 没有时返回空字符串
+func findPreviousBackup(outputDir, current string) string {
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		return ""
+	}
+	currentName := filepath.Base(current)
+	previous := ""
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || !strings.HasPrefix(name, backupDirPrefix) || name >= currentName || name <= previous {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(outputDir, name, indexFileName)); err == nil {
+			previous = name
+		}
+	}
+	if previous == "" {
+		return ""
+	}
+	return filepath.Join(outputDir, previous)
+}
+
+// loadBackupIndex 读取备份目录中的 index.yaml, 以 objectKey 为键返回
+func loadBackupIndex(backupDir string) (map[string]indexEntry, error) {
+	var entries []indexEntry
+	if err := readYAMLFile(filepath.Join(backupDir, indexFileName), &entries); err != nil {
+		if os.IsNotExist(err) {
+			return map[string]indexEntry{}, nil
+		}
+		return nil, err
+	}
+	index := make(map[string]indexEntry, len(entries))
+	for _, e := range entries {
+		index[objectKey(e.Kind, e.Namespace, e.Name)] = e
+	}
+	return index, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+)
+
+// namespaceDefaultKinds 为命名空间内的 Pod 提供默认 requests/limits 或限额的类型, 恢复顺序在工作负载之前
+var namespaceDefaultKinds = map[string]bool{"LimitRange": true, "ResourceQuota": true}
+
+// addNamespaceDefaults 用于 restore --with-namespace-defaults: 按类型/标签/名称筛选后,
+// 补回筛选结果所涉命名空间中被筛掉的 LimitRange 与 ResourceQuota, 保持 all 中的恢复顺序
+func addNamespaceDefaults(all, selected []restoreItem) ([]restoreItem, int) {
+	chosen := make(map[string]bool, len(selected))
+	namespaces := make(map[string]bool)
+	for _, item := range selected {
+		chosen[objectKey(item.Obj.GetKind(), item.Obj.GetNamespace(), item.Obj.GetName())] = true
+		namespaces[item.Obj.GetNamespace()] = true
+	}
+	var result []restoreItem
+	added := 0
+	for _, item := range all {
+		obj := item.Obj
+		switch {
+		case chosen[objectKey(obj.GetKind(), obj.GetNamespace(), obj.GetName())]:
+			result = append(result, item)
+		case namespaceDefaultKinds[obj.GetKind()] && namespaces[obj.GetNamespace()]:
+			result = append(result, item)
+			added++
+		}
+	}
+	return result, added
+}
+
+// checkNamespaceDefaults 找出容器未设置 requests 与 limits 的工作负载, 若本次恢复不包含其命名空间的 LimitRange,
+// 目标集群的该命名空间中也没有 LimitRange, 则这些 Pod 将以 BestEffort 运行 (命名空间有计算资源配额时会被拒绝), 返回按命名空间汇总的警告
+func checkNamespaceDefaults(client dynamic.Interface, items []restoreItem) []string {
+	unbounded := make(map[string][]string)
+	covered := make(map[string]bool)
+	for _, item := range items {
+		obj := item.Obj
+		if obj.GetKind() == "LimitRange" {
+			covered[obj.GetNamespace()] = true
+			continue
+		}
+		if !isWorkloadKind(obj.GetKind()) {
+			continue
+		}
+		podSpec := podSpecOf(obj.Object)
+		if podSpec == nil {
+			continue
+		}
+		for _, c := range containersOf(podSpec) {
+			resources, _ := c["resources"].(map[string]interface{})
+			requests, _ := resources["requests"].(map[string]interface{})
+			limits, _ := resources["limits"].(map[string]interface{})
+			if len(requests) == 0 && len(limits) == 0 {
+				unbounded[obj.GetNamespace()] = append(unbounded[obj.GetNamespace()], obj.GetKind()+"/"+obj.GetName())
+				break
+			}
+		}
+	}
+
+	var warnings []string
+	for ns, workloads := range unbounded {
+		if covered[ns] {
+			continue
+		}
+		list, err := client.Resource(resourceMap["limitranges"].GVR).Namespace(ns).List(context.TODO(), metav1.ListOptions{Limit: 1})
+		if err == nil && len(list.Items) > 0 {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("命名空间 %s 没有 LimitRange, 以下工作负载的容器未设置 requests/limits: %s", ns, strings.Join(workloads, ", ")))
+	}
+	sort.Strings(warnings)
+	return warnings
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// namespaceExclusion --exclude-namespaces 的名称/通配符模式与 --exclude-namespace-selector 标签选择器, 满足任一条件的命名空间不备份
+type namespaceExclusion struct {
+	patterns []string // path.Match 语法, 如 kube-*, openshift-*
+	selector labels.Selector
+}
+
+// newNamespaceExclusion 校验通配符模式并解析标签选择器, selector 为空时不按标签排除
+func newNamespaceExclusion(patterns []string, selector string) (*namespaceExclusion, error) {
+	e := &namespaceExclusion{}
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("无效的命名空间模式 '%s': %w", p, err)
+		}
+		e.patterns = append(e.patterns, p)
+	}
+	if selector != "" {
+		s, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("无效的命名空间标签选择器 '%s': %w", selector, err)
+		}
+		e.selector = s
+	}
+	return e, nil
+}
+
+// excludes 判断命名空间是否被排除
+func (e *namespaceExclusion) excludes(name string, nsLabels map[string]string) bool {
+	if matchNamespacePattern(e.patterns, name) {
+		return true
+	}
+	return e.selector != nil && e.selector.Matches(labels.Set(nsLabels))
+}
+
+// matchNamespacePattern 判断命名空间名称是否匹配任一名称或通配符模式
+func matchNamespacePattern(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// loadNamespaceFile 读取 --namespace-file 指定的命名空间清单: 每行一个名称, 忽略空行与 # 之后的注释, 重复的名称只保留一次
+func loadNamespaceFile(file string) ([]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取命名空间清单 '%s' 失败: %w", file, err)
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for i, line := range strings.Split(string(data), "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		name := strings.TrimSpace(line)
+		if name == "" || seen[name] {
+			continue
+		}
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return nil, fmt.Errorf("命名空间清单 '%s' 第 %d 行 '%s' 不是合法的命名空间名: %s", file, i+1, name, strings.Join(errs, "; "))
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("命名空间清单 '%s' 中没有命名空间", file)
+	}
+	return names, nil
+}
+
+// parseNamespaceList 解析 --namespace 的逗号分隔列表, 未指定命名空间或指定 --all-namespaces 时返回 all 为 true
+// 兼容旧用法 --namespace all, 由调用方提示改用 --all-namespaces
+func parseNamespaceList(value string, allNamespaces bool) (names []string, all bool, err error) {
+	names = splitList(value)
+	if len(names) == 1 && names[0] == "all" {
+		names = nil
+		allNamespaces = true
+	}
+	if allNamespaces && len(names) > 0 {
+		return nil, false, fmt.Errorf("--all-namespaces 不能与 --namespace 同时使用")
+	}
+	seen := make(map[string]bool, len(names))
+	var unique []string
+	for _, name := range names {
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return nil, false, fmt.Errorf("--namespace 中的 '%s' 不是合法的命名空间名: %s", name, strings.Join(errs, "; "))
+		}
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	return unique, len(unique) == 0, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNamespaceExclusion(t *testing.T) {
+	e, err := newNamespaceExclusion([]string{"kube-system", "openshift-*"}, "backup=disabled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		labels   map[string]string
+		excluded bool
+	}{
+		{"kube-system", nil, true},
+		{"kube-public", nil, false},
+		{"openshift-monitoring", nil, true},
+		{"web", map[string]string{"backup": "disabled"}, true},
+		{"web", map[string]string{"backup": "enabled"}, false},
+	}
+	for _, tc := range cases {
+		if got := e.excludes(tc.name, tc.labels); got != tc.excluded {
+			t.Errorf("excludes(%s, %v) = %v, 期望 %v", tc.name, tc.labels, got, tc.excluded)
+		}
+	}
+
+	if _, err := newNamespaceExclusion([]string{"kube-["}, ""); err == nil {
+		t.Error("无效的通配符模式应返回错误")
+	}
+	if _, err := newNamespaceExclusion(nil, "backup in (disabled"); err == nil {
+		t.Error("无效的标签选择器应返回错误")
+	}
+}
+
+func TestLoadNamespaceFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "namespaces.txt")
+	content := "# 由 CMDB 生成\nweb\n  api  # 支付后端\n\nweb\r\nbatch\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	names, err := loadNamespaceFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "web,api,batch" {
+		t.Errorf("命名空间 = %v, 期望 web,api,batch", names)
+	}
+
+	for _, bad := range []string{"# 只有注释\n\n", "web\nNot_Valid\n"} {
+		if err := os.WriteFile(file, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadNamespaceFile(file); err == nil {
+			t.Errorf("无效的清单应被拒绝: %q", bad)
+		}
+	}
+	if _, err := loadNamespaceFile(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
+
+func TestParseNamespaceList(t *testing.T) {
+	cases := []struct {
+		value   string
+		all     bool
+		want    string
+		wantAll bool
+		wantErr bool
+	}{
+		{value: "", want: "", wantAll: true},
+		{value: "", all: true, want: "", wantAll: true},
+		{value: "all", want: "", wantAll: true},
+		{value: "payments, billing,auth,billing", want: "payments,billing,auth"},
+		{value: "payments", all: true, wantErr: true},
+		{value: "payments,Bad_Name", wantErr: true},
+	}
+	for _, tc := range cases {
+		names, all, err := parseNamespaceList(tc.value, tc.all)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseNamespaceList(%q, %v) 应返回错误", tc.value, tc.all)
+			}
+			continue
+		}
+		if err != nil || strings.Join(names, ",") != tc.want || all != tc.wantAll {
+			t.Errorf("parseNamespaceList(%q, %v) = %v, %v, %v, 期望 %s, %v", tc.value, tc.all, names, all, err, tc.want, tc.wantAll)
+		}
+	}
+}
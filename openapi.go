@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/openapi"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// schemaRefPrefix OpenAPI v3 文档中组件引用的前缀
+const schemaRefPrefix = "#/components/schemas/"
+
+// schemaValidator 使用集群的 OpenAPI v3 文档校验清理后的清单, 发现重新应用时会被拒绝的对象
+// 只检查必填字段, 基本类型与未知字段 (kubectl 默认的严格字段校验会拒绝未知字段), 不检查取值范围与格式
+type schemaValidator struct {
+	paths map[string]openapi.GroupVersion
+	mu    sync.Mutex                // 保护 docs, 使多个命名空间可以并行校验
+	docs  map[string]*spec3.OpenAPI // 以 OpenAPI 路径为键, 加载失败时为 nil
+}
+
+// newSchemaValidator 读取集群提供的 OpenAPI v3 分组列表, 各分组文档在首次使用时加载
+func newSchemaValidator(client openapi.Client) (*schemaValidator, error) {
+	paths, err := client.Paths()
+	if err != nil {
+		return nil, err
+	}
+	return &schemaValidator{paths: paths, docs: make(map[string]*spec3.OpenAPI)}, nil
+}
+
+// doc 返回 GroupVersion 对应的 OpenAPI 文档, 集群未提供时返回 nil
+func (v *schemaValidator) doc(gv schema.GroupVersion) *spec3.OpenAPI {
+	path := "apis/" + gv.Group + "/" + gv.Version
+	if gv.Group == "" {
+		path = "api/" + gv.Version
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if doc, loaded := v.docs[path]; loaded {
+		return doc
+	}
+	v.docs[path] = nil
+	groupVersion, ok := v.paths[path]
+	if !ok {
+		return nil
+	}
+	data, err := groupVersion.Schema("application/json")
+	if err != nil {
+		return nil
+	}
+	var doc spec3.OpenAPI
+	if err := json.Unmarshal(data, &doc); err != nil || doc.Components == nil {
+		return nil
+	}
+	v.docs[path] = &doc
+	return &doc
+}
+
+// Validate 校验对象, 返回所有问题 (形如 "spec.replicas: 类型应为 integer"); 集群没有该类型的定义时不做校验
+func (v *schemaValidator) Validate(obj map[string]interface{}) []string {
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return []string{fmt.Sprintf("apiVersion: 无法解析 '%s'", apiVersion)}
+	}
+	doc := v.doc(gv)
+	if doc == nil {
+		return nil
+	}
+	root := kindSchema(doc, gv.WithKind(kind))
+	if root == nil {
+		return nil
+	}
+	var problems []string
+	validateValue(obj, root, doc.Components.Schemas, "", &problems)
+	return problems
+}
+
+// kindSchema 按 x-kubernetes-group-version-kind 扩展查找 Kind 的顶层定义
+func kindSchema(doc *spec3.OpenAPI, gvk schema.GroupVersionKind) *spec.Schema {
+	for _, s := range doc.Components.Schemas {
+		gvks, _ := s.Extensions["x-kubernetes-group-version-kind"].([]interface{})
+		for _, item := range gvks {
+			m, _ := item.(map[string]interface{})
+			if m["group"] == gvk.Group && m["version"] == gvk.Version && m["kind"] == gvk.Kind {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// resolveSchema 展开 $ref 以及只包含单个引用的 allOf (OpenAPI v3 中带默认值的字段采用这种写法)
+func resolveSchema(s *spec.Schema, schemas map[string]*spec.Schema) *spec.Schema {
+	for i := 0; s != nil && i < 10; i++ {
+		switch {
+		case s.Ref.String() != "":
+			s = schemas[strings.TrimPrefix(s.Ref.String(), schemaRefPrefix)]
+		case len(s.AllOf) == 1 && len(s.Properties) == 0 && len(s.Type) == 0:
+			s = &s.AllOf[0]
+		default:
+			return s
+		}
+	}
+	return s
+}
+
+// validateValue 递归校验 value 是否符合 schema, 问题追加到 problems
+func validateValue(value interface{}, s *spec.Schema, schemas map[string]*spec.Schema, path string, problems *[]string) {
+	s = resolveSchema(s, schemas)
+	if s == nil || value == nil {
+		return
+	}
+	if preserve, _ := s.Extensions["x-kubernetes-preserve-unknown-fields"].(bool); preserve && len(s.Properties) == 0 {
+		return
+	}
+	if intOrString, _ := s.Extensions["x-kubernetes-int-or-string"].(bool); intOrString {
+		switch value.(type) {
+		case string, int64, float64, int:
+		default:
+			*problems = append(*problems, fmt.Sprintf("%s: 类型应为整数或字符串", displayPath(path)))
+		}
+		return
+	}
+
+	switch {
+	case s.Type.Contains("object") || len(s.Properties) > 0:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: 类型应为 object", displayPath(path)))
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := m[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s: 缺少必填字段", displayPath(joinPath(path, name))))
+			}
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		preserve, _ := s.Extensions["x-kubernetes-preserve-unknown-fields"].(bool)
+		for _, k := range keys {
+			if prop, ok := s.Properties[k]; ok {
+				validateValue(m[k], &prop, schemas, joinPath(path, k), problems)
+				continue
+			}
+			switch {
+			case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+				validateValue(m[k], s.AdditionalProperties.Schema, schemas, joinPath(path, k), problems)
+			case len(s.Properties) > 0 && !preserve && (s.AdditionalProperties == nil || !s.AdditionalProperties.Allows):
+				*problems = append(*problems, fmt.Sprintf("%s: 未知字段", displayPath(joinPath(path, k))))
+			}
+		}
+	case s.Type.Contains("array"):
+		list, ok := value.([]interface{})
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: 类型应为 array", displayPath(path)))
+			return
+		}
+		if s.Items != nil && s.Items.Schema != nil {
+			for i, item := range list {
+				validateValue(item, s.Items.Schema, schemas, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case s.Type.Contains("string"):
+		if _, ok := value.(string); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: 类型应为 string", displayPath(path)))
+		}
+	case s.Type.Contains("integer"):
+		if !isInteger(value) {
+			*problems = append(*problems, fmt.Sprintf("%s: 类型应为 integer", displayPath(path)))
+		}
+	case s.Type.Contains("number"):
+		switch value.(type) {
+		case int64, float64, int:
+		default:
+			*problems = append(*problems, fmt.Sprintf("%s: 类型应为 number", displayPath(path)))
+		}
+	case s.Type.Contains("boolean"):
+		if _, ok := value.(bool); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s: 类型应为 boolean", displayPath(path)))
+		}
+	}
+}
+
+// isInteger 判断解码后的JSON值是否为整数
+func isInteger(value interface{}) bool {
+	switch n := value.(type) {
+	case int64, int:
+		return true
+	case float64:
+		return n == math.Trunc(n)
+	}
+	return false
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(根)"
+	}
+	return path
+}
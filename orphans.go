@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/spf13/pflag"
+)
+
+// orphanKinds prune-orphans 检查的类型
+var orphanKinds = []string{"ConfigMap", "Secret", "PersistentVolumeClaim"}
+
+// orphanEntry 备份中未被任何对象引用的对象
+type orphanEntry struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+}
+
+// runPruneOrphans 实现 prune-orphans 子命令: 列出备份中未被同一备份内任何对象引用的 ConfigMap/Secret/PVC, 供清理集群时参考
+// 只读取备份目录, 不连接集群, 不删除任何对象
+func runPruneOrphans(args []string) {
+	var backupDir, output string
+	fs := pflag.NewFlagSet("prune-orphans", pflag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: k8s-backup prune-orphans [参数] <备份目录>\n")
+		fmt.Fprintf(os.Stderr, "只读: 根据备份内容列出未被引用的 ConfigMap/Secret/PVC, 不连接集群, 不删除任何对象\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&backupDir, "from", "", "要分析的备份目录 (也可作为位置参数传入)")
+	fs.StringVarP(&output, "output", "o", "text", "输出格式 (text|json)")
+	fs.Parse(args)
+
+	if backupDir == "" && fs.NArg() > 0 {
+		backupDir = fs.Arg(0)
+	}
+	if backupDir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if output != "text" && output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s' (可选: text, json)\n", output)
+		os.Exit(2)
+	}
+	items, err := loadRestoreItems(backupDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 读取备份清单失败: %v\n", err)
+		os.Exit(1)
+	}
+	if !containsWorkloads(items) {
+		fmt.Fprintln(os.Stderr, "警告: 备份中没有工作负载 (可能以 --type 限定了资源类型), 以下对象未被引用不代表集群中无人使用")
+	}
+
+	orphans := findOrphans(items)
+	if output == "json" {
+		if orphans == nil {
+			orphans = []orphanEntry{}
+		}
+		data, _ := json.MarshalIndent(orphans, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	printOrphans(os.Stdout, orphans)
+}
+
+// findOrphans 按命名空间汇总备份中的引用关系, 返回未被引用的对象, 按命名空间, 类型, 名称排序
+// 由其他对象拥有 (ownerReferences), 服务账号令牌与 Helm release 等 Secret 不计入, 与 --config-usage-report 相同
+func findOrphans(items []restoreItem) []orphanEntry {
+	usages := make(map[string]*configUsage)
+	for _, item := range items {
+		ns := item.Obj.GetNamespace()
+		if ns == "" {
+			continue
+		}
+		usage, ok := usages[ns]
+		if !ok {
+			usage = newConfigUsage(ns, orphanKinds)
+			usages[ns] = usage
+		}
+		usage.exists(item.Obj)
+		usage.add(item.Obj.Object)
+	}
+	var orphans []orphanEntry
+	for ns, usage := range usages {
+		for _, obj := range usage.report().Objects {
+			if obj.Orphaned {
+				orphans = append(orphans, orphanEntry{Namespace: ns, Kind: obj.Kind, Name: obj.Name})
+			}
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool {
+		a, b := orphans[i], orphans[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return orphans
+}
+
+func containsWorkloads(items []restoreItem) bool {
+	for _, item := range items {
+		if isWorkloadKind(item.Obj.GetKind()) {
+			return true
+		}
+	}
+	return false
+}
+
+func printOrphans(w io.Writer, orphans []orphanEntry) {
+	if len(orphans) == 0 {
+		fmt.Fprintln(w, "备份中的 ConfigMap/Secret/PVC 均被引用, 没有孤立对象")
+		return
+	}
+	counts := make(map[string]int)
+	ns := ""
+	for _, o := range orphans {
+		if o.Namespace != ns {
+			ns = o.Namespace
+			fmt.Fprintf(w, "[命名空间: %s]\n", ns)
+		}
+		fmt.Fprintf(w, "  %s %s\n", o.Kind, o.Name)
+		counts[o.Kind]++
+	}
+	fmt.Fprintf(w, "\n共 %d 个孤立对象 (ConfigMap %d 个, Secret %d 个, PersistentVolumeClaim %d 个)\n",
+		len(orphans), counts["ConfigMap"], counts["Secret"], counts["PersistentVolumeClaim"])
+	fmt.Fprintln(w, "仅供参考, 本命令不会删除任何对象: 引用也可能来自备份之外 (未备份的 Pod, 其他命名空间的控制器, 通过 API 读取的应用), 删除前请逐一确认")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFindOrphans(t *testing.T) {
+	item := func(apiVersion, kind, namespace, name string, fields map[string]interface{}) restoreItem {
+		return restoreItem{Obj: fakeObject(apiVersion, kind, namespace, name, fields)}
+	}
+	podSpec := map[string]interface{}{
+		"containers": []interface{}{map[string]interface{}{
+			"name":    "db",
+			"envFrom": []interface{}{map[string]interface{}{"secretRef": map[string]interface{}{"name": "db-credentials"}}},
+		}},
+		"volumes": []interface{}{map[string]interface{}{"name": "backup", "persistentVolumeClaim": map[string]interface{}{"claimName": "backup"}}},
+	}
+	owned := item("v1", "ConfigMap", "db", "operator-state", nil)
+	owned.Obj.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "example.com/v1", Kind: "Cluster", Name: "db", UID: "uid-db"}})
+	items := []restoreItem{
+		item("apps/v1", "StatefulSet", "db", "postgres", map[string]interface{}{
+			"spec": map[string]interface{}{
+				"template":             map[string]interface{}{"spec": podSpec},
+				"volumeClaimTemplates": []interface{}{map[string]interface{}{"metadata": map[string]interface{}{"name": "data"}}},
+			},
+		}),
+		item("v1", "Secret", "db", "db-credentials", map[string]interface{}{"type": "Opaque"}),
+		item("v1", "Secret", "db", "old-credentials", map[string]interface{}{"type": "Opaque"}),
+		item("v1", "Secret", "db", "sh.helm.release.v1.db.v3", map[string]interface{}{"type": "helm.sh/release.v1"}),
+		item("v1", "PersistentVolumeClaim", "db", "backup", nil),
+		item("v1", "PersistentVolumeClaim", "db", "data-postgres-0", nil),
+		item("v1", "PersistentVolumeClaim", "db", "data-postgres-old", nil),
+		item("v1", "ConfigMap", "web", "unused", nil),
+		owned,
+	}
+	want := []orphanEntry{
+		{Namespace: "db", Kind: "PersistentVolumeClaim", Name: "data-postgres-old"},
+		{Namespace: "db", Kind: "Secret", Name: "old-credentials"},
+		{Namespace: "web", Kind: "ConfigMap", Name: "unused"},
+	}
+	if got := findOrphans(items); !reflect.DeepEqual(got, want) {
+		t.Errorf("孤立对象 = %+v\n期望 %+v", got, want)
+	}
+	if !containsWorkloads(items) || containsWorkloads(items[1:]) {
+		t.Error("containsWorkloads 结果不正确")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// outputDirVars --output-dir 模板中可用的变量, 如 /backups/{{.Cluster}}/{{.Date}}
+type outputDirVars struct {
+	Cluster    string // kubeconfig 当前上下文的集群名, 集群内运行 (无 kubeconfig) 时为集群 UID 的前 8 位
+	Context    string // kubeconfig 当前上下文名, 集群内运行时为空
+	ClusterUID string // kube-system 命名空间的 UID
+	Date       string // 2006-01-02
+	Time       string // 150405
+	Year       string
+	Month      string
+	Day        string
+}
+
+// newOutputDirVars 根据 kubeconfig 与集群标识生成模板变量
+func newOutputDirVars(kubeconfig string, fp clusterFingerprint, now time.Time) outputDirVars {
+	vars := outputDirVars{
+		ClusterUID: fp.ClusterUID,
+		Date:       now.Format("2006-01-02"),
+		Time:       now.Format("150405"),
+		Year:       now.Format("2006"),
+		Month:      now.Format("01"),
+		Day:        now.Format("02"),
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	if raw, err := loadingRules.Load(); err == nil && raw.CurrentContext != "" {
+		vars.Context = raw.CurrentContext
+		if ctx, ok := raw.Contexts[raw.CurrentContext]; ok {
+			vars.Cluster = ctx.Cluster
+		}
+	}
+	if vars.Cluster == "" && len(fp.ClusterUID) >= 8 {
+		vars.Cluster = fp.ClusterUID[:8]
+	}
+	return vars
+}
+
+// expandOutputDir 展开 --output-dir 中的模板, 不含 {{ 时原样返回
+// 变量值中的路径分隔符替换为 _, 使每个变量只占一级目录; 展开后出现空目录名 (引用的变量为空) 时报错, 避免不同集群写入同一目录
+func expandOutputDir(dir string, vars outputDirVars) (string, error) {
+	if !strings.Contains(dir, "{{") {
+		return dir, nil
+	}
+	tmpl, err := template.New("output-dir").Option("missingkey=error").Parse(dir)
+	if err != nil {
+		return "", fmt.Errorf("无效的 --output-dir 模板 '%s': %w", dir, err)
+	}
+	safe := vars
+	for _, v := range []*string{&safe.Cluster, &safe.Context, &safe.ClusterUID} {
+		*v = strings.NewReplacer("/", "_", `\`, "_").Replace(*v)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, safe); err != nil {
+		return "", fmt.Errorf("无效的 --output-dir 模板 '%s': %w", dir, err)
+	}
+	expanded := filepath.ToSlash(buf.String())
+	if strings.Contains(expanded, "//") && !strings.Contains(filepath.ToSlash(dir), "//") ||
+		strings.HasSuffix(expanded, "/") && !strings.HasSuffix(filepath.ToSlash(dir), "/") {
+		return "", fmt.Errorf("--output-dir 模板 '%s' 展开后含有空的目录名 ('%s'), 请确认引用的变量在当前环境中有值", dir, expanded)
+	}
+	return filepath.FromSlash(expanded), nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandOutputDir(t *testing.T) {
+	vars := outputDirVars{Cluster: "arn:aws:eks:us-east-1:123:cluster/prod", Context: "prod", Date: "2024-05-01", Time: "020000"}
+	cases := []struct {
+		dir     string
+		want    string
+		wantErr bool
+	}{
+		{dir: "/backups", want: "/backups"},
+		{dir: "/backups/{{.Context}}/{{.Date}}", want: "/backups/prod/2024-05-01"},
+		{dir: "/backups/{{.Cluster}}", want: "/backups/arn:aws:eks:us-east-1:123:cluster_prod"},
+		{dir: "/backups/{{.ClusterUID}}/{{.Date}}", wantErr: true},
+		{dir: "/backups/{{.Unknown}}", wantErr: true},
+		{dir: "/backups/{{.Date", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := expandOutputDir(c.dir, vars)
+		if (err != nil) != c.wantErr {
+			t.Errorf("expandOutputDir(%q) err = %v, 期望出错 %v", c.dir, err, c.wantErr)
+			continue
+		}
+		if !c.wantErr && got != filepath.FromSlash(c.want) {
+			t.Errorf("expandOutputDir(%q) = %q, 期望 %q", c.dir, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// backupPace 备份节奏预设: --nice 用于白天在敏感集群上临时备份, 降低 API server 压力;
+// --turbo 用于专用维护窗口, 放宽 client-go 默认的客户端限速 (5 QPS) 以尽快完成
+type backupPace struct {
+	Name             string
+	QPS              float32
+	Burst            int
+	NamespaceDelay   time.Duration // 相邻命名空间之间的暂停
+	WriteConcurrency int           // 未显式指定 --write-concurrency 时使用
+}
+
+var (
+	paceNice  = backupPace{Name: "nice", QPS: 2, Burst: 4, NamespaceDelay: 2 * time.Second, WriteConcurrency: 1}
+	paceTurbo = backupPace{Name: "turbo", QPS: 100, Burst: 200, WriteConcurrency: 16}
+)
+
+// resolveBackupPace 根据 --nice/--turbo 返回节奏预设, 均未指定时返回 nil (保持 client-go 默认值)
+func resolveBackupPace(nice, turbo bool) (*backupPace, error) {
+	switch {
+	case nice && turbo:
+		return nil, fmt.Errorf("--nice 与 --turbo 不能同时指定")
+	case nice:
+		return &paceNice, nil
+	case turbo:
+		return &paceTurbo, nil
+	}
+	return nil, nil
+}
+
+// apply 设置客户端限速, 并在 --write-concurrency 未显式指定时调整写入并发数
+func (p *backupPace) apply(config *rest.Config, writeConcurrency *int, writeConcurrencySet bool) {
+	config.QPS = p.QPS
+	config.Burst = p.Burst
+	if !writeConcurrencySet {
+		*writeConcurrency = p.WriteConcurrency
+	}
+}
+
+// pause 在处理下一个命名空间之前调用
+func (p *backupPace) pause() {
+	if p != nil && p.NamespaceDelay > 0 {
+		time.Sleep(p.NamespaceDelay)
+	}
+}
+
+// describe 返回节奏设置的可读描述, 备份开始时输出
+func (p *backupPace) describe(writeConcurrency int) string {
+	s := fmt.Sprintf("%s (QPS %g, 突发 %d, 写入并发 %d", p.Name, p.QPS, p.Burst, writeConcurrency)
+	if p.NamespaceDelay > 0 {
+		s += fmt.Sprintf(", 命名空间间隔 %s", p.NamespaceDelay)
+	}
+	return s + ")"
+}
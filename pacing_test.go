@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestResolveBackupPace(t *testing.T) {
+	if _, err := resolveBackupPace(true, true); err == nil {
+		t.Error("--nice 与 --turbo 同时指定应报错")
+	}
+	if pace, err := resolveBackupPace(false, false); err != nil || pace != nil {
+		t.Errorf("未指定时应保持默认值, 得到 %+v, %v", pace, err)
+	}
+
+	nice, _ := resolveBackupPace(true, false)
+	config := &rest.Config{}
+	concurrency := 8
+	nice.apply(config, &concurrency, false)
+	if config.QPS != 2 || config.Burst != 4 || concurrency != 1 {
+		t.Errorf("nice: QPS=%v Burst=%d 写入并发=%d", config.QPS, config.Burst, concurrency)
+	}
+	if nice.NamespaceDelay == 0 {
+		t.Error("nice 应在命名空间之间暂停")
+	}
+
+	turbo, _ := resolveBackupPace(false, true)
+	concurrency = 4
+	turbo.apply(config, &concurrency, true)
+	if config.QPS != 100 || concurrency != 4 {
+		t.Errorf("turbo: QPS=%v 写入并发=%d, 显式指定的 --write-concurrency 应保留", config.QPS, concurrency)
+	}
+	if turbo.describe(concurrency) != "turbo (QPS 100, 突发 200, 写入并发 4)" {
+		t.Errorf("describe = %q", turbo.describe(concurrency))
+	}
+
+	var none *backupPace
+	none.pause()
+}
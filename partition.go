@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// --partition-by-label 启用时的特殊分区名
+const (
+	partitionUnlabeled = "_unlabeled" // 命名空间没有该标签
+	partitionCluster   = "_cluster"   // 集群级资源, 不随任何租户的分区交付
+)
+
+// backupPartition 一个分区对应一个独立的备份目录, 拥有各自的元数据, 索引与报告
+// 并行备份时多个命名空间可能属于同一分区, 备份过程中的修改均通过加锁的方法进行
+type backupPartition struct {
+	Name       string // 分区名 (命名空间标签值), 未启用分区时为空
+	Root       string
+	mu         sync.Mutex // 保护以下字段
+	Index      []indexEntry
+	Images     *imageInventory
+	Namespaces []string
+	Total      int
+	Skipped    []skipEntry
+}
+
+// partitionSet 按需创建各分区的备份目录
+// 未启用分区时只有一个名为空的分区, 目录为 <outputDir>/<备份名>; 否则为 <outputDir>/<分区名>/<备份名>
+type partitionSet struct {
+	outputDir  string
+	backupName string
+	label      string
+	mu         sync.Mutex // 保护 byName
+	byName     map[string]*backupPartition
+}
+
+func newPartitionSet(outputDir, backupName, label string) *partitionSet {
+	return &partitionSet{outputDir: outputDir, backupName: backupName, label: label, byName: make(map[string]*backupPartition)}
+}
+
+// get 返回分区, 首次使用时创建其备份目录
+func (s *partitionSet) get(name string) (*backupPartition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.byName[name]; ok {
+		return p, nil
+	}
+	root := filepath.Join(s.outputDir, name, s.backupName)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	p := &backupPartition{Name: name, Root: root, Images: newImageInventory()}
+	s.byName[name] = p
+	return p, nil
+}
+
+// forNamespace 根据命名空间标签返回其所属分区名
+func (s *partitionSet) forNamespace(labels map[string]string) string {
+	if s.label == "" {
+		return ""
+	}
+	if value := labels[s.label]; value != "" {
+		return value
+	}
+	return partitionUnlabeled
+}
+
+// forCluster 返回集群级资源所属的分区名
+func (s *partitionSet) forCluster() string {
+	if s.label == "" {
+		return ""
+	}
+	return partitionCluster
+}
+
+// sorted 返回按名称排序的全部分区
+func (s *partitionSet) sorted() []*backupPartition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	partitions := make([]*backupPartition, 0, len(s.byName))
+	for _, p := range s.byName {
+		partitions = append(partitions, p)
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].Name < partitions[j].Name })
+	return partitions
+}
+
+// addNamespace 记录属于分区的命名空间
+func (p *backupPartition) addNamespace(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Namespaces = append(p.Namespaces, name)
+}
+
+// addEntry 将已写入的对象加入索引, obj 不为空时同时登记其引用的镜像, changes 为该对象的镜像改写
+func (p *backupPartition) addEntry(e indexEntry, obj map[string]interface{}, changes []imageChange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Index = append(p.Index, e)
+	if obj != nil {
+		p.Images.add(obj, changes)
+	}
+}
+
+// addTotal 累加分区的对象数
+func (p *backupPartition) addTotal(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Total += n
+}
+
+// dropFailed 将写入失败的文件 (以完整路径为键) 对应的对象移出索引并记入跳过报告, 返回移除的条目
+func (p *backupPartition) dropFailed(failed map[string]error) []indexEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	kept := p.Index[:0]
+	var dropped []indexEntry
+	for _, e := range p.Index {
+		err, ok := failed[filepath.Join(p.Root, filepath.FromSlash(e.Path))]
+		if !ok {
+			kept = append(kept, e)
+			continue
+		}
+		dropped = append(dropped, e)
+		p.Skipped = append(p.Skipped, skipEntry{Reason: skipWriteFailed, Kind: e.Kind, Namespace: e.Namespace, Name: e.Name, Detail: err.Error()})
+	}
+	p.Index = kept
+	p.Total -= len(dropped)
+	return dropped
+}
+
+// writeIndex 写入分区的备份元数据, 索引与镜像清单, 失败时输出警告; restore 依据这些文件恢复
+func (p *backupPartition) writeIndex(meta backupMetadata) {
+	if err := writeYAMLFile(filepath.Join(p.Root, metadataFileName), meta); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 写入备份元数据失败: %v\n", err)
+	}
+	if err := writeYAMLFile(filepath.Join(p.Root, indexFileName), p.Index); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 写入备份索引失败: %v\n", err)
+	}
+	if err := p.Images.write(p.Root); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 写入镜像清单失败: %v\n", err)
+	}
+}
+
+// issuesFor 筛选属于分区的失败与跳过事件, 避免租户报告中出现其他租户的命名空间
+// 不带命名空间的事件 (集群级资源) 归属集群分区, 未启用分区时全部保留
+func (p *backupPartition) issuesFor(issues []progressEvent, partitioned bool) []progressEvent {
+	if !partitioned {
+		return issues
+	}
+	namespaces := make(map[string]struct{}, len(p.Namespaces))
+	for _, ns := range p.Namespaces {
+		namespaces[ns] = struct{}{}
+	}
+	var result []progressEvent
+	for _, ev := range issues {
+		_, ok := namespaces[ev.Namespace]
+		if ok || (ev.Namespace == "" && p.Name == partitionCluster) {
+			result = append(result, ev)
+		}
+	}
+	return result
+}
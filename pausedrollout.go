@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// annotationPausedRollout restore --paused-rollout 记录工作负载原始副本数 (Job/CronJob 为 suspend) 的注解
+// 恢复中断时可据此找出仍处于暂停状态的工作负载
+const annotationPausedRollout = "k8s-back.io/paused-rollout"
+
+// pausedSuspend Job/CronJob 的 annotationPausedRollout 取值, 表示恢复完成后取消 suspend
+const pausedSuspend = "suspend"
+
+// pauseWorkloads 用于 restore --paused-rollout: 将副本型工作负载以 0 副本创建, 将 Job/CronJob 以 suspend 创建,
+// 待 ConfigMap、Secret、PVC 等依赖全部应用后再由 resumeWorkloads 恢复, 避免 Pod 在依赖不完整时反复崩溃重启
+// 副本数为 0 时 HPA 会暂停自动扩缩, 恢复副本数后自动继续; 原本就是 0 副本或已 suspend 的对象不做修改, 返回被暂停的对象数
+func pauseWorkloads(items []restoreItem) int {
+	paused := 0
+	for _, item := range items {
+		obj := item.Obj
+		var value string
+		switch obj.GetKind() {
+		case "Deployment", "StatefulSet", "ReplicaSet", "DeploymentConfig", "Rollout":
+			replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+			if err != nil {
+				continue
+			}
+			if !found {
+				replicas = 1
+			}
+			if replicas == 0 {
+				continue
+			}
+			if unstructured.SetNestedField(obj.Object, int64(0), "spec", "replicas") != nil {
+				continue
+			}
+			value = strconv.FormatInt(replicas, 10)
+		case "Job", "CronJob":
+			if suspended, _, _ := unstructured.NestedBool(obj.Object, "spec", "suspend"); suspended {
+				continue
+			}
+			if unstructured.SetNestedField(obj.Object, true, "spec", "suspend") != nil {
+				continue
+			}
+			value = pausedSuspend
+		default:
+			continue
+		}
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[annotationPausedRollout] = value
+		obj.SetAnnotations(annotations)
+		paused++
+	}
+	return paused
+}
+
+// resumeWorkloads 将 pauseWorkloads 暂停且本次成功创建的工作负载恢复为原始副本数或取消 suspend, 并移除注解
+func resumeWorkloads(client dynamic.Interface, mapper meta.RESTMapper, created []*unstructured.Unstructured) (resumed, failed int) {
+	for _, obj := range created {
+		value, ok := obj.GetAnnotations()[annotationPausedRollout]
+		if !ok {
+			continue
+		}
+		spec := map[string]interface{}{"suspend": false}
+		if value != pausedSuspend {
+			replicas, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			spec = map[string]interface{}{"replicas": replicas}
+		}
+		patch, _ := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{"annotations": map[string]interface{}{annotationPausedRollout: nil}},
+			"spec":     spec,
+		})
+		desc := describeObject(obj)
+		resClient, err := resourceClientFor(client, mapper, obj)
+		if err == nil {
+			_, err = resClient.Patch(context.TODO(), obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  错误: 恢复 %s 失败: %v\n", desc, err)
+			failed++
+			continue
+		}
+		if value == pausedSuspend {
+			fmt.Fprintf(logOut, "  ✓ %s 已取消 suspend\n", desc)
+		} else {
+			fmt.Fprintf(logOut, "  ✓ %s 已恢复为 %s 副本\n", desc, value)
+		}
+		resumed++
+	}
+	return resumed, failed
+}
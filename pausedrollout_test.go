@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestPausedRollout(t *testing.T) {
+	logOut = io.Discard
+	t.Cleanup(func() { logOut = os.Stdout })
+
+	web := fakeObject("apps/v1", "Deployment", "app", "web", map[string]interface{}{
+		"spec": map[string]interface{}{"replicas": int64(3)},
+	})
+	defaulted := fakeObject("apps/v1", "StatefulSet", "app", "db", map[string]interface{}{
+		"spec": map[string]interface{}{},
+	})
+	idle := fakeObject("apps/v1", "Deployment", "app", "idle", map[string]interface{}{
+		"spec": map[string]interface{}{"replicas": int64(0)},
+	})
+	report := fakeObject("batch/v1", "CronJob", "app", "report", map[string]interface{}{
+		"spec": map[string]interface{}{"schedule": "0 * * * *"},
+	})
+	settings := fakeObject("v1", "ConfigMap", "app", "settings", nil)
+	items := []restoreItem{{Obj: settings}, {Obj: web}, {Obj: defaulted}, {Obj: idle}, {Obj: report}}
+
+	if paused := pauseWorkloads(items); paused != 3 {
+		t.Errorf("暂停 %d 个工作负载, 期望 3 个", paused)
+	}
+	for _, obj := range []*unstructured.Unstructured{web, defaulted} {
+		if replicas, _, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); replicas != 0 {
+			t.Errorf("%s 应以 0 副本创建, 实际 %d", describeObject(obj), replicas)
+		}
+	}
+	if web.GetAnnotations()[annotationPausedRollout] != "3" || defaulted.GetAnnotations()[annotationPausedRollout] != "1" {
+		t.Errorf("原始副本数记录错误: %v, %v", web.GetAnnotations(), defaulted.GetAnnotations())
+	}
+	if suspend, _, _ := unstructured.NestedBool(report.Object, "spec", "suspend"); !suspend {
+		t.Error("CronJob 应以 suspend 创建")
+	}
+	if _, ok := idle.GetAnnotations()[annotationPausedRollout]; ok {
+		t.Error("原本 0 副本的工作负载不应被标记")
+	}
+
+	deploymentsGVR := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	cronJobsGVR := schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), web.DeepCopy(), report.DeepCopy(), settings.DeepCopy())
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	// StatefulSet 未在本次创建 (如已存在), 不应被修改
+	resumed, failed := resumeWorkloads(client, mapper, []*unstructured.Unstructured{settings, web, report})
+	if resumed != 2 || failed != 0 {
+		t.Errorf("恢复 %d 个, 失败 %d 个, 期望恢复 2 个", resumed, failed)
+	}
+	got, err := client.Resource(deploymentsGVR).Namespace("app").Get(context.TODO(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas, _, _ := unstructured.NestedInt64(got.Object, "spec", "replicas"); replicas != 3 {
+		t.Errorf("恢复后副本数 = %d, 期望 3", replicas)
+	}
+	if _, ok := got.GetAnnotations()[annotationPausedRollout]; ok {
+		t.Error("恢复后应移除暂停注解")
+	}
+	got, err = client.Resource(cronJobsGVR).Namespace("app").Get(context.TODO(), "report", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suspend, _, _ := unstructured.NestedBool(got.Object, "spec", "suspend"); suspend {
+		t.Error("恢复后 CronJob 应取消 suspend")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// --preset 可选的资源类型预设
+const (
+	presetOpenShift = "openshift" // Route, DeploymentConfig, ImageStream, BuildConfig
+	presetArgo      = "argo"      // Argo Rollouts 的 Rollout, AnalysisTemplate 与 Argo Workflows 的 WorkflowTemplate, CronWorkflow
+)
+
+// presetNames 全部预设, 用于参数校验与帮助信息
+var presetNames = []string{presetOpenShift, presetArgo}
+
+// parsePresets 解析逗号分隔的预设名称
+func parsePresets(s string) ([]string, error) {
+	presets := splitList(s)
+	for _, name := range presets {
+		known := false
+		for _, p := range presetNames {
+			known = known || p == name
+		}
+		if !known {
+			return nil, fmt.Errorf("不支持的预设 '%s' (可选: %s)", name, strings.Join(presetNames, ", "))
+		}
+	}
+	return presets, nil
+}
+
+// presetResourceTypes 返回预设包含的资源类型, 按依赖顺序排序
+func presetResourceTypes(presets []string) []string {
+	enabled := make(map[string]bool)
+	for _, name := range presets {
+		enabled[name] = true
+	}
+	var resourceTypes []string
+	for resType, resInfo := range resourceMap {
+		if resInfo.Preset != "" && enabled[resInfo.Preset] {
+			resourceTypes = append(resourceTypes, resType)
+		}
+	}
+	sortResourceTypes(resourceTypes)
+	return resourceTypes
+}
+
+// presetsFromArgs 从备份参数中找出 --preset 的值, 供 install 为 ClusterRole 授予对应的读取权限
+func presetsFromArgs(args []string) []string {
+	var presets []string
+	for i, arg := range args {
+		if value, ok := strings.CutPrefix(arg, "--preset="); ok {
+			presets = append(presets, splitList(value)...)
+		} else if arg == "--preset" && i+1 < len(args) {
+			presets = append(presets, splitList(args[i+1])...)
+		}
+	}
+	return presets
+}
+
+// filterServedTypes 通过 discovery 移除集群不提供的预设或可选资源类型
+// (如普通 Kubernetes 集群上的 OpenShift 资源, 1.30 之前的 ValidatingAdmissionPolicy)
+// 这些类型被静默跳过, 返回被移除的类型供调试输出; 其他类型原样保留
+func filterServedTypes(client serverResourcesLister, resourceTypes []string) (served, missing []string) {
+	groupResources := make(map[string]map[string]bool)
+	for _, resType := range resourceTypes {
+		resInfo := resourceMap[resType]
+		if resInfo.Preset == "" && !resInfo.Optional {
+			served = append(served, resType)
+			continue
+		}
+		gv := resInfo.GVR.GroupVersion().String()
+		resources, checked := groupResources[gv]
+		if !checked {
+			resources = make(map[string]bool)
+			if list, err := client.ServerResourcesForGroupVersion(gv); err == nil {
+				for _, r := range list.APIResources {
+					resources[r.Name] = true
+				}
+			}
+			groupResources[gv] = resources
+		}
+		if resources[resInfo.GVR.Resource] {
+			served = append(served, resType)
+		} else {
+			missing = append(missing, resType)
+		}
+	}
+	sort.Strings(missing)
+	return served, missing
+}
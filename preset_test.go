@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestFilterServedTypes(t *testing.T) {
+	client := &discoveryfake.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	client.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "configmaps"}}},
+		{GroupVersion: "route.openshift.io/v1", APIResources: []metav1.APIResource{{Name: "routes"}}},
+	}
+	presets, err := parsePresets(presetOpenShift)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceTypes := append([]string{"configmaps", "validatingadmissionpolicies"}, presetResourceTypes(presets)...)
+	served, missing := filterServedTypes(client, resourceTypes)
+	if strings.Join(served, ",") != "configmaps,routes" {
+		t.Errorf("served = %v", served)
+	}
+	if strings.Join(missing, ",") != "buildconfigs,deploymentconfigs,imagestreams,validatingadmissionpolicies" {
+		t.Errorf("missing = %v", missing)
+	}
+
+	if got := presetResourceTypes([]string{presetArgo}); strings.Join(got, ",") != "analysistemplates,rollouts,workflowtemplates,cronworkflows" {
+		t.Errorf("argo 预设 = %v", got)
+	}
+	if _, err := parsePresets("rancher"); err == nil {
+		t.Error("未知预设应返回错误")
+	}
+	if got := presetsFromArgs([]string{"--namespace", "all", "--preset=openshift"}); len(got) != 1 || got[0] != presetOpenShift {
+		t.Errorf("presetsFromArgs = %v", got)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processQueryLimitedInformation OpenProcess 所需的最小访问权限, syscall 包未定义
+const processQueryLimitedInformation = 0x1000
+
+// stillActive GetExitCodeProcess 对仍在运行的进程返回的退出码
+const stillActive = 259
+
+// errorInvalidParameter OpenProcess 对不存在的 PID 返回的错误
+const errorInvalidParameter syscall.Errno = 87
+
+// processAlive 判断本机进程是否仍在运行, 无法确定时视为仍在运行, 避免接管正在使用的锁
+// Windows 不支持 Signal(0), 改为打开进程句柄并查询退出码
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		// 进程不存在时为 ERROR_INVALID_PARAMETER, 其他错误 (如无权访问) 说明进程存在
+		return !errors.Is(err, errorInvalidParameter)
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 进度输出格式
+const (
+	progressFormatText  = "text"
+	progressFormatJSONL = "jsonl"
+)
+
+// logOut 是面向人的进度文字输出位置; jsonl 模式下 stdout 专用于事件流, 文字改写到 stderr
+var logOut io.Writer = os.Stdout
+
+// progressEvent 描述一条结构化进度事件, 每条事件序列化为一行 JSON
+type progressEvent struct {
+	Event     string `json:"event"`
+	Time      string `json:"time"`
+	Namespace string `json:"ns,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Count     int    `json:"count,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Duration  string `json:"duration,omitempty"`
+}
+
+// progressReporter 在 jsonl 模式下向 stdout 输出事件, text 模式下不输出任何事件
+// 两种模式下都会记录失败与跳过事件, 供备份结束后的汇总报告使用
+type progressReporter struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	issues []progressEvent
+}
+
+// newProgressReporter 根据 --progress-format 创建进度上报器
+func newProgressReporter(format string, w io.Writer) (*progressReporter, error) {
+	switch format {
+	case progressFormatText, "":
+		return &progressReporter{}, nil
+	case progressFormatJSONL:
+		logOut = os.Stderr
+		return &progressReporter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("不支持的进度格式 '%s' (可选: %s, %s)", format, progressFormatText, progressFormatJSONL)
+	}
+}
+
+// Emit 输出一条事件, 自动补全时间戳
+func (p *progressReporter) Emit(ev progressEvent) {
+	if p == nil {
+		return
+	}
+	ev.Time = time.Now().Format(time.RFC3339)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if strings.HasSuffix(ev.Event, "_failed") || strings.HasSuffix(ev.Event, "_skipped") {
+		p.issues = append(p.issues, ev)
+	}
+	if p.enc != nil {
+		p.enc.Encode(ev)
+	}
+}
+
+// Issues 返回目前为止记录的失败与跳过事件
+func (p *progressReporter) Issues() []progressEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]progressEvent(nil), p.issues...)
+}
+
+// outputMu 串行化各输出段向 logOut 与 stderr 的写入
+var outputMu sync.Mutex
+
+// outputSection 一个命名空间 (或集群级资源) 的文字输出段
+// buffered 为 true 时先缓存, flush 时在 outputMu 保护下整体写出, 使并行备份的各命名空间输出不交错;
+// 否则逐行直接写出, 顺序备份时保持实时输出
+type outputSection struct {
+	buffered bool
+	lines    []sectionLine
+}
+
+// sectionLine 缓存的一行输出, stderr 表示写到标准错误 (错误与警告)
+type sectionLine struct {
+	stderr bool
+	text   string
+}
+
+func newOutputSection(buffered bool) *outputSection {
+	return &outputSection{buffered: buffered}
+}
+
+// printf 输出进度文字, 对应 fmt.Fprintf(logOut, ...)
+func (s *outputSection) printf(format string, args ...interface{}) {
+	s.add(false, fmt.Sprintf(format, args...))
+}
+
+// errorf 输出错误或警告, 对应 fmt.Fprintf(os.Stderr, ...)
+func (s *outputSection) errorf(format string, args ...interface{}) {
+	s.add(true, fmt.Sprintf(format, args...))
+}
+
+func (s *outputSection) add(stderr bool, text string) {
+	if !s.buffered {
+		outputMu.Lock()
+		defer outputMu.Unlock()
+		writeSectionLine(sectionLine{stderr: stderr, text: text})
+		return
+	}
+	s.lines = append(s.lines, sectionLine{stderr: stderr, text: text})
+}
+
+// flush 整体写出缓存的输出
+func (s *outputSection) flush() {
+	if len(s.lines) == 0 {
+		return
+	}
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	for _, line := range s.lines {
+		writeSectionLine(line)
+	}
+	s.lines = nil
+}
+
+func writeSectionLine(line sectionLine) {
+	if line.stderr {
+		fmt.Fprint(os.Stderr, line.text)
+		return
+	}
+	fmt.Fprint(logOut, line.text)
+}
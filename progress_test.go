@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestOutputSectionBuffered(t *testing.T) {
+	var buf bytes.Buffer
+	logOut = &buf
+	t.Cleanup(func() { logOut = os.Stdout })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			out := newOutputSection(true)
+			for j := 0; j < 50; j++ {
+				out.printf("ns-%d line %d\n", i, j)
+			}
+			out.flush()
+		}(i)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 8*50 {
+		t.Fatalf("输出 %d 行, 期望 %d 行", len(lines), 8*50)
+	}
+	// 每个输出段的 50 行必须连续出现
+	for start := 0; start < len(lines); start += 50 {
+		prefix := strings.Fields(lines[start])[0]
+		for j := 0; j < 50; j++ {
+			if want := fmt.Sprintf("%s line %d", prefix, j); lines[start+j] != want {
+				t.Fatalf("第 %d 行 = %q, 期望 %q (输出段交错)", start+j, lines[start+j], want)
+			}
+		}
+	}
+}
+
+func TestOutputSectionUnbuffered(t *testing.T) {
+	var buf bytes.Buffer
+	logOut = &buf
+	t.Cleanup(func() { logOut = os.Stdout })
+
+	out := newOutputSection(false)
+	out.printf("实时输出 %d\n", 1)
+	if buf.String() != "实时输出 1\n" {
+		t.Errorf("未缓存的输出段应立即写出, 实际 %q", buf.String())
+	}
+	out.flush()
+	if buf.String() != "实时输出 1\n" {
+		t.Errorf("flush 不应重复输出, 实际 %q", buf.String())
+	}
+}
+
+func TestBackupperCountersConcurrent(t *testing.T) {
+	b := &Backupper{maxBytes: 1000}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				b.addResources(1)
+				b.reserveBytes(10)
+			}
+			b.recordInvalid("ConfigMap web/settings")
+		}()
+	}
+	wg.Wait()
+	if b.totalResources != 200 || len(b.invalidObjects) != 20 {
+		t.Errorf("对象数 %d, 校验失败 %d, 期望 200 与 20", b.totalResources, len(b.invalidObjects))
+	}
+	if !b.exceeded() || b.writtenBytes != 1000 {
+		t.Errorf("超过上限后应停止计入, 已写入 %d", b.writtenBytes)
+	}
+
+	// 并行备份多个命名空间, 其中多个命名空间属于同一分区
+	var objects []runtime.Object
+	namespaces := []string{"shop", "billing", "auth", "search", "mail", "ops"}
+	labels := make(map[string]map[string]string)
+	for i, ns := range namespaces {
+		labels[ns] = map[string]string{"team": fmt.Sprintf("team-%d", i%2)}
+		for j := 0; j < 3; j++ {
+			objects = append(objects, fakeObject("v1", "ConfigMap", ns, fmt.Sprintf("settings-%d", j), nil))
+		}
+	}
+	run, _ := newFakeBackupper(t, []string{"configmaps"}, nil, objects...)
+	run.partitions = newPartitionSet(t.TempDir(), "backup", "team")
+	run.sink = newFileSink(4, fsyncNone)
+	run.progress = nil
+	run.nsWorkers, run.bufferOutput = 4, true
+	var completed []string
+	run.backupNamespaces(namespaces, labels, nil, func(nsName string) {
+		// 记入检查点时该命名空间的清单必须已全部写入
+		p, _ := run.partitions.get(labels[nsName]["team"])
+		for j := 0; j < 3; j++ {
+			if _, err := os.Stat(filepath.Join(p.Root, nsName, "configmaps", fmt.Sprintf("settings-%d.yaml", j))); err != nil {
+				t.Errorf("%s 完成时清单未写入: %v", nsName, err)
+			}
+		}
+		completed = append(completed, nsName)
+	})
+	run.sink.close()
+
+	if run.totalResources != 18 || len(completed) != len(namespaces) {
+		t.Errorf("对象数 %d, 完成的命名空间 %d, 期望 18 与 %d", run.totalResources, len(completed), len(namespaces))
+	}
+	for i, p := range run.partitions.sorted() {
+		want := []string{namespaces[i], namespaces[i+2], namespaces[i+4]}
+		if p.Total != 9 || len(p.Index) != 9 || strings.Join(p.Namespaces, ",") != strings.Join(want, ",") {
+			t.Errorf("分区 %s: 对象数 %d, 索引 %d 条, 命名空间 %v; 期望 9, 9, %v", p.Name, p.Total, len(p.Index), p.Namespaces, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+)
+
+// labelRestoreSet 恢复时写入对象的集合标签, 值为 --restore-set 指定的集合名, --prune 据此识别上一次恢复的对象
+const labelRestoreSet = "k8s-back.io/restore-set"
+
+// defaultRestoreSet 未指定 --restore-set 时的集合名
+const defaultRestoreSet = "default"
+
+// validateRestoreSet 校验集合名可用作标签值
+func validateRestoreSet(name string) error {
+	if errs := validation.IsValidLabelValue(name); len(errs) > 0 || name == "" {
+		return fmt.Errorf("无效的恢复集合名 '%s': 须为合法的标签值", name)
+	}
+	return nil
+}
+
+// checkPrunable 检查备份能否用于 --prune: 只包含变更的备份 (--since/--modified-after 或 --incremental) 缺少未变化的对象,
+// 以其为准清理会删除这些对象
+func checkPrunable(meta *backupMetadata) error {
+	switch {
+	case meta == nil:
+		return nil
+	case meta.ModifiedAfter != "":
+		return fmt.Errorf("备份只包含 %s 之后变化的对象, 不能使用 --prune (会删除未变化的对象)", meta.ModifiedAfter)
+	case meta.IncrementalBase != "":
+		return fmt.Errorf("备份是基于 %s 的增量备份, 只包含有变化的对象, 不能使用 --prune (会删除未变化的对象)", meta.IncrementalBase)
+	}
+	return nil
+}
+
+// setRestoreSetLabel 为对象添加恢复集合标签
+func setRestoreSetLabel(obj *unstructured.Unstructured, setName string) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[labelRestoreSet] = setName
+	obj.SetLabels(labels)
+}
+
+// pruneRestoreSet 删除备份涉及的命名空间中属于同一恢复集合, 但不在本次备份内容中的对象
+// 检查的类型为备份中出现的类型与 resourceMap 中的内置类型, 目标集群不支持的类型被忽略; 只处理命名空间级对象
+func pruneRestoreSet(client dynamic.Interface, mapper meta.RESTMapper, setName string, items []restoreItem) (deleted, failed int) {
+	keep := make(map[string]struct{}, len(items))
+	namespaces := make(map[string]struct{})
+	kinds := make(map[schema.GroupKind]struct{})
+	for _, item := range items {
+		obj := item.Obj
+		keep[objectKey(obj.GetKind(), obj.GetNamespace(), obj.GetName())] = struct{}{}
+		if obj.GetKind() == "Namespace" {
+			namespaces[obj.GetName()] = struct{}{}
+		} else if ns := obj.GetNamespace(); ns != "" {
+			namespaces[ns] = struct{}{}
+		}
+		kinds[obj.GroupVersionKind().GroupKind()] = struct{}{}
+	}
+	for _, resInfo := range resourceMap {
+		kinds[schema.GroupKind{Group: resInfo.GVR.Group, Kind: resInfo.Kind}] = struct{}{}
+	}
+
+	var mappings []*meta.RESTMapping
+	for gk := range kinds {
+		mapping, err := mapper.RESTMapping(gk)
+		if err != nil || mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			continue
+		}
+		mappings = append(mappings, mapping)
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		return restoreRank(mappings[i].GroupVersionKind.Kind) > restoreRank(mappings[j].GroupVersionKind.Kind)
+	})
+
+	selector := labelRestoreSet + "=" + setName
+	for _, ns := range sortedKeys(namespaces) {
+		for _, mapping := range mappings {
+			resClient := client.Resource(mapping.Resource).Namespace(ns)
+			list, err := resClient.List(context.TODO(), metav1.ListOptions{LabelSelector: selector})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "  警告: 列出 %s (%s) 失败, 跳过清理: %v\n", mapping.GroupVersionKind.Kind, ns, err)
+				continue
+			}
+			for i := range list.Items {
+				obj := &list.Items[i]
+				if _, ok := keep[objectKey(obj.GetKind(), obj.GetNamespace(), obj.GetName())]; ok {
+					continue
+				}
+				desc := describeObject(obj)
+				policy := metav1.DeletePropagationBackground
+				if err := resClient.Delete(context.TODO(), obj.GetName(), metav1.DeleteOptions{PropagationPolicy: &policy}); err != nil {
+					fmt.Fprintf(os.Stderr, "  错误: 删除 %s 失败: %v\n", desc, err)
+					failed++
+					continue
+				}
+				fmt.Fprintf(logOut, "  ✗ %s 已删除 (不在备份中)\n", desc)
+				deleted++
+			}
+		}
+	}
+	return deleted, failed
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestPruneRestoreSet(t *testing.T) {
+	logOut = io.Discard
+	t.Cleanup(func() { logOut = os.Stdout })
+
+	labeled := func(kind, namespace, name, set string) *unstructured.Unstructured {
+		obj := fakeObject("v1", kind, namespace, name, nil)
+		if set != "" {
+			setRestoreSetLabel(obj, set)
+		}
+		return obj
+	}
+	configMapsGVR := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{configMapsGVR: "ConfigMapList"},
+		labeled("ConfigMap", "web", "kept", "default"),
+		labeled("ConfigMap", "web", "stale", "default"),
+		labeled("ConfigMap", "web", "other-set", "nightly"),
+		labeled("ConfigMap", "web", "unmanaged", ""),
+		labeled("ConfigMap", "api", "outside-backup", "default"),
+	)
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	items := []restoreItem{{Obj: fakeObject("v1", "ConfigMap", "web", "kept", nil)}}
+	deleted, failed := pruneRestoreSet(client, mapper, "default", items)
+	if deleted != 1 || failed != 0 {
+		t.Errorf("删除 %d 个, 失败 %d 个, 期望删除 1 个", deleted, failed)
+	}
+
+	list, err := client.Resource(configMapsGVR).Namespace("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remaining := make(map[string]bool)
+	for _, obj := range list.Items {
+		remaining[obj.GetNamespace()+"/"+obj.GetName()] = true
+	}
+	for _, name := range []string{"web/kept", "web/other-set", "web/unmanaged", "api/outside-backup"} {
+		if !remaining[name] {
+			t.Errorf("%s 不应被删除", name)
+		}
+	}
+	if remaining["web/stale"] {
+		t.Error("web/stale 应被删除")
+	}
+}
+
+func TestValidateRestoreSet(t *testing.T) {
+	for name, valid := range map[string]bool{"default": true, "prod-2024": true, "": false, "bad name": false} {
+		if err := validateRestoreSet(name); (err == nil) != valid {
+			t.Errorf("validateRestoreSet(%q) = %v", name, err)
+		}
+	}
+}
+
+func TestCheckPrunable(t *testing.T) {
+	cases := map[string]*backupMetadata{
+		"完整备份":  {Timestamp: "2026-10-15T08:00:00Z"},
+		"近期变更":  {ModifiedAfter: "2026-10-08T00:00:00Z"},
+		"增量备份":  {IncrementalBase: "/backups/k8s-backup-20261014-080000"},
+		"旧版本备份": nil,
+	}
+	for name, meta := range cases {
+		err := checkPrunable(meta)
+		if partial := meta != nil && (meta.ModifiedAfter != "" || meta.IncrementalBase != ""); (err != nil) != partial {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
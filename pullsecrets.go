@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// pullSecretsFileName 记录命名空间内 ServiceAccount 与 imagePullSecrets 关联的文件, 位于命名空间目录
+// 即使 Secret 本身未被备份, 恢复时也可据此知道需要重新创建哪些镜像拉取凭据
+const pullSecretsFileName = "pull-secrets.yaml"
+
+// pullSecretLinks ServiceAccount 名称 -> 其引用的 imagePullSecrets 名称
+type pullSecretLinks map[string][]string
+
+// add 记录 ServiceAccount 对象中引用的 imagePullSecrets
+func (l pullSecretLinks) add(sa map[string]interface{}) {
+	refs, _, _ := unstructured.NestedSlice(sa, "imagePullSecrets")
+	var names []string
+	for _, ref := range mapsOf(refs) {
+		if name, _ := ref["name"].(string); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+	l[(&unstructured.Unstructured{Object: sa}).GetName()] = names
+}
+
+// secretNames 返回被任一 ServiceAccount 引用的 Secret 名称集合
+func (l pullSecretLinks) secretNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, secrets := range l {
+		for _, name := range secrets {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// isDockerConfigSecret 判断 Secret 是否为镜像仓库凭据
+func isDockerConfigSecret(secret map[string]interface{}) bool {
+	secretType, _ := secret["type"].(string)
+	return secretType == string(corev1.SecretTypeDockerConfigJson) || secretType == string(corev1.SecretTypeDockercfg)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// pvBindingsFileName 记录 PV 与 PVC 绑定关系的文件, 位于 _global 目录
+// 备份会移除 PV 的 claimRef, 恢复时可据此重建或预绑定卷
+const pvBindingsFileName = "pv-bindings.yaml"
+
+// pvBinding 单个 PV 在备份时刻的绑定信息
+type pvBinding struct {
+	Namespace    string `yaml:"namespace,omitempty"`
+	ClaimName    string `yaml:"claimName,omitempty"`
+	StorageClass string `yaml:"storageClass,omitempty"`
+	Capacity     string `yaml:"capacity,omitempty"`
+	Driver       string `yaml:"driver,omitempty"`
+	VolumeHandle string `yaml:"volumeHandle,omitempty"`
+}
+
+// newPVBinding 从清理前的 PV 对象中提取绑定信息
+func newPVBinding(pv map[string]interface{}) pvBinding {
+	var b pvBinding
+	b.Namespace, _, _ = unstructured.NestedString(pv, "spec", "claimRef", "namespace")
+	b.ClaimName, _, _ = unstructured.NestedString(pv, "spec", "claimRef", "name")
+	b.StorageClass, _, _ = unstructured.NestedString(pv, "spec", "storageClassName")
+	b.Capacity, _, _ = unstructured.NestedString(pv, "spec", "capacity", "storage")
+	b.Driver, _, _ = unstructured.NestedString(pv, "spec", "csi", "driver")
+	b.VolumeHandle, _, _ = unstructured.NestedString(pv, "spec", "csi", "volumeHandle")
+	return b
+}
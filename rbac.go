@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// rbacGenOptions rbac-gen 子命令的参数, 与备份命令的范围参数含义一致
+type rbacGenOptions struct {
+	name                 string
+	namespace            string
+	resourceTypes        string
+	serviceAccount       string
+	skipSecrets          bool
+	skipClusterResources bool
+	includeRuntime       bool
+	presets              string
+}
+
+// runRBACGen 实现 rbac-gen 子命令: 按备份范围输出最小权限的 Role/ClusterRole 与绑定
+func runRBACGen(args []string) {
+	var opts rbacGenOptions
+	fs := pflag.NewFlagSet("rbac-gen", pflag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: k8s-backup rbac-gen [参数]\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.name, "name", "k8s-backup", "生成的 Role/ClusterRole 及绑定的名称")
+	fs.StringVarP(&opts.namespace, "namespace", "n", "all", "备份的命名空间 (逗号分隔, 'all'代表所有)")
+	fs.StringVarP(&opts.resourceTypes, "type", "t", "all", "备份的资源类型 (逗号分隔, 'all'代表所有支持的类型)")
+	fs.StringVar(&opts.serviceAccount, "service-account", "k8s-backup/k8s-backup", "绑定的 ServiceAccount (<命名空间>/<名称>)")
+	fs.BoolVar(&opts.skipSecrets, "skip-secrets", false, "备份时跳过Secret, 不授予Secret读取权限")
+	fs.BoolVar(&opts.skipClusterResources, "no-cluster-resources", false, "备份时不包含集群级资源, 不授予其读取权限")
+	fs.BoolVar(&opts.includeRuntime, "include-runtime-objects", false, "备份时包含 ReplicaSet 与 Pod, 授予其读取权限")
+	fs.StringVar(&opts.presets, "preset", "", "备份时启用的资源预设 (openshift, argo), 授予其读取权限")
+	fs.Parse(args)
+
+	saNamespace, saName, ok := strings.Cut(opts.serviceAccount, "/")
+	if !ok || saNamespace == "" || saName == "" {
+		fmt.Fprintf(os.Stderr, "错误: --service-account 格式应为 <命名空间>/<名称>, 实际为 '%s'\n", opts.serviceAccount)
+		os.Exit(2)
+	}
+
+	var resourceTypes []string
+	if opts.resourceTypes == "all" || opts.resourceTypes == "" {
+		presets, err := parsePresets(opts.presets)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(2)
+		}
+		resourceTypes = append(allResourceTypes(), presetResourceTypes(presets)...)
+		if opts.includeRuntime {
+			resourceTypes = append(resourceTypes, runtimeResourceTypes()...)
+		}
+		sortResourceTypes(resourceTypes)
+	} else {
+		for _, resType := range strings.Split(opts.resourceTypes, ",") {
+			if _, ok := resourceMap[resType]; !ok {
+				fmt.Fprintf(os.Stderr, "错误: 不支持的资源类型 '%s'\n", resType)
+				os.Exit(2)
+			}
+			resourceTypes = append(resourceTypes, resType)
+		}
+		sortResourceTypes(resourceTypes)
+	}
+	var filtered []string
+	for _, resType := range resourceTypes {
+		if opts.skipSecrets && resType == "secrets" {
+			continue
+		}
+		if opts.skipClusterResources && !resourceMap[resType].Namespaced {
+			continue
+		}
+		filtered = append(filtered, resType)
+	}
+
+	var namespaces []string
+	if opts.namespace != "all" {
+		namespaces = strings.Split(opts.namespace, ",")
+	}
+
+	data, err := marshalYAMLDocuments(rbacManifests(opts.name, filtered, namespaces, saNamespace, saName))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 生成清单失败: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(data)
+}
+
+// rbacManifests 生成备份所需的 RBAC 对象
+// namespaces 为空表示备份全部命名空间: 只读权限全部放入 ClusterRole, 并允许列出命名空间
+// 否则为每个命名空间生成 Role/RoleBinding, ClusterRole 中只保留集群级资源与权限自检
+func rbacManifests(name string, resourceTypes, namespaces []string, saNamespace, saName string) []interface{} {
+	subjects := []interface{}{
+		map[string]interface{}{"kind": "ServiceAccount", "name": saName, "namespace": saNamespace},
+	}
+	binding := func(kind, roleKind, namespace string) map[string]interface{} {
+		metadata := map[string]interface{}{"name": name}
+		if namespace != "" {
+			metadata["namespace"] = namespace
+		}
+		return map[string]interface{}{
+			"apiVersion": "rbac.authorization.k8s.io/v1", "kind": kind, "metadata": metadata,
+			"roleRef":  map[string]interface{}{"apiGroup": "rbac.authorization.k8s.io", "kind": roleKind, "name": name},
+			"subjects": subjects,
+		}
+	}
+
+	var docs []interface{}
+	clusterRules := []interface{}{ssarRule(), clusterPolicyRule()}
+	if len(namespaces) == 0 {
+		clusterRules = append(clusterRules, namespaceListRule())
+		clusterRules = append(clusterRules, readRules(resourceTypes, func(ResourceInfo) bool { return true })...)
+	} else {
+		clusterRules = append(clusterRules, readRules(resourceTypes, func(r ResourceInfo) bool { return !r.Namespaced })...)
+		namespacedRules := readRules(resourceTypes, func(r ResourceInfo) bool { return r.Namespaced })
+		for _, ns := range namespaces {
+			if len(namespacedRules) == 0 {
+				break
+			}
+			docs = append(docs, map[string]interface{}{
+				"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "Role",
+				"metadata": map[string]interface{}{"name": name, "namespace": ns},
+				"rules":    namespacedRules,
+			}, binding("RoleBinding", "Role", ns))
+		}
+	}
+	docs = append(docs, map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRole",
+		"metadata": map[string]interface{}{"name": name},
+		"rules":    clusterRules,
+	}, binding("ClusterRoleBinding", "ClusterRole", ""))
+	return docs
+}
+
+// readRules 为满足 include 条件的资源类型生成按 API 组合并的 get/list 规则
+func readRules(resourceTypes []string, include func(ResourceInfo) bool) []interface{} {
+	byGroup := make(map[string][]string)
+	var groups []string
+	for _, resType := range resourceTypes {
+		resInfo, ok := resourceMap[resType]
+		if !ok || !include(resInfo) {
+			continue
+		}
+		if _, seen := byGroup[resInfo.GVR.Group]; !seen {
+			groups = append(groups, resInfo.GVR.Group)
+		}
+		byGroup[resInfo.GVR.Group] = append(byGroup[resInfo.GVR.Group], resInfo.GVR.Resource)
+	}
+	var rules []interface{}
+	for _, group := range groups {
+		rules = append(rules, map[string]interface{}{
+			"apiGroups": []string{group}, "resources": byGroup[group], "verbs": []string{"get", "list"},
+		})
+	}
+	return rules
+}
+
+// ssarRule 备份前的权限自检需要创建 SelfSubjectAccessReview
+func ssarRule() map[string]interface{} {
+	return map[string]interface{}{
+		"apiGroups": []string{"authorization.k8s.io"}, "resources": []string{"selfsubjectaccessreviews"}, "verbs": []string{"create"},
+	}
+}
+
+// namespaceListRule 备份全部命名空间时需要列出命名空间
+func namespaceListRule() map[string]interface{} {
+	return map[string]interface{}{
+		"apiGroups": []string{""}, "resources": []string{"namespaces"}, "verbs": []string{"get", "list"},
+	}
+}
+
+// clusterPolicyRule 读取集群策略 ConfigMap (默认 --cluster-config) 的权限
+func clusterPolicyRule() map[string]interface{} {
+	_, name, _ := strings.Cut(defaultClusterConfig, "/")
+	return map[string]interface{}{
+		"apiGroups": []string{""}, "resources": []string{"configmaps"}, "resourceNames": []string{name}, "verbs": []string{"get"},
+	}
+}
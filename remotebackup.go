@@ -0,0 +1,332 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 远程备份的 URL 协议
+const remoteSchemeS3 = "s3"
+
+// S3 客户端读取的环境变量, 与 AWS CLI 相同; 未设置访问密钥时以匿名请求访问 (公开读的存储桶)
+// 设置 AWS_ENDPOINT_URL_S3 或 AWS_ENDPOINT_URL 时以路径风格访问该地址, 用于 MinIO, Ceph RGW 等 S3 兼容存储
+const (
+	envAWSAccessKeyID     = "AWS_ACCESS_KEY_ID"
+	envAWSSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	envAWSSessionToken    = "AWS_SESSION_TOKEN"
+	envAWSRegion          = "AWS_REGION"
+	envAWSDefaultRegion   = "AWS_DEFAULT_REGION"
+	envAWSEndpointS3      = "AWS_ENDPOINT_URL_S3"
+	envAWSEndpoint        = "AWS_ENDPOINT_URL"
+)
+
+// emptyPayloadHash 空请求体的 SHA-256, 用于 GET 请求的签名
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// remoteBackupTimeout 单个远程请求的超时时间
+const remoteBackupTimeout = 5 * time.Minute
+
+// isRemoteBackup 判断 restore --from 是否为远程存储的 URL (如 s3://bucket/k8s-backup-20240101/)
+func isRemoteBackup(ref string) bool {
+	return strings.Contains(ref, "://")
+}
+
+// remoteBackupName 返回远程备份 URL 的最后一级目录名, 与本地备份目录名的含义相同
+func remoteBackupName(ref string) string {
+	_, rest, _ := strings.Cut(ref, "://")
+	rest = strings.TrimRight(rest, "/")
+	return rest[strings.LastIndex(rest, "/")+1:]
+}
+
+// fetchRemoteBackup 将远程备份下载到本地临时目录, 返回该目录与下载的文件数; 调用方在恢复成功后删除目录
+func fetchRemoteBackup(ref string, getenv func(string) string) (string, int, error) {
+	scheme, _, _ := strings.Cut(ref, "://")
+	if scheme != remoteSchemeS3 {
+		return "", 0, fmt.Errorf("不支持的远程存储 '%s://' (支持: %s://)", scheme, remoteSchemeS3)
+	}
+	bucket, prefix, err := parseS3URL(ref)
+	if err != nil {
+		return "", 0, err
+	}
+	client, err := newS3Client(getenv)
+	if err != nil {
+		return "", 0, err
+	}
+	keys, err := client.list(bucket, prefix)
+	if err != nil {
+		return "", 0, fmt.Errorf("列出 %s 失败: %w", ref, err)
+	}
+	if len(keys) == 0 {
+		return "", 0, fmt.Errorf("%s 下没有任何文件", ref)
+	}
+	dir, err := os.MkdirTemp("", "k8s-back-restore-")
+	if err != nil {
+		return "", 0, err
+	}
+	for _, key := range keys {
+		rel := strings.TrimPrefix(key, prefix)
+		if !filepath.IsLocal(rel) {
+			os.RemoveAll(dir)
+			return "", 0, fmt.Errorf("对象键 '%s' 不是合法的相对路径", key)
+		}
+		if err := client.download(bucket, key, filepath.Join(dir, filepath.FromSlash(rel))); err != nil {
+			os.RemoveAll(dir)
+			return "", 0, fmt.Errorf("下载 s3://%s/%s 失败: %w", bucket, key, err)
+		}
+	}
+	return dir, len(keys), nil
+}
+
+// parseS3URL 解析 s3://<存储桶>/<前缀>, 非空前缀补齐结尾的 "/", 使其只匹配该目录下的对象
+func parseS3URL(ref string) (bucket, prefix string, err error) {
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != remoteSchemeS3 || u.Host == "" {
+		return "", "", fmt.Errorf("无效的 S3 地址 '%s', 格式应为 s3://<存储桶>/<备份目录>/", ref)
+	}
+	prefix = strings.TrimLeft(u.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return u.Host, prefix, nil
+}
+
+// s3Client 只读的最小 S3 客户端: ListObjectsV2 与 GetObject, 以 AWS Signature Version 4 签名
+type s3Client struct {
+	http         *http.Client
+	endpoint     *url.URL // 非空时以路径风格访问, 否则访问 AWS 的虚拟主机风格地址
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	now          func() time.Time
+}
+
+// newS3Client 按环境变量创建客户端
+func newS3Client(getenv func(string) string) (*s3Client, error) {
+	c := &s3Client{
+		http:         &http.Client{Timeout: remoteBackupTimeout},
+		region:       firstNonEmpty(getenv(envAWSRegion), getenv(envAWSDefaultRegion), "us-east-1"),
+		accessKey:    getenv(envAWSAccessKeyID),
+		secretKey:    getenv(envAWSSecretAccessKey),
+		sessionToken: getenv(envAWSSessionToken),
+		now:          time.Now,
+	}
+	if (c.accessKey == "") != (c.secretKey == "") {
+		return nil, fmt.Errorf("%s 与 %s 需要同时设置", envAWSAccessKeyID, envAWSSecretAccessKey)
+	}
+	if raw := firstNonEmpty(getenv(envAWSEndpointS3), getenv(envAWSEndpoint)); raw != "" {
+		endpoint, err := url.Parse(raw)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("无效的 S3 端点 '%s'", raw)
+		}
+		c.endpoint = endpoint
+	}
+	return c, nil
+}
+
+// objectURL 返回存储桶中对象 (key 为空时为存储桶本身) 的地址
+func (c *s3Client) objectURL(bucket, key string, query url.Values) *url.URL {
+	u := &url.URL{Scheme: "https", Host: bucket + ".s3." + c.region + ".amazonaws.com", Path: "/" + key}
+	if c.endpoint != nil {
+		u = &url.URL{Scheme: c.endpoint.Scheme, Host: c.endpoint.Host, Path: strings.TrimRight(c.endpoint.Path, "/") + "/" + bucket + "/" + key}
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = s3CanonicalQuery(query)
+	return u
+}
+
+// s3ListResult ListObjectsV2 的响应
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list 列出前缀下的全部对象键, 跳过以 "/" 结尾的目录占位对象
+func (c *s3Client) list(bucket, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(c.objectURL(bucket, "", query))
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析列表响应失败: %w", err)
+		}
+		for _, obj := range result.Contents {
+			if !strings.HasSuffix(obj.Key, "/") {
+				keys = append(keys, obj.Key)
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// download 下载对象到本地文件
+func (c *s3Client) download(bucket, key, dest string) error {
+	resp, err := c.do(c.objectURL(bucket, key, nil))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// s3Error S3 的错误响应
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// do 发送签名的 GET 请求, 非 2xx 响应转换为错误
+func (c *s3Client) do(u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.sign(req, c.now().UTC())
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var e s3Error
+	if body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024)); xml.Unmarshal(body, &e) == nil && e.Code != "" {
+		return nil, fmt.Errorf("%s: %s (HTTP %d)", e.Code, e.Message, resp.StatusCode)
+	}
+	return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+}
+
+// sign 按 AWS Signature Version 4 为请求添加 Authorization 等请求头; 未配置访问密钥时不签名
+func (c *s3Client) sign(req *http.Request, now time.Time) {
+	if c.accessKey == "" {
+		return
+	}
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", c.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, emptyPayloadHash,
+	}, "\n")
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	signature := hex.EncodeToString(hmacSHA256(sigV4SigningKey(c.secretKey, date, c.region, "s3"), stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+// sigV4SigningKey 派生 Signature Version 4 的签名密钥
+func sigV4SigningKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// s3EscapePath 按 RFC 3986 编码路径, 保留 "/", 与签名时的规范 URI 一致
+func s3EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = s3Escape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery 按参数名排序并以 RFC 3986 编码查询参数
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Escape 编码除 RFC 3986 非保留字符 (A-Z a-z 0-9 - _ . ~) 之外的全部字节
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
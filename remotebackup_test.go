@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchRemoteBackup(t *testing.T) {
+	objects := map[string]string{
+		"k8s-backup-1/metadata.yaml":             "version: 1\n",
+		"k8s-backup-1/web/configmaps/app.yaml":   "kind: ConfigMap\n",
+		"k8s-backup-1/web/secrets/db creds.yaml": "kind: Secret\n",
+		"k8s-backup-10/metadata.yaml":            "version: 1\n",
+	}
+	var unsigned int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") || r.Header.Get("x-amz-security-token") != "token" {
+			unsigned++
+		}
+		if r.URL.Path == "/backups/" {
+			// 每页一个对象, 覆盖分页
+			prefix, after := r.URL.Query().Get("prefix"), r.URL.Query().Get("continuation-token")
+			var keys []string
+			for key := range objects {
+				if strings.HasPrefix(key, prefix) && key > after {
+					keys = append(keys, key)
+				}
+			}
+			if len(keys) == 0 {
+				fmt.Fprint(w, "<ListBucketResult></ListBucketResult>")
+				return
+			}
+			first := keys[0]
+			for _, k := range keys {
+				if k < first {
+					first = k
+				}
+			}
+			fmt.Fprintf(w, "<ListBucketResult><Contents><Key>%s</Key></Contents><IsTruncated>%v</IsTruncated><NextContinuationToken>%s</NextContinuationToken></ListBucketResult>",
+				first, len(keys) > 1, first)
+			return
+		}
+		content, ok := objects[strings.TrimPrefix(r.URL.Path, "/backups/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer server.Close()
+
+	env := map[string]string{
+		envAWSAccessKeyID:     "AKID",
+		envAWSSecretAccessKey: "secret",
+		envAWSSessionToken:    "token",
+		envAWSEndpoint:        server.URL,
+	}
+	dir, files, err := fetchRemoteBackup("s3://backups/k8s-backup-1", func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if files != 3 || unsigned != 0 {
+		t.Errorf("下载文件数 = %d, 未签名请求 %d 个", files, unsigned)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "web", "secrets", "db creds.yaml"))
+	if err != nil || string(data) != "kind: Secret\n" {
+		t.Errorf("下载内容 = %q, err = %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "metadata.yaml")); err != nil {
+		t.Error(err)
+	}
+
+	if _, _, err := fetchRemoteBackup("s3://backups/missing/", func(k string) string { return env[k] }); err == nil {
+		t.Error("前缀下没有文件时应报错")
+	}
+	if _, _, err := fetchRemoteBackup("gs://backups/k8s-backup-1", func(k string) string { return env[k] }); err == nil {
+		t.Error("不支持的协议应报错")
+	}
+	if name := remoteBackupName("s3://backups/daily/k8s-backup-1/"); name != "k8s-backup-1" {
+		t.Errorf("remoteBackupName = %q", name)
+	}
+}
+
+func TestSigV4SigningKey(t *testing.T) {
+	// AWS Signature Version 4 文档中的示例
+	key := sigV4SigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	if got := hex.EncodeToString(key); got != "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d" {
+		t.Errorf("签名密钥 = %s", got)
+	}
+	if got := s3Escape("a b/c~d+é"); got != "a%20b%2Fc~d%2B%C3%A9" {
+		t.Errorf("s3Escape = %s", got)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// 汇总报告
+const (
+	reportFormatHTML   = "html" // --report 目前支持的格式
+	reportHTMLFileName = "report.html"
+	// reportDiffLimit HTML 报告中每类变更最多列出的对象数
+	reportDiffLimit = 200
+)
+
+// globalNamespaceLabel 统计与报告中集群级资源所在分组的名称, 与输出目录名一致
+const globalNamespaceLabel = "_global"
+
+// kindSummary 单个命名空间内某类资源的数量与大小
+type kindSummary struct {
+	Kind  string
+	Count int
+	Size  int
+}
+
+// namespaceSummary 单个命名空间的资源数量与清单总大小
+type namespaceSummary struct {
+	Name  string
+	Count int
+	Size  int
+	Kinds []kindSummary
+}
+
+// summarizeIndex 按命名空间与资源类型汇总备份索引, 结果按命名空间名排序
+func summarizeIndex(entries []indexEntry) []namespaceSummary {
+	byNamespace := make(map[string]map[string]*kindSummary)
+	for _, e := range entries {
+		ns := e.Namespace
+		if ns == "" {
+			ns = globalNamespaceLabel
+		}
+		kinds := byNamespace[ns]
+		if kinds == nil {
+			kinds = make(map[string]*kindSummary)
+			byNamespace[ns] = kinds
+		}
+		k := kinds[e.Kind]
+		if k == nil {
+			k = &kindSummary{Kind: e.Kind}
+			kinds[e.Kind] = k
+		}
+		k.Count++
+		k.Size += e.Size
+	}
+
+	summaries := make([]namespaceSummary, 0, len(byNamespace))
+	for ns, kinds := range byNamespace {
+		s := namespaceSummary{Name: ns}
+		for _, k := range kinds {
+			s.Count += k.Count
+			s.Size += k.Size
+			s.Kinds = append(s.Kinds, *k)
+		}
+		sort.Slice(s.Kinds, func(i, j int) bool { return s.Kinds[i].Kind < s.Kinds[j].Kind })
+		summaries = append(summaries, s)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+	return summaries
+}
+
+// indexDiff 两次备份之间新增, 删除与内容变化的对象 (以 objectKey 表示)
+type indexDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// diffIndexes 比较上一次与本次备份的索引
+func diffIndexes(previous map[string]indexEntry, current []indexEntry) indexDiff {
+	var diff indexDiff
+	seen := make(map[string]struct{}, len(current))
+	for _, e := range current {
+		key := objectKey(e.Kind, e.Namespace, e.Name)
+		seen[key] = struct{}{}
+		prev, ok := previous[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, key)
+		case prev.Digest != "" && prev.Digest != e.Digest:
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := seen[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// formatBytes 将字节数格式化为易读的形式
+func formatBytes(n int) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := unit, 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// htmlReport HTML 报告模板所需的数据
+type htmlReport struct {
+	BackupName    string
+	Timestamp     string
+	Version       string
+	Duration      string
+	Total         int
+	TotalSize     int
+	Namespaces    []namespaceSummary
+	MaxSize       int
+	Issues        []progressEvent
+	PreviousName  string
+	Diff          indexDiff
+	DiffCounts    [3]int // 截断前的新增/删除/变更数量
+	DiffTruncated bool
+	DiffLimit     int
+}
+
+// writeHTMLReport 在备份根目录生成独立的 report.html, 有上一次备份时附带差异
+func writeHTMLReport(backupRoot string, meta backupMetadata, duration string, index []indexEntry, issues []progressEvent, previousDir string, previous map[string]indexEntry) error {
+	report := htmlReport{
+		BackupName: filepath.Base(backupRoot),
+		Timestamp:  meta.Timestamp,
+		Version:    meta.Version,
+		Duration:   duration,
+		Namespaces: summarizeIndex(index),
+		Issues:     issues,
+		DiffLimit:  reportDiffLimit,
+	}
+	for _, ns := range report.Namespaces {
+		report.Total += ns.Count
+		report.TotalSize += ns.Size
+		if ns.Size > report.MaxSize {
+			report.MaxSize = ns.Size
+		}
+	}
+	if previous != nil {
+		report.PreviousName = filepath.Base(previousDir)
+		report.Diff = diffIndexes(previous, index)
+		report.DiffCounts = [3]int{len(report.Diff.Added), len(report.Diff.Removed), len(report.Diff.Changed)}
+		for _, list := range []*[]string{&report.Diff.Added, &report.Diff.Removed, &report.Diff.Changed} {
+			if len(*list) > reportDiffLimit {
+				*list = (*list)[:reportDiffLimit]
+				report.DiffTruncated = true
+			}
+		}
+	}
+
+	f, err := os.Create(filepath.Join(backupRoot, reportHTMLFileName))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return htmlReportTemplate.Execute(f, report)
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bytes": formatBytes,
+	"percent": func(part, whole int) int {
+		if whole == 0 {
+			return 0
+		}
+		return part * 100 / whole
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>备份报告 {{.BackupName}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "PingFang SC", sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+th { background: #f3f3f3; }
+td.num { text-align: right; }
+.bar { background: #4a90d9; height: 12px; }
+.bar-cell { width: 300px; }
+.added { color: #2a7a2a; } .removed { color: #b22; } .changed { color: #a60; }
+</style>
+</head>
+<body>
+<h1>备份报告: {{.BackupName}}</h1>
+<table>
+<tr><th>备份时间</th><td>{{.Timestamp}}</td></tr>
+<tr><th>工具版本</th><td>{{.Version}}</td></tr>
+<tr><th>耗时</th><td>{{.Duration}}</td></tr>
+<tr><th>资源总数</th><td>{{.Total}}</td></tr>
+<tr><th>清单总大小</th><td>{{bytes .TotalSize}}</td></tr>
+<tr><th>错误/跳过</th><td>{{len .Issues}}</td></tr>
+</table>
+
+<h2>命名空间概览</h2>
+<table>
+<tr><th>命名空间</th><th>资源数</th><th>大小</th><th class="bar-cell">占比</th></tr>
+{{range .Namespaces}}<tr><td><a href="#ns-{{.Name}}">{{.Name}}</a></td><td class="num">{{.Count}}</td><td class="num">{{bytes .Size}}</td><td class="bar-cell"><div class="bar" style="width: {{percent .Size $.MaxSize}}%"></div></td></tr>
+{{end}}</table>
+
+<h2>命名空间明细</h2>
+{{range .Namespaces}}<h3 id="ns-{{.Name}}">{{.Name}}</h3>
+<table>
+<tr><th>类型</th><th>数量</th><th>大小</th></tr>
+{{range .Kinds}}<tr><td>{{.Kind}}</td><td class="num">{{.Count}}</td><td class="num">{{bytes .Size}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>错误与跳过</h2>
+{{if .Issues}}<table>
+<tr><th>事件</th><th>命名空间</th><th>类型</th><th>名称</th><th>原因</th></tr>
+{{range .Issues}}<tr><td>{{.Event}}</td><td>{{.Namespace}}</td><td>{{.Kind}}</td><td>{{.Name}}</td><td>{{.Reason}}{{.Error}}</td></tr>
+{{end}}</table>
+{{else}}<p>无</p>{{end}}
+
+<h2>与上一次备份的差异</h2>
+{{if .PreviousName}}<p>对比备份: {{.PreviousName}} — 新增 {{index .DiffCounts 0}}, 删除 {{index .DiffCounts 1}}, 变更 {{index .DiffCounts 2}}{{if .DiffTruncated}} (每类最多列出 {{.DiffLimit}} 条){{end}}</p>
+<ul>
+{{range .Diff.Added}}<li class="added">+ {{.}}</li>
+{{end}}{{range .Diff.Removed}}<li class="removed">- {{.}}</li>
+{{end}}{{range .Diff.Changed}}<li class="changed">~ {{.}}</li>
+{{end}}</ul>
+{{else}}<p>未找到可对比的上一次备份</p>{{end}}
+</body>
+</html>
+`))
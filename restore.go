@@ -0,0 +1,899 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// 恢复时写入对象的来源注解
+const (
+	annotationRestoredFrom    = "k8s-back.io/restored-from"
+	annotationBackupTimestamp = "k8s-back.io/backup-timestamp"
+	annotationOriginalUID     = "k8s-back.io/original-uid"
+)
+
+// restoreRankDefault 未在 resourceMap 中的类型 (如自定义资源) 的恢复顺序: 内置工作负载之后, webhook 配置之前
+// webhook 配置放在最后, 避免其指向的服务尚未就绪时拦截后续对象的创建
+const restoreRankDefault = 85
+
+// restoreItem 表示备份目录中的一个待恢复对象
+type restoreItem struct {
+	Path string // 相对备份根目录的路径
+	Obj  *unstructured.Unstructured
+}
+
+// restoreOptions 恢复子命令的参数
+type restoreOptions struct {
+	kubeconfig    string
+	targetConfig  string
+	targetContext string
+	backupDir     string
+	addProvenance bool
+	valuesFile    string
+	setValues     []string
+	restoreSet    string
+	prune         bool
+	kinds         string
+	selector      string
+	names         string
+	force         bool
+	convert       bool
+	pinLBIPs      bool
+	storageClass  []string
+	imageRewrite  []string
+	applyRate     string
+	batchSize     int
+	batchPause    time.Duration
+	stripOwners   bool
+	stripOrigin   bool
+	withDefaults  bool
+	planFile      string
+	pausedRollout bool
+	systemConfig  bool
+	wait          bool
+	waitTimeout   time.Duration
+	immutable     string
+	immFields     string
+	onConflict    string
+	serverSide    bool
+	fieldManager  string
+	forceConflict bool
+	dryRun        bool
+	progress      string
+	stateFile     string
+}
+
+// runRestore 实现 restore 子命令: 将备份目录中的清单按依赖顺序应用到集群
+func runRestore(args []string) {
+	var opts restoreOptions
+	fs := pflag.NewFlagSet("restore", pflag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: k8s-backup restore [参数] <备份目录>\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.kubeconfig, "kubeconfig", "", "kubeconfig文件路径 (默认使用~/.kube/config), 同 --target-kubeconfig")
+	fs.StringVar(&opts.targetConfig, "target-kubeconfig", "", "目标集群的 kubeconfig 文件路径, 也可通过环境变量 "+envTargetKubeconfig+" 指定")
+	fs.StringVar(&opts.targetContext, "target-context", "", "目标集群在 kubeconfig 中的上下文名称, 用于一条命令完成跨集群恢复 (默认使用当前上下文), 也可通过环境变量 "+envTargetContext+" 指定; 以令牌连接时设置 "+envTargetServer+", "+envTargetToken+" 与可选的 "+envTargetCAFile)
+	fs.StringVar(&opts.backupDir, "from", "", "要恢复的备份目录或远程地址 (如 s3://bucket/k8s-backup-20240101/, 先下载到临时目录; 凭据与端点读取 AWS_ACCESS_KEY_ID, AWS_REGION, AWS_ENDPOINT_URL 等环境变量), 也可作为位置参数传入")
+	fs.BoolVar(&opts.addProvenance, "add-provenance", false, "为恢复的对象添加来源注解 (k8s-back.io/restored-from 等)")
+	fs.StringVar(&opts.valuesFile, "values", "", "变量文件 (YAML键值映射), 用于替换清单字符串中的 ${VAR}")
+	fs.StringArrayVar(&opts.setValues, "set", nil, "设置单个变量 KEY=VALUE, 优先于 --values (可重复)")
+	fs.StringVar(&opts.restoreSet, "restore-set", defaultRestoreSet, "恢复集合名, 写入对象标签 "+labelRestoreSet+", 供 --prune 识别")
+	fs.BoolVar(&opts.prune, "prune", false, "删除备份涉及的命名空间中属于同一恢复集合但不在本次备份中的对象")
+	fs.StringVar(&opts.kinds, "kinds", "", "只恢复指定类型 (逗号分隔, 如 deployments,configmaps 或 Deployment)")
+	fs.StringVar(&opts.kinds, "type", "", "同 --kinds")
+	fs.StringVarP(&opts.selector, "selector", "l", "", "只恢复标签匹配选择器的对象 (如 app=foo)")
+	fs.StringVar(&opts.names, "names", "", "只恢复指定名称的对象 (逗号分隔)")
+	fs.BoolVar(&opts.withDefaults, "with-namespace-defaults", false, "配合 --kinds/--type/--selector/--names 使用: 同时恢复所涉命名空间的 LimitRange 与 ResourceQuota, 并先于工作负载应用")
+	fs.BoolVar(&opts.convert, "convert-api-versions", true, "将目标集群已不再提供的旧 apiVersion (如 networking.k8s.io/v1beta1 Ingress) 改写为等价的新版本")
+	fs.BoolVar(&opts.pinLBIPs, "pin-loadbalancer-ips", false, "按 loadbalancers.yaml 为 LoadBalancer Service 指定备份时刻的外部 IP (spec.loadBalancerIP, Azure/MetalLB/Cilium 使用各自的注解), 需要云厂商支持且地址为保留的静态 IP")
+	fs.StringArrayVar(&opts.storageClass, "storageclass-mapping", nil, "改写 PVC 与 StatefulSet volumeClaimTemplates 的存储类 源=目标 (如 gp2=standard, 可用逗号分隔或重复指定), 用于目标集群没有源集群的存储类时; 目标为空 (gp2=) 时使用目标集群的默认存储类")
+	fs.StringArrayVar(&opts.imageRewrite, "image-rewrite", nil, "改写 Deployment/StatefulSet/DaemonSet/Job/CronJob 中容器镜像的仓库 源=目标 (如 registry.cn-hangzhou.aliyuncs.com=harbor.internal, 可用逗号分隔或重复指定), 用于在隔离网络中从内部镜像仓库拉取镜像")
+	fs.BoolVar(&opts.systemConfig, "include-system-config", false, "同时恢复备份 --include-system-config 保存的系统配置 (kube-system 的 coredns, kube-proxy, aws-auth 等), 默认跳过以免覆盖目标集群的访问映射与网络配置")
+	fs.BoolVar(&opts.stripOwners, "strip-ownership", false, "移除对象的 ownerReferences 与 finalizers 并输出报告, 用于旧版本或未经清理的备份, 避免对象被立即垃圾回收或删除时卡在 Terminating")
+	fs.BoolVar(&opts.stripOrigin, "strip-origin", false, "移除备份时 --stamp-origin 写入的来源注解 (k8s-back.io/source-cluster 等), 避免目标集群中的对象带有源集群的 resourceVersion")
+	fs.StringVar(&opts.applyRate, "apply-rate", "", "限制创建对象的速率 (如 20/s, 600/m), 避免大量对象压垮目标 API server 或准入 webhook; 默认受 client-go 客户端限速 (约 5/s)")
+	fs.IntVar(&opts.batchSize, "batch-size", 0, "每创建该数量的对象后暂停 --batch-pause, 0 表示不分批")
+	fs.DurationVar(&opts.batchPause, "batch-pause", 5*time.Second, "分批恢复时每批之间的暂停时间, 供控制器与 webhook 处理上一批对象")
+	fs.BoolVar(&opts.pausedRollout, "paused-rollout", false, "以 0 副本创建 Deployment/StatefulSet 等工作负载并 suspend Job/CronJob, 全部对象 (ConfigMap、Secret、PVC 等) 应用后再恢复原副本数, 避免 Pod 在依赖不完整时反复崩溃")
+	fs.BoolVar(&opts.wait, "wait", false, "恢复完成后等待本次创建或更新的 Deployment/StatefulSet/DaemonSet 完成滚动更新 (同 kubectl rollout status) 并输出健康汇总, 有未就绪的工作负载时以非零状态退出")
+	fs.DurationVar(&opts.waitTimeout, "wait-timeout", defaultRestoreWaitTimeout, "配合 --wait: 每个工作负载的等待上限")
+	fs.StringVar(&opts.planFile, "plan", "", "恢复计划文件 (YAML), 按层级顺序恢复并在指定对象之后暂停、等待就绪或运行 Job, 用于启动顺序严格的应用")
+	fs.StringVar(&opts.immutable, "immutable-conflict", immutableConflictSkip, "目标集群已存在内容不同的不可变 (immutable: true) ConfigMap/Secret 时的处理方式: skip 保留并警告, recreate 删除后按备份重建 (已挂载的 Pod 需重启)")
+	fs.StringVar(&opts.immFields, "immutable-fields", immutableFieldsFail, "按 --on-conflict 或 --server-side 更新已存在的对象时, 不可变字段 (如 Service 的 clusterIP, PVC 的 spec, Job 的 selector 与 template) 与备份不同导致更新被拒绝的处理方式: fail 列出这些字段并计为失败, keep-existing 保留现有值并更新其余字段, recreate 删除后按备份重建 (PVC 重建会解除与现有卷的绑定, 谨慎使用)")
+	fs.StringVar(&opts.onConflict, "on-conflict", onConflictSkip, "目标集群已存在同名对象时的处理方式: skip 保留现有对象, replace 以备份整体替换 (备份中没有的字段被移除), merge-patch 以备份作为 JSON merge patch 合并 (保留备份中没有的字段); 不可变 ConfigMap/Secret 按 --immutable-conflict 处理")
+	fs.BoolVar(&opts.serverSide, "server-side", false, "以 server-side apply 恢复对象: 只声明备份中出现的字段的所有权, 已存在的对象按备份更新而不覆盖控制器持有的字段 (如 HPA 调整的副本数), 重复恢复不产生修改; 不能与 --on-conflict 同时使用")
+	fs.StringVar(&opts.fieldManager, "field-manager", defaultFieldManager, "配合 --server-side: 恢复时使用的字段管理者名称")
+	fs.BoolVar(&opts.forceConflict, "force-conflicts", false, "配合 --server-side: 与其他字段管理者冲突时接管这些字段 (默认报错并跳过该对象)")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "只读取目标集群中的现有对象, 逐个输出将创建, 内容不同或无变化的对象及与备份的 unified diff, 不修改集群")
+	fs.StringVar(&opts.progress, "progress-format", progressFormatText, "进度输出格式 (text|jsonl), jsonl 时 stdout 输出与备份相同格式的结构化事件 (restore_started, resource_restored 等), 文字日志改写到 stderr")
+	fs.StringVar(&opts.stateFile, "state-file", "", "恢复进度文件: 逐个记录已应用与失败的对象; 恢复中断或部分失败后以相同的备份目录与文件重新运行时, 跳过已应用的对象, 只处理失败与未处理的对象; 全部成功后删除")
+	fs.BoolVar(&opts.force, "force", false, "目标集群不提供备份中的部分 apiVersion/Kind 时仍继续恢复")
+	fs.Parse(args)
+
+	if opts.backupDir == "" && fs.NArg() > 0 {
+		opts.backupDir = fs.Arg(0)
+	}
+	if opts.backupDir == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err := validateRestoreSet(opts.restoreSet); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(2)
+	}
+	progress, err := newProgressReporter(opts.progress, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(2)
+	}
+	if opts.dryRun && opts.stateFile != "" {
+		fmt.Fprintln(os.Stderr, "错误: --state-file 不能与 --dry-run 同时使用")
+		os.Exit(2)
+	}
+	if fs.Changed("kinds") && fs.Changed("type") {
+		fmt.Fprintln(os.Stderr, "错误: --type 与 --kinds 含义相同, 只能指定其中一个")
+		os.Exit(2)
+	}
+	filter, err := newRestoreFilter(opts.kinds, opts.selector, opts.names)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(2)
+	}
+	applyRate, err := parseApplyRate(opts.applyRate)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(2)
+	}
+	if err := validateImmutableConflict(opts.immutable); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(2)
+	}
+	if err := validateOnConflict(opts.onConflict); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(2)
+	}
+	if err := validateImmutableFields(opts.immFields); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(2)
+	}
+	if fs.Changed("immutable-fields") && opts.onConflict == onConflictSkip && !opts.serverSide {
+		fmt.Fprintf(os.Stderr, "错误: --immutable-fields 需要与 --on-conflict=%s|%s 或 --server-side 同时使用 (默认不更新已存在的对象)\n", onConflictReplace, onConflictMergePatch)
+		os.Exit(2)
+	}
+	if opts.serverSide && fs.Changed("on-conflict") {
+		fmt.Fprintln(os.Stderr, "错误: --server-side 不能与 --on-conflict 同时使用 (server-side apply 始终按备份更新已存在的对象)")
+		os.Exit(2)
+	}
+	if !opts.serverSide && (fs.Changed("field-manager") || opts.forceConflict) {
+		fmt.Fprintln(os.Stderr, "错误: --field-manager 与 --force-conflicts 需要同时指定 --server-side")
+		os.Exit(2)
+	}
+	if opts.serverSide && opts.fieldManager == "" {
+		fmt.Fprintln(os.Stderr, "错误: --field-manager 不能为空")
+		os.Exit(2)
+	}
+	storageClasses, err := parseStorageClassMapping(opts.storageClass)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: --storageclass-mapping: %v\n", err)
+		os.Exit(2)
+	}
+	imageRewrites, err := parseImageRewrites(opts.imageRewrite)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: --image-rewrite: %v\n", err)
+		os.Exit(2)
+	}
+	if opts.batchSize < 0 {
+		fmt.Fprintln(os.Stderr, "错误: --batch-size 不能为负数")
+		os.Exit(2)
+	}
+	var plan *restorePlan
+	if opts.planFile != "" {
+		if plan, err = loadRestorePlan(opts.planFile); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if plan != nil && opts.pausedRollout {
+		fmt.Fprintln(os.Stderr, "错误: --paused-rollout 不能与 --plan 同时使用 (计划的层级顺序与 waitReady 钩子依赖工作负载正常启动)")
+		os.Exit(2)
+	}
+	if opts.pausedRollout && (opts.serverSide || opts.onConflict != onConflictSkip) {
+		fmt.Fprintln(os.Stderr, "错误: --paused-rollout 不能与 --server-side 或 --on-conflict=replace|merge-patch 同时使用 (会将目标集群中已运行的工作负载缩容为 0)")
+		os.Exit(2)
+	}
+	if fs.Changed("wait-timeout") && !opts.wait {
+		fmt.Fprintln(os.Stderr, "错误: --wait-timeout 需要与 --wait 同时使用")
+		os.Exit(2)
+	}
+	if opts.wait && opts.dryRun {
+		fmt.Fprintln(os.Stderr, "错误: --wait 不能与 --dry-run 同时使用")
+		os.Exit(2)
+	}
+	if opts.waitTimeout <= 0 {
+		fmt.Fprintln(os.Stderr, "错误: --wait-timeout 必须大于 0")
+		os.Exit(2)
+	}
+	if filter != nil && opts.prune {
+		fmt.Fprintln(os.Stderr, "错误: --prune 不能与 --kinds/--selector/--names 同时使用")
+		os.Exit(2)
+	}
+
+	target, err := resolveRestoreTarget(opts.kubeconfig, opts.targetConfig, opts.targetContext, os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(2)
+	}
+	config, err := target.config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	dynamicClient, mapper, err := newApplyClientsForConfig(rest.CopyConfig(config), applyRate)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(logOut, "目标集群: %s\n", target.describe(config))
+
+	// 远程备份下载到临时目录后按本地目录恢复; 输出, 进度文件与来源注解仍使用远程地址
+	backupSource, backupName := opts.backupDir, filepath.Base(filepath.Clean(opts.backupDir))
+	var downloadDir string
+	if isRemoteBackup(backupSource) {
+		dir, files, err := fetchRemoteBackup(backupSource, os.Getenv)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 下载远程备份失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(logOut, "已下载 %s (%d 个文件) 到 %s\n", backupSource, files, dir)
+		opts.backupDir, downloadDir, backupName = dir, dir, remoteBackupName(backupSource)
+	}
+	// 应用对象之前中止时删除下载的备份, 避免含 Secret 的临时目录被遗留
+	abort := func(code int) {
+		removeDownload(downloadDir)
+		os.Exit(code)
+	}
+
+	backupMeta, err := loadBackupMetadata(opts.backupDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 读取备份元数据失败: %v\n", err)
+		abort(1)
+	}
+	if err := checkPrunable(backupMeta); opts.prune && err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		abort(2)
+	}
+	if backupMeta != nil && backupMeta.Tool != nil {
+		fmt.Fprintf(logOut, "备份由 %s 生成: k8s-backup %s\n", describeTool(backupMeta.Tool), strings.Join(backupMeta.Tool.Args, " "))
+		if warning := checkToolVersion(backupMeta.Tool.Version); warning != "" {
+			fmt.Fprintf(os.Stderr, "警告: %s\n", warning)
+		}
+	}
+	var targetCluster clusterFingerprint
+	if clientset, err := kubernetes.NewForConfig(config); err == nil {
+		targetCluster = collectFingerprint(config, clientset)
+	}
+	if backupMeta != nil && backupMeta.Cluster != nil {
+		warnClusterMismatch(backupName, *backupMeta.Cluster, targetCluster)
+	}
+	index, err := loadBackupIndex(opts.backupDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 读取备份索引失败: %v\n", err)
+		abort(1)
+	}
+
+	items, err := loadRestoreItems(opts.backupDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 读取备份清单失败: %v\n", err)
+		abort(1)
+	}
+	if !opts.systemConfig {
+		var excluded []string
+		if items, excluded = excludeSystemConfig(items); len(excluded) > 0 {
+			fmt.Fprintf(logOut, "跳过系统配置 %d 个 (%s), 使用 --include-system-config 恢复\n", len(excluded), strings.Join(excluded, ", "))
+		}
+	}
+	if filter != nil {
+		all := items
+		for _, k := range filter.unmatchedKinds(all) {
+			fmt.Fprintf(os.Stderr, "警告: 备份中没有类型为 '%s' 的对象\n", k)
+		}
+		if items = filter.apply(items); len(items) == 0 {
+			fmt.Fprintf(os.Stderr, "错误: 备份中的 %d 个对象均不匹配筛选条件\n", len(all))
+			abort(1)
+		}
+		if opts.withDefaults {
+			var added int
+			if items, added = addNamespaceDefaults(all, items); added > 0 {
+				fmt.Fprintf(logOut, "已加入所涉命名空间的 LimitRange/ResourceQuota %d 个\n", added)
+			}
+		}
+	}
+	for _, warning := range checkNamespaceDefaults(dynamicClient, items) {
+		fmt.Fprintf(os.Stderr, "警告: %s\n", warning)
+	}
+
+	if opts.valuesFile != "" || len(opts.setValues) > 0 {
+		values, err := loadRestoreValues(opts.valuesFile, opts.setValues)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 读取变量失败: %v\n", err)
+			abort(1)
+		}
+		missing := make(map[string]struct{})
+		for _, item := range items {
+			substituteValues(item.Obj.Object, values, missing)
+		}
+		if len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "警告: 以下变量未定义, 已保持原样: %s\n", strings.Join(sortedKeys(missing), ", "))
+		}
+	}
+
+	if backupMeta != nil && backupMeta.Cluster != nil {
+		if skew, ok := kubernetesMinorSkew(backupMeta.Cluster.KubernetesVersion, targetCluster.KubernetesVersion); ok && skew < 0 {
+			fmt.Fprintf(os.Stderr, "警告: 目标集群版本 %s 低于备份来源集群 %s, 部分字段可能不被支持\n", targetCluster.KubernetesVersion, backupMeta.Cluster.KubernetesVersion)
+		}
+	}
+	if opts.stripOwners {
+		printStrippedOwnership(stripOwnership(items))
+	}
+	if opts.stripOrigin {
+		fmt.Fprintf(logOut, "已移除 %d 个对象的来源注解\n", stripOriginAnnotations(items))
+	}
+	if len(storageClasses) > 0 {
+		printStorageClassRewrites(rewriteStorageClasses(items, storageClasses), storageClasses)
+	}
+	if len(imageRewrites) > 0 {
+		rewriteRestoreImages(items, imageRewrites)
+	}
+	if opts.pinLBIPs {
+		pinned, unpinnable := pinLoadBalancerIPs(opts.backupDir, items)
+		fmt.Fprintf(logOut, "已为 %d 个 LoadBalancer Service 指定备份时刻的外部 IP\n", pinned)
+		for _, desc := range unpinnable {
+			fmt.Fprintf(os.Stderr, "警告: 无法固定 %s 的外部地址\n", desc)
+		}
+	}
+	if opts.convert {
+		printAPIRewrites(rewriteAPIVersions(mapper, items))
+	}
+	if unserved := checkServedVersions(mapper, items); len(unserved) > 0 {
+		fmt.Fprintln(os.Stderr, "目标集群不提供备份中的以下 apiVersion/Kind:")
+		for _, u := range unserved {
+			fmt.Fprintf(os.Stderr, "  - %s\n", u)
+		}
+		if !opts.force {
+			fmt.Fprintln(os.Stderr, "错误: 版本不兼容, 已中止恢复 (使用 --force 跳过检查, 这些对象将创建失败)")
+			abort(1)
+		}
+		fmt.Fprintln(os.Stderr, "警告: 已指定 --force, 继续恢复")
+	}
+
+	if opts.dryRun {
+		if opts.prune {
+			fmt.Fprintln(os.Stderr, "警告: --dry-run 不预览 --prune 将删除的对象")
+		}
+		fmt.Fprintf(logOut, "预览恢复: %s (共 %d 个对象)\n", backupSource, len(items))
+		for _, item := range items {
+			prepareRestoreObject(item.Obj, opts, backupName, backupMeta, index)
+		}
+		conflictPolicy := opts.onConflict
+		if opts.serverSide {
+			conflictPolicy = conflictServerSide
+		}
+		changed := printRestorePreview(logOut, dynamicClient, mapper, items, opts.immutable, conflictPolicy)
+		removeDownload(downloadDir)
+		if changed > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if opts.pausedRollout {
+		fmt.Fprintf(logOut, "已暂停 %d 个工作负载, 将在其余对象应用后恢复\n", pauseWorkloads(items))
+	}
+	if plan != nil {
+		items = plan.order(items)
+		for _, key := range plan.unmatchedHooks(items) {
+			fmt.Fprintf(os.Stderr, "警告: 恢复计划钩子的对象 %s 不在本次恢复中, 该钩子不会执行\n", key)
+		}
+	}
+
+	var journal *restoreJournal
+	if opts.stateFile != "" {
+		if journal, err = openRestoreJournal(opts.stateFile, backupSource); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			abort(1)
+		}
+		if len(journal.applied) > 0 || len(journal.failed) > 0 {
+			fmt.Fprintf(logOut, "从进度文件 %s 续传: 之前已应用 %d 个对象, 失败待重试 %d 个\n", opts.stateFile, len(journal.applied), len(journal.failed))
+		}
+	}
+	events := &restoreEvents{progress: progress, journal: journal}
+
+	startTime := time.Now()
+	fmt.Fprintf(logOut, "恢复开始于: %s\n", startTime.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(logOut, "备份目录: %s (共 %d 个对象)\n", backupSource, len(items))
+	events.Emit(progressEvent{Event: restoreEventStarted, Path: backupSource, Count: len(items)})
+
+	throttle := newApplyThrottle(applyRate, opts.batchSize, opts.batchPause)
+	res := applyRestoreItems(dynamicClient, mapper, items, opts, backupName, backupMeta, index, plan, throttle, events)
+
+	if opts.pausedRollout {
+		fmt.Fprintln(logOut, "\n[恢复工作负载]")
+		resumedWorkloads, resumeFailed := resumeWorkloads(dynamicClient, mapper, res.objects)
+		fmt.Fprintf(logOut, "已恢复 %d 个工作负载\n", resumedWorkloads)
+		res.failed += resumeFailed
+	}
+	var health []workloadHealth
+	if opts.wait && !res.aborted {
+		fmt.Fprintln(logOut, "\n[等待工作负载就绪]")
+		health = waitForWorkloads(dynamicClient, mapper, res.objects, opts.waitTimeout)
+	}
+
+	switch {
+	case opts.serverSide:
+		fmt.Fprintf(logOut, "\n恢复完成 (server-side apply, 字段管理者 %s): 创建 %d 个, 更新 %d 个, 无变化 %d 个, 跳过 %d 个, 失败 %d 个\n",
+			opts.fieldManager, res.created, res.updated, res.unchanged, res.skipped, res.failed)
+	case opts.onConflict == onConflictSkip && res.updated == 0:
+		fmt.Fprintf(logOut, "\n恢复完成: 创建 %d 个, 已存在跳过 %d 个, 失败 %d 个\n", res.created, res.skipped, res.failed)
+	default:
+		fmt.Fprintf(logOut, "\n恢复完成: 创建 %d 个, 已存在并更新 %d 个, 跳过 %d 个, 失败 %d 个\n", res.created, res.updated, res.skipped, res.failed)
+	}
+	if res.resumed > 0 {
+		fmt.Fprintf(logOut, "之前的运行已应用而跳过 %d 个\n", res.resumed)
+	}
+	if res.uidFields > 0 || res.uidDropped > 0 {
+		fmt.Fprintf(logOut, "UID 引用: 改写为目标集群 UID %d 处, 移除无法解析的 ownerReferences %d 个\n", res.uidFields, res.uidDropped)
+	}
+	if opts.prune && !res.aborted {
+		fmt.Fprintf(logOut, "\n[清理恢复集合 %s]\n", opts.restoreSet)
+		deleted, pruneFailed := pruneRestoreSet(dynamicClient, mapper, opts.restoreSet, items)
+		fmt.Fprintf(logOut, "清理完成: 删除 %d 个, 失败 %d 个\n", deleted, pruneFailed)
+		res.failed += pruneFailed
+	}
+	notReady := 0
+	if opts.wait && !res.aborted {
+		notReady = printWorkloadHealth(logOut, health)
+	}
+	events.Emit(progressEvent{Event: restoreEventCompleted, Path: backupSource, Count: res.created, Duration: time.Since(startTime).Round(time.Second).String()})
+	if err := events.journal.finish(res.failed == 0); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 处理进度文件失败: %v\n", err)
+	} else if res.failed > 0 && events.journal != nil {
+		fmt.Fprintf(os.Stderr, "进度已保存到 %s, 以相同参数重新运行将只处理失败与未处理的对象\n", opts.stateFile)
+	}
+	if res.failed > 0 || notReady > 0 {
+		if downloadDir != "" {
+			fmt.Fprintf(os.Stderr, "下载的备份保留在 %s, 可以该目录作为 --from 重新运行, 完成后自行删除\n", downloadDir)
+		}
+		os.Exit(1)
+	}
+	removeDownload(downloadDir)
+}
+
+// restoreResult 应用恢复对象的结果, objects 为本次创建, 更新或确认无变化的对象, 供 --paused-rollout 与 --wait 使用
+type restoreResult struct {
+	created, updated, unchanged, skipped, resumed, failed int
+	uidFields, uidDropped                                 int
+	aborted                                               bool
+	objects                                               []*unstructured.Unstructured
+}
+
+// applyRestoreItems 按顺序应用恢复对象: 添加恢复集合标签与来源注解, 改写 UID 引用, 按冲突策略处理已存在的对象并执行恢复计划钩子
+// restore 子命令与 self-test 共用
+func applyRestoreItems(client dynamic.Interface, mapper meta.RESTMapper, items []restoreItem, opts restoreOptions, backupName string, backupMeta *backupMetadata, index map[string]indexEntry, plan *restorePlan, throttle *applyThrottle, events *restoreEvents) restoreResult {
+	var res restoreResult
+	uids := newUIDMap(items, index)
+	for i, item := range items {
+		obj := item.Obj
+		prepareRestoreObject(obj, opts, backupName, backupMeta, index)
+
+		desc := describeObject(obj)
+		resClient, err := resourceClientFor(client, mapper, obj)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  错误: %s: %v\n", desc, err)
+			res.failed++
+			ev := objectEvent(restoreEventFailed, item)
+			ev.Error = err.Error()
+			events.Emit(ev)
+			continue
+		}
+		if applied, wasCreated := events.journal.resumed(objectKey(obj.GetKind(), obj.GetNamespace(), obj.GetName())); applied {
+			// 之前的运行已应用: 读取其 UID 供引用它的对象改写, 由之前的运行创建的工作负载仍需取消暂停
+			if uids.needs(obj) {
+				if existing, err := resClient.Get(context.TODO(), obj.GetName(), metav1.GetOptions{}); err == nil {
+					uids.record(obj, string(existing.GetUID()))
+				}
+			}
+			if wasCreated {
+				res.objects = append(res.objects, obj)
+			}
+			ev := objectEvent(restoreEventSkipped, item)
+			ev.Reason = restoreSkipPreviously
+			events.Emit(ev)
+			res.resumed++
+			continue
+		}
+		rewrite := uids.rewrite(client, mapper, obj)
+		printUIDRewrite(desc, rewrite)
+		res.uidFields, res.uidDropped = res.uidFields+rewrite.Fields, res.uidDropped+len(rewrite.Dropped)
+		throttle.wait()
+		if opts.serverSide && !isDefaultServiceAccount(obj) {
+			// default ServiceAccount 由命名空间控制器创建, 其 imagePullSecrets 等字段仍按下方的合并逻辑处理
+			var result *unstructured.Unstructured
+			var outcome applyOutcome
+			kept, recreated, err := updateWithImmutableFields(resClient, obj, opts.immFields, func(desired *unstructured.Unstructured) error {
+				var err error
+				result, outcome, err = serverSideApply(resClient, desired, opts.fieldManager, opts.forceConflict)
+				return err
+			})
+			conflict := immutableNoConflict
+			if err != nil && outcome == applyConfigured && isImmutableObject(obj.Object) {
+				if c, cerr := handleImmutableConflict(resClient, obj, opts.immutable); cerr != nil || c != immutableNoConflict {
+					conflict, err = c, cerr
+				}
+			}
+			switch {
+			case err != nil:
+				fmt.Fprintf(os.Stderr, "  错误: 应用 %s 失败: %v\n", desc, err)
+				res.failed++
+				ev := objectEvent(restoreEventFailed, item)
+				ev.Error = err.Error()
+				events.Emit(ev)
+				continue
+			case recreated != nil:
+				uids.record(obj, string(recreated.GetUID()))
+				fmt.Fprintf(logOut, "  ↻ %s 的不可变字段与备份不同, 已删除并重建\n", desc)
+				res.created++
+				res.objects = append(res.objects, obj)
+				events.Emit(objectEvent(restoreEventRestored, item))
+			case conflict == immutableRecreated:
+				fmt.Fprintf(logOut, "  ↻ %s 为不可变对象且内容与备份不同, 已删除并重建\n", desc)
+				res.created++
+				res.objects = append(res.objects, obj)
+				events.Emit(objectEvent(restoreEventRestored, item))
+			case conflict == immutableDiffers:
+				fmt.Fprintf(os.Stderr, "  警告: %s 已存在且为不可变对象, 内容与备份不同, 无法原地更新 (使用 --immutable-conflict=%s 删除后重建)\n", desc, immutableConflictRecreate)
+				res.skipped++
+				ev := objectEvent(restoreEventSkipped, item)
+				ev.Reason = restoreSkipImmutable
+				events.Emit(ev)
+			case outcome == applyCreated:
+				uids.record(obj, string(result.GetUID()))
+				fmt.Fprintf(logOut, "  ✓ %s\n", desc)
+				res.created++
+				res.objects = append(res.objects, obj)
+				events.Emit(objectEvent(restoreEventRestored, item))
+			case outcome == applyUnchanged:
+				uids.record(obj, string(result.GetUID()))
+				fmt.Fprintf(logOut, "  = %s 无变化\n", desc)
+				res.unchanged++
+				res.objects = append(res.objects, obj)
+				ev := objectEvent(restoreEventSkipped, item)
+				ev.Reason = restoreSkipUnchanged
+				events.Emit(ev)
+			default:
+				uids.record(obj, string(result.GetUID()))
+				fmt.Fprintf(logOut, "  ↻ %s 已存在, 已按备份更新%s\n", desc, describeKeptFields(kept))
+				res.updated++
+				res.objects = append(res.objects, obj)
+				ev := objectEvent(restoreEventRestored, item)
+				ev.Reason = conflictServerSide
+				events.Emit(ev)
+			}
+		} else if result, err := resClient.Create(context.TODO(), obj, metav1.CreateOptions{}); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				fmt.Fprintf(os.Stderr, "  错误: 创建 %s 失败: %v\n", desc, err)
+				res.failed++
+				ev := objectEvent(restoreEventFailed, item)
+				ev.Error = err.Error()
+				events.Emit(ev)
+				continue
+			}
+			if uids.needs(obj) {
+				if existing, err := resClient.Get(context.TODO(), obj.GetName(), metav1.GetOptions{}); err == nil {
+					uids.record(obj, string(existing.GetUID()))
+				}
+			}
+			conflict, err := handleImmutableConflict(resClient, obj, opts.immutable)
+			switch {
+			case err != nil:
+				fmt.Fprintf(os.Stderr, "  错误: %s: %v\n", desc, err)
+				res.failed++
+				ev := objectEvent(restoreEventFailed, item)
+				ev.Error = err.Error()
+				events.Emit(ev)
+				continue
+			case conflict == immutableRecreated:
+				fmt.Fprintf(logOut, "  ↻ %s 为不可变对象且内容与备份不同, 已删除并重建\n", desc)
+				res.created++
+				res.objects = append(res.objects, obj)
+				events.Emit(objectEvent(restoreEventRestored, item))
+			case conflict == immutableDiffers:
+				fmt.Fprintf(os.Stderr, "  警告: %s 已存在且为不可变对象, 内容与备份不同, 无法原地更新 (使用 --immutable-conflict=%s 删除后重建)\n", desc, immutableConflictRecreate)
+				res.skipped++
+				ev := objectEvent(restoreEventSkipped, item)
+				ev.Reason = restoreSkipImmutable
+				events.Emit(ev)
+			case opts.onConflict == onConflictSkip && isDefaultServiceAccount(obj):
+				merged, err := mergeDefaultServiceAccount(resClient, obj)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "  错误: %s 已存在, %v\n", desc, err)
+					res.failed++
+					ev := objectEvent(restoreEventFailed, item)
+					ev.Error = err.Error()
+					events.Emit(ev)
+					continue
+				}
+				if !merged {
+					fmt.Fprintf(logOut, "  - %s 已存在且配置相同, 跳过\n", desc)
+					res.skipped++
+					ev := objectEvent(restoreEventSkipped, item)
+					ev.Reason = restoreSkipExists
+					events.Emit(ev)
+					break
+				}
+				fmt.Fprintf(logOut, "  ↻ %s 已由命名空间控制器创建, 已合并备份中的 imagePullSecrets, automountServiceAccountToken 等配置\n", desc)
+				res.updated++
+				events.Emit(objectEvent(restoreEventRestored, item))
+			case opts.onConflict != onConflictSkip:
+				kept, recreated, err := updateWithImmutableFields(resClient, obj, opts.immFields, func(desired *unstructured.Unstructured) error {
+					_, err := resolveConflict(resClient, desired, opts.onConflict)
+					return err
+				})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "  错误: %s 已存在, %v\n", desc, err)
+					res.failed++
+					ev := objectEvent(restoreEventFailed, item)
+					ev.Error = err.Error()
+					events.Emit(ev)
+					continue
+				}
+				if recreated != nil {
+					uids.record(obj, string(recreated.GetUID()))
+					fmt.Fprintf(logOut, "  ↻ %s 的不可变字段与备份不同, 已删除并重建\n", desc)
+					res.created++
+					res.objects = append(res.objects, obj)
+					events.Emit(objectEvent(restoreEventRestored, item))
+					break
+				}
+				fmt.Fprintf(logOut, "  ↻ %s 已存在, 已按 --on-conflict=%s 更新%s\n", desc, opts.onConflict, describeKeptFields(kept))
+				res.updated++
+				res.objects = append(res.objects, obj)
+				ev := objectEvent(restoreEventRestored, item)
+				ev.Reason = opts.onConflict
+				events.Emit(ev)
+			default:
+				fmt.Fprintf(logOut, "  - %s 已存在, 跳过\n", desc)
+				res.skipped++
+				ev := objectEvent(restoreEventSkipped, item)
+				ev.Reason = restoreSkipExists
+				events.Emit(ev)
+			}
+		} else {
+			uids.record(obj, string(result.GetUID()))
+			fmt.Fprintf(logOut, "  ✓ %s\n", desc)
+			res.created++
+			res.objects = append(res.objects, obj)
+			events.Emit(objectEvent(restoreEventRestored, item))
+		}
+		if plan != nil {
+			if err := plan.afterRestore(client, mapper, obj); err != nil {
+				fmt.Fprintf(os.Stderr, "  错误: %v\n", err)
+				fmt.Fprintf(os.Stderr, "错误: 恢复计划钩子失败, 已中止恢复, 剩余 %d 个对象未处理\n", len(items)-i-1)
+				res.failed++
+				res.aborted = true
+				events.Emit(progressEvent{Event: "restore_hook_failed", Namespace: obj.GetNamespace(), Kind: obj.GetKind(), Name: obj.GetName(), Error: err.Error()})
+				break
+			}
+		}
+	}
+
+	return res
+}
+
+// removeDownload 删除远程备份的临时下载目录, dir 为空时不做任何事
+func removeDownload(dir string) {
+	if dir == "" {
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 删除临时目录 %s 失败: %v\n", dir, err)
+	}
+}
+
+// newApplyClients 创建恢复与校验所需的动态客户端和基于集群发现信息的 RESTMapper
+// kubeContext 为空时使用 kubeconfig 中的当前上下文; applyRate 大于 0 时放宽 client-go 默认的客户端限速 (5 QPS),
+// 使 --apply-rate 成为实际生效的速率, 并为创建之外的查询请求留出余量
+func newApplyClients(kubeconfig, kubeContext string, applyRate float64) (dynamic.Interface, meta.RESTMapper, error) {
+	config, err := loadClientConfig(kubeconfig, kubeContext)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newApplyClientsForConfig(config, applyRate)
+}
+
+// newApplyClientsForConfig 同 newApplyClients, 使用已加载的客户端配置 (会修改其限速设置)
+func newApplyClientsForConfig(config *rest.Config, applyRate float64) (dynamic.Interface, meta.RESTMapper, error) {
+	if applyRate > 0 {
+		config.QPS = float32(applyRate * 2)
+		config.Burst = int(math.Ceil(applyRate)) * 2
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建动态客户端失败: %w", err)
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建发现客户端失败: %w", err)
+	}
+	groupResources, err := restmapper.GetAPIGroupResources(discoveryClient)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取集群API资源列表失败: %w", err)
+	}
+	return dynamicClient, restmapper.NewDiscoveryRESTMapper(groupResources), nil
+}
+
+// loadClientConfig 加载 kubeconfig, kubeContext 为空时使用当前上下文
+func loadClientConfig(kubeconfig, kubeContext string) (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext}).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("无法加载Kubernetes配置: %w", err)
+	}
+	return config, nil
+}
+
+// loadRestoreItems 遍历备份目录, 解析所有资源清单并按恢复顺序排序 (先按类型, 再按对象间的引用关系调整)
+// 同一对象可能同时出现在单资源文件与 all.yaml 中, 只保留首次出现的一份
+func loadRestoreItems(backupDir string) ([]restoreItem, error) {
+	var items []restoreItem
+	seen := make(map[string]struct{})
+	err := filepath.WalkDir(backupDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+		rel, err := filepath.Rel(backupDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if isReservedFile(rel) {
+			return nil
+		}
+
+		objs, err := decodeManifestFile(path)
+		if err != nil {
+			return fmt.Errorf("解析 '%s' 失败: %w", path, err)
+		}
+		for _, obj := range objs {
+			key := objectKey(obj.GetKind(), obj.GetNamespace(), obj.GetName())
+			if _, dup := seen[key]; dup {
+				continue
+			}
+			seen[key] = struct{}{}
+			items = append(items, restoreItem{Path: rel, Obj: obj})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return restoreRank(items[i].Obj.GetKind()) < restoreRank(items[j].Obj.GetKind())
+	})
+	return orderByDependencies(items), nil
+}
+
+// decodeManifestFile 解析YAML文件中的全部文档, 跳过不含 apiVersion/kind 的文档
+func decodeManifestFile(path string) ([]*unstructured.Unstructured, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var objs []*unstructured.Unstructured
+	decoder := yamlutil.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		var content map[string]interface{}
+		if err := decoder.Decode(&content); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		obj := &unstructured.Unstructured{Object: content}
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// restoreRank 返回资源类型的恢复顺序, 与 ResourceInfo.Order 一致
+func restoreRank(kind string) int {
+	if kind == "Namespace" {
+		return 0
+	}
+	for _, resInfo := range resourceMap {
+		if resInfo.Kind == kind {
+			return resInfo.Order
+		}
+	}
+	return restoreRankDefault
+}
+
+// resourceClientFor 通过集群发现信息解析对象对应的资源客户端
+func resourceClientFor(client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("目标集群不支持 %s: %w", gvk.String(), err)
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return client.Resource(mapping.Resource), nil
+	}
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(metav1.NamespaceDefault)
+	}
+	return client.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+}
+
+// prepareRestoreObject 在创建 (或 --dry-run 预览) 之前为对象添加来源注解与恢复集合标签
+func prepareRestoreObject(obj *unstructured.Unstructured, opts restoreOptions, backupName string, backupMeta *backupMetadata, index map[string]indexEntry) {
+	if opts.addProvenance {
+		entry, hasEntry := index[objectKey(obj.GetKind(), obj.GetNamespace(), obj.GetName())]
+		addProvenanceAnnotations(obj, backupName, backupMeta, entry, hasEntry)
+	}
+	setRestoreSetLabel(obj, opts.restoreSet)
+}
+
+// addProvenanceAnnotations 为对象添加备份来源注解
+func addProvenanceAnnotations(obj *unstructured.Unstructured, backupName string, backupMeta *backupMetadata, entry indexEntry, hasEntry bool) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[annotationRestoredFrom] = backupName
+	if backupMeta != nil && backupMeta.Timestamp != "" {
+		annotations[annotationBackupTimestamp] = backupMeta.Timestamp
+	}
+	if hasEntry && entry.UID != "" {
+		annotations[annotationOriginalUID] = entry.UID
+	}
+	obj.SetAnnotations(annotations)
+}
+
+// describeObject 返回对象的可读描述, 如 Deployment default/web
+func describeObject(obj *unstructured.Unstructured) string {
+	if ns := obj.GetNamespace(); ns != "" {
+		return fmt.Sprintf("%s %s/%s", obj.GetKind(), ns, obj.GetName())
+	}
+	return fmt.Sprintf("%s %s", obj.GetKind(), obj.GetName())
+}
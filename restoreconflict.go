@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// restore --on-conflict 的可选策略: 目标集群中已存在同名对象时如何处理
+// 内容不同的不可变 ConfigMap/Secret 无法更新, 始终按 --immutable-conflict 处理
+const (
+	onConflictSkip       = "skip"        // 保留现有对象 (默认)
+	onConflictReplace    = "replace"     // 以备份中的对象整体替换 (PUT), 备份中没有的字段被移除
+	onConflictMergePatch = "merge-patch" // 以备份中的对象作为 JSON merge patch 合并, 保留备份中没有的字段
+)
+
+// validateOnConflict 校验 --on-conflict 参数
+func validateOnConflict(policy string) error {
+	switch policy {
+	case onConflictSkip, onConflictReplace, onConflictMergePatch:
+		return nil
+	default:
+		return fmt.Errorf("不支持的 --on-conflict 策略 '%s' (可选: %s, %s, %s)", policy, onConflictSkip, onConflictReplace, onConflictMergePatch)
+	}
+}
+
+// resolveConflict 在创建返回 AlreadyExists 后按 policy 更新现有对象, 返回是否已更新; skip 策略不发出任何请求
+// replace 以现有对象的 resourceVersion 整体更新, 期间对象被他人修改时返回冲突错误而不覆盖其修改
+func resolveConflict(resClient dynamic.ResourceInterface, obj *unstructured.Unstructured, policy string) (bool, error) {
+	switch policy {
+	case onConflictReplace:
+		existing, err := resClient.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("读取已存在的对象失败: %w", err)
+		}
+		desired := obj.DeepCopy()
+		desired.SetResourceVersion(existing.GetResourceVersion())
+		if _, err := resClient.Update(context.TODO(), desired, metav1.UpdateOptions{}); err != nil {
+			return false, fmt.Errorf("替换失败: %w", err)
+		}
+		return true, nil
+	case onConflictMergePatch:
+		patch, err := json.Marshal(obj.Object)
+		if err != nil {
+			return false, err
+		}
+		if _, err := resClient.Patch(context.TODO(), obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return false, fmt.Errorf("合并更新失败: %w", err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// describeConflictPolicy 输出中描述已存在且内容不同的对象将如何处理
+func describeConflictPolicy(policy string) string {
+	switch policy {
+	case onConflictReplace:
+		return "内容不同, 将以备份整体替换"
+	case onConflictMergePatch:
+		return "内容不同, 将按备份合并更新"
+	case conflictServerSide:
+		return "内容不同, 将以 server-side apply 更新备份中的字段"
+	}
+	return "内容不同, 恢复时保留现有对象"
+}
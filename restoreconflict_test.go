@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestResolveConflict(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	live := fakeObject("v1", "ConfigMap", "app", "settings", map[string]interface{}{
+		"data": map[string]interface{}{"mode": "old", "extra": "kept-by-merge"},
+	})
+	backup := fakeObject("v1", "ConfigMap", "app", "settings", map[string]interface{}{
+		"data": map[string]interface{}{"mode": "new"},
+	})
+	backup.SetUID("")
+	backup.SetResourceVersion("")
+
+	cases := map[string]map[string]interface{}{
+		onConflictSkip:       {"mode": "old", "extra": "kept-by-merge"},
+		onConflictReplace:    {"mode": "new"},
+		onConflictMergePatch: {"mode": "new", "extra": "kept-by-merge"},
+	}
+	for policy, want := range cases {
+		t.Run(policy, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), live.DeepCopy())
+			resClient := client.Resource(gvr).Namespace("app")
+			updated, err := resolveConflict(resClient, backup, policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated != (policy != onConflictSkip) {
+				t.Errorf("updated = %v", updated)
+			}
+			got, err := resClient.Get(context.TODO(), "settings", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data := got.Object["data"].(map[string]interface{}); !jsonEqual(data, want) {
+				t.Errorf("data = %v, 期望 %v", data, want)
+			}
+		})
+	}
+	if err := validateOnConflict("overwrite"); err == nil {
+		t.Error("未知策略应报错")
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"backup-k8s/clean"
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// restore --dry-run 对单个对象的预览结果
+const (
+	previewCreate = "create" // 目标集群中不存在, 恢复时创建
+	previewUpdate = "update" // 已存在且内容不同; 按 --on-conflict 保留或更新, 不可变对象按 --immutable-conflict=recreate 时删除重建
+	previewNoop   = "no-op"  // 已存在且内容相同
+)
+
+// diffContext unified diff 中变更前后保留的上下文行数
+const diffContext = 3
+
+// maxDiffCells 逐行比较的最大规模 (两侧行数之积), 超过时整体显示为删除旧内容, 添加新内容
+const maxDiffCells = 4_000_000
+
+// previewNormalize 比较前统一清理现有对象与备份对象: 移除 status, uid, resourceVersion 等由集群填写的字段,
+// 保留证书字段与 last-applied 注解, 使两侧只在用户可见的内容上比较
+var previewNormalize = clean.Options{KeepCertKinds: map[string]bool{"all": true}, LastApplied: clean.LastAppliedPreserve}
+
+// previewRestoreItem 读取目标集群中的现有对象并与待恢复的对象比较, 返回预览结果与 unified diff (no-op 时为空)
+func previewRestoreItem(resClient dynamic.ResourceInterface, obj *unstructured.Unstructured) (string, string, error) {
+	desired, err := previewYAML(obj)
+	if err != nil {
+		return "", "", err
+	}
+	live, err := resClient.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return previewCreate, unifiedDiff("/dev/null", "备份", "", desired), nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("读取现有对象失败: %w", err)
+	}
+	current, err := previewYAML(live)
+	if err != nil {
+		return "", "", err
+	}
+	if current == desired {
+		return previewNoop, "", nil
+	}
+	return previewUpdate, unifiedDiff("集群", "备份", current, desired), nil
+}
+
+func previewYAML(obj *unstructured.Unstructured) (string, error) {
+	data, err := yaml.Marshal(clean.Resource(obj.DeepCopy().Object, previewNormalize))
+	return string(data), err
+}
+
+// printRestorePreview 输出 restore --dry-run 的结果, 返回失败的对象数
+func printRestorePreview(w io.Writer, dynamicClient dynamic.Interface, mapper meta.RESTMapper, items []restoreItem, immutablePolicy, conflictPolicy string) int {
+	counts := make(map[string]int)
+	failed := 0
+	for _, item := range items {
+		obj := item.Obj
+		desc := describeObject(obj)
+		resClient, err := resourceClientFor(dynamicClient, mapper, obj)
+		if err == nil {
+			var action, diff string
+			if action, diff, err = previewRestoreItem(resClient, obj); err == nil {
+				counts[action]++
+				switch {
+				case action == previewCreate:
+					fmt.Fprintf(w, "  + %s (创建)\n", desc)
+				case action == previewNoop:
+					fmt.Fprintf(w, "  = %s (无变化)\n", desc)
+				case isImmutableObject(obj.Object) && immutablePolicy == immutableConflictRecreate:
+					fmt.Fprintf(w, "  ~ %s (不可变对象, 将删除后重建)\n", desc)
+				case isImmutableObject(obj.Object):
+					fmt.Fprintf(w, "  ~ %s (不可变对象, 恢复时保留现有对象)\n", desc)
+				default:
+					fmt.Fprintf(w, "  ~ %s (%s)\n", desc, describeConflictPolicy(conflictPolicy))
+				}
+				if diff != "" {
+					fmt.Fprint(w, indentLines(diff, "      "))
+				}
+				continue
+			}
+		}
+		fmt.Fprintf(w, "  ! %s: %v\n", desc, err)
+		failed++
+	}
+	fmt.Fprintf(w, "\n预览完成 (未修改集群): 创建 %d 个, 内容不同 %d 个, 无变化 %d 个, 失败 %d 个\n",
+		counts[previewCreate], counts[previewUpdate], counts[previewNoop], failed)
+	return failed
+}
+
+func indentLines(s, prefix string) string {
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if line != "" {
+			b.WriteString(prefix)
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// diffOp 逐行比较的编辑操作, kind 为 ' ' (相同), '-' (删除) 或 '+' (添加)
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// diffLines 基于最长公共子序列计算从 a 到 b 的逐行编辑操作
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	var ops []diffOp
+	if n*m > maxDiffCells {
+		for _, line := range a {
+			ops = append(ops, diffOp{'-', line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{'+', line})
+		}
+		return ops
+	}
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// unifiedDiff 生成 from 到 to 的 unified diff, 内容相同时返回空字符串
+func unifiedDiff(fromName, toName, from, to string) string {
+	ops := diffLines(splitDiffLines(from), splitDiffLines(to))
+	// aPos[k], bPos[k] 为第 k 个操作之前两侧已经过的行数
+	aPos, bPos := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for k, op := range ops {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if op.kind != '+' {
+			aPos[k+1]++
+		}
+		if op.kind != '-' {
+			bPos[k+1]++
+		}
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := max(0, i-diffContext)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := 0
+			for end+run < len(ops) && ops[end+run].kind == ' ' {
+				run++
+			}
+			if end+run == len(ops) || run > 2*diffContext {
+				end += min(run, diffContext)
+				break
+			}
+			end += run
+		}
+		if buf.Len() == 0 {
+			fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", diffRange(aPos[start], aPos[end]-aPos[start]), diffRange(bPos[start], bPos[end]-bPos[start]))
+		for _, op := range ops[start:end] {
+			buf.WriteByte(op.kind)
+			buf.WriteString(op.text)
+			buf.WriteByte('\n')
+		}
+		i = end
+	}
+	return buf.String()
+}
+
+// diffRange 格式化 hunk 头中的行范围, start 为之前已经过的行数
+func diffRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitDiffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
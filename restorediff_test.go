@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	to := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	want := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -10,3 +10,4 @@
+ j
+ k
+ l
++m
+`
+	if got := unifiedDiff("old", "new", from, to); got != want {
+		t.Errorf("diff =\n%s\n期望:\n%s", got, want)
+	}
+	if got := unifiedDiff("old", "new", from, from); got != "" {
+		t.Errorf("内容相同时应返回空字符串, 得到:\n%s", got)
+	}
+	if got := unifiedDiff("/dev/null", "new", "", "x\n"); got != "--- /dev/null\n+++ new\n@@ -0,0 +1 @@\n+x\n" {
+		t.Errorf("新建文件 diff =\n%s", got)
+	}
+}
+
+func TestPreviewRestoreItem(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	live := fakeObject("v1", "ConfigMap", "app", "settings", map[string]interface{}{
+		"data": map[string]interface{}{"mode": "old", "level": "info"},
+	})
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), live.DeepCopy())
+	resClient := client.Resource(gvr).Namespace("app")
+
+	// 备份对象不含 uid/resourceVersion, 与现有对象只在这些字段上不同时视为无变化
+	same := live.DeepCopy()
+	same.SetUID("")
+	same.SetResourceVersion("")
+	if action, diff, err := previewRestoreItem(resClient, same); err != nil || action != previewNoop || diff != "" {
+		t.Errorf("无变化 = %s, %q, %v", action, diff, err)
+	}
+
+	changed := fakeObject("v1", "ConfigMap", "app", "settings", map[string]interface{}{
+		"data": map[string]interface{}{"mode": "new", "level": "info"},
+	})
+	action, diff, err := previewRestoreItem(resClient, changed)
+	if err != nil || action != previewUpdate || !strings.Contains(diff, "-    mode: old\n+    mode: new\n") {
+		t.Errorf("内容不同 = %s, %v\n%s", action, err, diff)
+	}
+
+	missing := fakeObject("v1", "ConfigMap", "app", "missing", nil)
+	if action, diff, err := previewRestoreItem(resClient, missing); err != nil || action != previewCreate || !strings.HasPrefix(diff, "--- /dev/null") {
+		t.Errorf("不存在 = %s, %v\n%s", action, err, diff)
+	}
+	if list, _ := resClient.List(context.TODO(), metav1.ListOptions{}); len(list.Items) != 1 {
+		t.Error("预览不应修改集群")
+	}
+}
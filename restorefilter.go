@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// restoreFilter 按类型, 标签与名称筛选待恢复的对象, 各条件之间为"与"关系, 未设置的条件不做限制
+type restoreFilter struct {
+	kinds    map[string]bool // 小写的 Kind 或资源类型名 (如 deployment, deployments)
+	selector labels.Selector
+	names    map[string]bool
+}
+
+// newRestoreFilter 解析 --kinds (--type), --selector 与 --names, 全部为空时返回 nil
+func newRestoreFilter(kinds, selector, names string) (*restoreFilter, error) {
+	if kinds == "" && selector == "" && names == "" {
+		return nil, nil
+	}
+	f := &restoreFilter{selector: labels.Everything()}
+	if kinds != "" {
+		f.kinds = make(map[string]bool)
+		for _, k := range strings.Split(kinds, ",") {
+			if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+				f.kinds[k] = true
+			}
+		}
+	}
+	if selector != "" {
+		s, err := labels.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("无效的标签选择器 '%s': %w", selector, err)
+		}
+		f.selector = s
+	}
+	if names != "" {
+		f.names = make(map[string]bool)
+		for _, n := range strings.Split(names, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				f.names[n] = true
+			}
+		}
+	}
+	return f, nil
+}
+
+// matchKind 判断 Kind 是否在 --kinds 中, 同时接受 Kind 与 resourceMap 中的资源类型名
+func (f *restoreFilter) matchKind(kind string) bool {
+	if f.kinds == nil {
+		return true
+	}
+	for k := range f.kinds {
+		if kindMatches(k, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// kindMatches 判断 --kinds 中的一项 (已转为小写) 是否指向 kind
+func kindMatches(k, kind string) bool {
+	lower := strings.ToLower(kind)
+	if k == lower || k == lower+"s" {
+		return true
+	}
+	resInfo, ok := resourceMap[k]
+	return ok && resInfo.Kind == kind
+}
+
+// unmatchedKinds 返回 --kinds 中在备份里没有任何对象的类型 (通常为拼写错误), 按字母排序
+func (f *restoreFilter) unmatchedKinds(items []restoreItem) []string {
+	var unmatched []string
+	for k := range f.kinds {
+		found := false
+		for _, item := range items {
+			if kindMatches(k, item.Obj.GetKind()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unmatched = append(unmatched, k)
+		}
+	}
+	sort.Strings(unmatched)
+	return unmatched
+}
+
+// apply 返回匹配筛选条件的对象, 并保留这些对象所在命名空间的 Namespace 清单, 以便恢复到尚不存在的命名空间
+// Namespace 对象只有在 --kinds 中明确列出时才按其他条件筛选
+func (f *restoreFilter) apply(items []restoreItem) []restoreItem {
+	selected := make([]bool, len(items))
+	namespaces := make(map[string]bool)
+	for i, item := range items {
+		obj := item.Obj
+		if obj.GetKind() == "Namespace" && f.kinds == nil {
+			continue
+		}
+		if !f.matchKind(obj.GetKind()) || !f.selector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+		if f.names != nil && !f.names[obj.GetName()] {
+			continue
+		}
+		selected[i] = true
+		namespaces[obj.GetNamespace()] = true
+	}
+
+	var result []restoreItem
+	for i, item := range items {
+		if selected[i] || (item.Obj.GetKind() == "Namespace" && namespaces[item.Obj.GetName()]) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
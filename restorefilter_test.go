@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRestoreFilter(t *testing.T) {
+	withLabels := func(kind, namespace, name string, labels map[string]string) restoreItem {
+		obj := fakeObject("v1", kind, namespace, name, nil)
+		obj.SetLabels(labels)
+		return restoreItem{Obj: obj}
+	}
+	items := []restoreItem{
+		{Obj: fakeObject("v1", "Namespace", "", "web", nil)},
+		{Obj: fakeObject("v1", "Namespace", "", "api", nil)},
+		withLabels("ConfigMap", "web", "settings", map[string]string{"app": "foo"}),
+		withLabels("ConfigMap", "api", "settings", map[string]string{"app": "bar"}),
+		withLabels("Deployment", "web", "web", map[string]string{"app": "foo"}),
+		withLabels("Deployment", "api", "api", map[string]string{"app": "bar"}),
+	}
+
+	cases := []struct {
+		name                   string
+		kinds, selector, names string
+		want                   string
+	}{
+		{name: "by resource type", kinds: "configmaps", want: "Namespace/web,Namespace/api,ConfigMap/web/settings,ConfigMap/api/settings"},
+		{name: "by kind", kinds: "Deployment", names: "api", want: "Namespace/api,Deployment/api/api"},
+		{name: "by selector", selector: "app=foo", want: "Namespace/web,ConfigMap/web/settings,Deployment/web/web"},
+		{name: "combined", kinds: "configmaps", selector: "app=bar", want: "Namespace/api,ConfigMap/api/settings"},
+		{name: "namespaces only", kinds: "namespaces", names: "web", want: "Namespace/web"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := newRestoreFilter(tc.kinds, tc.selector, tc.names)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, item := range f.apply(items) {
+				desc := item.Obj.GetKind() + "/"
+				if ns := item.Obj.GetNamespace(); ns != "" {
+					desc += ns + "/"
+				}
+				got = append(got, desc+item.Obj.GetName())
+			}
+			if strings.Join(got, ",") != tc.want {
+				t.Errorf("筛选结果 = %v, 期望 %s", got, tc.want)
+			}
+		})
+	}
+
+	if f, err := newRestoreFilter("", "", ""); f != nil || err != nil {
+		t.Errorf("未设置条件时应返回 nil, 实际 %v, %v", f, err)
+	}
+	if _, err := newRestoreFilter("", "app in (", ""); err == nil {
+		t.Error("无效的选择器应被拒绝")
+	}
+	f, _ := newRestoreFilter("deployments,Secret,configmap,deploymnets", "", "")
+	if got := f.unmatchedKinds(items); strings.Join(got, ",") != "deploymnets,secret" {
+		t.Errorf("unmatchedKinds = %v, 期望 [deploymnets secret]", got)
+	}
+}
+
+func TestNamespaceDefaults(t *testing.T) {
+	container := func(resources map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "app", "resources": resources}},
+		}}}}
+	}
+	all := []restoreItem{
+		{Obj: fakeObject("v1", "Namespace", "", "web", nil)},
+		{Obj: fakeObject("v1", "LimitRange", "web", "defaults", nil)},
+		{Obj: fakeObject("v1", "ResourceQuota", "api", "quota", nil)},
+		{Obj: fakeObject("apps/v1", "Deployment", "web", "frontend", container(nil))},
+		{Obj: fakeObject("apps/v1", "Deployment", "api", "backend", container(nil))},
+		{Obj: fakeObject("apps/v1", "Deployment", "api", "sized", container(map[string]interface{}{
+			"requests": map[string]interface{}{"cpu": "100m"},
+		}))},
+	}
+	f, _ := newRestoreFilter("deployments", "", "")
+	selected := f.apply(all)
+	run, _ := newFakeBackupper(t, nil, nil)
+
+	warnings := checkNamespaceDefaults(run.dynamicClient, selected)
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "Deployment/backend") || strings.Contains(warnings[0], "sized") {
+		t.Errorf("筛选后的警告 = %v, 期望 api 与 web 两条, 不含已设置 requests 的 sized", warnings)
+	}
+
+	withDefaults, added := addNamespaceDefaults(all, selected)
+	if added != 2 || len(withDefaults) != 6 {
+		t.Fatalf("补回 %d 个, 结果 %d 个, 期望补回 LimitRange 与 ResourceQuota", added, len(withDefaults))
+	}
+	warnings = checkNamespaceDefaults(run.dynamicClient, withDefaults)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "命名空间 api") {
+		t.Errorf("补回后的警告 = %v, 期望只剩没有 LimitRange 的 api", warnings)
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+)
+
+// restorePlan restore --plan 指定的恢复计划, 为启动顺序严格的应用 (如数据库 -> 缓存 -> 应用) 定义分层顺序与对象级钩子
+//
+//	tiers:                       # 按顺序恢复的层级, 每层之后可暂停
+//	  - name: database
+//	    selector: tier=db
+//	    pauseAfter: 30s
+//	hooks:                       # 指定对象恢复后执行的动作, 依次为 pause, waitReady, job
+//	  - after: Secret/db/db-credentials
+//	    job: jobs/migrate.yaml   # 相对计划文件的 Job 清单, 创建后等待其完成
+//	    timeout: 10m
+//	  - after: StatefulSet/db/postgres
+//	    waitReady: true
+type restorePlan struct {
+	Tiers []planTier `yaml:"tiers"`
+	Hooks []planHook `yaml:"hooks"`
+
+	tierEnds map[string]planTier // 每层最后一个对象的 objectKey -> 该层
+}
+
+// planTier 恢复计划中的一层, selector 与 kinds 同时设置时须同时匹配
+type planTier struct {
+	Name       string        `yaml:"name"`
+	Selector   string        `yaml:"selector"`
+	Kinds      []string      `yaml:"kinds"`
+	PauseAfter time.Duration `yaml:"pauseAfter"`
+
+	selector labels.Selector
+}
+
+// planHook 在 After 指定的对象 (Kind/命名空间/名称, 集群级对象为 Kind/名称) 创建后执行的动作
+type planHook struct {
+	After     string        `yaml:"after"`
+	Pause     time.Duration `yaml:"pause"`
+	WaitReady bool          `yaml:"waitReady"`
+	Job       string        `yaml:"job"`
+	Timeout   time.Duration `yaml:"timeout"` // waitReady 与 job 的等待上限, 默认 restoreHookTimeout
+
+	key string
+	job *unstructured.Unstructured
+}
+
+// restoreHookTimeout 钩子等待对象就绪或 Job 完成的默认上限
+const restoreHookTimeout = 5 * time.Minute
+
+// restoreHookPollInterval 钩子轮询对象状态的间隔
+var restoreHookPollInterval = 2 * time.Second
+
+// loadRestorePlan 读取并校验恢复计划, Job 清单路径相对计划文件所在目录
+func loadRestorePlan(path string) (*restorePlan, error) {
+	var plan restorePlan
+	if err := readYAMLFile(path, &plan); err != nil {
+		return nil, fmt.Errorf("读取恢复计划 '%s' 失败: %w", path, err)
+	}
+	for i := range plan.Tiers {
+		tier := &plan.Tiers[i]
+		if tier.Name == "" {
+			tier.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if tier.Selector == "" && len(tier.Kinds) == 0 {
+			return nil, fmt.Errorf("恢复计划层级 %s 须设置 selector 或 kinds", tier.Name)
+		}
+		tier.selector = labels.Everything()
+		if tier.Selector != "" {
+			s, err := labels.Parse(tier.Selector)
+			if err != nil {
+				return nil, fmt.Errorf("恢复计划层级 %s 的选择器无效: %w", tier.Name, err)
+			}
+			tier.selector = s
+		}
+	}
+	for i := range plan.Hooks {
+		hook := &plan.Hooks[i]
+		parts := strings.Split(hook.After, "/")
+		switch len(parts) {
+		case 2:
+			hook.key = objectKey(parts[0], "", parts[1])
+		case 3:
+			hook.key = objectKey(parts[0], parts[1], parts[2])
+		default:
+			return nil, fmt.Errorf("恢复计划钩子的 after '%s' 格式应为 Kind/命名空间/名称 或 Kind/名称", hook.After)
+		}
+		if hook.Timeout == 0 {
+			hook.Timeout = restoreHookTimeout
+		}
+		if hook.Job != "" {
+			jobPath := hook.Job
+			if !filepath.IsAbs(jobPath) {
+				jobPath = filepath.Join(filepath.Dir(path), jobPath)
+			}
+			data, err := os.ReadFile(jobPath)
+			if err != nil {
+				return nil, fmt.Errorf("读取钩子 Job '%s' 失败: %w", hook.Job, err)
+			}
+			job := &unstructured.Unstructured{}
+			if err := yaml.Unmarshal(data, &job.Object); err != nil || job.GetKind() != "Job" {
+				return nil, fmt.Errorf("钩子 Job '%s' 不是合法的 Job 清单", hook.Job)
+			}
+			hook.job = job
+		}
+	}
+	return &plan, nil
+}
+
+// matches 判断对象是否属于该层
+func (t planTier) matches(obj *unstructured.Unstructured) bool {
+	if len(t.Kinds) > 0 {
+		found := false
+		for _, k := range t.Kinds {
+			found = found || strings.EqualFold(k, obj.GetKind())
+		}
+		if !found {
+			return false
+		}
+	}
+	return t.selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+// order 按层级重新排列待恢复对象, 层内与层外对象保持原有的依赖顺序
+// 不属于任何层的对象中, 恢复顺序先于全部分层对象的 (如 Namespace, ServiceAccount) 排在最前, 其余排在最后
+func (p *restorePlan) order(items []restoreItem) []restoreItem {
+	if len(p.Tiers) == 0 {
+		return items
+	}
+	tiered := make([][]restoreItem, len(p.Tiers))
+	var rest []restoreItem
+	minRank := -1
+	for _, item := range items {
+		placed := false
+		for i, tier := range p.Tiers {
+			if tier.matches(item.Obj) {
+				tiered[i] = append(tiered[i], item)
+				if rank := restoreRank(item.Obj.GetKind()); minRank < 0 || rank < minRank {
+					minRank = rank
+				}
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			rest = append(rest, item)
+		}
+	}
+
+	var ordered, tail []restoreItem
+	for _, item := range rest {
+		if restoreRank(item.Obj.GetKind()) < minRank {
+			ordered = append(ordered, item)
+		} else {
+			tail = append(tail, item)
+		}
+	}
+	p.tierEnds = make(map[string]planTier)
+	for i, tier := range p.Tiers {
+		if len(tiered[i]) == 0 {
+			continue
+		}
+		ordered = append(ordered, tiered[i]...)
+		last := tiered[i][len(tiered[i])-1].Obj
+		p.tierEnds[objectKey(last.GetKind(), last.GetNamespace(), last.GetName())] = tier
+	}
+	return append(ordered, tail...)
+}
+
+// afterRestore 在对象创建 (或已存在) 后执行匹配的钩子与层级暂停, 钩子失败时返回错误, 调用方应中止恢复
+func (p *restorePlan) afterRestore(client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured) error {
+	key := objectKey(obj.GetKind(), obj.GetNamespace(), obj.GetName())
+	for _, hook := range p.Hooks {
+		if hook.key != key {
+			continue
+		}
+		if hook.Pause > 0 {
+			fmt.Fprintf(logOut, "    [钩子] 暂停 %s\n", hook.Pause)
+			time.Sleep(hook.Pause)
+		}
+		if hook.WaitReady {
+			fmt.Fprintf(logOut, "    [钩子] 等待 %s 就绪\n", describeObject(obj))
+			if err := waitForObject(client, mapper, obj, hook.Timeout, objectReady); err != nil {
+				return fmt.Errorf("等待 %s 就绪失败: %w", describeObject(obj), err)
+			}
+		}
+		if hook.job != nil {
+			if err := runHookJob(client, mapper, hook.job.DeepCopy(), obj.GetNamespace(), hook.Timeout); err != nil {
+				return fmt.Errorf("钩子 Job '%s' 失败: %w", hook.Job, err)
+			}
+		}
+	}
+	if tier, ok := p.tierEnds[key]; ok {
+		fmt.Fprintf(logOut, "  === 层级 %s 恢复完成", tier.Name)
+		if tier.PauseAfter > 0 {
+			fmt.Fprintf(logOut, ", 暂停 %s", tier.PauseAfter)
+		}
+		fmt.Fprintln(logOut)
+		time.Sleep(tier.PauseAfter)
+	}
+	return nil
+}
+
+// runHookJob 在 namespace (Job 清单未指定命名空间时) 中创建钩子 Job 并等待其完成
+func runHookJob(client dynamic.Interface, mapper meta.RESTMapper, job *unstructured.Unstructured, namespace string, timeout time.Duration) error {
+	if job.GetNamespace() == "" {
+		job.SetNamespace(namespace)
+	}
+	resClient, err := resourceClientFor(client, mapper, job)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(logOut, "    [钩子] 创建 %s 并等待完成\n", describeObject(job))
+	if _, err := resClient.Create(context.TODO(), job, metav1.CreateOptions{}); err != nil {
+		return err
+	}
+	return waitForObject(client, mapper, job, timeout, jobFinished)
+}
+
+// waitForObject 轮询对象直到 done 返回 true, done 返回错误 (如 Job 失败) 时立即结束
+func waitForObject(client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured, timeout time.Duration, done func(*unstructured.Unstructured) (bool, error)) error {
+	resClient, err := resourceClientFor(client, mapper, obj)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		current, err := resClient.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+		if err == nil {
+			ok, err := done(current)
+			if err != nil || ok {
+				return err
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("超过 %s 仍未完成", timeout)
+		}
+		time.Sleep(restoreHookPollInterval)
+	}
+}
+
+// objectReady 判断工作负载是否就绪: 控制器的就绪副本数达到期望值, Job 已完成, Pod 的 Ready 条件为 True
+// 其他类型的对象创建即视为就绪
+func objectReady(obj *unstructured.Unstructured) (bool, error) {
+	switch obj.GetKind() {
+	case "Job":
+		return jobFinished(obj)
+	case "Pod":
+		return conditionTrue(obj, "Ready"), nil
+	case "Deployment", "StatefulSet", "ReplicaSet", "Rollout", "DeploymentConfig":
+		replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		if !found {
+			replicas = 1
+		}
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		return ready >= replicas, nil
+	case "DaemonSet":
+		desired, _, _ := unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "numberReady")
+		return desired > 0 && ready >= desired, nil
+	}
+	return true, nil
+}
+
+// jobFinished Job 成功完成时返回 true, 失败时返回错误
+func jobFinished(obj *unstructured.Unstructured) (bool, error) {
+	if conditionTrue(obj, "Failed") {
+		return false, fmt.Errorf("Job %s 执行失败", obj.GetName())
+	}
+	return conditionTrue(obj, "Complete"), nil
+}
+
+// conditionTrue 判断 status.conditions 中指定类型的条件是否为 True
+func conditionTrue(obj *unstructured.Unstructured, condType string) bool {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range mapsOf(conditions) {
+		if c["type"] == condType && c["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
+
+// unmatchedHooks 返回钩子指向但不在待恢复对象中的对象, 通常是 after 写错或对象被筛选条件排除
+func (p *restorePlan) unmatchedHooks(items []restoreItem) []string {
+	present := make(map[string]bool, len(items))
+	for _, item := range items {
+		present[objectKey(item.Obj.GetKind(), item.Obj.GetNamespace(), item.Obj.GetName())] = true
+	}
+	var missing []string
+	for _, hook := range p.Hooks {
+		if !present[hook.key] {
+			missing = append(missing, hook.After)
+		}
+	}
+	return missing
+}
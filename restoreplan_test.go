@@ -0,0 +1,173 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRestorePlanOrder(t *testing.T) {
+	dir := t.TempDir()
+	planPath := filepath.Join(dir, "plan.yaml")
+	plan := `tiers:
+  - name: database
+    selector: tier=db
+  - name: app
+    kinds: [Deployment]
+hooks:
+  - after: Secret/db/creds
+    pause: 1ms
+  - after: Namespace/db
+    waitReady: true
+`
+	if err := os.WriteFile(planPath, []byte(plan), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := loadRestorePlan(planPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Hooks[0].key != objectKey("Secret", "db", "creds") || p.Hooks[1].key != objectKey("Namespace", "", "db") {
+		t.Errorf("钩子对象解析错误: %q, %q", p.Hooks[0].key, p.Hooks[1].key)
+	}
+	if p.Hooks[0].Pause != time.Millisecond || p.Hooks[1].Timeout != restoreHookTimeout {
+		t.Errorf("钩子时间参数解析错误: %+v", p.Hooks)
+	}
+
+	withTier := func(kind, namespace, name, tier string) restoreItem {
+		obj := fakeObject("v1", kind, namespace, name, nil)
+		if tier != "" {
+			obj.SetLabels(map[string]string{"tier": tier})
+		}
+		return restoreItem{Obj: obj}
+	}
+	items := []restoreItem{
+		withTier("Namespace", "", "db", ""),
+		withTier("Namespace", "", "app", ""),
+		withTier("ConfigMap", "app", "settings", ""),
+		withTier("Secret", "db", "creds", "db"),
+		withTier("Service", "app", "web", ""),
+		withTier("Deployment", "app", "web", ""),
+		withTier("StatefulSet", "db", "postgres", "db"),
+	}
+	var got []string
+	for _, item := range p.order(items) {
+		got = append(got, objectKey(item.Obj.GetKind(), item.Obj.GetNamespace(), item.Obj.GetName()))
+	}
+	want := []string{
+		objectKey("Namespace", "", "db"),
+		objectKey("Namespace", "", "app"),
+		objectKey("ConfigMap", "app", "settings"),
+		objectKey("Secret", "db", "creds"),
+		objectKey("StatefulSet", "db", "postgres"),
+		objectKey("Deployment", "app", "web"),
+		objectKey("Service", "app", "web"),
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("恢复顺序 = %v, 期望 %v", got, want)
+	}
+	if _, ok := p.tierEnds[objectKey("StatefulSet", "db", "postgres")]; !ok || len(p.tierEnds) != 2 {
+		t.Errorf("层级结束对象 = %v", p.tierEnds)
+	}
+	if missing := p.unmatchedHooks(items[1:]); len(missing) != 1 || missing[0] != "Namespace/db" {
+		t.Errorf("未匹配的钩子 = %v", missing)
+	}
+
+	for _, bad := range []string{
+		"tiers:\n  - name: empty\n",
+		"tiers:\n  - selector: 'a in ('\n",
+		"hooks:\n  - after: creds\n",
+		"hooks:\n  - after: Secret/db/creds\n    job: missing.yaml\n",
+	} {
+		if err := os.WriteFile(planPath, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadRestorePlan(planPath); err == nil {
+			t.Errorf("无效的恢复计划应被拒绝:\n%s", bad)
+		}
+	}
+}
+
+func TestRestorePlanHooks(t *testing.T) {
+	logOut = io.Discard
+	t.Cleanup(func() { logOut = os.Stdout })
+	interval := restoreHookPollInterval
+	restoreHookPollInterval = time.Millisecond
+	t.Cleanup(func() { restoreHookPollInterval = interval })
+
+	dir := t.TempDir()
+	job := "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\nspec:\n  template:\n    spec:\n      restartPolicy: Never\n"
+	if err := os.WriteFile(filepath.Join(dir, "migrate.yaml"), []byte(job), 0644); err != nil {
+		t.Fatal(err)
+	}
+	planPath := filepath.Join(dir, "plan.yaml")
+	plan := "hooks:\n  - after: Secret/db/creds\n    job: migrate.yaml\n  - after: StatefulSet/db/postgres\n    waitReady: true\n    timeout: 20ms\n"
+	if err := os.WriteFile(planPath, []byte(plan), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := loadRestorePlan(planPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}, meta.RESTScopeNamespace)
+
+	postgres := fakeObject("apps/v1", "StatefulSet", "db", "postgres", map[string]interface{}{
+		"spec":   map[string]interface{}{"replicas": int64(2)},
+		"status": map[string]interface{}{"readyReplicas": int64(1)},
+	})
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), postgres)
+	jobStatus := "Complete"
+	client.PrependReactor("get", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := fakeObject("batch/v1", "Job", "db", "migrate", map[string]interface{}{
+			"status": map[string]interface{}{"conditions": []interface{}{
+				map[string]interface{}{"type": jobStatus, "status": "True"},
+			}},
+		})
+		return true, obj, nil
+	})
+
+	if err := p.afterRestore(client, mapper, fakeObject("v1", "Secret", "db", "creds", nil)); err != nil {
+		t.Fatalf("Job 完成时钩子不应失败: %v", err)
+	}
+	created, err := client.Tracker().Get(schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}, "db", "migrate")
+	if err != nil {
+		t.Fatalf("钩子 Job 应创建在对象所在命名空间: %v", err)
+	}
+	if created.(*unstructured.Unstructured).GetName() != "migrate" {
+		t.Errorf("创建的 Job = %v", created)
+	}
+
+	jobStatus = "Failed"
+	if err := client.Tracker().Delete(schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}, "db", "migrate"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.afterRestore(client, mapper, fakeObject("v1", "Secret", "db", "creds", nil)); err == nil {
+		t.Error("Job 失败时钩子应返回错误")
+	}
+
+	if err := p.afterRestore(client, mapper, postgres); err == nil {
+		t.Error("StatefulSet 未就绪时应超时")
+	}
+	if err := unstructured.SetNestedField(postgres.Object, int64(2), "status", "readyReplicas"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Tracker().Update(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}, postgres, "db"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.afterRestore(client, mapper, postgres); err != nil {
+		t.Errorf("StatefulSet 就绪后钩子不应失败: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// restore 的进度事件, 格式与备份的 --progress-format jsonl 相同
+const (
+	restoreEventStarted   = "restore_started"
+	restoreEventCompleted = "restore_completed"
+	restoreEventRestored  = "resource_restored"
+	restoreEventSkipped   = "resource_skipped"
+	restoreEventFailed    = "resource_failed"
+)
+
+// resource_skipped 事件的原因
+const (
+	restoreSkipExists     = "already_exists"     // 目标集群中已存在
+	restoreSkipImmutable  = "immutable_differs"  // 已存在的不可变对象内容与备份不同
+	restoreSkipPreviously = "previously_applied" // 进度文件记录之前的运行中已应用
+	restoreSkipUnchanged  = "unchanged"          // --server-side 应用后对象无变化
+)
+
+// restoreJournal restore --state-file 的进度文件, 每行一条进度事件, 逐个对象追加写入
+// 恢复中断后以同一备份目录重新运行时, 跳过之前已创建或已存在的对象, 只处理失败与未处理的对象; 全部成功后删除
+type restoreJournal struct {
+	path    string
+	backup  string
+	file    *os.File
+	enc     *json.Encoder
+	applied map[string]string // 之前的运行中已应用的对象, 值为事件名
+	failed  map[string]string // 之前的运行中失败且尚未成功的对象, 值为错误信息
+}
+
+// openRestoreJournal 打开进度文件, 文件已存在时读取之前的进度; 文件属于其他备份目录时报错
+// 远程备份 (如 s3://) 每次下载到不同的临时目录, 以其地址标识备份
+func openRestoreJournal(path, backupDir string) (*restoreJournal, error) {
+	backup := backupDir
+	if !isRemoteBackup(backupDir) {
+		var err error
+		if backup, err = filepath.Abs(backupDir); err != nil {
+			return nil, err
+		}
+	}
+	j := &restoreJournal{path: path, backup: backup, applied: make(map[string]string), failed: make(map[string]string)}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开进度文件 '%s' 失败: %w", path, err)
+	}
+	j.file, j.enc = f, json.NewEncoder(f)
+	return j, nil
+}
+
+// load 读取之前运行写入的事件, 同一对象以最后一条事件为准
+func (j *restoreJournal) load() error {
+	f, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取进度文件 '%s' 失败: %w", j.path, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	first := true
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var ev progressEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			// 进程中断时最后一行可能不完整, 忽略该行
+			fmt.Fprintf(os.Stderr, "警告: 进度文件第 %d 行无法解析, 已忽略: %v\n", line, err)
+			continue
+		}
+		if first {
+			if ev.Event != restoreEventStarted || ev.Path != j.backup {
+				return fmt.Errorf("进度文件 '%s' 记录的是备份 %s 的恢复, 与本次的 %s 不同; 确认后删除该文件重新开始", j.path, orDash(ev.Path), j.backup)
+			}
+			first = false
+			continue
+		}
+		key := objectKey(ev.Kind, ev.Namespace, ev.Name)
+		switch ev.Event {
+		case restoreEventRestored, restoreEventSkipped:
+			if _, ok := j.applied[key]; !ok || ev.Event == restoreEventRestored {
+				j.applied[key] = ev.Event
+			}
+			delete(j.failed, key)
+		case restoreEventFailed:
+			if ev.Name != "" {
+				j.failed[key] = ev.Error
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// resumed 返回之前的运行是否已应用该对象, 以及当时是否由恢复创建 (而非已存在)
+func (j *restoreJournal) resumed(key string) (applied, created bool) {
+	if j == nil {
+		return false, false
+	}
+	event, ok := j.applied[key]
+	return ok, event == restoreEventRestored
+}
+
+// record 追加一条事件; 之前运行已应用而本次跳过的对象不再记录
+func (j *restoreJournal) record(ev progressEvent) error {
+	if j == nil || ev.Reason == restoreSkipPreviously {
+		return nil
+	}
+	if ev.Event == restoreEventStarted {
+		ev.Path = j.backup
+	}
+	ev.Time = time.Now().Format(time.RFC3339)
+	return j.enc.Encode(ev)
+}
+
+// finish 关闭进度文件, 恢复全部成功时删除
+func (j *restoreJournal) finish(succeeded bool) error {
+	if j == nil {
+		return nil
+	}
+	if err := j.file.Close(); err != nil {
+		return err
+	}
+	if !succeeded {
+		return nil
+	}
+	if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// restoreEvents 将恢复事件同时发送到 --progress-format 的事件流与 --state-file 的进度文件
+type restoreEvents struct {
+	progress *progressReporter
+	journal  *restoreJournal
+}
+
+// Emit 输出一条事件; 写入进度文件失败时警告一次并停止记录, 恢复本身继续
+func (r *restoreEvents) Emit(ev progressEvent) {
+	r.progress.Emit(ev)
+	if err := r.journal.record(ev); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 写入进度文件失败, 本次恢复中断后将无法续传: %v\n", err)
+		r.journal.file.Close()
+		r.journal = nil
+	}
+}
+
+// objectEvent 为恢复的对象创建进度事件
+func objectEvent(event string, item restoreItem) progressEvent {
+	return progressEvent{Event: event, Namespace: item.Obj.GetNamespace(), Kind: item.Obj.GetKind(), Name: item.Obj.GetName(), Path: item.Path}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRestoreJournalResume(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "restore-progress.jsonl")
+	item := func(kind, ns, name string) restoreItem {
+		return restoreItem{Obj: fakeObject("v1", kind, ns, name, nil)}
+	}
+
+	journal, err := openRestoreJournal(path, "backups/k8s-backup-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := &restoreEvents{journal: journal}
+	events.Emit(progressEvent{Event: restoreEventStarted, Path: "backups/k8s-backup-1", Count: 4})
+	events.Emit(objectEvent(restoreEventRestored, item("ConfigMap", "web", "settings")))
+	exists := objectEvent(restoreEventSkipped, item("Secret", "web", "token"))
+	exists.Reason = restoreSkipExists
+	events.Emit(exists)
+	failed := objectEvent(restoreEventFailed, item("Service", "web", "api"))
+	failed.Error = "admission webhook denied"
+	events.Emit(failed)
+	failed = objectEvent(restoreEventFailed, item("Deployment", "web", "api"))
+	failed.Error = "timeout"
+	events.Emit(failed)
+	events.Emit(objectEvent(restoreEventRestored, item("Deployment", "web", "api")))
+	if err := journal.finish(false); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟进程中断时写了一半的最后一行
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString(`{"event":"resource_restored","kind":"Ser`)
+	f.Close()
+
+	journal, err = openRestoreJournal(path, "backups/k8s-backup-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string][2]bool{
+		"ConfigMap/web/settings": {true, true},
+		"Secret/web/token":       {true, false},
+		"Deployment/web/api":     {true, true},
+		"Service/web/api":        {false, false},
+	} {
+		if applied, created := journal.resumed(key); applied != want[0] || created != want[1] {
+			t.Errorf("%s = %v, %v, 期望 %v", key, applied, created, want)
+		}
+	}
+	if len(journal.failed) != 1 || journal.failed["Service/web/api"] != "admission webhook denied" {
+		t.Errorf("失败待重试 = %v", journal.failed)
+	}
+	if err := journal.finish(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("全部成功后应删除进度文件")
+	}
+}
+
+func TestRestoreJournalOtherBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "restore-progress.jsonl")
+	journal, err := openRestoreJournal(path, "backups/k8s-backup-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	(&restoreEvents{journal: journal}).Emit(progressEvent{Event: restoreEventStarted, Path: "backups/k8s-backup-1"})
+	journal.finish(false)
+
+	if _, err := openRestoreJournal(path, "backups/k8s-backup-2"); err == nil || !strings.Contains(err.Error(), "k8s-backup-2") {
+		t.Errorf("属于其他备份的进度文件应报错, 得到 %v", err)
+	}
+}
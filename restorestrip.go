@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"backup-k8s/clean"
+)
+
+// strippedOwnership restore --strip-ownership 从单个对象上移除的 ownerReferences 与 finalizers
+type strippedOwnership struct {
+	Object     string
+	Owners     []string // Kind/名称
+	Finalizers []string
+}
+
+// stripOwnership 移除待恢复对象的 metadata.ownerReferences 与 metadata.finalizers
+// ownerReferences 以 UID 指向源集群中的对象, 在目标集群中必然不存在, 保留会使对象被垃圾回收器立即删除;
+// finalizers 可能属于目标集群未安装的控制器, 保留会使对象删除时一直停留在 Terminating
+// 旧版本或未经清理的备份中才会出现这些字段, 返回被修改的对象, 供输出报告
+func stripOwnership(items []restoreItem) []strippedOwnership {
+	var stripped []strippedOwnership
+	for _, item := range items {
+		obj := item.Obj
+		owners := obj.GetOwnerReferences()
+		finalizers := obj.GetFinalizers()
+		if len(owners) == 0 && len(finalizers) == 0 {
+			continue
+		}
+		s := strippedOwnership{Object: describeObject(obj), Finalizers: finalizers}
+		for _, owner := range owners {
+			s.Owners = append(s.Owners, owner.Kind+"/"+owner.Name)
+		}
+		obj.SetOwnerReferences(nil)
+		obj.SetFinalizers(nil)
+		stripped = append(stripped, s)
+	}
+	return stripped
+}
+
+// printStrippedOwnership 输出 stripOwnership 的报告
+func printStrippedOwnership(stripped []strippedOwnership) {
+	if len(stripped) == 0 {
+		return
+	}
+	fmt.Fprintf(logOut, "已移除 %d 个对象的 ownerReferences/finalizers:\n", len(stripped))
+	for _, s := range stripped {
+		var parts []string
+		if len(s.Owners) > 0 {
+			parts = append(parts, "owner: "+strings.Join(s.Owners, ", "))
+		}
+		if len(s.Finalizers) > 0 {
+			parts = append(parts, "finalizers: "+strings.Join(s.Finalizers, ", "))
+		}
+		fmt.Fprintf(logOut, "  - %s (%s)\n", s.Object, strings.Join(parts, "; "))
+	}
+}
+
+// stripOriginAnnotations 移除待恢复对象上 --stamp-origin 写入的来源注解, 返回被修改的对象数
+func stripOriginAnnotations(items []restoreItem) int {
+	stripped := 0
+	for _, item := range items {
+		if clean.StripOrigin(item.Obj.Object) {
+			stripped++
+		}
+	}
+	return stripped
+}
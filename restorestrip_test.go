@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"backup-k8s/clean"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStripOwnership(t *testing.T) {
+	owned := fakeObject("apps/v1", "ReplicaSet", "web", "frontend-abc", nil)
+	owned.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "frontend", UID: "uid-frontend"}})
+	finalized := fakeObject("v1", "ConfigMap", "web", "guarded", nil)
+	finalized.SetFinalizers([]string{"example.com/cleanup"})
+	plain := fakeObject("v1", "ConfigMap", "web", "plain", nil)
+
+	stripped := stripOwnership([]restoreItem{{Obj: owned}, {Obj: finalized}, {Obj: plain}})
+	if len(stripped) != 2 {
+		t.Fatalf("报告条目数 = %d, 期望 2", len(stripped))
+	}
+	if got := stripped[0].Owners; len(got) != 1 || got[0] != "Deployment/frontend" {
+		t.Errorf("owner 报告 = %v", got)
+	}
+	if got := stripped[1].Finalizers; len(got) != 1 || got[0] != "example.com/cleanup" {
+		t.Errorf("finalizer 报告 = %v", got)
+	}
+	if len(owned.GetOwnerReferences()) != 0 || len(finalized.GetFinalizers()) != 0 {
+		t.Error("ownerReferences/finalizers 未被移除")
+	}
+	if _, found := owned.Object["metadata"].(map[string]interface{})["ownerReferences"]; found {
+		t.Error("应删除空的 ownerReferences 字段")
+	}
+}
+
+func TestStripOriginAnnotations(t *testing.T) {
+	stamped := fakeObject("v1", "ConfigMap", "web", "stamped", nil)
+	stamped.SetAnnotations(map[string]string{clean.AnnotationSourceCluster: "prod", clean.AnnotationBackupTime: "2026-10-15T08:00:00Z", "team": "web"})
+	plain := fakeObject("v1", "ConfigMap", "web", "plain", nil)
+
+	if n := stripOriginAnnotations([]restoreItem{{Obj: stamped}, {Obj: plain}}); n != 1 {
+		t.Errorf("修改的对象数 = %d, 期望 1", n)
+	}
+	if got := stamped.GetAnnotations(); len(got) != 1 || got["team"] != "web" {
+		t.Errorf("annotations = %v, 应只保留非来源注解", got)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// 恢复目标集群也可通过环境变量指定, 命令行参数优先
+// SERVER 与 TOKEN 用于 CI 中不落盘地传入灾备集群凭据, 令牌不提供命令行参数以免出现在进程列表中
+const (
+	envTargetKubeconfig = "K8S_BACK_TARGET_KUBECONFIG"
+	envTargetContext    = "K8S_BACK_TARGET_CONTEXT"
+	envTargetServer     = "K8S_BACK_TARGET_SERVER"
+	envTargetToken      = "K8S_BACK_TARGET_TOKEN"
+	envTargetCAFile     = "K8S_BACK_TARGET_CA_FILE"
+)
+
+// restoreTarget 恢复的目标集群, 与备份时使用的 kubeconfig 上下文相互独立
+type restoreTarget struct {
+	kubeconfig string
+	context    string
+	server     string // 非空时直接以 server + token 连接, 不读取 kubeconfig
+	token      string
+	caFile     string
+}
+
+// resolveRestoreTarget 合并 --kubeconfig, --target-kubeconfig, --target-context 与环境变量
+// --kubeconfig 为旧参数, 与 --target-kubeconfig 含义相同, 不能同时指定
+func resolveRestoreTarget(kubeconfig, targetKubeconfig, targetContext string, getenv func(string) string) (restoreTarget, error) {
+	if kubeconfig != "" && targetKubeconfig != "" {
+		return restoreTarget{}, fmt.Errorf("--kubeconfig 与 --target-kubeconfig 不能同时指定")
+	}
+	t := restoreTarget{
+		kubeconfig: firstNonEmpty(targetKubeconfig, kubeconfig, getenv(envTargetKubeconfig)),
+		context:    firstNonEmpty(targetContext, getenv(envTargetContext)),
+		server:     getenv(envTargetServer),
+		token:      getenv(envTargetToken),
+		caFile:     getenv(envTargetCAFile),
+	}
+	if t.server == "" {
+		if t.token != "" || t.caFile != "" {
+			return restoreTarget{}, fmt.Errorf("设置了 %s/%s 但未设置 %s", envTargetToken, envTargetCAFile, envTargetServer)
+		}
+		return t, nil
+	}
+	if t.token == "" {
+		return restoreTarget{}, fmt.Errorf("设置了 %s 但未设置 %s", envTargetServer, envTargetToken)
+	}
+	if targetKubeconfig != "" || kubeconfig != "" || targetContext != "" {
+		return restoreTarget{}, fmt.Errorf("环境变量 %s 不能与 --kubeconfig/--target-kubeconfig/--target-context 同时使用", envTargetServer)
+	}
+	t.kubeconfig, t.context = "", ""
+	return t, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// config 返回目标集群的客户端配置
+func (t restoreTarget) config() (*rest.Config, error) {
+	if t.server == "" {
+		return loadClientConfig(t.kubeconfig, t.context)
+	}
+	return &rest.Config{
+		Host:            t.server,
+		BearerToken:     t.token,
+		TLSClientConfig: rest.TLSClientConfig{CAFile: t.caFile},
+	}, nil
+}
+
+// describe 返回目标集群的可读描述, 恢复开始前输出, 便于确认没有连错集群
+func (t restoreTarget) describe(config *rest.Config) string {
+	switch {
+	case t.server != "":
+		return fmt.Sprintf("%s (凭据来自环境变量 %s)", t.server, envTargetToken)
+	case t.context != "":
+		return fmt.Sprintf("上下文 %s (%s)", t.context, config.Host)
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = t.kubeconfig
+	if raw, err := loadingRules.Load(); err == nil && raw.CurrentContext != "" {
+		return fmt.Sprintf("当前上下文 %s (%s)", raw.CurrentContext, config.Host)
+	}
+	return config.Host
+}
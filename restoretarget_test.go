@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveRestoreTarget(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(key string) string { return vars[key] }
+	}
+
+	target, err := resolveRestoreTarget("", "", "prod-b", env(map[string]string{envTargetContext: "prod-a", envTargetKubeconfig: "/etc/dr/kubeconfig"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.context != "prod-b" || target.kubeconfig != "/etc/dr/kubeconfig" {
+		t.Errorf("命令行参数应优先于环境变量: %+v", target)
+	}
+	if _, err := resolveRestoreTarget("a", "b", "", env(nil)); err == nil {
+		t.Error("--kubeconfig 与 --target-kubeconfig 同时指定应返回错误")
+	}
+
+	target, err = resolveRestoreTarget("", "", "", env(map[string]string{envTargetServer: "https://dr.example.com:6443", envTargetToken: "secret"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := target.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "https://dr.example.com:6443" || config.BearerToken != "secret" {
+		t.Errorf("令牌连接配置 = %+v", config)
+	}
+	if _, err := resolveRestoreTarget("", "", "", env(map[string]string{envTargetServer: "https://dr.example.com"})); err == nil {
+		t.Error("只设置 server 未设置令牌应返回错误")
+	}
+	if _, err := resolveRestoreTarget("", "", "prod-b", env(map[string]string{envTargetServer: "https://dr.example.com", envTargetToken: "secret"})); err == nil {
+		t.Error("令牌连接不能与 --target-context 同时使用")
+	}
+}
+
+func TestRestoreTargetContext(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	data := `apiVersion: v1
+kind: Config
+current-context: prod-a
+clusters:
+- name: a
+  cluster: {server: "https://a.example.com"}
+- name: b
+  cluster: {server: "https://b.example.com"}
+contexts:
+- name: prod-a
+  context: {cluster: a, user: u}
+- name: prod-b
+  context: {cluster: b, user: u}
+users:
+- name: u
+  user: {token: t}
+`
+	if err := os.WriteFile(kubeconfig, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		context, host, desc string
+	}{
+		{"", "https://a.example.com", "当前上下文 prod-a (https://a.example.com)"},
+		{"prod-b", "https://b.example.com", "上下文 prod-b (https://b.example.com)"},
+	} {
+		target, err := resolveRestoreTarget("", kubeconfig, tc.context, func(string) string { return "" })
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, err := target.config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Host != tc.host || target.describe(config) != tc.desc {
+			t.Errorf("上下文 %q: host = %s, 描述 = %s", tc.context, config.Host, target.describe(config))
+		}
+	}
+}
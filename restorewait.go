@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// restoreWaitKinds restore --wait 等待就绪的工作负载类型, 与 kubectl rollout status 支持的类型相同
+var restoreWaitKinds = map[string]bool{"Deployment": true, "StatefulSet": true, "DaemonSet": true}
+
+// defaultRestoreWaitTimeout restore --wait-timeout 的默认值
+const defaultRestoreWaitTimeout = 5 * time.Minute
+
+// workloadHealth 单个工作负载的等待结果
+type workloadHealth struct {
+	desc    string
+	ready   bool
+	failed  bool   // 工作负载报告了失败 (如超过 progressDeadlineSeconds), 而非等待超时
+	status  string // 最后一次读取时的滚动更新状态, 如 "1/3 个副本可用"
+	err     error
+	elapsed time.Duration
+}
+
+// waitForWorkloads 依次等待 objs 中的工作负载完成滚动更新 (同 kubectl rollout status), 每个对象的等待上限为 timeout
+// 前面的对象等待期间后面的对象也在启动, 因此总耗时通常远小于数量乘以 timeout
+func waitForWorkloads(client dynamic.Interface, mapper meta.RESTMapper, objs []*unstructured.Unstructured, timeout time.Duration) []workloadHealth {
+	var results []workloadHealth
+	seen := make(map[string]bool)
+	for _, obj := range objs {
+		key := objectKey(obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		if !restoreWaitKinds[obj.GetKind()] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result := workloadHealth{desc: describeObject(obj)}
+		fmt.Fprintf(logOut, "  等待 %s 就绪...\n", result.desc)
+		start := time.Now()
+		result.err = waitForObject(client, mapper, obj, timeout, func(current *unstructured.Unstructured) (bool, error) {
+			done, status, err := rolloutStatus(current)
+			result.status, result.failed = status, err != nil
+			return done, err
+		})
+		result.ready, result.elapsed = result.err == nil, time.Since(start).Round(time.Second)
+		if result.ready {
+			fmt.Fprintf(logOut, "  ✓ %s 已就绪 (%s)\n", result.desc, result.elapsed)
+		} else {
+			fmt.Fprintf(logOut, "  ✗ %s 未就绪: %v\n", result.desc, result.err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// rolloutStatus 判断工作负载的滚动更新是否完成, 返回当前状态的描述; Deployment 超过 progressDeadlineSeconds 时返回错误
+// 判断条件与 kubectl rollout status 相同: 控制器已观察到最新的 generation, 且全部副本已更新并可用
+func rolloutStatus(obj *unstructured.Unstructured) (bool, string, error) {
+	generation := obj.GetGeneration()
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if generation > 0 && observed < generation {
+		return false, "等待控制器处理最新的规约", nil
+	}
+	replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		replicas = 1
+	}
+	status := func(field string) int64 {
+		v, _, _ := unstructured.NestedInt64(obj.Object, "status", field)
+		return v
+	}
+
+	switch obj.GetKind() {
+	case "Deployment":
+		if conditionReason(obj, "Progressing") == "ProgressDeadlineExceeded" {
+			return false, "超过 progressDeadlineSeconds", fmt.Errorf("滚动更新超过 progressDeadlineSeconds 仍未完成")
+		}
+		updated, available := status("updatedReplicas"), status("availableReplicas")
+		switch {
+		case updated < replicas:
+			return false, fmt.Sprintf("%d/%d 个副本已更新", updated, replicas), nil
+		case status("replicas") > updated:
+			return false, fmt.Sprintf("%d 个旧副本等待终止", status("replicas")-updated), nil
+		case available < updated:
+			return false, fmt.Sprintf("%d/%d 个副本可用", available, updated), nil
+		}
+		return true, fmt.Sprintf("%d/%d 个副本可用", available, replicas), nil
+	case "StatefulSet":
+		ready := status("readyReplicas")
+		if ready < replicas {
+			return false, fmt.Sprintf("%d/%d 个副本就绪", ready, replicas), nil
+		}
+		current, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+		update, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+		if strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type"); strategy != "OnDelete" && update != "" && current != update {
+			return false, fmt.Sprintf("%d/%d 个副本已更新到最新版本", status("updatedReplicas"), replicas), nil
+		}
+		return true, fmt.Sprintf("%d/%d 个副本就绪", ready, replicas), nil
+	case "DaemonSet":
+		desired := status("desiredNumberScheduled")
+		if updated := status("updatedNumberScheduled"); updated < desired {
+			return false, fmt.Sprintf("%d/%d 个节点已更新", updated, desired), nil
+		}
+		available := status("numberAvailable")
+		if available < desired {
+			return false, fmt.Sprintf("%d/%d 个节点可用", available, desired), nil
+		}
+		return true, fmt.Sprintf("%d/%d 个节点可用", available, desired), nil
+	}
+	return true, "", nil
+}
+
+// conditionReason 返回 status.conditions 中指定类型条件的 reason
+func conditionReason(obj *unstructured.Unstructured, condType string) string {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range mapsOf(conditions) {
+		if c["type"] == condType {
+			reason, _ := c["reason"].(string)
+			return reason
+		}
+	}
+	return ""
+}
+
+// printWorkloadHealth 输出工作负载的健康汇总, 返回未就绪的数量
+func printWorkloadHealth(w io.Writer, results []workloadHealth) int {
+	notReady := 0
+	for _, r := range results {
+		if !r.ready {
+			notReady++
+		}
+	}
+	fmt.Fprintf(w, "\n工作负载健康汇总: 就绪 %d 个, 未就绪 %d 个\n", len(results)-notReady, notReady)
+	if len(results) == 0 {
+		return 0
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  对象\t状态\t副本\t耗时")
+	for _, r := range results {
+		state := "就绪"
+		if !r.ready {
+			state = "未就绪"
+			if r.failed {
+				state = "失败"
+			}
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", r.desc, state, orDash(r.status), r.elapsed)
+	}
+	tw.Flush()
+	return notReady
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestRolloutStatus(t *testing.T) {
+	deployment := func(generation int64, status map[string]interface{}) *unstructured.Unstructured {
+		obj := fakeObject("apps/v1", "Deployment", "web", "api", map[string]interface{}{
+			"spec":   map[string]interface{}{"replicas": int64(3)},
+			"status": status,
+		})
+		obj.SetGeneration(generation)
+		return obj
+	}
+	cases := []struct {
+		name    string
+		obj     *unstructured.Unstructured
+		done    bool
+		status  string
+		wantErr bool
+	}{
+		{name: "generation not observed", obj: deployment(2, map[string]interface{}{"observedGeneration": int64(1)}), status: "等待控制器处理最新的规约"},
+		{name: "updating", obj: deployment(1, map[string]interface{}{"observedGeneration": int64(1), "updatedReplicas": int64(1)}), status: "1/3 个副本已更新"},
+		{name: "old replicas", obj: deployment(1, map[string]interface{}{"observedGeneration": int64(1), "updatedReplicas": int64(3), "replicas": int64(4)}), status: "1 个旧副本等待终止"},
+		{name: "available", obj: deployment(1, map[string]interface{}{"observedGeneration": int64(1), "updatedReplicas": int64(3), "replicas": int64(3), "availableReplicas": int64(3)}), done: true, status: "3/3 个副本可用"},
+		{name: "deadline exceeded", obj: deployment(1, map[string]interface{}{"observedGeneration": int64(1), "conditions": []interface{}{
+			map[string]interface{}{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded"},
+		}}), status: "超过 progressDeadlineSeconds", wantErr: true},
+		{name: "statefulset revision", obj: fakeObject("apps/v1", "StatefulSet", "db", "pg", map[string]interface{}{
+			"spec":   map[string]interface{}{"replicas": int64(2)},
+			"status": map[string]interface{}{"readyReplicas": int64(2), "updatedReplicas": int64(1), "currentRevision": "pg-1", "updateRevision": "pg-2"},
+		}), status: "1/2 个副本已更新到最新版本"},
+		{name: "daemonset", obj: fakeObject("apps/v1", "DaemonSet", "kube-system", "agent", map[string]interface{}{
+			"status": map[string]interface{}{"desiredNumberScheduled": int64(3), "updatedNumberScheduled": int64(3), "numberAvailable": int64(3)},
+		}), done: true, status: "3/3 个节点可用"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			done, status, err := rolloutStatus(tc.obj)
+			if done != tc.done || status != tc.status || (err != nil) != tc.wantErr {
+				t.Errorf("rolloutStatus = %v, %q, %v; 期望 %v, %q, 错误 %v", done, status, err, tc.done, tc.status, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestWaitForWorkloads(t *testing.T) {
+	logOut = io.Discard
+	t.Cleanup(func() { logOut = os.Stdout })
+	interval := restoreHookPollInterval
+	restoreHookPollInterval = time.Millisecond
+	t.Cleanup(func() { restoreHookPollInterval = interval })
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	ready := fakeObject("apps/v1", "Deployment", "web", "api", map[string]interface{}{
+		"spec":   map[string]interface{}{"replicas": int64(1)},
+		"status": map[string]interface{}{"updatedReplicas": int64(1), "replicas": int64(1), "availableReplicas": int64(1)},
+	})
+	pending := fakeObject("apps/v1", "Deployment", "web", "worker", map[string]interface{}{
+		"spec": map[string]interface{}{"replicas": int64(2)},
+	})
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), ready, pending)
+
+	objs := []*unstructured.Unstructured{ready, pending, ready, fakeObject("v1", "ConfigMap", "web", "settings", nil)}
+	results := waitForWorkloads(client, mapper, objs, 20*time.Millisecond)
+	if len(results) != 2 || !results[0].ready || results[1].ready || results[1].failed {
+		t.Fatalf("等待结果 = %+v", results)
+	}
+
+	var out bytes.Buffer
+	if notReady := printWorkloadHealth(&out, results); notReady != 1 {
+		t.Errorf("未就绪数量 = %d, 期望 1", notReady)
+	}
+	for _, want := range []string{"就绪 1 个, 未就绪 1 个", "Deployment web/worker", "0/2 个副本已更新"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("健康汇总缺少 %q:\n%s", want, out.String())
+		}
+	}
+}
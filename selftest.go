@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"backup-k8s/clean"
+	"github.com/spf13/pflag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// selfTestLabel 自检创建的命名空间与对象上的标签, 清理失败时可据此手动删除
+const selfTestLabel = "k8s-back.io/self-test"
+
+// selfTestTypes 自检备份与恢复的资源类型, 与 selfTestObjects 对应
+var selfTestTypes = []string{"configmaps", "secrets", "services", "deployments"}
+
+var namespacesGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// selfTestOptions self-test 子命令的参数
+type selfTestOptions struct {
+	kubeconfig  string
+	kubeContext string
+	keep        bool
+}
+
+// runSelfTest 实现 self-test 子命令: 在临时命名空间中创建示例对象, 备份后恢复到另一个临时命名空间并比较结果,
+// 用于升级工具或集群后一条命令确认备份与恢复端到端可用
+func runSelfTest(args []string) {
+	var opts selfTestOptions
+	fs := pflag.NewFlagSet("self-test", pflag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: k8s-backup self-test [参数]\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.kubeconfig, "kubeconfig", "", "kubeconfig文件路径 (默认使用~/.kube/config)")
+	fs.StringVar(&opts.kubeContext, "context", "", "kubeconfig 中的上下文名称 (默认使用当前上下文)")
+	fs.BoolVar(&opts.keep, "keep", false, "结束后保留临时命名空间与备份目录, 便于排查")
+	fs.Parse(args)
+
+	config, err := loadClientConfig(opts.kubeconfig, opts.kubeContext)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 创建标准客户端失败: %v\n", err)
+		os.Exit(1)
+	}
+	dynamicClient, mapper, err := newApplyClients(opts.kubeconfig, opts.kubeContext, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	mismatches, err := selfTest(clientset, dynamicClient, mapper, time.Now().Format("20060102-150405"), opts.keep)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 自检失败: %v\n", err)
+		os.Exit(1)
+	}
+	if len(mismatches) > 0 {
+		fmt.Fprintln(os.Stderr, "\n自检失败, 恢复结果与源对象不一致:")
+		for _, m := range mismatches {
+			fmt.Fprintf(os.Stderr, "  ✗ %s\n", m)
+		}
+		os.Exit(1)
+	}
+	fmt.Fprintln(logOut, "\n自检通过: 恢复结果与源对象一致")
+}
+
+// selfTest 以 backup 与 restore 共用的流程执行一次端到端自检, 返回恢复结果与源对象 (清理后) 不一致之处; 无法完成自检 (如无权限创建命名空间) 时返回错误
+func selfTest(clientset kubernetes.Interface, client dynamic.Interface, mapper meta.RESTMapper, suffix string, keep bool) ([]string, error) {
+	src := "k8s-back-selftest-" + suffix + "-src"
+	dst := "k8s-back-selftest-" + suffix + "-dst"
+	dir, err := os.MkdirTemp("", "k8s-back-selftest-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if keep {
+			fmt.Fprintf(logOut, "\n已保留命名空间 %s, %s 与备份目录 %s\n", src, dst, dir)
+			return
+		}
+		fmt.Fprintln(logOut, "\n[清理]")
+		os.RemoveAll(dir)
+		for _, ns := range []string{src, dst} {
+			err := client.Resource(namespacesGVR).Delete(context.TODO(), ns, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				fmt.Fprintf(os.Stderr, "警告: 删除命名空间 %s 失败, 请手动删除 (标签 %s=true): %v\n", ns, selfTestLabel, err)
+			}
+		}
+	}()
+
+	fmt.Fprintf(logOut, "[1/4] 在命名空间 %s 中创建示例对象\n", src)
+	samples := selfTestObjects(src)
+	for _, obj := range append([]*unstructured.Unstructured{selfTestNamespace(src)}, samples...) {
+		resClient, err := resourceClientFor(client, mapper, obj)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := resClient.Create(context.TODO(), obj, metav1.CreateOptions{}); err != nil {
+			return nil, fmt.Errorf("创建 %s 失败: %w", describeObject(obj), err)
+		}
+	}
+
+	fmt.Fprintf(logOut, "[2/4] 备份命名空间 %s 到 %s\n", src, dir)
+	partitions := newPartitionSet(dir, "backup", "")
+	run := &Backupper{
+		clientset:     clientset,
+		dynamicClient: client,
+		resourceTypes: selfTestTypes,
+		allInOne:      allInOneOff,
+		cleanOpts:     clean.Options{LastApplied: clean.LastAppliedStrip},
+		progress:      &progressReporter{},
+		partitions:    partitions,
+		sink:          newFileSink(1, fsyncNone),
+	}
+	run.backupNamespaces([]string{src}, nil, nil, func(string) {})
+	run.sink.close()
+	partition, err := partitions.get("")
+	if err != nil {
+		return nil, err
+	}
+	if len(partition.Skipped) > 0 {
+		s := partition.Skipped[0]
+		return nil, fmt.Errorf("备份跳过了 %s (命名空间 %s): %s %s", s.Kind, s.Namespace, s.Reason, s.Detail)
+	}
+	partition.writeIndex(backupMetadata{
+		Version:        version,
+		Timestamp:      time.Now().Format(time.RFC3339),
+		Namespaces:     partition.Namespaces,
+		ResourceTypes:  selfTestTypes,
+		TotalResources: partition.Total,
+	})
+
+	fmt.Fprintf(logOut, "[3/4] 恢复到命名空间 %s\n", dst)
+	backupMeta, err := loadBackupMetadata(partition.Root)
+	if err != nil {
+		return nil, fmt.Errorf("读取备份元数据失败: %w", err)
+	}
+	index, err := loadBackupIndex(partition.Root)
+	if err != nil {
+		return nil, fmt.Errorf("读取备份索引失败: %w", err)
+	}
+	items, err := loadRestoreItems(partition.Root)
+	if err != nil {
+		return nil, fmt.Errorf("读取备份清单失败: %w", err)
+	}
+	for _, item := range items {
+		if item.Obj.GetKind() == "Namespace" {
+			item.Obj.SetName(dst)
+			item.Obj.SetLabels(map[string]string{selfTestLabel: "true"})
+		} else {
+			item.Obj.SetNamespace(dst)
+		}
+	}
+	opts := restoreOptions{restoreSet: defaultRestoreSet, onConflict: onConflictSkip, immutable: immutableConflictSkip, immFields: immutableFieldsFail}
+	res := applyRestoreItems(client, mapper, items, opts, filepath.Base(partition.Root), backupMeta, index, nil, newApplyThrottle(0, 0, 0), &restoreEvents{})
+	if res.failed > 0 {
+		return nil, fmt.Errorf("恢复失败 %d 个对象", res.failed)
+	}
+
+	fmt.Fprintln(logOut, "[4/4] 比较恢复结果与源对象")
+	var mismatches []string
+	for _, sample := range samples {
+		desc := fmt.Sprintf("%s %s", sample.GetKind(), sample.GetName())
+		srcClient, err := resourceClientFor(client, mapper, sample)
+		if err != nil {
+			return nil, err
+		}
+		source, err := srcClient.Get(context.TODO(), sample.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("读取源对象 %s 失败: %w", desc, err)
+		}
+		target := sample.DeepCopy()
+		target.SetNamespace(dst)
+		dstClient, err := resourceClientFor(client, mapper, target)
+		if err != nil {
+			return nil, err
+		}
+		restored, err := dstClient.Get(context.TODO(), sample.GetName(), metav1.GetOptions{})
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s: 读取恢复结果失败: %v", desc, err))
+			continue
+		}
+		// 恢复集合标签由 restore 添加, 不属于备份内容
+		actual := clean.Resource(restored.Object, run.cleanOpts)
+		unstructured.RemoveNestedField(actual, "metadata", "labels", labelRestoreSet)
+		fields := compareRestored(clean.Resource(source.Object, run.cleanOpts), actual)
+		for _, field := range fields {
+			mismatches = append(mismatches, fmt.Sprintf("%s: 字段 %s 不一致", desc, field))
+		}
+		if len(fields) == 0 {
+			fmt.Fprintf(logOut, "  ✓ %s\n", desc)
+		}
+	}
+	return mismatches, nil
+}
+
+// selfTestNamespace 返回带自检标签的命名空间
+func selfTestNamespace(name string) *unstructured.Unstructured {
+	ns := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Namespace"}}
+	ns.SetName(name)
+	ns.SetLabels(map[string]string{selfTestLabel: "true"})
+	return ns
+}
+
+// selfTestObjects 返回自检使用的示例对象, Deployment 为 0 副本, 不依赖镜像拉取与调度
+func selfTestObjects(namespace string) []*unstructured.Unstructured {
+	labels := map[string]interface{}{"app": "k8s-back-selftest"}
+	objects := []*unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "v1", "kind": "ConfigMap",
+			"metadata": map[string]interface{}{"name": "selftest-config"},
+			"data":     map[string]interface{}{"app.conf": "mode=self-test\nretries=3\n"},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1", "kind": "Secret",
+			"metadata": map[string]interface{}{"name": "selftest-secret"},
+			"type":     "Opaque",
+			"data":     map[string]interface{}{"password": base64.StdEncoding.EncodeToString([]byte("k8s-back-self-test"))},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1", "kind": "Service",
+			"metadata": map[string]interface{}{"name": "selftest-svc"},
+			"spec": map[string]interface{}{
+				"selector": labels,
+				"ports": []interface{}{map[string]interface{}{
+					"name": "http", "port": int64(80), "targetPort": int64(8080), "protocol": "TCP",
+				}},
+			},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "apps/v1", "kind": "Deployment",
+			"metadata": map[string]interface{}{"name": "selftest-app"},
+			"spec": map[string]interface{}{
+				"replicas": int64(0),
+				"selector": map[string]interface{}{"matchLabels": labels},
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{"labels": labels},
+					"spec": map[string]interface{}{
+						"containers": []interface{}{map[string]interface{}{
+							"name": "app", "image": "registry.k8s.io/pause:3.9",
+						}},
+					},
+				},
+			},
+		}},
+	}
+	for _, obj := range objects {
+		obj.SetNamespace(namespace)
+		obj.SetLabels(map[string]string{selfTestLabel: "true"})
+	}
+	return objects
+}
+
+// compareRestored 比较清理后的源对象与恢复结果, 返回不一致的字段 (顶层字段及 metadata 的标签与注解)
+func compareRestored(expected, actual map[string]interface{}) []string {
+	fields := make(map[string]struct{})
+	for _, obj := range []map[string]interface{}{expected, actual} {
+		for key := range obj {
+			if key != "metadata" && key != "status" && !jsonEqual(expected[key], actual[key]) {
+				fields[key] = struct{}{}
+			}
+		}
+	}
+	expectedMeta, _ := expected["metadata"].(map[string]interface{})
+	actualMeta, _ := actual["metadata"].(map[string]interface{})
+	for _, key := range []string{"labels", "annotations"} {
+		if !jsonEqual(expectedMeta[key], actualMeta[key]) {
+			fields["metadata."+key] = struct{}{}
+		}
+	}
+	return sortedKeys(fields)
+}
+
+// jsonEqual 按 JSON 序列化结果比较, 忽略 YAML 与 JSON 解码产生的数值类型差异
+func jsonEqual(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
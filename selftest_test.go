@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestSelfTest(t *testing.T) {
+	run, _ := newFakeBackupper(t, nil, nil)
+	client := run.dynamicClient.(*dynamicfake.FakeDynamicClient)
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	for _, resType := range selfTestTypes {
+		info := resourceMap[resType]
+		mapper.Add(info.GVR.GroupVersion().WithKind(info.Kind), meta.RESTScopeNamespace)
+	}
+
+	mismatches, err := selfTest(run.clientset, client, mapper, "test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) > 0 {
+		t.Errorf("不应有不一致: %v", mismatches)
+	}
+	restored, err := client.Resource(resourceMap["configmaps"].GVR).Namespace("k8s-back-selftest-test-dst").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.Items) != 1 {
+		t.Fatalf("恢复的 ConfigMap 数 = %d, 期望 1", len(restored.Items))
+	}
+	if set := restored.Items[0].GetLabels()[labelRestoreSet]; set != defaultRestoreSet {
+		t.Errorf("恢复的对象应带恢复集合标签 %s, 实际 %q", defaultRestoreSet, set)
+	}
+	if _, err := client.Resource(namespacesGVR).Get(context.TODO(), "k8s-back-selftest-test-src", metav1.GetOptions{}); err == nil {
+		t.Error("自检结束后应删除临时命名空间")
+	}
+}
+
+func TestCompareRestored(t *testing.T) {
+	expected := map[string]interface{}{
+		"kind":     "ConfigMap",
+		"metadata": map[string]interface{}{"name": "a", "labels": map[string]interface{}{"app": "x"}},
+		"data":     map[string]interface{}{"k": "v"},
+		"spec":     map[string]interface{}{"port": int64(80)},
+	}
+	same := map[string]interface{}{
+		"kind":     "ConfigMap",
+		"metadata": map[string]interface{}{"name": "a", "labels": map[string]interface{}{"app": "x"}, "uid": "new"},
+		"data":     map[string]interface{}{"k": "v"},
+		"spec":     map[string]interface{}{"port": float64(80)},
+	}
+	if fields := compareRestored(expected, same); len(fields) != 0 {
+		t.Errorf("等价对象不应有差异: %v", fields)
+	}
+	changed := map[string]interface{}{
+		"kind":     "ConfigMap",
+		"metadata": map[string]interface{}{"name": "a"},
+		"data":     map[string]interface{}{"k": "w"},
+		"spec":     map[string]interface{}{"port": int64(80)},
+		"extra":    true,
+	}
+	got := compareRestored(expected, changed)
+	want := []string{"data", "extra", "metadata.labels"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("差异字段 = %v, 期望 %v", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// defaultFieldManager restore --server-side 默认使用的字段管理者名称
+const defaultFieldManager = "k8s-back"
+
+// conflictServerSide --server-side 时已存在对象的处理方式, 用于预览输出与进度事件
+const conflictServerSide = "server-side"
+
+// serverSideApply 的结果
+type applyOutcome int
+
+const (
+	applyCreated    applyOutcome = iota // 目标集群中不存在, 已创建
+	applyConfigured                     // 已存在, 本字段管理者持有的字段有变化
+	applyUnchanged                      // 已存在且与上一次应用相同 (resourceVersion 未变), 重复恢复时的常见情况
+)
+
+// serverSideApply 以 server-side apply 恢复对象: 只声明备份中出现的字段的所有权, 不覆盖由控制器等其他字段管理者持有的字段,
+// 同一字段管理者重复应用相同内容时不产生修改; 与其他管理者的字段冲突时返回错误, force 为 true 时接管这些字段
+// 应用前读取一次现有对象, 用于区分创建, 更新与无变化
+func serverSideApply(resClient dynamic.ResourceInterface, obj *unstructured.Unstructured, fieldManager string, force bool) (*unstructured.Unstructured, applyOutcome, error) {
+	outcome := applyCreated
+	var before string
+	existing, err := resClient.Get(context.TODO(), obj.GetName(), metav1.GetOptions{})
+	switch {
+	case err == nil:
+		outcome, before = applyConfigured, existing.GetResourceVersion()
+	case !apierrors.IsNotFound(err):
+		return nil, outcome, fmt.Errorf("读取现有对象失败: %w", err)
+	}
+
+	desired := obj.DeepCopy()
+	desired.SetResourceVersion("")
+	desired.SetManagedFields(nil)
+	result, err := resClient.Apply(context.TODO(), obj.GetName(), desired, metav1.ApplyOptions{FieldManager: fieldManager, Force: force})
+	if err != nil {
+		if apierrors.IsConflict(err) && !force {
+			return nil, outcome, fmt.Errorf("与其他字段管理者冲突 (使用 --force-conflicts 接管这些字段): %w", err)
+		}
+		return nil, outcome, err
+	}
+	if outcome == applyConfigured && before != "" && result.GetResourceVersion() == before {
+		outcome = applyUnchanged
+	}
+	return result, outcome, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestServerSideApply(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	desired := fakeObject("v1", "ConfigMap", "web", "settings", map[string]interface{}{"data": map[string]interface{}{"mode": "prod"}})
+
+	// 目标集群中不存在: fake 客户端的 apply 不会创建对象, 以 reactor 模拟 API server 的行为
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	var patchType types.PatchType
+	client.PrependReactor("patch", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchActionImpl)
+		patchType = patch.GetPatchType()
+		created := &unstructured.Unstructured{}
+		if err := created.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, err
+		}
+		if created.GetResourceVersion() != "" {
+			t.Errorf("应用前应清除 resourceVersion, 实际为 %q", created.GetResourceVersion())
+		}
+		created.SetResourceVersion("2")
+		return true, created, nil
+	})
+	_, outcome, err := serverSideApply(client.Resource(gvr).Namespace("web"), desired, "restorer", false)
+	if err != nil || outcome != applyCreated {
+		t.Fatalf("outcome = %v, err = %v, 期望 applyCreated", outcome, err)
+	}
+	if patchType != types.ApplyPatchType {
+		t.Errorf("patch 类型 = %q, 期望 %q", patchType, types.ApplyPatchType)
+	}
+
+	// 已存在: resourceVersion 未变视为无变化
+	existing := fakeObject("v1", "ConfigMap", "web", "settings", map[string]interface{}{"data": map[string]interface{}{"mode": "prod"}})
+	client = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), existing)
+	client.PrependReactor("patch", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, existing.DeepCopy(), nil
+	})
+	_, outcome, err = serverSideApply(client.Resource(gvr).Namespace("web"), desired, defaultFieldManager, false)
+	if err != nil || outcome != applyUnchanged {
+		t.Errorf("outcome = %v, err = %v, 期望 applyUnchanged", outcome, err)
+	}
+
+	// 与其他字段管理者冲突
+	client = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), existing)
+	client.PrependReactor("patch", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewConflict(gvr.GroupResource(), "settings", nil)
+	})
+	_, outcome, err = serverSideApply(client.Resource(gvr).Namespace("web"), desired, defaultFieldManager, false)
+	if err == nil || outcome != applyConfigured || !strings.Contains(err.Error(), "--force-conflicts") {
+		t.Errorf("outcome = %v, err = %v, 期望提示 --force-conflicts 的冲突错误", outcome, err)
+	}
+}
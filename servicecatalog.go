@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// catalog-export 的输出格式与默认文件名, 默认写入备份目录
+const (
+	catalogFormatBackstage = "backstage"
+	catalogFormatJSON      = "json"
+
+	catalogInfoFileName     = "catalog-info.yaml"
+	catalogServicesFileName = "services.json"
+
+	labelPartOf = "app.kubernetes.io/part-of"
+	labelName   = "app.kubernetes.io/name"
+)
+
+// catalogOptions catalog-export 子命令的参数
+type catalogOptions struct {
+	format       string
+	output       string
+	ownerLabel   string
+	defaultOwner string
+	lifecycle    string
+}
+
+// catalogComponent 一个长期运行的工作负载及暴露它的 Service
+type catalogComponent struct {
+	Namespace string            `json:"namespace"`
+	Kind      string            `json:"kind"`
+	Name      string            `json:"name"`
+	Type      string            `json:"type"`             // service (有 Service 选中), cronjob 或 worker
+	Owner     string            `json:"owner"`            // 工作负载或命名空间的 owner 标签, 均未设置时为 --default-owner
+	System    string            `json:"system,omitempty"` // app.kubernetes.io/part-of 标签
+	Images    []string          `json:"images"`
+	Services  []string          `json:"services,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// catalogServices services.json 的内容
+type catalogServices struct {
+	Backup     string             `json:"backup"`
+	Components []catalogComponent `json:"components"`
+}
+
+// runCatalogExport 实现 catalog-export 子命令: 从备份中汇总工作负载, 镜像, Service 与归属标签,
+// 生成 Backstage catalog-info.yaml 或通用的 services.json, 供平台服务目录导入
+func runCatalogExport(args []string) {
+	var opts catalogOptions
+	fs := pflag.NewFlagSet("catalog-export", pflag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: k8s-backup catalog-export [参数] <备份目录>\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.format, "format", catalogFormatBackstage, "输出格式: backstage (catalog-info.yaml) 或 json (services.json)")
+	fs.StringVarP(&opts.output, "output", "o", "", "输出文件, '-' 表示标准输出 (默认写入备份目录下的 catalog-info.yaml 或 services.json)")
+	fs.StringVar(&opts.ownerLabel, "owner-label", "owner", "记录负责团队的标签, 工作负载未设置时使用所在命名空间的标签")
+	fs.StringVar(&opts.defaultOwner, "default-owner", "unknown", "工作负载与命名空间均未设置 owner 标签时的负责人")
+	fs.StringVar(&opts.lifecycle, "lifecycle", "production", "Backstage 组件的 spec.lifecycle")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if opts.format != catalogFormatBackstage && opts.format != catalogFormatJSON {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的格式 '%s' (可选: %s, %s)\n", opts.format, catalogFormatBackstage, catalogFormatJSON)
+		os.Exit(2)
+	}
+	backupDir := fs.Arg(0)
+	items, err := loadRestoreItems(backupDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 读取备份失败: %v\n", err)
+		os.Exit(1)
+	}
+	components := collectCatalogComponents(items, opts.ownerLabel, opts.defaultOwner)
+
+	var data []byte
+	if opts.format == catalogFormatJSON {
+		data, err = json.MarshalIndent(catalogServices{Backup: filepath.Base(filepath.Clean(backupDir)), Components: components}, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = backstageCatalog(components, opts.lifecycle)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 生成服务目录失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	output := opts.output
+	if output == "-" {
+		os.Stdout.Write(data)
+		return
+	}
+	if output == "" {
+		output = filepath.Join(backupDir, catalogInfoFileName)
+		if opts.format == catalogFormatJSON {
+			output = filepath.Join(backupDir, catalogServicesFileName)
+		}
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 写入 '%s' 失败: %v\n", output, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(logOut, "已导出 %d 个组件到 %s\n", len(components), output)
+}
+
+// isCatalogKind 服务目录只收录长期存在的工作负载, ReplicaSet, Job 与 Pod 由控制器生成, 不单独列出
+func isCatalogKind(kind string) bool {
+	return isWorkloadKind(kind) && kind != "Pod" && kind != "ReplicaSet" && kind != "Job"
+}
+
+// collectCatalogComponents 从备份对象中汇总组件, 按命名空间与名称排序
+func collectCatalogComponents(items []restoreItem, ownerLabel, defaultOwner string) []catalogComponent {
+	nsOwners := make(map[string]string)
+	var services []*unstructured.Unstructured
+	var workloads []*unstructured.Unstructured
+	for _, item := range items {
+		switch kind := item.Obj.GetKind(); {
+		case kind == "Namespace":
+			nsOwners[item.Obj.GetName()] = item.Obj.GetLabels()[ownerLabel]
+		case kind == "Service":
+			services = append(services, item.Obj)
+		case isCatalogKind(kind):
+			workloads = append(workloads, item.Obj)
+		}
+	}
+
+	components := make([]catalogComponent, 0, len(workloads))
+	for _, obj := range workloads {
+		objLabels := obj.GetLabels()
+		c := catalogComponent{
+			Namespace: obj.GetNamespace(),
+			Kind:      obj.GetKind(),
+			Name:      obj.GetName(),
+			Type:      "worker",
+			Owner:     objLabels[ownerLabel],
+			System:    objLabels[labelPartOf],
+			Images:    workloadImages(obj.Object),
+			Labels:    objLabels,
+		}
+		if c.Owner == "" {
+			c.Owner = nsOwners[c.Namespace]
+		}
+		if c.Owner == "" {
+			c.Owner = defaultOwner
+		}
+		podLabels := labels.Set(podTemplateLabels(obj.Object))
+		for _, svc := range services {
+			selector, _, _ := unstructured.NestedStringMap(svc.Object, "spec", "selector")
+			if svc.GetNamespace() == c.Namespace && len(selector) > 0 && labels.SelectorFromSet(selector).Matches(podLabels) {
+				c.Services = append(c.Services, svc.GetName())
+			}
+		}
+		sort.Strings(c.Services)
+		switch {
+		case len(c.Services) > 0:
+			c.Type = "service"
+		case c.Kind == "CronJob":
+			c.Type = "cronjob"
+		}
+		components = append(components, c)
+	}
+	sort.Slice(components, func(i, j int) bool {
+		if components[i].Namespace != components[j].Namespace {
+			return components[i].Namespace < components[j].Namespace
+		}
+		if components[i].Name != components[j].Name {
+			return components[i].Name < components[j].Name
+		}
+		return components[i].Kind < components[j].Kind
+	})
+	return components
+}
+
+// podTemplateLabels 返回工作负载 Pod 模板上的标签, 与 podSpecOf 使用相同的路径
+func podTemplateLabels(obj map[string]interface{}) map[string]string {
+	path := []string{"spec", "template", "metadata", "labels"}
+	if kind, _ := obj["kind"].(string); kind == "CronJob" {
+		path = []string{"spec", "jobTemplate", "spec", "template", "metadata", "labels"}
+	}
+	result, _, _ := unstructured.NestedStringMap(obj, path...)
+	return result
+}
+
+// workloadImages 返回工作负载引用的镜像, 去重并排序
+func workloadImages(obj map[string]interface{}) []string {
+	unique := make(map[string]struct{})
+	for _, c := range containersOf(podSpecOf(obj)) {
+		if image, _ := c["image"].(string); image != "" {
+			unique[image] = struct{}{}
+		}
+	}
+	return sortedKeys(unique)
+}
+
+// backstageEntity Backstage 目录中的 Component 实体, 实体命名空间与 Kubernetes 命名空间一致
+type backstageEntity struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   backstageMetadata `yaml:"metadata"`
+	Spec       backstageSpec     `yaml:"spec"`
+}
+
+type backstageMetadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace"`
+	Title       string            `yaml:"title,omitempty"`
+	Annotations map[string]string `yaml:"annotations"`
+	Tags        []string          `yaml:"tags,omitempty"`
+}
+
+type backstageSpec struct {
+	Type      string `yaml:"type"`
+	Lifecycle string `yaml:"lifecycle"`
+	Owner     string `yaml:"owner"`
+	System    string `yaml:"system,omitempty"`
+}
+
+// backstageCatalog 生成多文档的 catalog-info.yaml
+// backstage.io/kubernetes-id 优先取 app.kubernetes.io/name 标签, 与 Backstage Kubernetes 插件的约定一致
+func backstageCatalog(components []catalogComponent, lifecycle string) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, c := range components {
+		id := c.Labels[labelName]
+		if id == "" {
+			id = c.Name
+		}
+		entity := backstageEntity{
+			APIVersion: "backstage.io/v1alpha1",
+			Kind:       "Component",
+			Metadata: backstageMetadata{
+				Name:      c.Name,
+				Namespace: c.Namespace,
+				Title:     c.Kind + " " + c.Namespace + "/" + c.Name,
+				Annotations: map[string]string{
+					"backstage.io/kubernetes-id":        id,
+					"backstage.io/kubernetes-namespace": c.Namespace,
+				},
+				Tags: []string{"kubernetes", strings.ToLower(c.Kind)},
+			},
+			Spec: backstageSpec{Type: c.Type, Lifecycle: lifecycle, Owner: c.Owner, System: c.System},
+		}
+		if len(c.Images) > 0 {
+			entity.Metadata.Annotations["k8s-back.io/images"] = strings.Join(c.Images, ",")
+		}
+		if len(c.Services) > 0 {
+			entity.Metadata.Annotations["k8s-back.io/services"] = strings.Join(c.Services, ",")
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		data, err := yaml.Marshal(entity)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCollectCatalogComponents(t *testing.T) {
+	template := func(labels map[string]interface{}, image string) map[string]interface{} {
+		return map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": labels},
+			"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{"name": "main", "image": image}},
+			},
+		}
+	}
+	ns := fakeObject("v1", "Namespace", "", "shop", nil)
+	ns.SetLabels(map[string]string{"owner": "team-shop"})
+	web := fakeObject("apps/v1", "Deployment", "shop", "web", map[string]interface{}{
+		"spec": map[string]interface{}{"template": template(map[string]interface{}{"app": "web", "tier": "frontend"}, "nginx:1.27")},
+	})
+	web.SetLabels(map[string]string{"owner": "team-web", labelPartOf: "storefront", labelName: "storefront-web"})
+	report := fakeObject("batch/v1", "CronJob", "shop", "report", map[string]interface{}{
+		"spec": map[string]interface{}{"jobTemplate": map[string]interface{}{
+			"spec": map[string]interface{}{"template": template(map[string]interface{}{"app": "report"}, "report:2")},
+		}},
+	})
+	queue := fakeObject("apps/v1", "StatefulSet", "other", "queue", map[string]interface{}{
+		"spec": map[string]interface{}{"template": template(map[string]interface{}{"app": "web"}, "rabbitmq:3")},
+	})
+	svc := fakeObject("v1", "Service", "shop", "web", map[string]interface{}{
+		"spec": map[string]interface{}{"selector": map[string]interface{}{"app": "web"}},
+	})
+	rs := fakeObject("apps/v1", "ReplicaSet", "shop", "web-5d8f", map[string]interface{}{
+		"spec": map[string]interface{}{"template": template(map[string]interface{}{"app": "web"}, "nginx:1.27")},
+	})
+	var items []restoreItem
+	for _, obj := range []*unstructured.Unstructured{ns, web, report, queue, svc, rs} {
+		items = append(items, restoreItem{Obj: obj})
+	}
+
+	components := collectCatalogComponents(items, "owner", "unknown")
+	if len(components) != 3 {
+		t.Fatalf("组件 = %+v, 期望 3 个 (不含 ReplicaSet)", components)
+	}
+	// 按命名空间与名称排序
+	queueC, reportC, webC := components[0], components[1], components[2]
+	if queueC.Name != "queue" || queueC.Owner != "unknown" || queueC.Type != "worker" || len(queueC.Services) != 0 {
+		t.Errorf("其他命名空间的 Service 不应选中 queue: %+v", queueC)
+	}
+	if reportC.Type != "cronjob" || reportC.Owner != "team-shop" || strings.Join(reportC.Images, ",") != "report:2" {
+		t.Errorf("report = %+v", reportC)
+	}
+	if webC.Type != "service" || webC.Owner != "team-web" || webC.System != "storefront" || strings.Join(webC.Services, ",") != "web" {
+		t.Errorf("web = %+v", webC)
+	}
+
+	data, err := backstageCatalog(components, "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := strings.Split(string(data), "---\n")
+	if len(docs) != 3 {
+		t.Fatalf("catalog-info.yaml 应包含 3 个文档:\n%s", data)
+	}
+	var entity backstageEntity
+	if err := yaml.Unmarshal([]byte(docs[2]), &entity); err != nil {
+		t.Fatal(err)
+	}
+	if entity.Kind != "Component" || entity.Metadata.Namespace != "shop" || entity.Spec.Owner != "team-web" || entity.Spec.Lifecycle != "production" {
+		t.Errorf("实体 = %+v", entity)
+	}
+	if id := entity.Metadata.Annotations["backstage.io/kubernetes-id"]; id != "storefront-web" {
+		t.Errorf("kubernetes-id = %q, 应取 %s 标签", id, labelName)
+	}
+	if !isReservedFile(catalogInfoFileName) {
+		t.Error("catalog-info.yaml 不应被恢复当作资源清单")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNamespaceShard(t *testing.T) {
+	var shards []namespaceShard
+	for i := 1; i <= 4; i++ {
+		s, err := parseShard(fmt.Sprintf("%d/4", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		shards = append(shards, s)
+	}
+	// 每个命名空间恰好属于一个分片
+	for i := 0; i < 200; i++ {
+		ns := fmt.Sprintf("team-%d", i)
+		owners := 0
+		for _, s := range shards {
+			if s.contains(ns) {
+				owners++
+			}
+		}
+		if owners != 1 {
+			t.Errorf("命名空间 %s 属于 %d 个分片", ns, owners)
+		}
+	}
+	if !shards[0].ownsClusterResources() || shards[1].ownsClusterResources() {
+		t.Error("集群级资源应只由第一个分片备份")
+	}
+
+	if s, err := parseShard(""); err != nil || s.enabled() || !s.contains("any") {
+		t.Errorf("空参数应表示不分片, 实际 %+v, %v", s, err)
+	}
+	for _, invalid := range []string{"0/4", "5/4", "1/0", "1", "a/b"} {
+		if _, err := parseShard(invalid); err == nil {
+			t.Errorf("--shard %q 应被拒绝", invalid)
+		}
+	}
+}
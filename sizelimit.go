@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// parseMaxBackupSize 解析 --max-backup-size (如 5Gi, 500Mi), 空字符串表示不限制
+func parseMaxBackupSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(s)
+	if err != nil || q.Sign() <= 0 {
+		return 0, fmt.Errorf("无效的 --max-backup-size '%s': 须为正的容量值 (如 5Gi, 500Mi)", s)
+	}
+	return q.Value(), nil
+}
+
+// reserveBytes 在写入清单前累计备份大小, 超过 --max-backup-size 时返回 false 并标记本次备份已超限
+// 超限后不再写入任何对象, 已写入的内容保留, 由调用方以非零状态结束
+func (b *Backupper) reserveBytes(n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sizeExceeded {
+		return false
+	}
+	if b.maxBytes > 0 && b.writtenBytes+int64(n) > b.maxBytes {
+		b.sizeExceeded = true
+		return false
+	}
+	b.writtenBytes += int64(n)
+	return true
+}
+
+// sizeExceededError 描述超限时的错误信息, desc 为第一个未能写入的对象
+func (b *Backupper) sizeExceededError(desc string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return fmt.Sprintf("备份大小将超过上限 %s (已写入 %s), %s 及之后的对象未备份", formatBytes(int(b.maxBytes)), formatBytes(int(b.writtenBytes)), desc)
+}
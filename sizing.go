@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// sizingFileName 每个命名空间目录下记录备份时刻副本数与弹性伸缩状态的文件
+const sizingFileName = "sizing.yaml"
+
+var pdbGVR = schema.GroupVersionResource{Group: "policy", Version: "v1", Resource: "poddisruptionbudgets"}
+
+// namespaceSizing 备份时刻命名空间内的规模数据, 供恢复后决定扩缩容参考
+type namespaceSizing struct {
+	Namespace  string           `yaml:"namespace"`
+	CapturedAt string           `yaml:"capturedAt"`
+	Workloads  []workloadSizing `yaml:"workloads,omitempty"`
+	HPAs       []hpaSizing      `yaml:"horizontalPodAutoscalers,omitempty"`
+	PDBs       []pdbSizing      `yaml:"podDisruptionBudgets,omitempty"`
+}
+
+type workloadSizing struct {
+	Kind              string `yaml:"kind"`
+	Name              string `yaml:"name"`
+	Replicas          int64  `yaml:"replicas"`
+	ReadyReplicas     int64  `yaml:"readyReplicas"`
+	AvailableReplicas int64  `yaml:"availableReplicas"`
+}
+
+type hpaSizing struct {
+	Name            string        `yaml:"name"`
+	Target          string        `yaml:"target"`
+	MinReplicas     int64         `yaml:"minReplicas"`
+	MaxReplicas     int64         `yaml:"maxReplicas"`
+	CurrentReplicas int64         `yaml:"currentReplicas"`
+	DesiredReplicas int64         `yaml:"desiredReplicas"`
+	Metrics         []interface{} `yaml:"metrics,omitempty"`
+	CurrentMetrics  []interface{} `yaml:"currentMetrics,omitempty"`
+}
+
+type pdbSizing struct {
+	Name               string      `yaml:"name"`
+	MinAvailable       interface{} `yaml:"minAvailable,omitempty"`
+	MaxUnavailable     interface{} `yaml:"maxUnavailable,omitempty"`
+	CurrentHealthy     int64       `yaml:"currentHealthy"`
+	DesiredHealthy     int64       `yaml:"desiredHealthy"`
+	DisruptionsAllowed int64       `yaml:"disruptionsAllowed"`
+	ExpectedPods       int64       `yaml:"expectedPods"`
+}
+
+// empty 判断是否没有任何可记录的规模数据
+func (s *namespaceSizing) empty() bool {
+	return len(s.Workloads) == 0 && len(s.HPAs) == 0 && len(s.PDBs) == 0
+}
+
+// collectNamespaceSizing 读取命名空间内工作负载副本数, HPA 与 PDB 的实时状态
+// 单个类型读取失败 (如无权限) 时仅经 out 输出警告, 不影响其余数据
+func collectNamespaceSizing(client dynamic.Interface, namespace string, out *outputSection) *namespaceSizing {
+	sizing := &namespaceSizing{Namespace: namespace, CapturedAt: time.Now().Format(time.RFC3339)}
+
+	for _, resType := range []string{"deployments", "statefulsets"} {
+		resInfo := resourceMap[resType]
+		for _, item := range listForSizing(client, resInfo.GVR, namespace, out) {
+			replicas, _, _ := unstructured.NestedInt64(item.Object, "spec", "replicas")
+			ready, _, _ := unstructured.NestedInt64(item.Object, "status", "readyReplicas")
+			available, _, _ := unstructured.NestedInt64(item.Object, "status", "availableReplicas")
+			sizing.Workloads = append(sizing.Workloads, workloadSizing{
+				Kind: resInfo.Kind, Name: item.GetName(),
+				Replicas: replicas, ReadyReplicas: ready, AvailableReplicas: available,
+			})
+		}
+	}
+
+	for _, item := range listForSizing(client, resourceMap["horizontalpodautoscalers"].GVR, namespace, out) {
+		targetKind, _, _ := unstructured.NestedString(item.Object, "spec", "scaleTargetRef", "kind")
+		targetName, _, _ := unstructured.NestedString(item.Object, "spec", "scaleTargetRef", "name")
+		hpa := hpaSizing{Name: item.GetName(), Target: targetKind + "/" + targetName}
+		hpa.MinReplicas, _, _ = unstructured.NestedInt64(item.Object, "spec", "minReplicas")
+		hpa.MaxReplicas, _, _ = unstructured.NestedInt64(item.Object, "spec", "maxReplicas")
+		hpa.CurrentReplicas, _, _ = unstructured.NestedInt64(item.Object, "status", "currentReplicas")
+		hpa.DesiredReplicas, _, _ = unstructured.NestedInt64(item.Object, "status", "desiredReplicas")
+		hpa.Metrics, _, _ = unstructured.NestedSlice(item.Object, "spec", "metrics")
+		hpa.CurrentMetrics, _, _ = unstructured.NestedSlice(item.Object, "status", "currentMetrics")
+		sizing.HPAs = append(sizing.HPAs, hpa)
+	}
+
+	for _, item := range listForSizing(client, pdbGVR, namespace, out) {
+		pdb := pdbSizing{Name: item.GetName()}
+		pdb.MinAvailable, _, _ = unstructured.NestedFieldNoCopy(item.Object, "spec", "minAvailable")
+		pdb.MaxUnavailable, _, _ = unstructured.NestedFieldNoCopy(item.Object, "spec", "maxUnavailable")
+		pdb.CurrentHealthy, _, _ = unstructured.NestedInt64(item.Object, "status", "currentHealthy")
+		pdb.DesiredHealthy, _, _ = unstructured.NestedInt64(item.Object, "status", "desiredHealthy")
+		pdb.DisruptionsAllowed, _, _ = unstructured.NestedInt64(item.Object, "status", "disruptionsAllowed")
+		pdb.ExpectedPods, _, _ = unstructured.NestedInt64(item.Object, "status", "expectedPods")
+		sizing.PDBs = append(sizing.PDBs, pdb)
+	}
+
+	return sizing
+}
+
+// listForSizing 列出规模数据所需的资源, 失败时返回空列表
+func listForSizing(client dynamic.Interface, gvr schema.GroupVersionResource, namespace string, out *outputSection) []unstructured.Unstructured {
+	list, err := client.Resource(gvr).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		out.printf("  警告: 读取 %s 规模数据失败: %v\n", gvr.Resource, err)
+		return nil
+	}
+	return list.Items
+}
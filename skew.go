@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"backup-k8s/clean"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+// skewFileName 备份根目录下记录备份期间发生变化的对象的文件
+const skewFileName = "skew.yaml"
+
+// 对象在备份期间的变化类型
+const (
+	skewModified = "modified"
+	skewDeleted  = "deleted"
+)
+
+// skewEntry 一个在列出之后, 备份结束之前被修改或删除的对象
+type skewEntry struct {
+	Kind           string `yaml:"kind"`
+	Namespace      string `yaml:"namespace,omitempty"`
+	Name           string `yaml:"name"`
+	Path           string `yaml:"path"`
+	Change         string `yaml:"change"`
+	ListedVersion  string `yaml:"listedResourceVersion"`
+	CurrentVersion string `yaml:"currentResourceVersion,omitempty"`
+	Refetched      bool   `yaml:"refetched,omitempty"`
+	RefetchSkipped string `yaml:"refetchSkipped,omitempty"` // 未能重新获取的原因
+}
+
+// backupSkew 写入 skew.yaml 的内容
+type backupSkew struct {
+	CheckedAt string      `yaml:"checkedAt"`
+	Objects   []skewEntry `yaml:"objects"`
+}
+
+// resourceTypeForKind 根据 Kind 查找 resourceMap 中的资源类型
+func resourceTypeForKind(kind string) (string, ResourceInfo, bool) {
+	for resType, resInfo := range resourceMap {
+		if resInfo.Kind == kind {
+			return resType, resInfo, true
+		}
+	}
+	return "", ResourceInfo{}, false
+}
+
+// checkBackupSkew 备份结束时按命名空间与类型重新列出对象, 与索引中记录的 resourceVersion 比较
+// refetch 为 true 时用最新版本覆盖被修改对象的清单文件并更新索引 (调用方保证未启用 all.yaml)
+func checkBackupSkew(client dynamic.Interface, backupRoot string, index []indexEntry, refetch bool, opts clean.Options) backupSkew {
+	type group struct{ kind, namespace string }
+	groups := make(map[group][]int)
+	var order []group
+	for i, e := range index {
+		g := group{e.Kind, e.Namespace}
+		if _, ok := groups[g]; !ok {
+			order = append(order, g)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	skew := backupSkew{CheckedAt: time.Now().Format(time.RFC3339), Objects: []skewEntry{}}
+	for _, g := range order {
+		resType, resInfo, ok := resourceTypeForKind(g.kind)
+		if !ok {
+			continue
+		}
+		list, err := client.Resource(resInfo.GVR).Namespace(g.namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  警告: 复查 %s 失败: %v\n", resInfo.Kind, err)
+			continue
+		}
+		current := make(map[string]*unstructured.Unstructured, len(list.Items))
+		for i := range list.Items {
+			current[list.Items[i].GetName()] = &list.Items[i]
+		}
+
+		for _, i := range groups[g] {
+			e := &index[i]
+			s := skewEntry{Kind: e.Kind, Namespace: e.Namespace, Name: e.Name, Path: e.Path, ListedVersion: e.ResourceVersion}
+			obj, exists := current[e.Name]
+			switch {
+			case !exists:
+				s.Change = skewDeleted
+			case obj.GetResourceVersion() != e.ResourceVersion:
+				s.Change = skewModified
+				s.CurrentVersion = obj.GetResourceVersion()
+			default:
+				continue
+			}
+			if refetch && s.Change == skewModified {
+				if err := refetchObject(backupRoot, resType, obj, e, opts); err != nil {
+					s.RefetchSkipped = err.Error()
+				} else {
+					s.Refetched = true
+				}
+			}
+			skew.Objects = append(skew.Objects, s)
+		}
+	}
+	sort.Slice(skew.Objects, func(i, j int) bool { return skew.Objects[i].Path < skew.Objects[j].Path })
+	return skew
+}
+
+// refetchObject 用最新版本覆盖对象的清单文件, 并更新对应的索引条目
+func refetchObject(backupRoot, resType string, obj *unstructured.Unstructured, e *indexEntry, opts clean.Options) error {
+	updated := newIndexEntry(obj)
+	_, data, err := renderResource(resType, obj, opts)
+	if err != nil {
+		return err
+	}
+	fullPath := filepath.Join(backupRoot, filepath.FromSlash(e.Path))
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+		return err
+	}
+	*e = updated.withFile(backupRoot, fullPath, data)
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// skippedFileName 备份根目录下记录未备份对象及原因的文件, 用于完整性审计
+const skippedFileName = "skipped.yaml"
+
+// 跳过原因代码; 带有 ownerReferences 的对象照常备份, 没有对应的原因
+const (
+	skipPermissionDenied  = "permission_denied"    // 无 list 权限, 整个资源类型被跳过
+	skipListFailed        = "list_failed"          // 列出资源失败
+	skipSystemSecret      = "system_secret"        // ServiceAccount 令牌, Helm 发布记录等系统生成的 Secret
+	skipSecretsDisabled   = "secrets_disabled"     // --skip-secrets 或集群策略禁止备份 Secret
+	skipSystemManaged     = "system_managed"       // 内置排除清单中的控制器生成对象
+	skipUnchanged         = "unchanged"            // --since / --modified-after 时间下限之前未变化的对象
+	skipExcludedNamespace = "excluded_namespace"   // --exclude-namespaces, --exclude-namespace-selector 或集群策略排除的命名空间
+	skipPolicyType        = "policy_excluded_type" // 集群策略排除的资源类型
+	skipRuntimeType       = "runtime_type"         // 未指定 --include-runtime-objects 时默认排除的 ReplicaSet 与 Pod
+	skipSizeLimit         = "size_limit"           // 超过 --max-backup-size 后未写入
+	skipRenderFailed      = "render_failed"        // 序列化失败
+	skipWriteFailed       = "write_failed"         // 写入清单文件失败
+)
+
+// skipEntry 一个被跳过的对象, 未填写 Name 时表示整个资源类型或命名空间
+type skipEntry struct {
+	Reason    string `yaml:"reason"`
+	Kind      string `yaml:"kind,omitempty"`
+	Namespace string `yaml:"namespace,omitempty"`
+	Name      string `yaml:"name,omitempty"`
+	Detail    string `yaml:"detail,omitempty"`
+}
+
+// skipReport 写入 skipped.yaml 的内容
+type skipReport struct {
+	Counts  map[string]int `yaml:"counts"`
+	Objects []skipEntry    `yaml:"objects"`
+}
+
+// newSkipReport 按原因汇总跳过记录, 记录按原因, 命名空间, Kind 与名称排序
+func newSkipReport(entries []skipEntry) skipReport {
+	report := skipReport{Counts: make(map[string]int), Objects: append([]skipEntry{}, entries...)}
+	for _, e := range entries {
+		report.Counts[e.Reason]++
+	}
+	sort.SliceStable(report.Objects, func(i, j int) bool {
+		a, b := report.Objects[i], report.Objects[j]
+		if a.Reason != b.Reason {
+			return a.Reason < b.Reason
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return report
+}
+
+// skip 记录一个被跳过的对象或资源类型
+func (p *backupPartition) skip(e skipEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Skipped = append(p.Skipped, e)
+}
+
+// filterSkipped 原地筛选对象, check 返回非空原因代码的对象被移除并记入跳过报告, 返回保留的对象与被跳过的数量
+func (p *backupPartition) filterSkipped(items []unstructured.Unstructured, check func(obj *unstructured.Unstructured) (reason, detail string)) ([]unstructured.Unstructured, int) {
+	kept := items[:0]
+	for i := range items {
+		obj := &items[i]
+		if reason, detail := check(obj); reason != "" {
+			p.skip(skipEntry{Reason: reason, Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName(), Detail: detail})
+			continue
+		}
+		kept = append(kept, *obj)
+	}
+	return kept, len(items) - len(kept)
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// --state-dir 下的文件, 在多次运行之间保存, 供增量备份, 断点续传与 watch 模式使用
+const (
+	stateVersionsFile   = "resource-versions.yaml" // 上一次完整备份中各对象的 resourceVersion
+	stateCheckpointFile = "checkpoint.yaml"        // 进行中的备份已完成的命名空间
+	stateDiscoveryFile  = "discovery.yaml"         // 资源类型发现结果的缓存
+	stateLockFile       = "lock"                   // 防止多个进程同时使用同一状态目录
+
+	stateDiscoveryTTL = 10 * time.Minute
+)
+
+// stateParts state reset 可单独清除的部分, 与文件一一对应
+var stateParts = map[string]string{
+	"versions":   stateVersionsFile,
+	"checkpoint": stateCheckpointFile,
+	"discovery":  stateDiscoveryFile,
+	"locks":      stateLockFile,
+}
+
+// stateDir 持久化状态目录, 不同集群应使用不同的目录
+type stateDir struct {
+	Root string
+}
+
+// stateObject resource-versions.yaml 中的一个对象
+type stateObject struct {
+	UID             string `yaml:"uid,omitempty"`
+	ResourceVersion string `yaml:"resourceVersion"`
+	Digest          string `yaml:"digest"`
+}
+
+// stateVersions 上一次完整备份的对象版本索引, 键为 objectKey
+type stateVersions struct {
+	Backup    string                 `yaml:"backup"`
+	UpdatedAt string                 `yaml:"updatedAt"`
+	Objects   map[string]stateObject `yaml:"objects"`
+}
+
+// stateCheckpoint 进行中的备份的进度, 备份正常结束后删除; 残留时说明上一次备份被中断
+type stateCheckpoint struct {
+	Backup     string   `yaml:"backup"`
+	StartedAt  string   `yaml:"startedAt"`
+	Namespaces []string `yaml:"namespaces"`          // 计划备份的命名空间
+	Completed  []string `yaml:"completed,omitempty"` // 已完成的命名空间
+}
+
+// stateDiscovery 按 API 组版本缓存集群提供的资源名, 未提供的组版本记为空列表
+type stateDiscovery struct {
+	Server        string              `yaml:"server"`
+	CachedAt      string              `yaml:"cachedAt"`
+	GroupVersions map[string][]string `yaml:"groupVersions"`
+}
+
+// stateLock 锁文件的内容, 用于判断持有者是否仍在运行
+type stateLock struct {
+	PID        int    `yaml:"pid"`
+	Host       string `yaml:"host"`
+	Command    string `yaml:"command"`
+	AcquiredAt string `yaml:"acquiredAt"`
+}
+
+// openStateDir 打开状态目录, 不存在时创建
+func openStateDir(root string) (*stateDir, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, fmt.Errorf("创建状态目录 '%s' 失败: %w", root, err)
+	}
+	return &stateDir{Root: root}, nil
+}
+
+func (s *stateDir) path(name string) string {
+	return filepath.Join(s.Root, name)
+}
+
+// readState 读取状态文件, 文件不存在时返回 false
+func (s *stateDir) readState(name string, v interface{}) (bool, error) {
+	err := readYAMLFile(s.path(name), v)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("读取 %s 失败: %w", name, err)
+	}
+	return true, nil
+}
+
+// lock 获取状态目录的锁; 锁由本机已退出的进程遗留时直接接管, 否则返回持有者信息
+func (s *stateDir) lock(command string) error {
+	host, _ := os.Hostname()
+	data, err := yaml.Marshal(stateLock{PID: os.Getpid(), Host: host, Command: command, AcquiredAt: time.Now().Format(time.RFC3339)})
+	if err != nil {
+		return err
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(s.path(stateLockFile), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_, err = f.Write(data)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			return err
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("创建锁文件失败: %w", err)
+		}
+		var held stateLock
+		if _, err := s.readState(stateLockFile, &held); err != nil {
+			return err
+		}
+		if held.Host != host || processAlive(held.PID) {
+			return fmt.Errorf("状态目录 '%s' 正被 %s 上的进程 %d (%s) 使用, 开始于 %s; 确认该进程已不在运行后执行 state reset --only locks",
+				s.Root, held.Host, held.PID, held.Command, held.AcquiredAt)
+		}
+		fmt.Fprintf(os.Stderr, "警告: 接管已退出的进程 %d 遗留的状态目录锁\n", held.PID)
+		if err := os.Remove(s.path(stateLockFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("删除遗留的锁文件失败: %w", err)
+		}
+	}
+	return fmt.Errorf("获取状态目录锁失败: 锁文件被其他进程抢先创建")
+}
+
+// unlock 释放 lock 获取的锁
+func (s *stateDir) unlock() error {
+	if err := os.Remove(s.path(stateLockFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// beginCheckpoint 记录一次备份开始, 覆盖之前残留的检查点
+func (s *stateDir) beginCheckpoint(backup string, namespaces []string) (*stateCheckpoint, error) {
+	cp := &stateCheckpoint{Backup: backup, StartedAt: time.Now().Format(time.RFC3339), Namespaces: namespaces}
+	return cp, writeYAMLFile(s.path(stateCheckpointFile), cp)
+}
+
+// completeNamespace 将命名空间记为已完成并立即写入, 进程中断后可据此得知哪些命名空间需要重新备份
+func (s *stateDir) completeNamespace(cp *stateCheckpoint, namespace string) error {
+	cp.Completed = append(cp.Completed, namespace)
+	return writeYAMLFile(s.path(stateCheckpointFile), cp)
+}
+
+// finishCheckpoint 备份正常结束后删除检查点
+func (s *stateDir) finishCheckpoint() error {
+	if err := os.Remove(s.path(stateCheckpointFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// saveVersions 用本次备份的索引替换对象版本索引, carried 为增量备份中未变化而沿用基线的条目
+func (s *stateDir) saveVersions(backup string, partitions []*backupPartition, carried map[string]stateObject) error {
+	versions := stateVersions{Backup: backup, UpdatedAt: time.Now().Format(time.RFC3339), Objects: make(map[string]stateObject)}
+	for key, obj := range carried {
+		versions.Objects[key] = obj
+	}
+	for _, p := range partitions {
+		for _, e := range p.Index {
+			versions.Objects[objectKey(e.Kind, e.Namespace, e.Name)] = stateObject{UID: e.UID, ResourceVersion: e.ResourceVersion, Digest: e.Digest}
+		}
+	}
+	return writeYAMLFile(s.path(stateVersionsFile), versions)
+}
+
+// reset 删除指定部分的状态文件, parts 为空时删除全部; 返回实际删除的文件
+func (s *stateDir) reset(parts []string) ([]string, error) {
+	if len(parts) == 0 {
+		parts = make([]string, 0, len(stateParts))
+		for part := range stateParts {
+			parts = append(parts, part)
+		}
+	}
+	var removed []string
+	for _, part := range parts {
+		name, ok := stateParts[part]
+		if !ok {
+			return removed, fmt.Errorf("未知的状态 '%s', 可选: versions, checkpoint, discovery, locks", part)
+		}
+		err := os.Remove(s.path(name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
+
+// serverResourcesLister filterServedTypes 需要的 discovery 接口
+type serverResourcesLister interface {
+	ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error)
+}
+
+// cachedDiscovery 以状态目录中的 discovery.yaml 缓存 ServerResourcesForGroupVersion 的结果
+// 缓存属于其他 API Server 或超过 stateDiscoveryTTL 时作废; 查询出错 (除组版本不存在外) 不缓存
+type cachedDiscovery struct {
+	client serverResourcesLister
+	path   string
+	cache  stateDiscovery
+	hits   int
+	dirty  bool
+}
+
+// discovery 返回带缓存的 discovery 客户端, 读取缓存失败时视为无缓存
+func (s *stateDir) discovery(client serverResourcesLister, server string) *cachedDiscovery {
+	c := &cachedDiscovery{client: client, path: s.path(stateDiscoveryFile)}
+	ok, err := s.readState(stateDiscoveryFile, &c.cache)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v, 重新查询\n", err)
+	}
+	cachedAt, _ := time.Parse(time.RFC3339, c.cache.CachedAt)
+	if !ok || err != nil || c.cache.Server != server || time.Since(cachedAt) > stateDiscoveryTTL {
+		c.cache = stateDiscovery{Server: server, GroupVersions: make(map[string][]string)}
+	}
+	return c
+}
+
+func (c *cachedDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	if names, ok := c.cache.GroupVersions[groupVersion]; ok {
+		c.hits++
+		if len(names) == 0 {
+			return nil, apierrors.NewNotFound(metav1.SchemeGroupVersion.WithResource("groupversions").GroupResource(), groupVersion)
+		}
+		list := &metav1.APIResourceList{GroupVersion: groupVersion}
+		for _, name := range names {
+			list.APIResources = append(list.APIResources, metav1.APIResource{Name: name})
+		}
+		return list, nil
+	}
+	list, err := c.client.ServerResourcesForGroupVersion(groupVersion)
+	switch {
+	case err == nil:
+		names := make([]string, 0, len(list.APIResources))
+		for _, r := range list.APIResources {
+			names = append(names, r.Name)
+		}
+		c.cache.GroupVersions[groupVersion] = names
+		c.dirty = true
+	case apierrors.IsNotFound(err):
+		c.cache.GroupVersions[groupVersion] = []string{}
+		c.dirty = true
+	}
+	return list, err
+}
+
+// save 有新的查询结果时写回缓存, 缓存时间从第一次写入起算
+func (c *cachedDiscovery) save() error {
+	if !c.dirty {
+		return nil
+	}
+	if c.cache.CachedAt == "" {
+		c.cache.CachedAt = time.Now().Format(time.RFC3339)
+	}
+	return writeYAMLFile(c.path, c.cache)
+}
+
+// runState 实现 state 子命令: 查看或重置 --state-dir
+func runState(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "用法: k8s-backup state show|reset --state-dir <目录> [参数]")
+	}
+	if len(args) == 0 || (args[0] != "show" && args[0] != "reset") {
+		usage()
+		os.Exit(2)
+	}
+	action := args[0]
+	var root, only string
+	var force bool
+	fs := pflag.NewFlagSet("state "+action, pflag.ExitOnError)
+	fs.Usage = func() {
+		usage()
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&root, "state-dir", "", "状态目录, 与备份时的 --state-dir 相同")
+	if action == "reset" {
+		fs.StringVar(&only, "only", "", "只清除指定部分, 逗号分隔: versions, checkpoint, discovery, locks (默认全部)")
+		fs.BoolVar(&force, "force", false, "状态目录被正在运行的备份锁定时仍然清除")
+	}
+	fs.Parse(args[1:])
+	if root == "" {
+		fmt.Fprintln(os.Stderr, "错误: 需要指定 --state-dir")
+		os.Exit(2)
+	}
+	if _, err := os.Stat(root); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 状态目录 '%s' 不可用: %v\n", root, err)
+		os.Exit(1)
+	}
+	state := &stateDir{Root: root}
+
+	if action == "show" {
+		if err := state.print(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	parts := splitList(only)
+	var held stateLock
+	if ok, _ := state.readState(stateLockFile, &held); ok && !force && !slices.Equal(parts, []string{"locks"}) {
+		if host, _ := os.Hostname(); held.Host != host || processAlive(held.PID) {
+			fmt.Fprintf(os.Stderr, "错误: 状态目录正被 %s 上的进程 %d 使用, 确认后使用 --force 清除\n", held.Host, held.PID)
+			os.Exit(1)
+		}
+	}
+	removed, err := state.reset(parts)
+	for _, name := range removed {
+		fmt.Fprintf(logOut, "已删除 %s\n", state.path(name))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if len(removed) == 0 {
+		fmt.Fprintln(logOut, "没有需要清除的状态")
+	}
+}
+
+// print 输出状态目录中各部分的概况
+func (s *stateDir) print(w io.Writer) error {
+	fmt.Fprintf(w, "状态目录: %s\n", s.Root)
+
+	var held stateLock
+	ok, err := s.readState(stateLockFile, &held)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\n[锁]")
+	if !ok {
+		fmt.Fprintln(w, "  未锁定")
+	} else {
+		status := "运行中"
+		if host, _ := os.Hostname(); held.Host == host && !processAlive(held.PID) {
+			status = "进程已退出, 下次运行时自动接管"
+		}
+		fmt.Fprintf(w, "  %s 上的进程 %d (%s), 开始于 %s, %s\n", held.Host, held.PID, held.Command, held.AcquiredAt, status)
+	}
+
+	var cp stateCheckpoint
+	if ok, err = s.readState(stateCheckpointFile, &cp); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\n[检查点]")
+	if !ok {
+		fmt.Fprintln(w, "  无 (上一次备份正常结束)")
+	} else {
+		fmt.Fprintf(w, "  备份 %s 开始于 %s, 已完成 %d/%d 个命名空间\n", cp.Backup, cp.StartedAt, len(cp.Completed), len(cp.Namespaces))
+	}
+
+	var versions stateVersions
+	if ok, err = s.readState(stateVersionsFile, &versions); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\n[对象版本索引]")
+	if !ok {
+		fmt.Fprintln(w, "  无")
+	} else {
+		fmt.Fprintf(w, "  %d 个对象, 来自备份 %s, 更新于 %s\n", len(versions.Objects), versions.Backup, versions.UpdatedAt)
+	}
+
+	var disc stateDiscovery
+	if ok, err = s.readState(stateDiscoveryFile, &disc); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "\n[资源发现缓存]")
+	if !ok {
+		fmt.Fprintln(w, "  无")
+	} else {
+		expired := ""
+		if cachedAt, err := time.Parse(time.RFC3339, disc.CachedAt); err != nil || time.Since(cachedAt) > stateDiscoveryTTL {
+			expired = ", 已过期"
+		}
+		fmt.Fprintf(w, "  %s 的 %d 个 API 组版本, 缓存于 %s%s\n", disc.Server, len(disc.GroupVersions), disc.CachedAt, expired)
+	}
+	return nil
+}
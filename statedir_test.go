@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestStateDirLock(t *testing.T) {
+	state, err := openStateDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := state.lock("backup a"); err != nil {
+		t.Fatal(err)
+	}
+	err = state.lock("backup b")
+	if err == nil || !strings.Contains(err.Error(), "backup a") {
+		t.Fatalf("锁被当前进程持有时应返回持有者信息, 实际 %v", err)
+	}
+	if err := state.unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !processAlive(os.Getpid()) || processAlive(1<<30) {
+		t.Fatal("processAlive 应只对仍在运行的进程返回 true")
+	}
+
+	// 本机已退出的进程遗留的锁直接接管
+	host, _ := os.Hostname()
+	if err := writeYAMLFile(state.path(stateLockFile), stateLock{PID: 1 << 30, Host: host, Command: "backup old"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.lock("backup c"); err != nil {
+		t.Fatalf("应接管遗留的锁: %v", err)
+	}
+	var held stateLock
+	if _, err := state.readState(stateLockFile, &held); err != nil || held.PID != os.Getpid() || held.Command != "backup c" {
+		t.Errorf("锁文件 = %+v, %v", held, err)
+	}
+
+	// 其他主机持有的锁无法判断是否仍在运行, 不接管
+	if err := writeYAMLFile(state.path(stateLockFile), stateLock{PID: 1 << 30, Host: host + "-other"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.lock("backup d"); err == nil {
+		t.Error("其他主机持有的锁不应被接管")
+	}
+}
+
+func TestStateDirCheckpointAndVersions(t *testing.T) {
+	state, err := openStateDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := state.beginCheckpoint("/backups/k8s-backup-1", []string{"app", "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := state.completeNamespace(cp, "app"); err != nil {
+		t.Fatal(err)
+	}
+	var saved stateCheckpoint
+	if ok, err := state.readState(stateCheckpointFile, &saved); !ok || err != nil || strings.Join(saved.Completed, ",") != "app" {
+		t.Errorf("检查点 = %+v, %v", saved, err)
+	}
+	if err := state.finishCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := state.readState(stateCheckpointFile, &saved); ok {
+		t.Error("备份结束后应删除检查点")
+	}
+
+	partition := &backupPartition{Index: []indexEntry{
+		{Kind: "ConfigMap", Namespace: "app", Name: "settings", UID: "u1", ResourceVersion: "42", Digest: "sha256:a"},
+		{Kind: "ClusterRole", Name: "viewer", ResourceVersion: "7", Digest: "sha256:b"},
+	}}
+	if err := state.saveVersions("/backups/k8s-backup-1", []*backupPartition{partition}, nil); err != nil {
+		t.Fatal(err)
+	}
+	var versions stateVersions
+	if _, err := state.readState(stateVersionsFile, &versions); err != nil {
+		t.Fatal(err)
+	}
+	if got := versions.Objects[objectKey("ConfigMap", "app", "settings")]; got.ResourceVersion != "42" || got.UID != "u1" || len(versions.Objects) != 2 {
+		t.Errorf("版本索引 = %+v", versions.Objects)
+	}
+
+	removed, err := state.reset([]string{"versions", "checkpoint"})
+	if err != nil || strings.Join(removed, ",") != stateVersionsFile {
+		t.Errorf("reset 删除了 %v, %v", removed, err)
+	}
+	if _, err := state.reset([]string{"cache"}); err == nil {
+		t.Error("未知的状态部分应返回错误")
+	}
+}
+
+func TestCachedDiscovery(t *testing.T) {
+	state, err := openStateDir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &discoveryfake.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	client.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "configmaps"}}},
+		{GroupVersion: "route.openshift.io/v1", APIResources: []metav1.APIResource{{Name: "routes"}}},
+	}
+	resourceTypes := []string{"configmaps", "routes", "deploymentconfigs", "validatingadmissionpolicies"}
+
+	cache := state.discovery(client, "https://a.example.com")
+	served, missing := filterServedTypes(cache, resourceTypes)
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+	queries := len(client.Actions())
+
+	cache = state.discovery(client, "https://a.example.com")
+	cachedServed, cachedMissing := filterServedTypes(cache, resourceTypes)
+	if len(client.Actions()) != queries || cache.hits == 0 {
+		t.Errorf("缓存有效时不应再查询集群, 查询 %d 次, 命中 %d 次", len(client.Actions())-queries, cache.hits)
+	}
+	if strings.Join(cachedServed, ",") != strings.Join(served, ",") || strings.Join(cachedMissing, ",") != strings.Join(missing, ",") {
+		t.Errorf("缓存结果 %v/%v 与直接查询 %v/%v 不一致", cachedServed, cachedMissing, served, missing)
+	}
+
+	cache = state.discovery(client, "https://b.example.com")
+	filterServedTypes(cache, resourceTypes)
+	if cache.hits != 0 {
+		t.Error("其他 API Server 的缓存不应使用")
+	}
+}
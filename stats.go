@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// statsFileName 备份根目录下的命名空间规模与增长统计
+const statsFileName = "stats.yaml"
+
+// statsTopN 日志中展示的命名空间数量 (按大小降序), 完整数据见 stats.yaml
+const statsTopN = 10
+
+// namespaceStats 单个命名空间的规模, 存在上一次备份时附带增量
+type namespaceStats struct {
+	Namespace         string   `yaml:"namespace"`
+	Count             int      `yaml:"count"`
+	Size              int      `yaml:"size"`
+	CountDelta        *int     `yaml:"countDelta,omitempty"`
+	SizeDelta         *int     `yaml:"sizeDelta,omitempty"`
+	SizeGrowthPercent *float64 `yaml:"sizeGrowthPercent,omitempty"`
+}
+
+// backupStats 写入 stats.yaml 的内容
+type backupStats struct {
+	Previous   string           `yaml:"previous,omitempty"`
+	TotalCount int              `yaml:"totalCount"`
+	TotalSize  int              `yaml:"totalSize"`
+	Namespaces []namespaceStats `yaml:"namespaces"`
+}
+
+// computeBackupStats 汇总本次备份各命名空间的规模, previous 非空时计算相对上一次备份的增长
+func computeBackupStats(index []indexEntry, previousDir string, previous map[string]indexEntry) backupStats {
+	stats := backupStats{}
+	prevByNamespace := make(map[string]namespaceSummary)
+	if previous != nil {
+		stats.Previous = filepath.Base(previousDir)
+		entries := make([]indexEntry, 0, len(previous))
+		for _, e := range previous {
+			entries = append(entries, e)
+		}
+		for _, s := range summarizeIndex(entries) {
+			prevByNamespace[s.Name] = s
+		}
+	}
+
+	for _, s := range summarizeIndex(index) {
+		ns := namespaceStats{Namespace: s.Name, Count: s.Count, Size: s.Size}
+		if previous != nil {
+			prev := prevByNamespace[s.Name]
+			countDelta, sizeDelta := s.Count-prev.Count, s.Size-prev.Size
+			ns.CountDelta, ns.SizeDelta = &countDelta, &sizeDelta
+			if prev.Size > 0 {
+				growth := float64(sizeDelta) * 100 / float64(prev.Size)
+				ns.SizeGrowthPercent = &growth
+			}
+		}
+		stats.TotalCount += s.Count
+		stats.TotalSize += s.Size
+		stats.Namespaces = append(stats.Namespaces, ns)
+	}
+	return stats
+}
+
+// printBackupStats 在日志中输出最大的若干命名空间及其增长
+func printBackupStats(stats backupStats) {
+	if len(stats.Namespaces) == 0 {
+		return
+	}
+	top := append([]namespaceStats(nil), stats.Namespaces...)
+	sort.SliceStable(top, func(i, j int) bool { return top[i].Size > top[j].Size })
+	if len(top) > statsTopN {
+		top = top[:statsTopN]
+	}
+
+	fmt.Fprintf(logOut, "\n命名空间规模 (按大小排序, 前 %d 个, 完整数据见 %s):\n", len(top), statsFileName)
+	for _, ns := range top {
+		line := fmt.Sprintf("  %-30s %6d 个 %10s", ns.Namespace, ns.Count, formatBytes(ns.Size))
+		if ns.SizeDelta != nil {
+			line += fmt.Sprintf("  (资源 %+d, 大小 %+d B", *ns.CountDelta, *ns.SizeDelta)
+			if ns.SizeGrowthPercent != nil {
+				line += fmt.Sprintf(", %+.1f%%", *ns.SizeGrowthPercent)
+			}
+			line += ")"
+		}
+		fmt.Fprintln(logOut, line)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// annotationBetaStorageClass 早期版本以注解指定的存储类, 目标集群仍按此注解选择存储类
+const annotationBetaStorageClass = "volume.beta.kubernetes.io/storage-class"
+
+// parseStorageClassMapping 解析 --storageclass-mapping, 每项格式为 源=目标, 可用逗号分隔多项或重复指定
+// 目标为空 (如 gp2=) 表示移除 storageClassName, 由目标集群的默认存储类供应
+func parseStorageClassMapping(specs []string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, spec := range specs {
+		for _, pair := range splitList(spec) {
+			from, to, ok := strings.Cut(pair, "=")
+			from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+			if !ok || from == "" {
+				return nil, fmt.Errorf("无效的存储类映射 '%s', 格式应为 源=目标 (如 gp2=standard)", pair)
+			}
+			if existing, dup := mapping[from]; dup && existing != to {
+				return nil, fmt.Errorf("存储类 '%s' 指定了多个目标 ('%s', '%s')", from, existing, to)
+			}
+			mapping[from] = to
+		}
+	}
+	return mapping, nil
+}
+
+// storageClassRewrite 按映射改写的一组存储类
+type storageClassRewrite struct {
+	From    string
+	To      string
+	Objects []string
+}
+
+// rewriteStorageClasses 按 mapping 改写 PVC 与 StatefulSet volumeClaimTemplates 的 spec.storageClassName (及旧版注解),
+// 返回按源存储类汇总的改写记录; 未指定存储类的 PVC 使用目标集群的默认存储类, 不做改写
+func rewriteStorageClasses(items []restoreItem, mapping map[string]string) []storageClassRewrite {
+	if len(mapping) == 0 {
+		return nil
+	}
+	rewrites := make(map[string]*storageClassRewrite)
+	record := func(from, to, desc string) {
+		r, ok := rewrites[from]
+		if !ok {
+			r = &storageClassRewrite{From: from, To: to}
+			rewrites[from] = r
+		}
+		r.Objects = append(r.Objects, desc)
+	}
+	for _, item := range items {
+		obj := item.Obj
+		switch obj.GetKind() {
+		case "PersistentVolumeClaim":
+			if from, ok := mapClaimStorageClass(obj.Object, mapping); ok {
+				record(from, mapping[from], describeObject(obj))
+			}
+		case "StatefulSet":
+			templates, _, _ := unstructured.NestedSlice(obj.Object, "spec", "volumeClaimTemplates")
+			changed := false
+			for _, template := range mapsOf(templates) {
+				if from, ok := mapClaimStorageClass(template, mapping); ok {
+					name, _, _ := unstructured.NestedString(template, "metadata", "name")
+					record(from, mapping[from], fmt.Sprintf("%s (volumeClaimTemplate %s)", describeObject(obj), name))
+					changed = true
+				}
+			}
+			if changed {
+				unstructured.SetNestedSlice(obj.Object, templates, "spec", "volumeClaimTemplates")
+			}
+		}
+	}
+	result := make([]storageClassRewrite, 0, len(rewrites))
+	for _, r := range rewrites {
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].From < result[j].From })
+	return result
+}
+
+// mapClaimStorageClass 改写单个 PVC (或 volumeClaimTemplate) 的存储类, 返回改写前的存储类
+func mapClaimStorageClass(claim map[string]interface{}, mapping map[string]string) (string, bool) {
+	from, found, _ := unstructured.NestedString(claim, "spec", "storageClassName")
+	annotations, _, _ := unstructured.NestedStringMap(claim, "metadata", "annotations")
+	fromAnnotation, annotated := annotations[annotationBetaStorageClass]
+	if !found && annotated {
+		from = fromAnnotation
+	}
+	to, ok := mapping[from]
+	if (!found && !annotated) || !ok {
+		return "", false
+	}
+	if to == "" {
+		unstructured.RemoveNestedField(claim, "spec", "storageClassName")
+	} else if found {
+		unstructured.SetNestedField(claim, to, "spec", "storageClassName")
+	}
+	if annotated {
+		if to == "" {
+			delete(annotations, annotationBetaStorageClass)
+		} else {
+			annotations[annotationBetaStorageClass] = to
+		}
+		if len(annotations) == 0 {
+			unstructured.RemoveNestedField(claim, "metadata", "annotations")
+		} else {
+			unstructured.SetNestedStringMap(claim, annotations, "metadata", "annotations")
+		}
+	}
+	return from, true
+}
+
+// printStorageClassRewrites 输出 rewriteStorageClasses 的报告, 并提示未匹配任何对象的映射
+func printStorageClassRewrites(rewrites []storageClassRewrite, mapping map[string]string) {
+	used := make(map[string]bool)
+	for _, r := range rewrites {
+		used[r.From] = true
+		to := r.To
+		if to == "" {
+			to = "(目标集群默认存储类)"
+		}
+		fmt.Fprintf(logOut, "已将 %d 个卷声明的存储类 %s 改写为 %s:\n", len(r.Objects), r.From, to)
+		for _, desc := range r.Objects {
+			fmt.Fprintf(logOut, "  - %s\n", desc)
+		}
+	}
+	var unused []string
+	for from := range mapping {
+		if !used[from] {
+			unused = append(unused, from)
+		}
+	}
+	sort.Strings(unused)
+	for _, from := range unused {
+		fmt.Fprintf(os.Stderr, "警告: 备份中没有使用存储类 '%s' 的 PVC, 该映射未生效\n", from)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParseStorageClassMapping(t *testing.T) {
+	mapping, err := parseStorageClassMapping([]string{"gp2=standard, io1=premium", "legacy="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mapping) != 3 || mapping["gp2"] != "standard" || mapping["io1"] != "premium" || mapping["legacy"] != "" {
+		t.Errorf("映射 = %v", mapping)
+	}
+	for _, bad := range [][]string{{"gp2"}, {"=standard"}, {"gp2=standard", "gp2=premium"}} {
+		if _, err := parseStorageClassMapping(bad); err == nil {
+			t.Errorf("parseStorageClassMapping(%q) 应返回错误", bad)
+		}
+	}
+}
+
+func TestRewriteStorageClasses(t *testing.T) {
+	claim := func(name, class string) *unstructured.Unstructured {
+		return fakeObject("v1", "PersistentVolumeClaim", "db", name, map[string]interface{}{
+			"spec": map[string]interface{}{"storageClassName": class},
+		})
+	}
+	data := claim("data", "gp2")
+	other := claim("cache", "local-path")
+	legacy := fakeObject("v1", "PersistentVolumeClaim", "db", "legacy", nil)
+	legacy.SetAnnotations(map[string]string{annotationBetaStorageClass: "gp2"})
+	defaulted := claim("scratch", "io1")
+	sts := fakeObject("apps/v1", "StatefulSet", "db", "postgres", map[string]interface{}{
+		"spec": map[string]interface{}{"volumeClaimTemplates": []interface{}{
+			map[string]interface{}{"metadata": map[string]interface{}{"name": "pgdata"}, "spec": map[string]interface{}{"storageClassName": "gp2"}},
+		}},
+	})
+	items := []restoreItem{{Obj: data}, {Obj: other}, {Obj: legacy}, {Obj: defaulted}, {Obj: sts}}
+
+	rewrites := rewriteStorageClasses(items, map[string]string{"gp2": "standard", "io1": ""})
+	if len(rewrites) != 2 || rewrites[0].From != "gp2" || len(rewrites[0].Objects) != 3 || rewrites[1].From != "io1" {
+		t.Fatalf("改写记录 = %+v", rewrites)
+	}
+	if got, _, _ := unstructured.NestedString(data.Object, "spec", "storageClassName"); got != "standard" {
+		t.Errorf("data 的存储类 = %s", got)
+	}
+	if got, _, _ := unstructured.NestedString(other.Object, "spec", "storageClassName"); got != "local-path" {
+		t.Errorf("未映射的存储类被改写为 %s", got)
+	}
+	if got := legacy.GetAnnotations()[annotationBetaStorageClass]; got != "standard" {
+		t.Errorf("旧版注解 = %s", got)
+	}
+	if _, found, _ := unstructured.NestedString(defaulted.Object, "spec", "storageClassName"); found {
+		t.Error("目标为空时应移除 storageClassName")
+	}
+	templates, _, _ := unstructured.NestedSlice(sts.Object, "spec", "volumeClaimTemplates")
+	if got, _, _ := unstructured.NestedString(mapsOf(templates)[0], "spec", "storageClassName"); got != "standard" {
+		t.Errorf("volumeClaimTemplate 的存储类 = %s", got)
+	}
+}
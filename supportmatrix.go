@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+)
+
+// list-types 输出的资源类型状态
+const (
+	typeStatusEnabled  = "enabled"  // 按当前参数会备份
+	typeStatusLimited  = "limited"  // 会备份, 但只能读取部分命名空间
+	typeStatusSkipped  = "skipped"  // 已启用, 但集群不提供或无权读取, 备份时跳过或报错
+	typeStatusDisabled = "disabled" // 按当前参数不备份
+)
+
+var typeStatusText = map[string]string{
+	typeStatusEnabled:  "备份",
+	typeStatusLimited:  "部分",
+	typeStatusSkipped:  "跳过",
+	typeStatusDisabled: "未启用",
+}
+
+// typeSupport 单个资源类型在工具与集群中的支持情况
+type typeSupport struct {
+	Type       string `json:"type"`
+	Kind       string `json:"kind"`
+	APIVersion string `json:"apiVersion"`
+	Namespaced bool   `json:"namespaced"`
+	Source     string `json:"source"` // builtin, optional, runtime 或 preset:<名称>
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// typeSelection list-types 中影响资源类型是否启用的备份参数, 含义与备份命令相同
+type typeSelection struct {
+	types              string
+	presets            []string
+	includeRuntime     bool
+	skipSecrets        bool
+	noClusterResources bool
+}
+
+// supportDiscovery buildSupportMatrix 需要的 discovery 接口
+type supportDiscovery interface {
+	serverResourcesLister
+	ServerGroups() (*metav1.APIGroupList, error)
+}
+
+func runListTypes(args []string) {
+	var sel typeSelection
+	var kubeconfig, kubeContext, presetStr, output string
+	var cluster bool
+	fs := pflag.NewFlagSet("list-types", pflag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: k8s-backup list-types [--cluster] [备份参数]\n")
+		fs.PrintDefaults()
+	}
+	fs.BoolVar(&cluster, "cluster", false, "通过 discovery 与当前集群提供的 API 比较, 并检查集群范围的 list 权限, 列出备份时会跳过的类型及原因")
+	fs.StringVar(&kubeconfig, "kubeconfig", "", "kubeconfig文件路径 (默认使用~/.kube/config)")
+	fs.StringVar(&kubeContext, "context", "", "kubeconfig中的上下文名称 (默认使用当前上下文)")
+	fs.StringVarP(&sel.types, "type", "t", "all", "备份的资源类型 (同备份命令)")
+	fs.StringVar(&presetStr, "preset", "", "额外备份的资源预设 (同备份命令)")
+	fs.BoolVar(&sel.includeRuntime, "include-runtime-objects", false, "同备份命令")
+	fs.BoolVar(&sel.skipSecrets, "skip-secrets", false, "同备份命令")
+	fs.BoolVar(&sel.noClusterResources, "no-cluster-resources", false, "同备份命令")
+	fs.StringVarP(&output, "output", "o", "text", "输出格式 (text|json)")
+	fs.Parse(args)
+
+	if output != "text" && output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s' (可选: text, json)\n", output)
+		os.Exit(2)
+	}
+	presets, err := parsePresets(presetStr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(2)
+	}
+	sel.presets = presets
+
+	var client supportDiscovery
+	var canList func(schema.GroupVersionResource) bool
+	if cluster {
+		config, err := loadClientConfig(kubeconfig, kubeContext)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 无法加载Kubernetes配置: %v\n", err)
+			os.Exit(1)
+		}
+		discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 创建发现客户端失败: %v\n", err)
+			os.Exit(1)
+		}
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 创建标准客户端失败: %v\n", err)
+			os.Exit(1)
+		}
+		client = discoveryClient
+		canList = func(gvr schema.GroupVersionResource) bool {
+			allowed, err := checkResourceAccess(clientset, gvr, "")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+			}
+			return allowed
+		}
+	}
+
+	matrix, err := buildSupportMatrix(sel, client, canList)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if output == "json" {
+		data, _ := json.MarshalIndent(matrix, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	printSupportMatrix(matrix, cluster)
+}
+
+// buildSupportMatrix 列出全部已知资源类型按 sel 是否启用; client 非空时再与集群提供的 API 比较,
+// canList 非空时检查集群范围的 list 权限 (命名空间级类型无此权限时仍可能在部分命名空间中备份)
+func buildSupportMatrix(sel typeSelection, client supportDiscovery, canList func(schema.GroupVersionResource) bool) ([]typeSupport, error) {
+	enabled, err := selectedTypes(sel)
+	if err != nil {
+		return nil, err
+	}
+
+	var groupVersions map[string][]string // 组 -> 集群提供的版本
+	resources := make(map[string]map[string]bool)
+	if client != nil {
+		groups, err := client.ServerGroups()
+		if err != nil {
+			return nil, fmt.Errorf("获取集群API组失败: %w", err)
+		}
+		groupVersions = make(map[string][]string)
+		for _, g := range groups.Groups {
+			for _, v := range g.Versions {
+				groupVersions[g.Name] = append(groupVersions[g.Name], v.Version)
+			}
+		}
+	}
+
+	var all []string
+	for resType := range resourceMap {
+		all = append(all, resType)
+	}
+	sortResourceTypes(all)
+
+	matrix := make([]typeSupport, 0, len(all))
+	for _, resType := range all {
+		resInfo := resourceMap[resType]
+		entry := typeSupport{
+			Type:       resType,
+			Kind:       resInfo.Kind,
+			APIVersion: resInfo.GVR.GroupVersion().String(),
+			Namespaced: resInfo.Namespaced,
+			Source:     typeSource(resInfo),
+			Status:     typeStatusEnabled,
+		}
+		if reason, ok := enabled[resType]; !ok || reason != "" {
+			entry.Status, entry.Reason = typeStatusDisabled, reason
+			matrix = append(matrix, entry)
+			continue
+		}
+		if client == nil {
+			matrix = append(matrix, entry)
+			continue
+		}
+
+		gv := resInfo.GVR.GroupVersion()
+		served, ok := resources[gv.String()]
+		if !ok {
+			served = make(map[string]bool)
+			if list, err := client.ServerResourcesForGroupVersion(gv.String()); err == nil {
+				for _, r := range list.APIResources {
+					served[r.Name] = true
+				}
+			}
+			resources[gv.String()] = served
+		}
+		silent := resInfo.Preset != "" || resInfo.Optional
+		switch {
+		case served[resInfo.GVR.Resource]:
+			if canList != nil && !canList(resInfo.GVR) {
+				if resInfo.Namespaced {
+					entry.Status, entry.Reason = typeStatusLimited, "无集群范围的 list 权限, 只备份有权限的命名空间"
+				} else {
+					entry.Status, entry.Reason = typeStatusSkipped, "无 list 权限"
+				}
+			}
+		case len(groupVersions[gv.Group]) == 0:
+			entry.Status, entry.Reason = typeStatusSkipped, fmt.Sprintf("集群不提供 API 组 %s", displayGroup(gv.Group))
+		case !slices.Contains(groupVersions[gv.Group], gv.Version):
+			entry.Status, entry.Reason = typeStatusSkipped, fmt.Sprintf("集群只提供 %s 的 %s 版本", displayGroup(gv.Group), strings.Join(groupVersions[gv.Group], ", "))
+		default:
+			entry.Status, entry.Reason = typeStatusSkipped, fmt.Sprintf("集群的 %s 中没有 %s", gv, resInfo.GVR.Resource)
+		}
+		if entry.Status == typeStatusSkipped && !served[resInfo.GVR.Resource] {
+			if silent {
+				entry.Reason += ", 静默跳过"
+			} else {
+				entry.Reason += ", 备份时报错"
+			}
+		}
+		matrix = append(matrix, entry)
+	}
+	return matrix, nil
+}
+
+// selectedTypes 按备份命令相同的规则确定启用的类型; 值为空表示启用, 否则为未启用的原因
+func selectedTypes(sel typeSelection) (map[string]string, error) {
+	reasons := make(map[string]string)
+	if sel.types == "all" || sel.types == "" {
+		for _, resType := range allResourceTypes() {
+			reasons[resType] = ""
+		}
+		for _, resType := range presetResourceTypes(sel.presets) {
+			reasons[resType] = ""
+		}
+		for resType, resInfo := range resourceMap {
+			switch {
+			case resInfo.Runtime && !sel.includeRuntime:
+				reasons[resType] = "需要 --include-runtime-objects"
+			case resInfo.Runtime:
+				reasons[resType] = ""
+			case resInfo.Preset != "":
+				if _, ok := reasons[resType]; !ok {
+					reasons[resType] = "需要 --preset " + resInfo.Preset
+				}
+			}
+		}
+	} else {
+		for _, resType := range splitList(sel.types) {
+			resInfo, ok := resourceMap[resType]
+			if !ok {
+				return nil, fmt.Errorf("不支持的资源类型 '%s'", resType)
+			}
+			reasons[resType] = ""
+			if resInfo.Runtime && !sel.includeRuntime {
+				reasons[resType] = "需要 --include-runtime-objects"
+			}
+		}
+		for resType := range resourceMap {
+			if _, ok := reasons[resType]; !ok {
+				reasons[resType] = "未包含在 --type 中"
+			}
+		}
+	}
+	if sel.skipSecrets && reasons["secrets"] == "" {
+		reasons["secrets"] = "--skip-secrets"
+	}
+	if sel.noClusterResources {
+		for resType, resInfo := range resourceMap {
+			if !resInfo.Namespaced && reasons[resType] == "" {
+				reasons[resType] = "--no-cluster-resources"
+			}
+		}
+	}
+	return reasons, nil
+}
+
+func typeSource(resInfo ResourceInfo) string {
+	switch {
+	case resInfo.Preset != "":
+		return "preset:" + resInfo.Preset
+	case resInfo.Runtime:
+		return "runtime"
+	case resInfo.Optional:
+		return "optional"
+	}
+	return "builtin"
+}
+
+func displayGroup(group string) string {
+	if group == "" {
+		return "core"
+	}
+	return group
+}
+
+// printSupportMatrix 以表格输出, 连接集群时在末尾汇总会被跳过的类型数
+func printSupportMatrix(matrix []typeSupport, cluster bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "类型\tKind\tAPI版本\t范围\t来源\t状态\t原因")
+	skipped := 0
+	for _, e := range matrix {
+		scope := "集群"
+		if e.Namespaced {
+			scope = "命名空间"
+		}
+		if e.Status == typeStatusSkipped {
+			skipped++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Type, e.Kind, e.APIVersion, scope, e.Source, typeStatusText[e.Status], orDash(e.Reason))
+	}
+	w.Flush()
+	if cluster {
+		fmt.Printf("\n已启用的类型中有 %d 个将在当前集群上被跳过\n", skipped)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	discoveryfake "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestBuildSupportMatrix(t *testing.T) {
+	client := &discoveryfake.FakeDiscovery{Fake: &k8stesting.Fake{}}
+	client.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "configmaps"}, {Name: "secrets"}, {Name: "persistentvolumes"}}},
+		{GroupVersion: "autoscaling/v1", APIResources: []metav1.APIResource{{Name: "horizontalpodautoscalers"}}},
+		{GroupVersion: "route.openshift.io/v1", APIResources: []metav1.APIResource{{Name: "routes"}}},
+	}
+	canList := func(gvr schema.GroupVersionResource) bool {
+		return gvr.Resource != "secrets" && gvr.Resource != "persistentvolumes"
+	}
+
+	matrix, err := buildSupportMatrix(typeSelection{types: "all", presets: []string{presetOpenShift}}, client, canList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matrix) != len(resourceMap) {
+		t.Fatalf("应列出全部 %d 个类型, 得到 %d 个", len(resourceMap), len(matrix))
+	}
+	byType := make(map[string]typeSupport)
+	for _, e := range matrix {
+		byType[e.Type] = e
+	}
+	cases := map[string]struct{ status, reason string }{
+		"configmaps":                  {typeStatusEnabled, ""},
+		"secrets":                     {typeStatusLimited, "无集群范围的 list 权限, 只备份有权限的命名空间"},
+		"persistentvolumes":           {typeStatusSkipped, "无 list 权限"},
+		"horizontalpodautoscalers":    {typeStatusSkipped, "集群只提供 autoscaling 的 v1 版本, 备份时报错"},
+		"deployments":                 {typeStatusSkipped, "集群不提供 API 组 apps, 备份时报错"},
+		"routes":                      {typeStatusEnabled, ""},
+		"imagestreams":                {typeStatusSkipped, "集群不提供 API 组 image.openshift.io, 静默跳过"},
+		"validatingadmissionpolicies": {typeStatusSkipped, "集群不提供 API 组 admissionregistration.k8s.io, 静默跳过"},
+		"rollouts":                    {typeStatusDisabled, "需要 --preset argo"},
+		"pods":                        {typeStatusDisabled, "需要 --include-runtime-objects"},
+	}
+	for resType, want := range cases {
+		if got := byType[resType]; got.Status != want.status || got.Reason != want.reason {
+			t.Errorf("%s = %s (%s), 期望 %s (%s)", resType, got.Status, got.Reason, want.status, want.reason)
+		}
+	}
+	if byType["routes"].Source != "preset:openshift" || byType["validatingadmissionpolicies"].Source != "optional" {
+		t.Errorf("来源 = %s, %s", byType["routes"].Source, byType["validatingadmissionpolicies"].Source)
+	}
+}
+
+func TestSelectedTypes(t *testing.T) {
+	reasons, err := selectedTypes(typeSelection{types: "configmaps,secrets,persistentvolumes", skipSecrets: true, noClusterResources: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"configmaps":        "",
+		"secrets":           "--skip-secrets",
+		"persistentvolumes": "--no-cluster-resources",
+		"deployments":       "未包含在 --type 中",
+	}
+	for resType, reason := range want {
+		if reasons[resType] != reason {
+			t.Errorf("%s = %q, 期望 %q", resType, reasons[resType], reason)
+		}
+	}
+	if _, err := selectedTypes(typeSelection{types: "gadgets"}); err == nil {
+		t.Error("未知类型应报错")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// systemConfigDir _global 下保存 --include-system-config 系统配置的目录
+const systemConfigDir = "systemconfig"
+
+// systemConfigRef 系统命名空间中重建控制面后需要的 ConfigMap
+type systemConfigRef struct {
+	namespace string
+	name      string
+	desc      string
+}
+
+// systemConfigObjects --include-system-config 备份的对象清单, 集群中不存在的对象 (如非 EKS 集群的 aws-auth) 静默忽略
+var systemConfigObjects = []systemConfigRef{
+	{namespace: "kube-system", name: "coredns", desc: "CoreDNS 配置 (Corefile, 自定义域名解析)"},
+	{namespace: "kube-system", name: "kube-proxy", desc: "kube-proxy 配置 (代理模式, clusterCIDR)"},
+	{namespace: "kube-system", name: "kubeadm-config", desc: "kubeadm 集群配置 (证书 SAN, 网段)"},
+	{namespace: "kube-system", name: "kubelet-config", desc: "kubeadm 下发的 kubelet 配置"},
+	{namespace: "kube-system", name: "aws-auth", desc: "EKS IAM 角色与用户映射"},
+	{namespace: "kube-public", name: "cluster-info", desc: "集群 CA 与 API 地址 (kubeadm join 使用)"},
+}
+
+// backupSystemConfig 逐个获取 systemConfigObjects 中的 ConfigMap, 写入 _global/systemconfig/<命名空间>_<名称>.yaml
+// 这些对象所在的命名空间默认被排除, 且不属于集群级资源, 因此单独按名称获取而不是列出整个命名空间
+func (b *Backupper) backupSystemConfig(writer *manifestWriter, partition *backupPartition, globalDir string, graph *dependencyGraph) {
+	resInfo := resourceMap["configmaps"]
+	fmt.Fprintln(logOut, "  资源: 系统配置 (--include-system-config)")
+	backupCount := 0
+	for _, ref := range systemConfigObjects {
+		if b.exceeded() {
+			break
+		}
+		resource, err := b.resourceClient(resInfo.GVR).Namespace(ref.namespace).Get(context.TODO(), ref.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if apierrors.IsForbidden(err) {
+			fmt.Fprintf(logOut, "    警告: 无权限读取 %s/%s, 跳过\n", ref.namespace, ref.name)
+			partition.skip(skipEntry{Reason: skipPermissionDenied, Kind: resInfo.Kind, Namespace: ref.namespace, Name: ref.name})
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "    错误: 获取 %s/%s 失败: %v\n", ref.namespace, ref.name, err)
+			b.progress.Emit(progressEvent{Event: "resource_failed", Namespace: ref.namespace, Kind: resInfo.Kind, Name: ref.name, Error: err.Error()})
+			partition.skip(skipEntry{Reason: skipListFailed, Kind: resInfo.Kind, Namespace: ref.namespace, Name: ref.name, Detail: err.Error()})
+			continue
+		}
+
+		entry := newIndexEntry(resource)
+		obj, yamlData, err := renderResource("configmaps", resource, b.cleanOpts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "    错误: 序列化 '%s/%s' 失败: %v\n", ref.namespace, ref.name, err)
+			partition.skip(skipEntry{Reason: skipRenderFailed, Kind: resInfo.Kind, Namespace: ref.namespace, Name: ref.name, Detail: err.Error()})
+			continue
+		}
+		if !b.reserveBytes(len(yamlData)) {
+			msg := b.sizeExceededError(fmt.Sprintf("%s %s/%s", resInfo.Kind, ref.namespace, ref.name))
+			fmt.Fprintf(os.Stderr, "    错误: %s\n", msg)
+			b.progress.Emit(progressEvent{Event: "backup_size_limit_failed", Namespace: ref.namespace, Kind: resInfo.Kind, Name: ref.name, Error: msg})
+			partition.skip(skipEntry{Reason: skipSizeLimit, Kind: resInfo.Kind, Namespace: ref.namespace, Name: ref.name, Detail: "该对象及之后的全部对象未备份"})
+			break
+		}
+		filename := ref.namespace + "_" + ref.name + ".yaml"
+		fullPath, err := writer.write(systemConfigDir, filename, yamlData)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "    错误: 写入文件 '%s' 失败: %v\n", filepath.Join(globalDir, systemConfigDir, filename), err)
+			partition.skip(skipEntry{Reason: skipWriteFailed, Kind: resInfo.Kind, Namespace: ref.namespace, Name: ref.name, Detail: err.Error()})
+			continue
+		}
+		fmt.Fprintf(logOut, "    - %s/%s: %s\n", ref.namespace, ref.name, ref.desc)
+		backupCount++
+		partition.addEntry(entry.withFile(partition.Root, fullPath, yamlData), nil, nil)
+		graph.add(obj)
+		b.progress.Emit(progressEvent{Event: "resource_backed_up", Namespace: ref.namespace, Kind: resInfo.Kind, Name: ref.name, Path: fullPath})
+	}
+	fmt.Fprintf(logOut, "    ✓ 备份 %d 个系统配置\n", backupCount)
+	b.addResources(backupCount)
+	partition.addTotal(backupCount)
+}
+
+// isSystemConfig 判断对象是否为 systemConfigObjects 中的系统配置
+func isSystemConfig(obj *unstructured.Unstructured) bool {
+	if obj.GetKind() != "ConfigMap" {
+		return false
+	}
+	for _, ref := range systemConfigObjects {
+		if obj.GetNamespace() == ref.namespace && obj.GetName() == ref.name {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeSystemConfig 从待恢复对象中移除系统配置, 返回保留的对象与被移除对象的描述
+// 这些配置属于源集群 (如 aws-auth 映射的 IAM 角色, kube-proxy 的网段), 覆盖目标集群的同名对象可能导致无法访问集群或节点网络中断
+func excludeSystemConfig(items []restoreItem) ([]restoreItem, []string) {
+	kept := items[:0]
+	var excluded []string
+	for _, item := range items {
+		if isSystemConfig(item.Obj) {
+			excluded = append(excluded, item.Obj.GetNamespace()+"/"+item.Obj.GetName())
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept, excluded
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupSystemConfig(t *testing.T) {
+	run, partitions := newFakeBackupper(t,
+		[]string{"persistentvolumes"},
+		nil,
+		fakeObject("v1", "ConfigMap", "kube-system", "coredns", map[string]interface{}{
+			"data": map[string]interface{}{"Corefile": ".:53 {\n    forward . /etc/resolv.conf\n}\n"},
+		}),
+		fakeObject("v1", "ConfigMap", "kube-public", "cluster-info", nil),
+		fakeObject("v1", "ConfigMap", "kube-system", "extension-apiserver-authentication", nil),
+	)
+	run.systemConfig = true
+	run.backupClusterResources()
+
+	dir := filepath.Join(partitions.byName[""].Root, "_global", systemConfigDir)
+	for _, name := range []string{"kube-system_coredns.yaml", "kube-public_cluster-info.yaml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("缺少系统配置 %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "kube-system_extension-apiserver-authentication.yaml")); err == nil {
+		t.Error("不应备份清单之外的 kube-system ConfigMap")
+	}
+	if run.totalResources != 2 {
+		t.Errorf("备份资源数 = %d, 期望 2 (不存在的 aws-auth 等应静默忽略)", run.totalResources)
+	}
+
+	// 恢复默认跳过系统配置, 避免覆盖目标集群的同名对象
+	items, err := loadRestoreItems(partitions.byName[""].Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items = append(items, restoreItem{Obj: fakeObject("v1", "ConfigMap", "shop", "coredns", nil)})
+	kept, excluded := excludeSystemConfig(items)
+	if len(excluded) != 2 || len(kept) != 1 || kept[0].Obj.GetNamespace() != "shop" {
+		t.Errorf("跳过 %v, 保留 %d 个对象", excluded, len(kept))
+	}
+}
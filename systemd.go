@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var weekdayNames = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// cronToOnCalendar 将5段式 cron 表达式转换为 systemd 的 OnCalendar 格式
+// 支持 *, 数字, 列表 (a,b), 范围 (a-b) 与步长 (*/n, a-b/n)
+func cronToOnCalendar(expr string) (string, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return "", fmt.Errorf("cron 表达式 '%s' 必须包含5个字段", expr)
+	}
+	minute, err := convertCronField(fields[0], 0, false)
+	if err != nil {
+		return "", err
+	}
+	hour, err := convertCronField(fields[1], 0, false)
+	if err != nil {
+		return "", err
+	}
+	dom, err := convertCronField(fields[2], 1, true)
+	if err != nil {
+		return "", err
+	}
+	month, err := convertCronField(fields[3], 1, true)
+	if err != nil {
+		return "", err
+	}
+	calendar := fmt.Sprintf("*-%s-%s %s:%s:00", month, dom, hour, minute)
+
+	if fields[4] == "*" {
+		return calendar, nil
+	}
+	if fields[2] != "*" {
+		// cron 中日期与星期是"或"的关系, systemd 中是"与"的关系, 无法等价转换
+		return "", fmt.Errorf("cron 表达式 '%s' 同时限定了日期与星期, 无法转换为 OnCalendar, 请手动编写", expr)
+	}
+	weekdays, err := convertCronWeekdays(fields[4])
+	if err != nil {
+		return "", err
+	}
+	return weekdays + " " + calendar, nil
+}
+
+// convertCronField 转换数值字段, noPad 为 true 时不补零 (日期与月份), 否则补零为两位 (时与分)
+func convertCronField(field string, stepStart int, noPad bool) (string, error) {
+	if field == "*" {
+		return "*", nil
+	}
+	var parts []string
+	for _, part := range strings.Split(field, ",") {
+		base, step, hasStep := strings.Cut(part, "/")
+		if hasStep {
+			if _, err := strconv.Atoi(step); err != nil {
+				return "", fmt.Errorf("无效的 cron 步长 '%s'", part)
+			}
+		}
+		var converted string
+		switch {
+		case base == "*":
+			converted = strconv.Itoa(stepStart)
+		case strings.Contains(base, "-"):
+			from, to, _ := strings.Cut(base, "-")
+			f, err1 := strconv.Atoi(from)
+			t, err2 := strconv.Atoi(to)
+			if err1 != nil || err2 != nil {
+				return "", fmt.Errorf("无效的 cron 范围 '%s'", part)
+			}
+			converted = padCron(f, noPad) + ".." + padCron(t, noPad)
+		default:
+			n, err := strconv.Atoi(base)
+			if err != nil {
+				return "", fmt.Errorf("无效的 cron 字段 '%s'", part)
+			}
+			converted = padCron(n, noPad)
+		}
+		if hasStep {
+			converted += "/" + step
+		}
+		parts = append(parts, converted)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func padCron(n int, noPad bool) string {
+	if noPad {
+		return strconv.Itoa(n)
+	}
+	return fmt.Sprintf("%02d", n)
+}
+
+// convertCronWeekdays 将星期字段 (0-7, 0 与 7 均为周日) 转换为 systemd 的星期名称
+func convertCronWeekdays(field string) (string, error) {
+	var parts []string
+	for _, part := range strings.Split(field, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		f, err := strconv.Atoi(from)
+		if err != nil || f < 0 || f > 7 {
+			return "", fmt.Errorf("无效的 cron 星期 '%s'", part)
+		}
+		if !isRange {
+			parts = append(parts, weekdayNames[f])
+			continue
+		}
+		t, err := strconv.Atoi(to)
+		if err != nil || t < 0 || t > 7 {
+			return "", fmt.Errorf("无效的 cron 星期 '%s'", part)
+		}
+		parts = append(parts, weekdayNames[f]+".."+weekdayNames[t])
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// systemdUnits 生成 service 与 timer 单元的内容
+func systemdUnits(unitName, onCalendar, kubeconfig string, execArgs []string) (service, timer string) {
+	var quoted []string
+	for _, arg := range execArgs {
+		quoted = append(quoted, systemdQuote(arg))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("[Unit]\n")
+	sb.WriteString("Description=Kubernetes 资源定时备份 (k8s-backup)\n")
+	sb.WriteString("Wants=network-online.target\n")
+	sb.WriteString("After=network-online.target\n\n")
+	sb.WriteString("[Service]\n")
+	sb.WriteString("Type=oneshot\n")
+	if kubeconfig != "" {
+		sb.WriteString("Environment=" + systemdQuote("KUBECONFIG="+kubeconfig) + "\n")
+	}
+	sb.WriteString("ExecStart=" + strings.Join(quoted, " ") + "\n")
+	service = sb.String()
+
+	sb.Reset()
+	sb.WriteString("[Unit]\n")
+	sb.WriteString("Description=定时触发 " + unitName + ".service\n\n")
+	sb.WriteString("[Timer]\n")
+	sb.WriteString("OnCalendar=" + onCalendar + "\n")
+	sb.WriteString("Persistent=true\n")
+	sb.WriteString("Unit=" + unitName + ".service\n\n")
+	sb.WriteString("[Install]\n")
+	sb.WriteString("WantedBy=timers.target\n")
+	timer = sb.String()
+	return service, timer
+}
+
+// systemdQuote 按 systemd 的规则为包含空白或引号的参数加双引号
+func systemdQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\%$") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$")
+	return `"` + r.Replace(arg) + `"`
+}
+
+// installSystemd 输出或写入 systemd 单元, 执行的是当前二进制与给定的备份参数
+func installSystemd(opts installOptions) error {
+	onCalendar, err := cronToOnCalendar(opts.schedule)
+	if err != nil {
+		return err
+	}
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("无法确定当前可执行文件路径: %w", err)
+	}
+	kubeconfig := opts.kubeconfig
+	if kubeconfig == "" {
+		kubeconfig = os.Getenv("KUBECONFIG")
+	}
+	if kubeconfig != "" && !strings.Contains(kubeconfig, string(os.PathListSeparator)) {
+		if abs, err := filepath.Abs(kubeconfig); err == nil {
+			kubeconfig = abs
+		}
+	}
+
+	service, timer := systemdUnits(opts.name, onCalendar, kubeconfig, append([]string{binary}, opts.backupArgs...))
+	if opts.systemdDir == "" {
+		fmt.Printf("# %s.service\n%s\n# %s.timer\n%s", opts.name, service, opts.name, timer)
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(opts.systemdDir, opts.name+".service"), []byte(service), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(opts.systemdDir, opts.name+".timer"), []byte(timer), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(logOut, "已写入 %s.service 与 %s.timer 到 %s, 执行以下命令启用:\n", opts.name, opts.name, opts.systemdDir)
+	fmt.Fprintf(logOut, "  systemctl daemon-reload && systemctl enable --now %s.timer\n", opts.name)
+	return nil
+}
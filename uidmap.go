@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// uidMap 恢复过程中源集群 UID 到目标集群 UID 的映射
+// 备份保留的 ownerReferences 与部分 CR 字段以 UID 引用其他对象, 原样恢复时指向不存在的对象
+// (ownerReferences 会使对象被垃圾回收器删除), 恢复时据此改写为目标集群中的 UID
+type uidMap struct {
+	byOld      map[string]string // 源 UID -> 目标 UID
+	oldUIDs    map[string]string // objectKey -> 源 UID, 来自 index.yaml
+	referenced map[string]bool   // 被其他对象引用的源 UID
+}
+
+// uidRewrite 单个对象上改写的引用
+type uidRewrite struct {
+	Fields  int      // 改写的字段数 (含 ownerReferences)
+	Dropped []string // 目标集群中找不到属主而移除的 ownerReferences, Kind/名称
+}
+
+// newUIDMap 根据备份索引与待恢复对象建立映射表, 旧版本备份的索引没有 UID 时只能按名称解析 ownerReferences
+func newUIDMap(items []restoreItem, index map[string]indexEntry) *uidMap {
+	m := &uidMap{byOld: make(map[string]string), oldUIDs: make(map[string]string), referenced: make(map[string]bool)}
+	known := make(map[string]bool)
+	for _, item := range items {
+		key := objectKey(item.Obj.GetKind(), item.Obj.GetNamespace(), item.Obj.GetName())
+		if entry, ok := index[key]; ok && entry.UID != "" {
+			m.oldUIDs[key] = entry.UID
+			known[entry.UID] = true
+		}
+	}
+	for _, item := range items {
+		for _, ref := range item.Obj.GetOwnerReferences() {
+			m.referenced[string(ref.UID)] = true
+		}
+		walkUIDStrings(item.Obj.Object, func(s string) (string, bool) {
+			if known[s] {
+				m.referenced[s] = true
+			}
+			return "", false
+		})
+	}
+	return m
+}
+
+// needs 判断对象的源 UID 是否被其他对象引用; 对象已存在而跳过时, 只有此时才需要读取其在目标集群中的 UID
+func (m *uidMap) needs(obj *unstructured.Unstructured) bool {
+	return m.referenced[m.oldUIDs[objectKey(obj.GetKind(), obj.GetNamespace(), obj.GetName())]]
+}
+
+// record 记录对象在目标集群中的 UID
+func (m *uidMap) record(obj *unstructured.Unstructured, newUID string) {
+	if old := m.oldUIDs[objectKey(obj.GetKind(), obj.GetNamespace(), obj.GetName())]; old != "" && newUID != "" {
+		m.byOld[old] = newUID
+	}
+}
+
+// rewrite 在创建对象前改写其中的 UID 引用
+// ownerReferences 先查映射表, 未命中时按 Kind/名称在目标集群中查找属主, 仍找不到则移除该引用并在结果中列出;
+// 其他字段只改写与映射表中源 UID 完全相同的字符串值
+func (m *uidMap) rewrite(client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured) uidRewrite {
+	var result uidRewrite
+	if owners := obj.GetOwnerReferences(); len(owners) > 0 {
+		kept := owners[:0]
+		for _, ref := range owners {
+			newUID, ok := m.byOld[string(ref.UID)]
+			if !ok {
+				newUID, ok = lookupOwnerUID(client, mapper, obj.GetNamespace(), ref)
+				if ok && ref.UID != "" {
+					m.byOld[string(ref.UID)] = newUID
+				}
+			}
+			if !ok {
+				result.Dropped = append(result.Dropped, ref.Kind+"/"+ref.Name)
+				continue
+			}
+			if string(ref.UID) != newUID {
+				ref.UID = types.UID(newUID)
+				result.Fields++
+			}
+			kept = append(kept, ref)
+		}
+		obj.SetOwnerReferences(kept)
+	}
+	if len(m.byOld) > 0 {
+		for field, value := range obj.Object {
+			if field == "metadata" {
+				continue
+			}
+			result.Fields += walkUIDStrings(value, func(s string) (string, bool) {
+				newUID, ok := m.byOld[s]
+				return newUID, ok
+			})
+		}
+	}
+	return result
+}
+
+// lookupOwnerUID 按 ownerReference 的 apiVersion/Kind/名称在目标集群中查找属主的 UID
+// 属主只能与对象位于同一命名空间或为集群级资源
+func lookupOwnerUID(client dynamic.Interface, mapper meta.RESTMapper, namespace string, ref metav1.OwnerReference) (string, bool) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil || mapper == nil {
+		return "", false
+	}
+	mapping, err := mapper.RESTMapping(gv.WithKind(ref.Kind).GroupKind(), gv.Version)
+	if err != nil {
+		return "", false
+	}
+	var resClient dynamic.ResourceInterface = client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		resClient = client.Resource(mapping.Resource).Namespace(namespace)
+	}
+	owner, err := resClient.Get(context.TODO(), ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", false
+	}
+	return string(owner.GetUID()), true
+}
+
+// walkUIDStrings 遍历嵌套的 map 与列表, 对每个字符串调用 replace, 返回被替换的个数
+func walkUIDStrings(v interface{}, replace func(string) (string, bool)) int {
+	count := 0
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			if s, ok := item.(string); ok {
+				if r, ok := replace(s); ok {
+					value[k] = r
+					count++
+				}
+				continue
+			}
+			count += walkUIDStrings(item, replace)
+		}
+	case []interface{}:
+		for i, item := range value {
+			if s, ok := item.(string); ok {
+				if r, ok := replace(s); ok {
+					value[i] = r
+					count++
+				}
+				continue
+			}
+			count += walkUIDStrings(item, replace)
+		}
+	}
+	return count
+}
+
+// printUIDRewrite 输出单个对象的改写结果
+func printUIDRewrite(desc string, r uidRewrite) {
+	for _, owner := range r.Dropped {
+		fmt.Fprintf(logOut, "  ! %s 的属主 %s 在目标集群中不存在, 已移除该 ownerReference (否则对象会被垃圾回收)\n", desc, owner)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestUIDMapRewrite(t *testing.T) {
+	owner := fakeObject("example.com/v1", "Database", "app", "db", nil)
+	child := fakeObject("v1", "Secret", "app", "db-credentials", map[string]interface{}{
+		"spec": map[string]interface{}{"databaseRef": map[string]interface{}{"uid": "old-db"}},
+	})
+	child.SetOwnerReferences([]metav1.OwnerReference{
+		{APIVersion: "example.com/v1", Kind: "Database", Name: "db", UID: "old-db"},
+		{APIVersion: "example.com/v1", Kind: "Database", Name: "gone", UID: "old-gone"},
+	})
+	index := map[string]indexEntry{
+		objectKey("Database", "app", "db"):           {UID: "old-db"},
+		objectKey("Secret", "app", "db-credentials"): {UID: "old-secret"},
+	}
+	items := []restoreItem{{Obj: owner}, {Obj: child}}
+
+	uids := newUIDMap(items, index)
+	if !uids.needs(owner) || uids.needs(child) {
+		t.Error("只有被引用的对象需要记录目标 UID")
+	}
+	uids.record(owner, "new-db")
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Database"}, meta.RESTScopeNamespace)
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{{Group: "example.com", Version: "v1", Resource: "databases"}: "DatabaseList"})
+
+	result := uids.rewrite(client, mapper, child)
+	if result.Fields != 2 {
+		t.Errorf("改写 %d 处, 期望 ownerReferences 与 spec 各 1 处", result.Fields)
+	}
+	if len(result.Dropped) != 1 || result.Dropped[0] != "Database/gone" {
+		t.Errorf("移除的 ownerReferences = %v", result.Dropped)
+	}
+	refs := child.GetOwnerReferences()
+	if len(refs) != 1 || refs[0].UID != "new-db" {
+		t.Errorf("ownerReferences = %+v", refs)
+	}
+	if uid := child.Object["spec"].(map[string]interface{})["databaseRef"].(map[string]interface{})["uid"]; uid != "new-db" {
+		t.Errorf("spec 中的 UID = %v", uid)
+	}
+}
+
+func TestUIDMapLookupExistingOwner(t *testing.T) {
+	existing := fakeObject("apps/v1", "Deployment", "app", "web", nil)
+	existing.SetUID("target-web")
+	pod := fakeObject("v1", "ConfigMap", "app", "web-config", nil)
+	pod.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", UID: "source-web"}})
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), existing)
+
+	// 属主不在本次恢复中但目标集群已存在, 按名称查找
+	result := newUIDMap([]restoreItem{{Obj: pod}}, nil).rewrite(client, mapper, pod)
+	if result.Fields != 1 || len(result.Dropped) != 0 || pod.GetOwnerReferences()[0].UID != "target-web" {
+		t.Errorf("结果 = %+v, ownerReferences = %+v", result, pod.GetOwnerReferences())
+	}
+}
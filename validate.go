@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/pflag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validateRestoreOptions validate-restore 子命令的参数
+type validateRestoreOptions struct {
+	kubeconfig  string
+	kubeContext string
+	backupDir   string
+	kind        bool
+	kindImage   string
+	keepKind    bool
+	dryRun      bool
+}
+
+// restoreRejection 被 API server 拒绝的清单
+type restoreRejection struct {
+	Path   string
+	Object string
+	Error  string
+}
+
+// runValidateRestore 实现 validate-restore 子命令: 将备份应用到沙箱集群, 报告被 API server 拒绝的清单
+// 默认使用服务端 dry-run, 对象不会真正写入; 命名空间会被真实创建, 否则其中的对象无法通过校验
+func runValidateRestore(args []string) {
+	var opts validateRestoreOptions
+	fs := pflag.NewFlagSet("validate-restore", pflag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: k8s-backup validate-restore [参数] <备份目录>\n")
+		fmt.Fprintf(os.Stderr, "示例: k8s-backup validate-restore --context sandbox ./k8s-backup-20240101-020000\n")
+		fmt.Fprintf(os.Stderr, "      k8s-backup validate-restore --kind ./k8s-backup-20240101-020000\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.kubeconfig, "kubeconfig", "", "沙箱集群的kubeconfig文件路径 (默认使用~/.kube/config)")
+	fs.StringVar(&opts.kubeContext, "context", "", "沙箱集群在kubeconfig中的上下文名称 (默认使用当前上下文)")
+	fs.BoolVar(&opts.kind, "kind", false, "使用 kind 创建临时集群进行校验, 结束后删除 (需要 PATH 中有 kind 命令)")
+	fs.StringVar(&opts.kindImage, "kind-image", "", "kind 节点镜像, 用于匹配生产集群的 Kubernetes 版本 (如 kindest/node:v1.30.0)")
+	fs.BoolVar(&opts.keepKind, "keep-kind", false, "校验结束后保留 kind 集群, 便于排查")
+	fs.BoolVar(&opts.dryRun, "dry-run", true, "使用服务端 dry-run 校验; 设为 false 时真实创建对象 (仅用于可丢弃的沙箱)")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	opts.backupDir = fs.Arg(0)
+	if opts.kind && (opts.kubeconfig != "" || opts.kubeContext != "") {
+		fmt.Fprintln(os.Stderr, "错误: --kind 不能与 --kubeconfig/--context 同时使用")
+		os.Exit(2)
+	}
+
+	os.Exit(validateRestore(opts))
+}
+
+// validateRestore 执行校验并返回退出码, 单独成函数以保证 kind 集群在退出前被清理
+func validateRestore(opts validateRestoreOptions) int {
+	if backupMeta, err := loadBackupMetadata(opts.backupDir); err == nil && backupMeta != nil && backupMeta.Tool != nil {
+		if warning := checkToolVersion(backupMeta.Tool.Version); warning != "" {
+			fmt.Fprintf(os.Stderr, "警告: %s\n", warning)
+		}
+	}
+	items, err := loadRestoreItems(opts.backupDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 读取备份清单失败: %v\n", err)
+		return 1
+	}
+
+	if opts.kind {
+		cleanup, kubeconfig, err := createKindCluster(opts.kindImage, opts.keepKind)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return 1
+		}
+		defer cleanup()
+		opts.kubeconfig = kubeconfig
+	}
+
+	rejections, err := validateRestoreItems(opts, items)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(logOut, "\n校验完成: 共 %d 个对象, 被拒绝 %d 个\n", len(items), len(rejections))
+	for _, r := range rejections {
+		fmt.Fprintf(logOut, "  ✗ %s (%s)\n      %s\n", r.Object, r.Path, r.Error)
+	}
+	if len(rejections) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// validateRestoreItems 按恢复顺序逐个提交对象, 收集被拒绝的清单
+// 已存在的对象视为通过, 沙箱中可能已有内置对象 (如 default ServiceAccount)
+func validateRestoreItems(opts validateRestoreOptions, items []restoreItem) ([]restoreRejection, error) {
+	dynamicClient, mapper, err := newApplyClients(opts.kubeconfig, opts.kubeContext, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(logOut, "校验备份: %s (共 %d 个对象, dry-run: %v)\n", opts.backupDir, len(items), opts.dryRun)
+	var rejections []restoreRejection
+	for _, item := range items {
+		obj := item.Obj
+		desc := describeObject(obj)
+		resClient, err := resourceClientFor(dynamicClient, mapper, obj)
+		if err != nil {
+			rejections = append(rejections, restoreRejection{Path: item.Path, Object: desc, Error: err.Error()})
+			continue
+		}
+		createOpts := metav1.CreateOptions{}
+		if opts.dryRun && obj.GetKind() != "Namespace" {
+			createOpts.DryRun = []string{metav1.DryRunAll}
+		}
+		if _, err := resClient.Create(context.TODO(), obj, createOpts); err != nil && !apierrors.IsAlreadyExists(err) {
+			rejections = append(rejections, restoreRejection{Path: item.Path, Object: desc, Error: err.Error()})
+			continue
+		}
+		fmt.Fprintf(logOut, "  ✓ %s\n", desc)
+	}
+	return rejections, nil
+}
+
+// createKindCluster 创建临时 kind 集群, 返回清理函数与其 kubeconfig 路径
+func createKindCluster(image string, keep bool) (func(), string, error) {
+	name := fmt.Sprintf("k8s-backup-validate-%d", time.Now().Unix())
+	dir, err := os.MkdirTemp("", name)
+	if err != nil {
+		return nil, "", err
+	}
+	kubeconfig := filepath.Join(dir, "kubeconfig")
+
+	args := []string{"create", "cluster", "--name", name, "--kubeconfig", kubeconfig, "--wait", "2m"}
+	if image != "" {
+		args = append(args, "--image", image)
+	}
+	fmt.Fprintf(logOut, "创建临时 kind 集群 %s ...\n", name)
+	cmd := exec.Command("kind", args...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		os.RemoveAll(dir)
+		return nil, "", fmt.Errorf("创建 kind 集群失败: %w", err)
+	}
+
+	cleanup := func() {
+		if keep {
+			fmt.Fprintf(logOut, "保留 kind 集群 %s, kubeconfig: %s\n", name, kubeconfig)
+			return
+		}
+		cmd := exec.Command("kind", "delete", "cluster", "--name", name)
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 删除 kind 集群 %s 失败: %v\n", name, err)
+		}
+		os.RemoveAll(dir)
+	}
+	return cleanup, kubeconfig, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// valueRefPattern 匹配清单字符串中的 ${VAR} 引用
+var valueRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// loadRestoreValues 读取 --values 文件 (键值均为字符串的YAML映射) 并叠加 --set KEY=VALUE, --set 优先
+func loadRestoreValues(path string, sets []string) (map[string]string, error) {
+	values := make(map[string]string)
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("解析 '%s' 失败: %w", path, err)
+		}
+	}
+	for _, set := range sets {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok || !valueRefPattern.MatchString("${"+key+"}") {
+			return nil, fmt.Errorf("--set 格式应为 KEY=VALUE, 实际为 '%s'", set)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// substituteValues 将对象中所有字符串值里的 ${VAR} 替换为 values 中的值
+// 未定义的引用保持原样 (ConfigMap 中的脚本可能本身就包含 ${...}), 并记录到 missing
+func substituteValues(v interface{}, values map[string]string, missing map[string]struct{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return valueRefPattern.ReplaceAllStringFunc(val, func(ref string) string {
+			name := ref[2 : len(ref)-1]
+			if value, ok := values[name]; ok {
+				return value
+			}
+			missing[name] = struct{}{}
+			return ref
+		})
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = substituteValues(item, values, missing)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = substituteValues(item, values, missing)
+		}
+	}
+	return v
+}
+
+// sortedKeys 返回集合中的键, 按字典序排序
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
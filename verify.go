@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+)
+
+// verifyPageSize --verify-counts 重新列出对象时每页的数量, 只计数不保留对象
+const verifyPageSize = 500
+
+// countMismatch 复查时发现的一个命名空间内某资源类型的数量不一致
+type countMismatch struct {
+	Kind      string
+	Namespace string
+	Listed    int      // 复查时集群中的对象数
+	Written   int      // 已写入清单的对象数
+	Skipped   int      // 按对象记入 skipped.yaml 的数量
+	Missing   []string // 索引中有记录, 但磁盘上不存在的清单文件
+}
+
+func (m countMismatch) String() string {
+	scope := m.Kind
+	if m.Namespace != "" {
+		scope = fmt.Sprintf("%s (命名空间 %s)", m.Kind, m.Namespace)
+	}
+	if len(m.Missing) > 0 {
+		return fmt.Sprintf("%s: %d 个清单文件不存在, 如 %s", scope, len(m.Missing), m.Missing[0])
+	}
+	return fmt.Sprintf("%s: 集群中 %d 个, 已写入 %d 个, 跳过 %d 个", scope, m.Listed, m.Written, m.Skipped)
+}
+
+// verifyBackupCounts 备份写入完成后, 按命名空间与资源类型分页重新列出对象, 将数量与已写入及按对象跳过的数量之和比较,
+// 并检查索引中的清单文件是否真实存在; 整个资源类型被跳过 (如无权限) 的组合不做比较
+// clusterScoped 为 true 时同时复查该分区中的集群级资源
+func verifyBackupCounts(client dynamic.Interface, p *backupPartition, resourceTypes []string, clusterScoped bool) []countMismatch {
+	type group struct{ namespace, kind string }
+	written := make(map[group]int)
+	missing := make(map[group][]string)
+	for _, e := range p.Index {
+		if e.Namespace != "" && strings.HasPrefix(e.Path, "_global/") {
+			continue // _global 下的参数对象 (如 ConfigMap) 不属于命名空间目录的计数
+		}
+		g := group{e.Namespace, e.Kind}
+		written[g]++
+		if _, err := os.Stat(filepath.Join(p.Root, filepath.FromSlash(e.Path))); err != nil {
+			missing[g] = append(missing[g], e.Path)
+		}
+	}
+	skipped := make(map[group]int)
+	typeSkipped := make(map[group]bool)
+	for _, s := range p.Skipped {
+		g := group{s.Namespace, s.Kind}
+		if s.Name == "" {
+			typeSkipped[g] = true
+		} else {
+			skipped[g]++
+		}
+	}
+
+	var mismatches []countMismatch
+	check := func(resInfo ResourceInfo, namespace string) {
+		g := group{namespace, resInfo.Kind}
+		if typeSkipped[g] {
+			return
+		}
+		listed, err := countObjects(client, resInfo, namespace)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "  警告: 复查 %s 失败: %v\n", resInfo.Kind, err)
+			return
+		}
+		if listed != written[g]+skipped[g] || len(missing[g]) > 0 {
+			mismatches = append(mismatches, countMismatch{
+				Kind: resInfo.Kind, Namespace: namespace,
+				Listed: listed, Written: written[g], Skipped: skipped[g], Missing: missing[g],
+			})
+		}
+	}
+	for _, resType := range resourceTypes {
+		resInfo, ok := resourceMap[resType]
+		if !ok {
+			continue
+		}
+		if !resInfo.Namespaced {
+			if clusterScoped {
+				check(resInfo, "")
+			}
+			continue
+		}
+		for _, ns := range p.Namespaces {
+			check(resInfo, ns)
+		}
+	}
+	sort.SliceStable(mismatches, func(i, j int) bool {
+		if mismatches[i].Namespace != mismatches[j].Namespace {
+			return mismatches[i].Namespace < mismatches[j].Namespace
+		}
+		return mismatches[i].Kind < mismatches[j].Kind
+	})
+	return mismatches
+}
+
+// countObjects 使用 limit/continue 分页列出对象并计数
+func countObjects(client dynamic.Interface, resInfo ResourceInfo, namespace string) (int, error) {
+	count := 0
+	opts := metav1.ListOptions{Limit: verifyPageSize}
+	for {
+		list, err := client.Resource(resInfo.GVR).Namespace(namespace).List(context.TODO(), opts)
+		if err != nil {
+			return count, err
+		}
+		count += len(list.Items)
+		if opts.Continue = list.GetContinue(); opts.Continue == "" {
+			return count, nil
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// unservedVersion 备份中目标集群未提供的 apiVersion/Kind
+type unservedVersion struct {
+	GVK       schema.GroupVersionKind
+	Count     int
+	Preferred string // 目标集群对该 Kind 提供的首选版本, 为空表示整个 Kind 都不存在
+}
+
+func (u unservedVersion) String() string {
+	desc := fmt.Sprintf("%s %s (%d 个对象)", u.GVK.GroupVersion().String(), u.GVK.Kind, u.Count)
+	if u.Preferred != "" {
+		return desc + fmt.Sprintf(": 目标集群只提供 %s", u.Preferred)
+	}
+	return desc + ": 目标集群不存在该类型"
+}
+
+// checkServedVersions 根据目标集群的发现信息找出备份中不被提供的 apiVersion/Kind
+// 备份中包含的 CRD 所定义的类型视为已提供, 它们会在恢复过程中先于自定义资源创建
+func checkServedVersions(mapper meta.RESTMapper, items []restoreItem) []unservedVersion {
+	fromCRDs := crdServedVersions(items)
+	byGVK := make(map[schema.GroupVersionKind]*unservedVersion)
+	for _, item := range items {
+		gvk := item.Obj.GroupVersionKind()
+		if fromCRDs[gvk] {
+			continue
+		}
+		if u, ok := byGVK[gvk]; ok {
+			if u != nil {
+				u.Count++
+			}
+			continue
+		}
+		if _, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+			byGVK[gvk] = nil
+			continue
+		}
+		u := &unservedVersion{GVK: gvk, Count: 1}
+		if mapping, err := mapper.RESTMapping(gvk.GroupKind()); err == nil {
+			u.Preferred = mapping.GroupVersionKind.GroupVersion().String()
+		}
+		byGVK[gvk] = u
+	}
+
+	var result []unservedVersion
+	for _, u := range byGVK {
+		if u != nil {
+			result = append(result, *u)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].GVK.String() < result[j].GVK.String() })
+	return result
+}
+
+// crdServedVersions 返回备份中 CRD 定义并提供的全部 GroupVersionKind
+func crdServedVersions(items []restoreItem) map[schema.GroupVersionKind]bool {
+	served := make(map[schema.GroupVersionKind]bool)
+	for _, item := range items {
+		if item.Obj.GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(item.Obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(item.Obj.Object, "spec", "names", "kind")
+		versions, _, _ := unstructured.NestedSlice(item.Obj.Object, "spec", "versions")
+		for _, v := range mapsOf(versions) {
+			name, _ := v["name"].(string)
+			if isServed, ok := v["served"].(bool); ok && !isServed {
+				continue
+			}
+			served[schema.GroupVersionKind{Group: group, Version: name, Kind: kind}] = true
+		}
+	}
+	return served
+}
+
+// kubernetesMinorSkew 返回目标集群相对备份来源集群的次版本差 (目标 - 来源), 无法解析时 ok 为 false
+func kubernetesMinorSkew(source, target string) (skew int, ok bool) {
+	sourceMinor, ok1 := kubernetesMinor(source)
+	targetMinor, ok2 := kubernetesMinor(target)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return targetMinor - sourceMinor, true
+}
+
+// kubernetesMinor 解析 v1.29.3 或 v1.29.3-eks-xxx 形式版本号中的次版本
+func kubernetesMinor(version string) (int, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, false
+	}
+	minor, err := strconv.Atoi(strings.TrimRightFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }))
+	if err != nil {
+		return 0, false
+	}
+	return minor, true
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCheckServedVersions(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "apps", Version: "v1"}, {Group: "policy", Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"}, meta.RESTScopeNamespace)
+
+	crd := fakeObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "widgets.example.com", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"group":    "example.com",
+			"names":    map[string]interface{}{"kind": "Widget"},
+			"versions": []interface{}{map[string]interface{}{"name": "v1", "served": true}},
+		},
+	})
+	items := []restoreItem{
+		{Obj: fakeObject("apps/v1", "Deployment", "web", "a", nil)},
+		{Obj: fakeObject("policy/v1beta1", "PodDisruptionBudget", "web", "a", nil)},
+		{Obj: fakeObject("policy/v1beta1", "PodDisruptionBudget", "web", "b", nil)},
+		{Obj: fakeObject("example.com/v1", "Widget", "web", "w", nil)},
+		{Obj: fakeObject("example.com/v2", "Gadget", "web", "g", nil)},
+	}
+	// CRD 本身的类型由 apiextensions 提供
+	mapper.Add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}, meta.RESTScopeRoot)
+	items = append(items, restoreItem{Obj: crd})
+
+	unserved := checkServedVersions(mapper, items)
+	if len(unserved) != 2 {
+		t.Fatalf("不被提供的类型 = %v, 期望 2 个", unserved)
+	}
+	if u := unserved[0]; u.GVK.Kind != "Gadget" || u.Preferred != "" {
+		t.Errorf("unserved[0] = %+v", u)
+	}
+	if u := unserved[1]; u.GVK.Kind != "PodDisruptionBudget" || u.Count != 2 || u.Preferred != "policy/v1" {
+		t.Errorf("unserved[1] = %+v", u)
+	}
+}
+
+func TestKubernetesMinorSkew(t *testing.T) {
+	cases := []struct {
+		source, target string
+		skew           int
+		ok             bool
+	}{
+		{"v1.29.3", "v1.27.1", -2, true},
+		{"v1.28.5-eks-5e0fdde", "v1.30.0", 2, true},
+		{"v1.30+", "v1.30.2", 0, true},
+		{"", "v1.30.0", 0, false},
+	}
+	for _, tc := range cases {
+		skew, ok := kubernetesMinorSkew(tc.source, tc.target)
+		if skew != tc.skew || ok != tc.ok {
+			t.Errorf("kubernetesMinorSkew(%s, %s) = %d, %v", tc.source, tc.target, skew, ok)
+		}
+	}
+}
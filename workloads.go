@@ -0,0 +1,45 @@
+package main
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// podSpecOf 返回工作负载中的 Pod 规格, 非工作负载返回 nil
+// 支持 Pod 本身, 带 spec.template 的控制器 (Deployment/StatefulSet/Job 等) 以及 CronJob
+func podSpecOf(obj map[string]interface{}) map[string]interface{} {
+	kind, _ := obj["kind"].(string)
+	var path []string
+	switch kind {
+	case "Pod":
+		path = []string{"spec"}
+	case "CronJob":
+		path = []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	default:
+		path = []string{"spec", "template", "spec"}
+	}
+	return nestedMapNoCopy(obj, path...)
+}
+
+// nestedMapNoCopy 返回嵌套的 map 引用而不做拷贝
+func nestedMapNoCopy(obj map[string]interface{}, path ...string) map[string]interface{} {
+	field, found, _ := unstructured.NestedFieldNoCopy(obj, path...)
+	if !found {
+		return nil
+	}
+	m, _ := field.(map[string]interface{})
+	return m
+}
+
+// containersOf 返回 Pod 规格中的全部容器 (含 init 与 ephemeral 容器)
+func containersOf(podSpec map[string]interface{}) []map[string]interface{} {
+	var containers []map[string]interface{}
+	for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		list, _ := podSpec[field].([]interface{})
+		for _, item := range list {
+			if c, ok := item.(map[string]interface{}); ok {
+				containers = append(containers, c)
+			}
+		}
+	}
+	return containers
+}